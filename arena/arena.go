@@ -0,0 +1,171 @@
+// Package arena provides a region allocator for request-scoped protobuf decoding.
+//
+// Types generated with protogen -arena get an UnmarshalProtobufArena method that
+// allocates nested messages, slices, strings and bytes from an Arena instead of
+// individually from the Go heap. Everything allocated from an Arena is released at
+// once by Reset, and the memory is reused by subsequent allocations:
+//
+//	var a arena.Arena
+//	for _, data := range requests {
+//	    var msg Message
+//	    if err := msg.UnmarshalProtobufArena(data, &a); err != nil {
+//	        return err
+//	    }
+//	    handle(&msg)
+//	    a.Reset() // msg and everything it references must not be used after this
+//	}
+//
+// The zero value is ready to use. An Arena must not be used concurrently.
+package arena
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// chunkSize is the minimum size in bytes of the memory blocks allocated by an Arena.
+const chunkSize = 8 << 10
+
+// Arena allocates values in large blocks that are released together by Reset.
+type Arena struct {
+	// bufs holds byte chunks for strings and bytes; bufs[bufIdx] is in use.
+	bufs   [][]byte
+	bufIdx int
+
+	// slabs holds a *slab[T] per allocated type T.
+	slabs map[reflect.Type]resetter
+}
+
+type resetter interface {
+	reset()
+}
+
+// Reset releases all memory allocated from a, making it available for reuse.
+//
+// Values previously allocated from a must not be used after Reset is called.
+func (a *Arena) Reset() {
+	for i := range a.bufs {
+		a.bufs[i] = a.bufs[i][:0]
+	}
+	a.bufIdx = 0
+	for _, s := range a.slabs {
+		s.reset()
+	}
+}
+
+// CloneString returns a copy of s allocated from a.
+func (a *Arena) CloneString(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	b := a.alloc(len(s))
+	copy(b, s)
+	return unsafe.String(&b[0], len(b))
+}
+
+// CloneBytes returns a copy of b allocated from a.
+//
+// A nil slice is returned for empty b.
+func (a *Arena) CloneBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	dst := a.alloc(len(b))
+	copy(dst, b)
+	return dst
+}
+
+// alloc returns a byte slice with length and capacity n from the current chunk.
+func (a *Arena) alloc(n int) []byte {
+	for a.bufIdx < len(a.bufs) {
+		buf := a.bufs[a.bufIdx]
+		if cap(buf)-len(buf) >= n {
+			start := len(buf)
+			buf = buf[:start+n]
+			a.bufs[a.bufIdx] = buf
+			return buf[start : start+n : start+n]
+		}
+		a.bufIdx++
+	}
+	buf := make([]byte, n, max(n, chunkSize))
+	a.bufs = append(a.bufs, buf)
+	a.bufIdx = len(a.bufs) - 1
+	return buf[:n:n]
+}
+
+// New returns a pointer to a new zero value of type T allocated from a.
+func New[T any](a *Arena) *T {
+	return &getSlab[T](a).alloc(1)[0]
+}
+
+// MakeSlice returns a zeroed slice of T with the given length and capacity allocated from a.
+func MakeSlice[T any](a *Arena, length, capacity int) []T {
+	if capacity < length {
+		panic("arena: MakeSlice: cap out of range")
+	}
+	if capacity == 0 {
+		return nil
+	}
+	return getSlab[T](a).alloc(capacity)[:length]
+}
+
+// Append appends vs to s like the built-in append, growing s from a when its capacity is exceeded.
+func Append[T any](a *Arena, s []T, vs ...T) []T {
+	n := len(s) + len(vs)
+	if n <= cap(s) {
+		return append(s, vs...)
+	}
+	ns := MakeSlice[T](a, len(s), max(2*cap(s), n, 4))
+	copy(ns, s)
+	return append(ns, vs...)
+}
+
+func getSlab[T any](a *Arena) *slab[T] {
+	t := reflect.TypeFor[T]()
+	if s, ok := a.slabs[t]; ok {
+		return s.(*slab[T])
+	}
+	if a.slabs == nil {
+		a.slabs = make(map[reflect.Type]resetter)
+	}
+	var zero T
+	s := &slab[T]{
+		chunkLen: max(1, chunkSize/max(1, int(unsafe.Sizeof(zero)))),
+	}
+	a.slabs[t] = s
+	return s
+}
+
+// slab allocates values of type T from typed chunks, so the garbage collector
+// keeps tracing pointers stored in allocated values.
+type slab[T any] struct {
+	chunks   [][]T
+	idx      int // index of the chunk in use
+	chunkLen int // minimum number of elements in a chunk
+}
+
+func (s *slab[T]) reset() {
+	for i := range s.chunks {
+		clear(s.chunks[i])
+		s.chunks[i] = s.chunks[i][:0]
+	}
+	s.idx = 0
+}
+
+// alloc returns a zeroed slice with length and capacity n.
+func (s *slab[T]) alloc(n int) []T {
+	for s.idx < len(s.chunks) {
+		chunk := s.chunks[s.idx]
+		if cap(chunk)-len(chunk) >= n {
+			start := len(chunk)
+			chunk = chunk[:start+n]
+			s.chunks[s.idx] = chunk
+			return chunk[start : start+n : start+n]
+		}
+		s.idx++
+	}
+	chunk := make([]T, n, max(n, s.chunkLen))
+	s.chunks = append(s.chunks, chunk)
+	s.idx = len(s.chunks) - 1
+	return chunk[:n:n]
+}
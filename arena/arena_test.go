@@ -0,0 +1,107 @@
+package arena
+
+import (
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Name string
+	Next *item
+}
+
+func TestNew(t *testing.T) {
+	var a Arena
+	p := New[item](&a)
+	p.Name = "first"
+	q := New[item](&a)
+	if q.Name != "" || q.Next != nil {
+		t.Fatalf("expected zero value, got %+v", q)
+	}
+	q.Next = p
+	if p.Name != "first" {
+		t.Fatalf("allocation overwrote previous value: %+v", p)
+	}
+}
+
+func TestResetReusesMemory(t *testing.T) {
+	var a Arena
+	p := New[item](&a)
+	p.Name = "stale"
+	a.Reset()
+	q := New[item](&a)
+	if q != p {
+		t.Fatal("expected memory to be reused after Reset")
+	}
+	if q.Name != "" {
+		t.Fatalf("expected zeroed value after Reset, got %q", q.Name)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	var a Arena
+	var s []int64
+	for i := 0; i < 1000; i++ {
+		s = Append(&a, s, int64(i))
+	}
+	for i, v := range s {
+		if v != int64(i) {
+			t.Fatalf("s[%d] = %d, want %d", i, v, i)
+		}
+	}
+
+	// Appending within capacity must not allocate.
+	s = MakeSlice[int64](&a, 0, 4)
+	s = Append(&a, s, 1, 2, 3)
+	allocs := testing.AllocsPerRun(10, func() {
+		_ = Append(&a, s, 4)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestMakeSliceIsolation(t *testing.T) {
+	var a Arena
+	s1 := MakeSlice[int](&a, 2, 2)
+	s2 := MakeSlice[int](&a, 2, 2)
+	s1 = append(s1, 42) // must not overwrite s2
+	if s2[0] != 0 {
+		t.Fatalf("append to s1 overwrote s2: %v", s2)
+	}
+	_ = s1
+}
+
+func TestCloneString(t *testing.T) {
+	var a Arena
+	src := []byte("hello")
+	s := a.CloneString(string(src))
+	src[0] = 'j'
+	if s != "hello" {
+		t.Fatalf("got %q, want %q", s, "hello")
+	}
+
+	big := strings.Repeat("x", 3*chunkSize)
+	if got := a.CloneString(big); got != big {
+		t.Fatal("large string mismatch")
+	}
+	if a.CloneString("") != "" {
+		t.Fatal("expected empty string")
+	}
+	if s != "hello" {
+		t.Fatalf("previous string changed to %q", s)
+	}
+}
+
+func TestCloneBytes(t *testing.T) {
+	var a Arena
+	b1 := a.CloneBytes([]byte("abc"))
+	b2 := a.CloneBytes([]byte("def"))
+	b1 = append(b1, 'x') // must not overwrite b2
+	if string(b2) != "def" {
+		t.Fatalf("append to b1 overwrote b2: %q", b2)
+	}
+	if a.CloneBytes(nil) != nil {
+		t.Fatal("expected nil for empty input")
+	}
+}
@@ -0,0 +1,196 @@
+package easyprotogen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Package is the parsed form of the types passed to Generate, shared by all backends.
+type Package struct {
+	Name       string               // Go package name
+	Types      []string             // Names of the types to generate code for, in the requested order
+	TypeInfos  map[string]*TypeInfo // Parsed types by name
+	Options    Options
+	Services   []*ServiceInfo // Parsed service interfaces, in the requested order
+	SourceHash string         // Hash of the sources, recorded in the header of generated Go files
+
+	// AllTypes are the types generated by the invocation with Config.Split, which generates
+	// the code of Types, a single one, in a file of its own; nil otherwise.
+	AllTypes []string
+
+	// HeaderDecls are the declarations of the header of generated Go files, like the _mp pool,
+	// that other files generated by protogen in the package already declare. They are omitted
+	// from the header.
+	HeaderDecls map[string]bool
+}
+
+// Backend emits a file from the parsed types of a package.
+type Backend interface {
+	// Name returns the name selecting the backend in Config.Emit.
+	Name() string
+	// Path returns the path of the emitted file, given the path of the generated Go file.
+	Path(output string) string
+	// Generate returns the contents of the emitted file.
+	Generate(pkg *Package) ([]byte, error)
+}
+
+// DefaultBackend is the name of the backend generating easyproto marshaling code.
+const DefaultBackend = "easyproto"
+
+// builtinBackends are the backends selectable by name without Config.Backends.
+var builtinBackends = []Backend{easyprotoBackend{}, protoFileBackend{}, jsonSchemaBackend{}, openAPIBackend{}}
+
+// selectBackends returns the backends named by emit, looking them up in custom before the
+// built-in backends. An empty emit selects the default backend.
+func selectBackends(emit []string, custom []Backend) ([]Backend, error) {
+	if len(emit) == 0 {
+		emit = []string{DefaultBackend}
+	}
+	backends := make([]Backend, 0, len(emit))
+	for _, name := range emit {
+		b := findBackend(name, custom)
+		if b == nil {
+			b = findBackend(name, builtinBackends)
+		}
+		if b == nil {
+			return nil, fmt.Errorf("unknown backend %q", name)
+		}
+		backends = append(backends, b)
+	}
+	return backends, nil
+}
+
+// findBackend returns the backend with the given name, or nil.
+func findBackend(name string, backends []Backend) Backend {
+	for _, b := range backends {
+		if b.Name() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// easyprotoBackend generates the marshaling code.
+type easyprotoBackend struct{}
+
+func (easyprotoBackend) Name() string { return DefaultBackend }
+
+func (easyprotoBackend) Path(output string) string { return output }
+
+func (easyprotoBackend) Generate(pkg *Package) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := generateCode(&buf, pkg); err != nil {
+		return nil, fmt.Errorf("failed to generate code: %w", err)
+	}
+	code, err := formatCode(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return addSourceHash(addBuildConstraint(code, pkg.Options.BuildConstraint), pkg.SourceHash), nil
+}
+
+// protoFileBackend exports the types as a proto3 .proto file.
+type protoFileBackend struct{}
+
+func (protoFileBackend) Name() string { return "proto" }
+
+func (protoFileBackend) Path(output string) string {
+	return strings.TrimSuffix(output, ".go") + ".proto"
+}
+
+func (protoFileBackend) Generate(pkg *Package) ([]byte, error) {
+	tmpl, err := template.New("protofile").Funcs(template.FuncMap{
+		"snakeCase":        snakeCase,
+		"protoFieldType":   protoFieldType,
+		"protoTypeComment": protoTypeComment,
+		"usesWrappers": func() bool {
+			return anyField(pkg.Types, pkg.TypeInfos, func(f *FieldInfo) bool { return f.IsWrapper })
+		},
+	}).Parse(protoFileTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .proto template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, pkg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// protoFieldType returns the .proto type of a non-oneof field, without the repeated label.
+// Enums are exported as int32 and custom types as bytes, since their definitions are unknown;
+// both are wire-compatible with the generated code.
+func protoFieldType(f *FieldInfo) string {
+	switch {
+	case f.IsWrapper:
+		return wrapperTypes[f.ProtoType]
+	case f.IsMap:
+		value := f.MapValueProto
+		if f.MapValueCustom {
+			value = "bytes"
+		} else if f.MapValueIsMsg {
+			value = strings.TrimPrefix(f.MapValueType, "*")
+		}
+		return "map<" + f.MapKeyProto + ", " + value + ">"
+	case f.IsCustom:
+		return "bytes"
+	case f.IsMessage:
+		name := f.BaseType
+		if f.IsRepeated || f.IsPointer {
+			name = f.ElemType
+		}
+		return name
+	case f.IsEnum:
+		return "int32"
+	}
+	return schemaType(f)
+}
+
+// protoTypeComment returns a comment naming the Go type of fields exported by protoFieldType
+// as int32 or bytes, or "".
+func protoTypeComment(f *FieldInfo) string {
+	goType := f.BaseType
+	if f.IsRepeated || f.IsPointer {
+		goType = f.ElemType
+	}
+	switch {
+	case f.IsMap && f.MapValueCustom:
+		return "custom " + strings.TrimPrefix(f.MapValueType, "*")
+	case f.IsMap:
+		return ""
+	case f.IsEnum:
+		return "enum " + goType
+	case f.IsCustom:
+		return "custom " + goType
+	case isBigNumber(f) || (f.IsDecimal && f.ProtoType == "bytes"):
+		return goType
+	}
+	return ""
+}
+
+// snakeCase converts a Go identifier to a .proto field name, e.g. UserID to user_id.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Words start at an upper case letter following a lower case one, or at the last
+			// upper case letter of an acronym followed by a lower case one, except for plural
+			// acronyms like IDs.
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1]) && !isPluralSuffix(runes[i+1:])) {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// isPluralSuffix reports whether runes start with an s ending a word.
+func isPluralSuffix(runes []rune) bool {
+	return runes[0] == 's' && (len(runes) == 1 || unicode.IsUpper(runes[1]))
+}
@@ -1,11 +1,15 @@
 // Code generated by protogen. DO NOT EDIT.
+// Source hash: 95257551a2979cbd13776fc570a69c4d6920724e2b47803aeb66988d3c0ac3d7
 
 package bench
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/VictoriaMetrics/easyproto"
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+	"github.com/aryehlev/easyproto-gen/groups"
 )
 
 var _mp easyproto.MarshalerPool
@@ -22,79 +26,125 @@ type ProtobufUnmarshaler interface {
 	UnmarshalProtobuf(src []byte) error
 }
 
+// cloneProtobuf copies src to dst by marshaling and unmarshaling it.
+// It is used for custom types, which have no CloneProtobufInto method.
+func cloneProtobuf(dst ProtobufUnmarshaler, src ProtobufMarshaler) {
+	m := _mp.Get()
+	src.MarshalProtobufTo(m.MessageMarshaler())
+	data := m.Marshal(nil)
+	_mp.Put(m)
+	if err := dst.UnmarshalProtobuf(data); err != nil {
+		panic(fmt.Errorf("BUG: cannot unmarshal %T marshaled by %T: %w", dst, src, err))
+	}
+}
+
 // MarshalProtobuf marshals Message into protobuf message, appends this message to dst and returns the result.
 func (x *Message) MarshalProtobuf(dst []byte) []byte {
 	m := _mp.Get()
+	dst = x.MarshalProtobufWith(m, dst)
+	_mp.Put(m)
+	return dst
+}
+
+// MarshalProtobufWith marshals Message like MarshalProtobuf, using m instead of a Marshaler
+// of the package pool, so that callers marshaling many messages can keep a Marshaler per goroutine.
+// m is reset first, so it needs no Reset between calls; it must not be used concurrently.
+func (x *Message) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {
+	m.Reset()
 	x.MarshalProtobufTo(m.MessageMarshaler())
 	dst = m.Marshal(dst)
-	_mp.Put(m)
 	return dst
 }
 
 // MarshalProtobufTo marshals Message fields to the given MessageMarshaler.
 // Implements ProtobufMarshaler interface.
 func (x *Message) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
-	mm.AppendInt64(1, x.ID)
-	mm.AppendString(2, x.Text)
+	if x.ID != 0 {
+		mm.AppendInt64(1, x.ID)
+	}
+	if len(x.Text) > 0 {
+		mm.AppendString(2, x.Text)
+	}
 	if x.Sender != nil {
 		x.Sender.MarshalProtobufTo(mm.AppendMessage(3))
 	}
-	mm.AppendInt64(4, x.Timestamp)
+	if x.Timestamp != 0 {
+		mm.AppendInt64(4, x.Timestamp)
+	}
 	for _, v := range x.Tags {
 		mm.AppendString(5, v)
 	}
 }
 
 // UnmarshalProtobuf unmarshals Message from protobuf message at src.
-func (x *Message) UnmarshalProtobuf(src []byte) (err error) {
+//
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Message) UnmarshalProtobuf(src []byte) error {
 	// Set default values
 	x.ID = *new(int64)
 	x.Text = *new(string)
 	x.Sender = nil
 	x.Timestamp = *new(int64)
 	x.Tags = x.Tags[:0]
+	return x.MergeFromProtobuf(src)
+}
 
+// MergeFromProtobuf merges protobuf message at src into Message.
+//
+// Scalar fields present in src overwrite the existing values, repeated and map fields
+// are appended to and nested messages are merged recursively.
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Message) MergeFromProtobuf(src []byte) (err error) {
 	// Parse message
+	n := len(src)
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
-		src, err = fc.NextField(src)
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
 		if err != nil {
-			return fmt.Errorf("cannot read next field in Message: %w", err)
+			// Skip the groups of proto2 messages, which easyproto can't read, like other
+			// unknown fields.
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return easyprotoerr.Field("Message", "", offset, easyprotoerr.NextField(src, err))
 		}
+		src = tail
 		switch fc.FieldNum {
 		case 1:
 			v, ok := fc.Int64()
 			if !ok {
-				return fmt.Errorf("cannot read Message.ID")
+				return easyprotoerr.Field("Message", "ID", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.ID = v
 		case 2:
 			v, ok := fc.String()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Text")
+				return easyprotoerr.Field("Message", "Text", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Text = v
 		case 3:
 			data, ok := fc.MessageData()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Sender data")
+				return easyprotoerr.Field("Message", "Sender", offset, fmt.Errorf("cannot read message: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			if x.Sender == nil {
 				x.Sender = &User{}
 			}
-			if err := x.Sender.UnmarshalProtobuf(data); err != nil {
-				return fmt.Errorf("cannot unmarshal Message.Sender: %w", err)
+			if err := x.Sender.MergeFromProtobuf(data); err != nil {
+				return easyprotoerr.Nested("Message", "Sender", n-len(src)-len(data), err)
 			}
 		case 4:
 			v, ok := fc.Int64()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Timestamp")
+				return easyprotoerr.Field("Message", "Timestamp", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Timestamp = v
 		case 5:
 			v, ok := fc.String()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Tags")
+				return easyprotoerr.Field("Message", "Tags", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Tags = append(x.Tags, v)
 		}
@@ -102,57 +152,827 @@ func (x *Message) UnmarshalProtobuf(src []byte) (err error) {
 	return nil
 }
 
+// CloneProtobuf returns a deep copy of Message, or nil if x is nil.
+func (x *Message) CloneProtobuf() *Message {
+	if x == nil {
+		return nil
+	}
+	c := &Message{}
+	x.CloneProtobufInto(c)
+	return c
+}
+
+// CloneProtobufInto deep copies Message into dst, overwriting all of its fields.
+//
+// Slices, maps and nested messages of protobuf fields are copied, so x and dst share no memory
+// through them. Fields without protobuf tags are copied shallowly.
+// Strings are copied as well, so dst doesn't alias the buffer x was unmarshaled from.
+func (x *Message) CloneProtobufInto(dst *Message) {
+	*dst = *x
+	dst.Text = strings.Clone(x.Text)
+	dst.Sender = x.Sender.CloneProtobuf()
+	if x.Tags != nil {
+		dst.Tags = make([]string, len(x.Tags))
+		for i, v := range x.Tags {
+			dst.Tags[i] = strings.Clone(v)
+		}
+	}
+}
+
 // MarshalProtobuf marshals User into protobuf message, appends this message to dst and returns the result.
 func (x *User) MarshalProtobuf(dst []byte) []byte {
 	m := _mp.Get()
+	dst = x.MarshalProtobufWith(m, dst)
+	_mp.Put(m)
+	return dst
+}
+
+// MarshalProtobufWith marshals User like MarshalProtobuf, using m instead of a Marshaler
+// of the package pool, so that callers marshaling many messages can keep a Marshaler per goroutine.
+// m is reset first, so it needs no Reset between calls; it must not be used concurrently.
+func (x *User) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {
+	m.Reset()
 	x.MarshalProtobufTo(m.MessageMarshaler())
 	dst = m.Marshal(dst)
-	_mp.Put(m)
 	return dst
 }
 
 // MarshalProtobufTo marshals User fields to the given MessageMarshaler.
 // Implements ProtobufMarshaler interface.
 func (x *User) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
-	mm.AppendInt64(1, x.ID)
-	mm.AppendString(2, x.Name)
-	mm.AppendString(3, x.Email)
+	if x.ID != 0 {
+		mm.AppendInt64(1, x.ID)
+	}
+	if len(x.Name) > 0 {
+		mm.AppendString(2, x.Name)
+	}
+	if len(x.Email) > 0 {
+		mm.AppendString(3, x.Email)
+	}
 }
 
 // UnmarshalProtobuf unmarshals User from protobuf message at src.
-func (x *User) UnmarshalProtobuf(src []byte) (err error) {
+//
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *User) UnmarshalProtobuf(src []byte) error {
 	// Set default values
 	x.ID = *new(int64)
 	x.Name = *new(string)
 	x.Email = *new(string)
+	return x.MergeFromProtobuf(src)
+}
 
+// MergeFromProtobuf merges protobuf message at src into User.
+//
+// Scalar fields present in src overwrite the existing values, repeated and map fields
+// are appended to and nested messages are merged recursively.
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *User) MergeFromProtobuf(src []byte) (err error) {
 	// Parse message
+	n := len(src)
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
-		src, err = fc.NextField(src)
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
 		if err != nil {
-			return fmt.Errorf("cannot read next field in User: %w", err)
+			// Skip the groups of proto2 messages, which easyproto can't read, like other
+			// unknown fields.
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return easyprotoerr.Field("User", "", offset, easyprotoerr.NextField(src, err))
 		}
+		src = tail
 		switch fc.FieldNum {
 		case 1:
 			v, ok := fc.Int64()
 			if !ok {
-				return fmt.Errorf("cannot read User.ID")
+				return easyprotoerr.Field("User", "ID", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.ID = v
 		case 2:
 			v, ok := fc.String()
 			if !ok {
-				return fmt.Errorf("cannot read User.Name")
+				return easyprotoerr.Field("User", "Name", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Name = v
 		case 3:
 			v, ok := fc.String()
 			if !ok {
-				return fmt.Errorf("cannot read User.Email")
+				return easyprotoerr.Field("User", "Email", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Email = v
 		}
 	}
 	return nil
 }
+
+// CloneProtobuf returns a deep copy of User, or nil if x is nil.
+func (x *User) CloneProtobuf() *User {
+	if x == nil {
+		return nil
+	}
+	c := &User{}
+	x.CloneProtobufInto(c)
+	return c
+}
+
+// CloneProtobufInto deep copies User into dst, overwriting all of its fields.
+//
+// Slices, maps and nested messages of protobuf fields are copied, so x and dst share no memory
+// through them. Fields without protobuf tags are copied shallowly.
+// Strings are copied as well, so dst doesn't alias the buffer x was unmarshaled from.
+func (x *User) CloneProtobufInto(dst *User) {
+	*dst = *x
+	dst.Name = strings.Clone(x.Name)
+	dst.Email = strings.Clone(x.Email)
+}
+
+// MarshalProtobuf marshals Wide into protobuf message, appends this message to dst and returns the result.
+func (x *Wide) MarshalProtobuf(dst []byte) []byte {
+	m := _mp.Get()
+	dst = x.MarshalProtobufWith(m, dst)
+	_mp.Put(m)
+	return dst
+}
+
+// MarshalProtobufWith marshals Wide like MarshalProtobuf, using m instead of a Marshaler
+// of the package pool, so that callers marshaling many messages can keep a Marshaler per goroutine.
+// m is reset first, so it needs no Reset between calls; it must not be used concurrently.
+func (x *Wide) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {
+	m.Reset()
+	x.MarshalProtobufTo(m.MessageMarshaler())
+	dst = m.Marshal(dst)
+	return dst
+}
+
+// MarshalProtobufTo marshals Wide fields to the given MessageMarshaler.
+// Implements ProtobufMarshaler interface.
+func (x *Wide) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
+	if len(x.F1) > 0 {
+		mm.AppendString(1, x.F1)
+	}
+	if x.F2 != 0 {
+		mm.AppendDouble(2, x.F2)
+	}
+	if x.F3 != 0 {
+		mm.AppendUint32(3, x.F3)
+	}
+	if x.F4 {
+		mm.AppendBool(4, x.F4)
+	}
+	if x.F5 != 0 {
+		mm.AppendInt32(5, x.F5)
+	}
+	if x.F6 != 0 {
+		mm.AppendInt64(6, x.F6)
+	}
+	if len(x.F7) > 0 {
+		mm.AppendString(7, x.F7)
+	}
+	if x.F8 != 0 {
+		mm.AppendDouble(8, x.F8)
+	}
+	if x.F9 != 0 {
+		mm.AppendUint32(9, x.F9)
+	}
+	if x.F10 {
+		mm.AppendBool(10, x.F10)
+	}
+	if x.F11 != 0 {
+		mm.AppendInt32(11, x.F11)
+	}
+	if x.F12 != 0 {
+		mm.AppendInt64(12, x.F12)
+	}
+	if len(x.F13) > 0 {
+		mm.AppendString(13, x.F13)
+	}
+	if x.F14 != 0 {
+		mm.AppendDouble(14, x.F14)
+	}
+	if x.F15 != 0 {
+		mm.AppendUint32(15, x.F15)
+	}
+	if x.F16 {
+		mm.AppendBool(16, x.F16)
+	}
+	if x.F17 != 0 {
+		mm.AppendInt32(17, x.F17)
+	}
+	if x.F18 != 0 {
+		mm.AppendInt64(18, x.F18)
+	}
+	if len(x.F19) > 0 {
+		mm.AppendString(19, x.F19)
+	}
+	if x.F20 != 0 {
+		mm.AppendDouble(20, x.F20)
+	}
+	if x.F21 != 0 {
+		mm.AppendUint32(21, x.F21)
+	}
+	if x.F22 {
+		mm.AppendBool(22, x.F22)
+	}
+	if x.F23 != 0 {
+		mm.AppendInt32(23, x.F23)
+	}
+	if x.F24 != 0 {
+		mm.AppendInt64(24, x.F24)
+	}
+	if len(x.F25) > 0 {
+		mm.AppendString(25, x.F25)
+	}
+	if x.F26 != 0 {
+		mm.AppendDouble(26, x.F26)
+	}
+	if x.F27 != 0 {
+		mm.AppendUint32(27, x.F27)
+	}
+	if x.F28 {
+		mm.AppendBool(28, x.F28)
+	}
+	if x.F29 != 0 {
+		mm.AppendInt32(29, x.F29)
+	}
+	if x.F30 != 0 {
+		mm.AppendInt64(30, x.F30)
+	}
+	if len(x.F31) > 0 {
+		mm.AppendString(31, x.F31)
+	}
+	if x.F32 != 0 {
+		mm.AppendDouble(32, x.F32)
+	}
+	if x.F33 != 0 {
+		mm.AppendUint32(33, x.F33)
+	}
+	if x.F34 {
+		mm.AppendBool(34, x.F34)
+	}
+	if x.F35 != 0 {
+		mm.AppendInt32(35, x.F35)
+	}
+	if x.F36 != 0 {
+		mm.AppendInt64(36, x.F36)
+	}
+	if len(x.F37) > 0 {
+		mm.AppendString(37, x.F37)
+	}
+	if x.F38 != 0 {
+		mm.AppendDouble(38, x.F38)
+	}
+	if x.F39 != 0 {
+		mm.AppendUint32(39, x.F39)
+	}
+	if x.F40 {
+		mm.AppendBool(40, x.F40)
+	}
+	if x.F41 != 0 {
+		mm.AppendInt32(41, x.F41)
+	}
+	if x.F42 != 0 {
+		mm.AppendInt64(42, x.F42)
+	}
+	if len(x.F43) > 0 {
+		mm.AppendString(43, x.F43)
+	}
+	if x.F44 != 0 {
+		mm.AppendDouble(44, x.F44)
+	}
+	if x.F45 != 0 {
+		mm.AppendUint32(45, x.F45)
+	}
+	if x.F46 {
+		mm.AppendBool(46, x.F46)
+	}
+	if x.F47 != 0 {
+		mm.AppendInt32(47, x.F47)
+	}
+	if x.F48 != 0 {
+		mm.AppendInt64(48, x.F48)
+	}
+	if len(x.F49) > 0 {
+		mm.AppendString(49, x.F49)
+	}
+	if x.F50 != 0 {
+		mm.AppendDouble(50, x.F50)
+	}
+	if x.F51 != 0 {
+		mm.AppendUint32(51, x.F51)
+	}
+	if x.F52 {
+		mm.AppendBool(52, x.F52)
+	}
+	if x.F53 != 0 {
+		mm.AppendInt32(53, x.F53)
+	}
+	if x.F54 != 0 {
+		mm.AppendInt64(54, x.F54)
+	}
+	if len(x.F55) > 0 {
+		mm.AppendString(55, x.F55)
+	}
+	if x.F56 != 0 {
+		mm.AppendDouble(56, x.F56)
+	}
+	if x.F57 != 0 {
+		mm.AppendUint32(57, x.F57)
+	}
+	if x.F58 {
+		mm.AppendBool(58, x.F58)
+	}
+	if x.F59 != 0 {
+		mm.AppendInt32(59, x.F59)
+	}
+	if x.F60 != 0 {
+		mm.AppendInt64(60, x.F60)
+	}
+}
+
+// UnmarshalProtobuf unmarshals Wide from protobuf message at src.
+//
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Wide) UnmarshalProtobuf(src []byte) error {
+	// Set default values
+	x.F1 = *new(string)
+	x.F2 = *new(float64)
+	x.F3 = *new(uint32)
+	x.F4 = *new(bool)
+	x.F5 = *new(int32)
+	x.F6 = *new(int64)
+	x.F7 = *new(string)
+	x.F8 = *new(float64)
+	x.F9 = *new(uint32)
+	x.F10 = *new(bool)
+	x.F11 = *new(int32)
+	x.F12 = *new(int64)
+	x.F13 = *new(string)
+	x.F14 = *new(float64)
+	x.F15 = *new(uint32)
+	x.F16 = *new(bool)
+	x.F17 = *new(int32)
+	x.F18 = *new(int64)
+	x.F19 = *new(string)
+	x.F20 = *new(float64)
+	x.F21 = *new(uint32)
+	x.F22 = *new(bool)
+	x.F23 = *new(int32)
+	x.F24 = *new(int64)
+	x.F25 = *new(string)
+	x.F26 = *new(float64)
+	x.F27 = *new(uint32)
+	x.F28 = *new(bool)
+	x.F29 = *new(int32)
+	x.F30 = *new(int64)
+	x.F31 = *new(string)
+	x.F32 = *new(float64)
+	x.F33 = *new(uint32)
+	x.F34 = *new(bool)
+	x.F35 = *new(int32)
+	x.F36 = *new(int64)
+	x.F37 = *new(string)
+	x.F38 = *new(float64)
+	x.F39 = *new(uint32)
+	x.F40 = *new(bool)
+	x.F41 = *new(int32)
+	x.F42 = *new(int64)
+	x.F43 = *new(string)
+	x.F44 = *new(float64)
+	x.F45 = *new(uint32)
+	x.F46 = *new(bool)
+	x.F47 = *new(int32)
+	x.F48 = *new(int64)
+	x.F49 = *new(string)
+	x.F50 = *new(float64)
+	x.F51 = *new(uint32)
+	x.F52 = *new(bool)
+	x.F53 = *new(int32)
+	x.F54 = *new(int64)
+	x.F55 = *new(string)
+	x.F56 = *new(float64)
+	x.F57 = *new(uint32)
+	x.F58 = *new(bool)
+	x.F59 = *new(int32)
+	x.F60 = *new(int64)
+	return x.MergeFromProtobuf(src)
+}
+
+// MergeFromProtobuf merges protobuf message at src into Wide.
+//
+// Scalar fields present in src overwrite the existing values, repeated and map fields
+// are appended to and nested messages are merged recursively.
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Wide) MergeFromProtobuf(src []byte) (err error) {
+	// Parse message
+	n := len(src)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
+		if err != nil {
+			// Skip the groups of proto2 messages, which easyproto can't read, like other
+			// unknown fields.
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return easyprotoerr.Field("Wide", "", offset, easyprotoerr.NextField(src, err))
+		}
+		src = tail
+		switch fc.FieldNum {
+		case 1:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F1", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F1 = v
+		case 2:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F2", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F2 = v
+		case 3:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F3", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F3 = v
+		case 4:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F4", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F4 = v
+		case 5:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F5", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F5 = v
+		case 6:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F6", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F6 = v
+		case 7:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F7", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F7 = v
+		case 8:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F8", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F8 = v
+		case 9:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F9", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F9 = v
+		case 10:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F10", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F10 = v
+		case 11:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F11", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F11 = v
+		case 12:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F12", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F12 = v
+		case 13:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F13", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F13 = v
+		case 14:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F14", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F14 = v
+		case 15:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F15", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F15 = v
+		case 16:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F16", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F16 = v
+		case 17:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F17", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F17 = v
+		case 18:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F18", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F18 = v
+		case 19:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F19", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F19 = v
+		case 20:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F20", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F20 = v
+		case 21:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F21", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F21 = v
+		case 22:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F22", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F22 = v
+		case 23:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F23", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F23 = v
+		case 24:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F24", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F24 = v
+		case 25:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F25", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F25 = v
+		case 26:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F26", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F26 = v
+		case 27:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F27", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F27 = v
+		case 28:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F28", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F28 = v
+		case 29:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F29", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F29 = v
+		case 30:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F30", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F30 = v
+		case 31:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F31", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F31 = v
+		case 32:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F32", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F32 = v
+		case 33:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F33", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F33 = v
+		case 34:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F34", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F34 = v
+		case 35:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F35", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F35 = v
+		case 36:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F36", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F36 = v
+		case 37:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F37", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F37 = v
+		case 38:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F38", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F38 = v
+		case 39:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F39", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F39 = v
+		case 40:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F40", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F40 = v
+		case 41:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F41", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F41 = v
+		case 42:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F42", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F42 = v
+		case 43:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F43", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F43 = v
+		case 44:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F44", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F44 = v
+		case 45:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F45", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F45 = v
+		case 46:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F46", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F46 = v
+		case 47:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F47", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F47 = v
+		case 48:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F48", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F48 = v
+		case 49:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F49", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F49 = v
+		case 50:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F50", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F50 = v
+		case 51:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F51", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F51 = v
+		case 52:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F52", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F52 = v
+		case 53:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F53", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F53 = v
+		case 54:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F54", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F54 = v
+		case 55:
+			v, ok := fc.String()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F55", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F55 = v
+		case 56:
+			v, ok := fc.Double()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F56", offset, fmt.Errorf("cannot read double: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F56 = v
+		case 57:
+			v, ok := fc.Uint32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F57", offset, fmt.Errorf("cannot read uint32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F57 = v
+		case 58:
+			v, ok := fc.Bool()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F58", offset, fmt.Errorf("cannot read bool: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F58 = v
+		case 59:
+			v, ok := fc.Int32()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F59", offset, fmt.Errorf("cannot read int32: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F59 = v
+		case 60:
+			v, ok := fc.Int64()
+			if !ok {
+				return easyprotoerr.Field("Wide", "F60", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			x.F60 = v
+		}
+	}
+	return nil
+}
+
+// CloneProtobuf returns a deep copy of Wide, or nil if x is nil.
+func (x *Wide) CloneProtobuf() *Wide {
+	if x == nil {
+		return nil
+	}
+	c := &Wide{}
+	x.CloneProtobufInto(c)
+	return c
+}
+
+// CloneProtobufInto deep copies Wide into dst, overwriting all of its fields.
+//
+// Slices, maps and nested messages of protobuf fields are copied, so x and dst share no memory
+// through them. Fields without protobuf tags are copied shallowly.
+// Strings are copied as well, so dst doesn't alias the buffer x was unmarshaled from.
+func (x *Wide) CloneProtobufInto(dst *Wide) {
+	*dst = *x
+	dst.F1 = strings.Clone(x.F1)
+	dst.F7 = strings.Clone(x.F7)
+	dst.F13 = strings.Clone(x.F13)
+	dst.F19 = strings.Clone(x.F19)
+	dst.F25 = strings.Clone(x.F25)
+	dst.F31 = strings.Clone(x.F31)
+	dst.F37 = strings.Clone(x.F37)
+	dst.F43 = strings.Clone(x.F43)
+	dst.F49 = strings.Clone(x.F49)
+	dst.F55 = strings.Clone(x.F55)
+}
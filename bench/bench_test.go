@@ -2,6 +2,7 @@ package bench
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 
 	"google.golang.org/protobuf/proto"
@@ -103,6 +104,34 @@ func BenchmarkUnmarshal_JSON(b *testing.B) {
 	}
 }
 
+// BenchmarkUnmarshal_Wide decodes a message with 60 dense field numbers, whose switch on the
+// field number the compiler lowers to a jump table.
+func BenchmarkUnmarshal_Wide(b *testing.B) {
+	var wide Wide
+	v := reflect.ValueOf(&wide).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			f.SetString("value")
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Float64:
+			f.SetFloat(float64(i) + 0.5)
+		case reflect.Int32, reflect.Int64:
+			f.SetInt(int64(i) << 10)
+		case reflect.Uint32:
+			f.SetUint(uint64(i) << 10)
+		}
+	}
+	data := wide.MarshalProtobuf(nil)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	var msg Wide
+	for i := 0; i < b.N; i++ {
+		_ = msg.UnmarshalProtobuf(data)
+	}
+}
+
 // =============================================================================
 // Size Comparison (run as test, not benchmark)
 // =============================================================================
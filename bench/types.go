@@ -1,6 +1,6 @@
 package bench
 
-//go:generate protogen -type=Message,User
+//go:generate protogen -type=Message,User,Wide -conformance=Message=ProtoMessage,User=ProtoUser
 
 // Message is the easyproto-gen version.
 type Message struct {
@@ -17,3 +17,68 @@ type User struct {
 	Name  string `protobuf:"2" json:"name"`
 	Email string `protobuf:"3" json:"email"`
 }
+
+// Wide has 60 fields numbered densely from 1, for measuring the dispatch on field numbers of
+// unmarshal methods on wide messages.
+type Wide struct {
+	F1  string  `protobuf:"1"`
+	F2  float64 `protobuf:"2"`
+	F3  uint32  `protobuf:"3"`
+	F4  bool    `protobuf:"4"`
+	F5  int32   `protobuf:"5"`
+	F6  int64   `protobuf:"6"`
+	F7  string  `protobuf:"7"`
+	F8  float64 `protobuf:"8"`
+	F9  uint32  `protobuf:"9"`
+	F10 bool    `protobuf:"10"`
+	F11 int32   `protobuf:"11"`
+	F12 int64   `protobuf:"12"`
+	F13 string  `protobuf:"13"`
+	F14 float64 `protobuf:"14"`
+	F15 uint32  `protobuf:"15"`
+	F16 bool    `protobuf:"16"`
+	F17 int32   `protobuf:"17"`
+	F18 int64   `protobuf:"18"`
+	F19 string  `protobuf:"19"`
+	F20 float64 `protobuf:"20"`
+	F21 uint32  `protobuf:"21"`
+	F22 bool    `protobuf:"22"`
+	F23 int32   `protobuf:"23"`
+	F24 int64   `protobuf:"24"`
+	F25 string  `protobuf:"25"`
+	F26 float64 `protobuf:"26"`
+	F27 uint32  `protobuf:"27"`
+	F28 bool    `protobuf:"28"`
+	F29 int32   `protobuf:"29"`
+	F30 int64   `protobuf:"30"`
+	F31 string  `protobuf:"31"`
+	F32 float64 `protobuf:"32"`
+	F33 uint32  `protobuf:"33"`
+	F34 bool    `protobuf:"34"`
+	F35 int32   `protobuf:"35"`
+	F36 int64   `protobuf:"36"`
+	F37 string  `protobuf:"37"`
+	F38 float64 `protobuf:"38"`
+	F39 uint32  `protobuf:"39"`
+	F40 bool    `protobuf:"40"`
+	F41 int32   `protobuf:"41"`
+	F42 int64   `protobuf:"42"`
+	F43 string  `protobuf:"43"`
+	F44 float64 `protobuf:"44"`
+	F45 uint32  `protobuf:"45"`
+	F46 bool    `protobuf:"46"`
+	F47 int32   `protobuf:"47"`
+	F48 int64   `protobuf:"48"`
+	F49 string  `protobuf:"49"`
+	F50 float64 `protobuf:"50"`
+	F51 uint32  `protobuf:"51"`
+	F52 bool    `protobuf:"52"`
+	F53 int32   `protobuf:"53"`
+	F54 int64   `protobuf:"54"`
+	F55 string  `protobuf:"55"`
+	F56 float64 `protobuf:"56"`
+	F57 uint32  `protobuf:"57"`
+	F58 bool    `protobuf:"58"`
+	F59 int32   `protobuf:"59"`
+	F60 int64   `protobuf:"60"`
+}
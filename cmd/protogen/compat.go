@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
+
+// runCompat implements the compat subcommand and returns the process exit code.
+func runCompat(args []string) int {
+	fs := flag.NewFlagSet("compat", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protogen compat old_dir|old_lock_file new_dir")
+		fmt.Fprintln(fs.Output(), "Reports wire-incompatible changes of protobuf-tagged types between two versions of a package.")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	issues, err := easyprotogen.Compat(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// maxDiffCells limits the size of the table used to diff the changed middle of two files.
+// Larger changes are shown as a removal of all old lines followed by the new lines.
+const maxDiffCells = 1 << 24
+
+// diffOp is a line of an edit script: kind is ' ' for an unchanged line, '-' for a removed line
+// and '+' for an added line.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the line differences between a and b in unified format,
+// or "" if they are equal.
+func unifiedDiff(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(strings.SplitAfter(a, "\n"), strings.SplitAfter(b, "\n"))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// A hunk extends until the changes are separated by more than twice the context.
+		start := max(0, i-diffContext)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = run
+		}
+
+		hunkOld, hunkNew := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", hunkOld, oldCount, hunkNew, newCount)
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		oldLine, newLine = hunkOld+oldCount, hunkNew+newCount
+		i = end
+	}
+	return sb.String()
+}
+
+// diffLines returns an edit script turning lines x into lines y.
+func diffLines(x, y []string) []diffOp {
+	// Drop the empty element after a trailing newline.
+	if len(x) > 0 && x[len(x)-1] == "" {
+		x = x[:len(x)-1]
+	}
+	if len(y) > 0 && y[len(y)-1] == "" {
+		y = y[:len(y)-1]
+	}
+
+	// Match the common prefix and suffix directly, leaving only the changed middle
+	// for the longest common subsequence table.
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+	mx, my := x[pre:len(x)-suf], y[pre:len(y)-suf]
+
+	ops := make([]diffOp, 0, len(x)+len(y)-pre-suf)
+	for _, line := range x[:pre] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	n, m := len(mx), len(my)
+	if n*m > maxDiffCells {
+		for _, line := range mx {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range my {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the length of the longest common subsequence of mx[i:] and my[j:].
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if mx[i] == my[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && mx[i] == my[j]:
+				ops = append(ops, diffOp{' ', mx[i]})
+				i++
+				j++
+			case j == m || (i < n && lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+				ops = append(ops, diffOp{'-', mx[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', my[j]})
+				j++
+			}
+		}
+	}
+	for _, line := range x[len(x)-suf:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
+
+// runInspect implements the inspect subcommand and returns the process exit code.
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	typeName := fs.String("type", "", "struct type of the payload")
+	dir := fs.String("dir", ".", "directory of the package declaring the type")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protogen inspect -type=Type [-dir=dir] payload_file|-")
+		fmt.Fprintln(fs.Output(), "Prints an annotated breakdown of a protobuf payload decoded as the type, reading stdin for -.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *typeName == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := readPayload(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, err := easyprotogen.Inspect(*dir, *typeName, data)
+	fmt.Print(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// readPayload returns the contents of the file at path, or of stdin if path is "-".
+func readPayload(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -4,6 +4,33 @@
 //
 //	//go:generate go run github.com/VictoriaMetrics/easyproto/cmd/protogen -type=Timeseries,Sample
 //
+// The compat subcommand reports wire-incompatible changes between two versions of a package:
+//
+//	protogen compat old_dir new_dir
+//
+// The tag subcommand adds protobuf tags with new field numbers to the untagged exported fields
+// of existing structs:
+//
+//	protogen tag -type=Order
+//
+// The renumber subcommand changes field numbers, refusing wire-incompatible changes unless forced:
+//
+//	protogen renumber -type=Order -compact -move=Status=2
+//
+// The inspect subcommand prints the fields of a protobuf payload decoded as a type, with their
+// offsets, wire types and values, in the text format of protoscope:
+//
+//	protogen inspect -type=Order payload.bin
+//
+// The size subcommand prints the encoded size of each field of a payload, largest first:
+//
+//	protogen size -type=Order payload.bin
+//
+// When invoked by go vet, protogen runs the protogenvet analyzer checking protobuf tags
+// and generated code that is out of date:
+//
+//	go vet -vettool=$(which protogen) ./...
+//
 // Struct tags format:
 //
 //	`protobuf:"fieldNum[,type][,options...]"`
@@ -19,6 +46,24 @@
 //   - repeated: field is a repeated (slice) field
 //   - optional: field is optional (pointer type, nil means unset)
 //   - enum: field is an enum type (uses int32 wire type)
+//   - extract: generate an Extract<Type><Field>(src []byte) function reading only this field
+//   - redact: omit the field from MarshalProtobufRedacted output
+//
+// Options may directly follow the field number when the type is inferred: `protobuf:"1,extract"`.
+//
+// Field numbers and names of deleted fields can be reserved with a directive in the doc
+// comment of the type; using them is an error:
+//
+//	//protogen:reserved 3,4,10-15,OldName
+//
+// With -scan, the types to generate are marked with directives instead of listed with -type,
+// and options of a type are set next to it:
+//
+//	//go:generate protogen -scan ./...
+//
+//	//protogen:generate
+//	//protogen:options value-receiver hot=Value
+//	type Sample struct { ... }
 //
 // When you need non-default wire types, specify explicitly:
 //   - sint32, sint64: for signed integers with many negative values
@@ -62,141 +107,417 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/format"
-	"go/parser"
-	"go/token"
+	"go/build"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
 )
 
 var (
-	typeNames = flag.String("type", "", "comma-separated list of type names")
-	output    = flag.String("output", "", "output file name; default srcdir/<type>_proto.go")
+	typeNames = flag.String("type", "", "comma-separated list of type names or patterns like Event*, matching the struct types with protobuf tags")
+	scan      = flag.Bool("scan", false, "also generate the struct types with a //protogen:generate directive; a dir/... argument selects every package below dir with such types")
+	excludes  = flag.String("exclude-type", "", "comma-separated list of type names or patterns not to generate code for, even if -type matches them")
+	services  = flag.String("service", "", "comma-separated list of interface names to generate HTTP handlers and clients for")
+	output    = flag.String("output", "", "output file name, or - to print the generated code instead of writing it; default srcdir/<type>_proto.go")
+	tags      = flag.String("tags", "", "comma-separated build tags selecting the files of the package, like go build -tags")
+	buildTags = flag.String("buildtags", "", "build constraint expression of a //go:build line added to the generated files, e.g. linux || darwin")
+	tests     = flag.Bool("include-tests", false, "also parse the _test.go files of the package, for types only used by tests; the output is a _test.go file")
+	split     = flag.Bool("split", false, "write the code of every type to its own srcdir/<type>_proto.go file")
 	noHeader  = flag.Bool("noheader", false, "skip generating the _mp pool and interface definitions (use when adding to existing generated file)")
+	runtime   = flag.String("runtime", "", "import path of a package declaring the pool and interfaces shared by generated files, like github.com/aryehlev/easyproto-gen/protoruntime; replaces -noheader")
+
+	copyStrings   = flag.Bool("copy-strings", false, "copy decoded strings and bytes instead of aliasing the input buffer, so messages stay valid after it is reused")
+	arenaMode     = flag.Bool("arena", false, "generate UnmarshalProtobufArena methods allocating from github.com/aryehlev/easyproto-gen/arena")
+	mask          = flag.Bool("mask", false, "generate <Type>FieldMask types and MarshalProtobufMasked methods")
+	filter        = flag.Bool("filter", false, "generate Filter<Type>Protobuf functions removing fields from encoded messages")
+	deterministic = flag.Bool("deterministic", false, "generate MarshalProtobufDeterministic and HashProtobuf methods sorting map entries by key")
+	random        = flag.Bool("random", false, "generate FuzzFill methods setting all fields to random values")
+	quick         = flag.Bool("quick", false, "generate Generate methods implementing testing/quick.Generator; implies -random")
+	protocTypes   = flag.Bool("protoc-types", false, "the types are protoc-gen-go structs; generate easyproto methods for them, reading their protobuf tags in the protoc-gen-go format")
+	protoAdapter  = flag.Bool("proto-adapter", false, "embed a descriptor of the types and generate ProtoAdapter methods returning proto.Message adapters from github.com/aryehlev/easyproto-gen/protoadapter")
+	descriptorSet = flag.Bool("descriptor-set", false, "embed a descriptor of the types, export it as <Type>FileDescriptorSet and register it with protoregistry.GlobalFiles for server reflection")
+	register      = flag.Bool("register", false, "register the types with github.com/aryehlev/easyproto-gen/easyprotoreg under <package>.<Type> in an init function")
+	int32Ints     = flag.Bool("int32", false, "encode int and uint fields without an explicit protobuf type as int32 and uint32, which 32-bit platforms decode without overflow")
+	sizeBreakdown = flag.Bool("size-breakdown", false, "generate SizeBreakdown methods returning the number of bytes of each field in the encoded message")
+	stringer      = flag.Bool("stringer", false, "generate String methods printing the set fields, with redacted fields masked")
+	limit         = flag.Bool("limit", false, "generate MarshalProtobufLimit methods failing for messages longer than a byte limit")
+	observe       = flag.Bool("observe", false, "report MarshalProtobuf and UnmarshalProtobuf calls to package protoobserve in builds with the protogen_observe tag")
+	pool          = flag.Bool("pool", false, "generate Acquire<Type> and Release<Type> functions pooling the types; []*Type fields decode their elements from the pool")
+	direct        = flag.Bool("direct", false, "generate SizeProtobuf and AppendProtobuf methods writing messages directly into the output with precomputed lengths, used by MarshalProtobuf")
+	tinygo        = flag.Bool("tinygo", false, "generate code without sync.Pool, unsafe and reflection, for TinyGo builds")
+	standalone    = flag.Bool("standalone", false, "declare a minimal protobuf codec in the generated file so that it imports only the standard library")
+	methodPrefix  = flag.String("method-prefix", "", "prefix of the generated method names, to avoid collisions with existing methods")
+	methodSuffix  = flag.String("method-suffix", "", "replacement of Protobuf in the generated method names; e.g. PB generates MarshalPB and UnmarshalPB")
+	valueRecv     = flag.String("value-receivers", "", "comma-separated types whose marshal methods have value receivers, for small types stored by value")
+	templates     = flag.String("template", "", "comma-separated text/template files executed after the built-in template, replacing its named templates and appending their output")
+
+	emit = flag.String("emit", easyprotogen.DefaultBackend, "comma-separated backends to run: easyproto (marshaling code), proto (a .proto file next to the output file), jsonschema (a JSON Schema of the proto3 JSON mapping next to it) and openapi (OpenAPI 3.1 component schemas and service operations next to it)")
+
+	hotFields = flag.String("hot", "", "file listing the fields unmarshal methods check first, as Type.Field lines, hottest first")
+	profile   = flag.String("profile", "", "CPU profile of a program built with the current output file; unmarshal methods check the fields with the most samples first")
+
+	genFuzz     = flag.Bool("gen-fuzz", false, "also generate <output>_fuzz_test.go with fuzz tests of the UnmarshalProtobuf methods")
+	conformance = flag.String("conformance", "", "comma-separated Type=ProtoType pairs; also generate <output>_conformance_test.go checking each type against its protoc-gen-go counterpart")
+	doc         = flag.String("doc", "", "also write a Markdown document of the fields of the types, with their numbers, wire types and comments, to this file, like schema.md")
+	graph       = flag.String("graph", "", "also write a diagram of the message types referencing each other: dot for <output>.dot in the Graphviz format, mermaid for <output>.mmd")
+
+	strictFields   = flag.Bool("strict-fields", false, "fail if an exported field of the types has no protobuf tag; exclude fields with protobuf:\"-\"")
+	force          = flag.Bool("force", false, "generate the methods even if the types already declare methods with the same names")
+	check          = flag.Bool("check", false, "do not write files; print a diff and exit with status 1 if the output file is not up to date")
+	warnDeprecated = flag.Bool("warn-deprecated", false, "warn about deprecated fields set in composite literals in the package's _test.go files")
+
+	lock          = flag.Bool("lock", false, "record the wire schema in protogen.lock in the package directory and fail on incompatible changes to it")
+	allowBreaking = flag.Bool("allow-breaking", false, "with -lock, accept incompatible changes and update the lock file")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compat" {
+		os.Exit(runCompat(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tag" {
+		os.Exit(runTag(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "renumber" {
+		os.Exit(runRenumber(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		os.Exit(runInspect(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "size" {
+		os.Exit(runSize(os.Args[2:]))
+	}
+	if isVetTool(os.Args[1:]) {
+		runVetTool()
+	}
+
 	flag.Parse()
 
-	if *typeNames == "" {
-		log.Fatal("-type flag is required")
+	if *typeNames == "" && !*scan {
+		log.Fatal("-type or -scan flag is required")
 	}
 
-	types := strings.Split(*typeNames, ",")
-	for i := range types {
-		types[i] = strings.TrimSpace(types[i])
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+		for i := range types {
+			types[i] = strings.TrimSpace(types[i])
+		}
+	}
+	var excludeTypes []string
+	if *excludes != "" {
+		excludeTypes = strings.Split(*excludes, ",")
+		for i := range excludeTypes {
+			excludeTypes[i] = strings.TrimSpace(excludeTypes[i])
+		}
+	}
+	var serviceNames []string
+	if *services != "" {
+		serviceNames = strings.Split(*services, ",")
+		for i := range serviceNames {
+			serviceNames[i] = strings.TrimSpace(serviceNames[i])
+		}
 	}
 
-	// Get the directory to parse
-	dir := "."
-	if len(flag.Args()) > 0 {
-		dir = flag.Args()[0]
+	// Get the directories to parse
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	if *scan {
+		var err error
+		if dirs, err = scanDirs(dirs, *tests); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	// Parse .go files in directory
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		log.Fatalf("failed to read directory %s: %v", dir, err)
+	var buildTagList []string
+	if *tags != "" {
+		buildTagList = strings.Split(*tags, ",")
+		for i := range buildTagList {
+			buildTagList[i] = strings.TrimSpace(buildTagList[i])
+		}
 	}
 
-	fset := token.NewFileSet()
-	var files []*ast.File
-	var pkgName string
+	var valueReceivers []string
+	if *valueRecv != "" {
+		valueReceivers = strings.Split(*valueRecv, ",")
+		for i := range valueReceivers {
+			valueReceivers[i] = strings.TrimSpace(valueReceivers[i])
+		}
+	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
-			continue
+	var extraTemplates []string
+	if *templates != "" {
+		for _, path := range strings.Split(*templates, ",") {
+			src, err := os.ReadFile(strings.TrimSpace(path))
+			if err != nil {
+				log.Fatalf("failed to read template: %v", err)
+			}
+			extraTemplates = append(extraTemplates, string(src))
 		}
-		filePath := filepath.Join(dir, entry.Name())
-		file, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
-		if err != nil {
-			log.Fatalf("failed to parse file %s: %v", filePath, err)
+	}
+
+	// With -output=-, the files are generated at their default paths and printed.
+	stdout := *output == "-"
+	if stdout {
+		if *check {
+			log.Fatal("-check can't be combined with -output=-")
 		}
-		if pkgName == "" {
-			pkgName = file.Name.Name
-		} else if file.Name.Name != pkgName {
-			continue // skip files from different packages
+		*output = ""
+	}
+	if len(dirs) > 1 && (*output != "" || *conformance != "" || *doc != "" || *hotFields != "" || *profile != "") {
+		log.Fatal("-output, -conformance, -doc, -hot and -profile can't be used with several directories")
+	}
+	typesByDir, err := namesByDir(dirs, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	excludesByDir, err := namesByDir(dirs, excludeTypes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	servicesByDir, err := namesByDir(dirs, serviceNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := easyprotogen.Config{
+		Output:       *output,
+		Scan:         *scan,
+		Split:        *split,
+		IncludeTests: *tests,
+		Tags:         buildTagList,
+		Options: easyprotogen.Options{
+			SkipHeader:      *noHeader,
+			Runtime:         *runtime,
+			BuildConstraint: *buildTags,
+			CopyStrings:     *copyStrings,
+			Arena:           *arenaMode,
+			Mask:            *mask,
+			Filter:          *filter,
+			Deterministic:   *deterministic,
+			Random:          *random || *quick,
+			Quick:           *quick,
+			ProtocTypes:     *protocTypes,
+			ProtoAdapter:    *protoAdapter,
+			DescriptorSet:   *descriptorSet,
+			Register:        *register,
+			Int32:           *int32Ints,
+			SizeBreakdown:   *sizeBreakdown,
+			Stringer:        *stringer,
+			Limit:           *limit,
+			Observe:         *observe,
+			Pool:            *pool,
+			Direct:          *direct,
+			TinyGo:          *tinygo,
+			Standalone:      *standalone,
+			MethodPrefix:    *methodPrefix,
+			MethodSuffix:    *methodSuffix,
+			ValueReceivers:  valueReceivers,
+			Templates:       extraTemplates,
+		},
+		HotFields:     *hotFields,
+		Profile:       *profile,
+		Emit:          strings.Split(*emit, ","),
+		Fuzz:          *genFuzz,
+		Conformance:   *conformance,
+		Doc:           *doc,
+		Graph:         *graph,
+		Lock:          *lock,
+		AllowBreaking: *allowBreaking,
+		StrictFields:  *strictFields,
+		Force:         *force,
+		// Skip parsing entirely if the output was generated from the same sources
+		SkipUnchanged: !*check && !*warnDeprecated,
+	}
+	if *warnDeprecated {
+		cfg.Warn = func(msg string) {
+			log.Printf("warning: %s", msg)
 		}
-		files = append(files, file)
+	}
+	// The directories are generated in one process, sharing the parsed templates.
+	var outputs []easyprotogen.File
+	for _, dir := range dirs {
+		cfg.Dir, cfg.Types, cfg.ExcludeTypes, cfg.Services = dir, typesByDir[dir], excludesByDir[dir], servicesByDir[dir]
+		if len(cfg.Types) == 0 && !*scan {
+			log.Fatalf("no types to generate in %s", dir)
+		}
+		files, err := easyprotogen.Generate(context.Background(), cfg)
+		if ce := (*easyprotogen.CompatError)(nil); errors.As(err, &ce) {
+			log.Fatalf("%v\nuse -allow-breaking to accept them", err)
+		}
+		if errors.Is(err, easyprotogen.ErrMethodExists) {
+			log.Fatalf("%v\nuse -method-prefix or -method-suffix to rename the generated methods, or -force to generate them anyway", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputs = append(outputs, files...)
 	}
 
-	if len(files) == 0 {
-		log.Fatal("no Go files found")
+	if stdout {
+		if err := printOutputs(os.Stdout, outputs); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	// Find the requested types
-	typeInfos := make(map[string]*TypeInfo)
-	for _, file := range files {
-		for _, decl := range file.Decls {
-			genDecl, ok := decl.(*ast.GenDecl)
-			if !ok || genDecl.Tok != token.TYPE {
-				continue
+	if *check {
+		stale := false
+		for _, out := range outputs {
+			diff, err := checkOutput(out.Path, out.Content)
+			if err != nil {
+				log.Fatal(err)
 			}
-			for _, spec := range genDecl.Specs {
-				typeSpec, ok := spec.(*ast.TypeSpec)
-				if !ok {
-					continue
-				}
-				for _, typeName := range types {
-					if typeSpec.Name.Name == typeName {
-						structType, ok := typeSpec.Type.(*ast.StructType)
-						if !ok {
-							log.Fatalf("type %s is not a struct", typeName)
-						}
-						info, err := parseStruct(typeName, structType)
-						if err != nil {
-							log.Fatalf("failed to parse struct %s: %v", typeName, err)
-						}
-						typeInfos[typeName] = info
-					}
-				}
+			if diff != "" {
+				fmt.Print(diff)
+				log.Printf("%s is out of date; run go generate", out.Path)
+				stale = true
 			}
 		}
+		if stale {
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Check all types were found
-	for _, typeName := range types {
-		if _, ok := typeInfos[typeName]; !ok {
-			log.Fatalf("type %s not found", typeName)
+	for _, out := range outputs {
+		written, err := writeFileIfChanged(out.Path, out.Content)
+		if err != nil {
+			log.Fatalf("failed to write output file: %v", err)
+		}
+		if written {
+			fmt.Printf("Generated %s\n", out.Path)
+		} else {
+			fmt.Printf("%s is up to date\n", out.Path)
 		}
 	}
+}
 
-	// Generate code
-	var buf bytes.Buffer
-	if err := generateCode(&buf, pkgName, types, typeInfos, *noHeader); err != nil {
-		log.Fatalf("failed to generate code: %v", err)
+// namesByDir assigns the type or service names to the directories. With several directories,
+// every name is qualified with the name of its package, like pkg.Type.
+func namesByDir(dirs, names []string) (map[string][]string, error) {
+	byDir := make(map[string][]string)
+	if len(dirs) == 1 || len(names) == 0 {
+		byDir[dirs[0]] = names
+		return byDir, nil
 	}
+	pkgDirs := make(map[string]string)
+	for _, dir := range dirs {
+		pkg, err := build.ImportDir(dir, 0)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := pkgDirs[pkg.Name]; ok {
+			return nil, fmt.Errorf("directories %s and %s both have package %s", other, dir, pkg.Name)
+		}
+		pkgDirs[pkg.Name] = dir
+	}
+	for _, name := range names {
+		pkgName, typeName, ok := strings.Cut(name, ".")
+		if !ok {
+			return nil, fmt.Errorf("%s must be qualified with its package name, like pkg.%s, with several directories", name, name)
+		}
+		dir, ok := pkgDirs[pkgName]
+		if !ok {
+			return nil, fmt.Errorf("no directory has the package of %s", name)
+		}
+		byDir[dir] = append(byDir[dir], typeName)
+	}
+	return byDir, nil
+}
 
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		tmpFile, tmpErr := os.CreateTemp("", "protogen_debug_*.go")
-		if tmpErr == nil {
-			tmpFile.Write(buf.Bytes())
-			tmpFile.Close()
-			log.Fatalf("failed to format generated code (debug output: %s): %v", tmpFile.Name(), err)
+// scanDirs replaces the dir/... arguments of -scan with the directories below dir, including dir,
+// whose Go files have a //protogen:generate directive, in _test.go files only if tests is set.
+// Like the go command, it skips testdata and vendor directories and those whose names start
+// with . or _.
+func scanDirs(args []string, tests bool) ([]string, error) {
+	var dirs []string
+	for _, arg := range args {
+		root, ok := strings.CutSuffix(arg, "/...")
+		if !ok {
+			dirs = append(dirs, arg)
+			continue
+		}
+		n := len(dirs)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".go") || (strings.HasSuffix(path, "_test.go") && !tests) || slices.Contains(dirs[n:], filepath.Dir(path)) {
+				return nil
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, line := range bytes.Split(src, []byte("\n")) {
+				if string(bytes.TrimSpace(line)) == "//protogen:generate" {
+					dirs = append(dirs, filepath.Dir(path))
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(dirs) == n {
+			return nil, fmt.Errorf("no packages with //protogen:generate directives in %s", arg)
 		}
-		log.Fatalf("failed to format generated code: %v", err)
 	}
+	return dirs, nil
+}
 
-	// Determine output file
-	outputFile := *output
-	if outputFile == "" {
-		if len(types) == 1 {
-			outputFile = filepath.Join(dir, strings.ToLower(types[0])+"_proto.go")
-		} else {
-			outputFile = filepath.Join(dir, pkgName+"_proto.go")
+// printOutputs writes the contents of the generated files to w. If there are several, each
+// is preceded by a comment line with its path.
+func printOutputs(w io.Writer, outputs []easyprotogen.File) error {
+	for _, out := range outputs {
+		if len(outputs) > 1 {
+			if _, err := fmt.Fprintf(w, "// %s\n", out.Path); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(out.Content); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if err := os.WriteFile(outputFile, formatted, 0644); err != nil {
-		log.Fatalf("failed to write output file: %v", err)
+// checkOutput returns the differences between the file at path and the generated code,
+// or "" if the file is up to date. A missing file differs from any code.
+func checkOutput(path string, generated []byte) (string, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
 	}
+	return unifiedDiff(path+" (on disk)", path+" (generated)", string(existing), string(generated)), nil
+}
 
-	fmt.Printf("Generated %s\n", outputFile)
+// writeFileIfChanged writes data to the file at path unless it already has the same
+// contents, so that its modification time only changes with its contents.
+// It reports whether the file was written.
+func writeFileIfChanged(path string, data []byte) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	return true, os.WriteFile(path, data, 0644)
 }
@@ -1,447 +1,204 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
-)
 
-// parseTestStruct parses a struct definition from source code and returns the TypeInfo
-func parseTestStruct(t *testing.T, typeName, source string) (*TypeInfo, error) {
-	t.Helper()
-	fset := token.NewFileSet()
-	src := "package test\n\n" + source
-	f, err := parser.ParseFile(fset, "test.go", src, 0)
-	if err != nil {
-		t.Fatalf("failed to parse source: %v", err)
-	}
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
 
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
-			continue
-		}
-		for _, spec := range genDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok || typeSpec.Name.Name != typeName {
-				continue
-			}
-			structType, ok := typeSpec.Type.(*ast.StructType)
-			if !ok {
-				t.Fatalf("type %s is not a struct", typeName)
-			}
-			return parseStruct(typeName, structType)
+func TestIsVetTool(t *testing.T) {
+	for _, args := range [][]string{{"-V=full"}, {"-flags"}, {"-json", "/tmp/vet.cfg"}} {
+		if !isVetTool(args) {
+			t.Errorf("isVetTool(%q) = false, want true", args)
 		}
 	}
-	t.Fatalf("type %s not found", typeName)
-	return nil, nil
-}
-
-func TestOneofTagParsing_ValidTag(t *testing.T) {
-	source := `
-type Message interface{ MessageType() string }
-type TextMessage struct{}
-type ImageMessage struct{}
-
-type Chat struct {
-	ID      int64   ` + "`protobuf:\"1\"`" + `
-	Content Message ` + "`protobuf:\"oneof,TextMessage:2,ImageMessage:3\"`" + `
-}
-`
-	info, err := parseTestStruct(t, "Chat", source)
-	if err != nil {
-		t.Fatalf("expected valid oneof tag, got error: %v", err)
+	if isVetTool([]string{"-type=Stale", "."}) {
+		t.Error("isVetTool returned true for generator flags")
 	}
+}
 
-	if len(info.Fields) != 2 {
-		t.Fatalf("expected 2 fields, got %d", len(info.Fields))
+func TestUnifiedDiff(t *testing.T) {
+	if d := unifiedDiff("a", "b", "x\ny\n", "x\ny\n"); d != "" {
+		t.Errorf("expected no diff for equal inputs, got %q", d)
 	}
 
-	// Find the Content field
-	var contentField *FieldInfo
-	for _, f := range info.Fields {
-		if f.Name == "Content" {
-			contentField = f
-			break
+	var old, cur strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&old, "line %d\n", i)
+		switch i {
+		case 2:
+			fmt.Fprintf(&cur, "line %d changed\n", i)
+		case 15:
+			// removed
+		default:
+			fmt.Fprintf(&cur, "line %d\n", i)
 		}
 	}
-
-	if contentField == nil {
-		t.Fatal("Content field not found")
-	}
-
-	if !contentField.IsOneof {
-		t.Error("expected IsOneof to be true")
-	}
-
-	if len(contentField.OneofVariants) != 2 {
-		t.Fatalf("expected 2 variants, got %d", len(contentField.OneofVariants))
-	}
-
-	if contentField.OneofVariants[0].TypeName != "TextMessage" || contentField.OneofVariants[0].FieldNum != 2 {
-		t.Errorf("variant 0 mismatch: got %+v", contentField.OneofVariants[0])
-	}
-	if contentField.OneofVariants[1].TypeName != "ImageMessage" || contentField.OneofVariants[1].FieldNum != 3 {
-		t.Errorf("variant 1 mismatch: got %+v", contentField.OneofVariants[1])
-	}
-}
-
-func TestOneofTagParsing_MissingVariants(t *testing.T) {
-	source := `
-type Message interface{}
-type Chat struct {
-	Content Message ` + "`protobuf:\"oneof\"`" + `
-}
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+ line 4
+ line 5
+@@ -12,7 +12,6 @@
+ line 12
+ line 13
+ line 14
+-line 15
+ line 16
+ line 17
+ line 18
 `
-	_, err := parseTestStruct(t, "Chat", source)
-	if err == nil {
-		t.Fatal("expected error for oneof with no variants")
-	}
-	if !strings.Contains(err.Error(), "at least one variant") {
-		t.Errorf("expected 'at least one variant' error, got: %v", err)
+	if got := unifiedDiff("old", "new", old.String(), cur.String()); got != want {
+		t.Errorf("got diff:\n%s\nwant:\n%s", got, want)
 	}
 }
 
-func TestOneofTagParsing_InvalidVariantFormat(t *testing.T) {
-	tests := []struct {
-		name    string
-		tag     string
-		wantErr string
-	}{
-		{
-			name:    "missing colon",
-			tag:     "`protobuf:\"oneof,TextMessage\"`",
-			wantErr: "expected Type:FieldNum format",
-		},
-		{
-			name:    "non-numeric field number",
-			tag:     "`protobuf:\"oneof,TextMessage:abc\"`",
-			wantErr: "invalid field number",
-		},
-		{
-			name:    "field number too low",
-			tag:     "`protobuf:\"oneof,TextMessage:0\"`",
-			wantErr: "must be 1-536870911",
-		},
-		{
-			name:    "field number too high",
-			tag:     "`protobuf:\"oneof,TextMessage:536870912\"`",
-			wantErr: "must be 1-536870911",
-		},
-		{
-			name:    "reserved field number",
-			tag:     "`protobuf:\"oneof,TextMessage:19000\"`",
-			wantErr: "reserved",
-		},
-		{
-			name:    "reserved field number high end",
-			tag:     "`protobuf:\"oneof,TextMessage:19999\"`",
-			wantErr: "reserved",
-		},
-	}
+func TestCheckOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user_proto.go")
+	code := []byte("package test\n")
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			source := `
-type Message interface{}
-type TextMessage struct{}
-type Chat struct {
-	Content Message ` + tc.tag + `
-}
-`
-			_, err := parseTestStruct(t, "Chat", source)
-			if err == nil {
-				t.Fatal("expected error")
-			}
-			if !strings.Contains(err.Error(), tc.wantErr) {
-				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
-			}
-		})
+	diff, err := checkOutput(path, code)
+	if err != nil {
+		t.Fatalf("checkOutput failed: %v", err)
+	}
+	if !strings.Contains(diff, "+package test") {
+		t.Errorf("expected a missing file to differ, got %q", diff)
 	}
-}
 
-func TestOneofTagParsing_DuplicateFieldNumbers(t *testing.T) {
-	source := `
-type Message interface{}
-type TextMessage struct{}
-type ImageMessage struct{}
-type Chat struct {
-	Content Message ` + "`protobuf:\"oneof,TextMessage:2,ImageMessage:2\"`" + `
-}
-`
-	_, err := parseTestStruct(t, "Chat", source)
-	if err == nil {
-		t.Fatal("expected error for duplicate field numbers in oneof")
+	if err := os.WriteFile(path, code, 0644); err != nil {
+		t.Fatal(err)
 	}
-	if !strings.Contains(err.Error(), "duplicate field number") {
-		t.Errorf("expected 'duplicate field number' error, got: %v", err)
+	diff, err = checkOutput(path, code)
+	if err != nil || diff != "" {
+		t.Errorf("expected no diff for an up-to-date file, got %q, %v", diff, err)
 	}
 }
 
-func TestOneofTagParsing_DuplicateWithRegularField(t *testing.T) {
-	source := `
-type Message interface{}
-type TextMessage struct{}
-type Chat struct {
-	ID      int64   ` + "`protobuf:\"2\"`" + `
-	Content Message ` + "`protobuf:\"oneof,TextMessage:2\"`" + `
-}
-`
-	_, err := parseTestStruct(t, "Chat", source)
-	if err == nil {
-		t.Fatal("expected error for field number collision between oneof variant and regular field")
-	}
-	if !strings.Contains(err.Error(), "duplicate field number") {
-		t.Errorf("expected 'duplicate field number' error, got: %v", err)
+func TestWriteFileIfChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user_proto.go")
+	for i, want := range []bool{true, false} {
+		written, err := writeFileIfChanged(path, []byte("package test\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != want {
+			t.Errorf("write %d: got written=%v, want %v", i, written, want)
+		}
 	}
 }
 
-func TestOneofTagParsing_MultipleVariants(t *testing.T) {
-	source := `
-type Message interface{}
-type TextMessage struct{}
-type ImageMessage struct{}
-type VideoMessage struct{}
-type AudioMessage struct{}
-type Chat struct {
-	Content Message ` + "`protobuf:\"oneof,TextMessage:1,ImageMessage:2,VideoMessage:3,AudioMessage:4\"`" + `
-}
-`
-	info, err := parseTestStruct(t, "Chat", source)
-	if err != nil {
-		t.Fatalf("expected valid oneof tag with 4 variants, got error: %v", err)
+func TestPrintOutputs(t *testing.T) {
+	code := easyprotogen.File{Path: "user_proto.go", Content: []byte("package test\n")}
+	var buf bytes.Buffer
+	if err := printOutputs(&buf, []easyprotogen.File{code}); err != nil {
+		t.Fatal(err)
 	}
-
-	if len(info.Fields) != 1 {
-		t.Fatalf("expected 1 field, got %d", len(info.Fields))
+	if got := buf.String(); got != "package test\n" {
+		t.Errorf("got %q for a single file", got)
 	}
 
-	contentField := info.Fields[0]
-	if len(contentField.OneofVariants) != 4 {
-		t.Fatalf("expected 4 variants, got %d", len(contentField.OneofVariants))
+	buf.Reset()
+	proto := easyprotogen.File{Path: "user_proto.proto", Content: []byte("syntax = \"proto3\";\n")}
+	if err := printOutputs(&buf, []easyprotogen.File{code, proto}); err != nil {
+		t.Fatal(err)
 	}
-
-	expected := []struct {
-		name     string
-		fieldNum int
-	}{
-		{"TextMessage", 1},
-		{"ImageMessage", 2},
-		{"VideoMessage", 3},
-		{"AudioMessage", 4},
+	if want := "// user_proto.go\npackage test\n// user_proto.proto\nsyntax = \"proto3\";\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
 	}
+}
 
-	for i, exp := range expected {
-		if contentField.OneofVariants[i].TypeName != exp.name {
-			t.Errorf("variant %d name: got %q, want %q", i, contentField.OneofVariants[i].TypeName, exp.name)
+func TestNamesByDir(t *testing.T) {
+	root := t.TempDir()
+	var dirs []string
+	for _, pkg := range []string{"a", "b"} {
+		dir := filepath.Join(root, pkg)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
 		}
-		if contentField.OneofVariants[i].FieldNum != exp.fieldNum {
-			t.Errorf("variant %d field num: got %d, want %d", i, contentField.OneofVariants[i].FieldNum, exp.fieldNum)
+		if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte("package "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
 		}
+		dirs = append(dirs, dir)
 	}
-}
 
-func TestOneofTagParsing_ValidFieldNumberEdgeCases(t *testing.T) {
-	tests := []struct {
-		name     string
-		fieldNum string
-		want     int
-	}{
-		{"minimum field number", "1", 1},
-		{"maximum field number", "536870911", 536870911},
-		{"just below reserved", "18999", 18999},
-		{"just above reserved", "20000", 20000},
+	byDir, err := namesByDir(dirs, []string{"a.Foo", "b.Bar", "a.Baz"})
+	if err != nil {
+		t.Fatalf("namesByDir failed: %v", err)
 	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			source := `
-type Message interface{}
-type TextMessage struct{}
-type Chat struct {
-	Content Message ` + "`protobuf:\"oneof,TextMessage:" + tc.fieldNum + "\"`" + `
-}
-`
-			info, err := parseTestStruct(t, "Chat", source)
-			if err != nil {
-				t.Fatalf("expected valid tag, got error: %v", err)
-			}
-
-			if len(info.Fields[0].OneofVariants) != 1 {
-				t.Fatal("expected 1 variant")
-			}
-
-			if info.Fields[0].OneofVariants[0].FieldNum != tc.want {
-				t.Errorf("field num: got %d, want %d", info.Fields[0].OneofVariants[0].FieldNum, tc.want)
-			}
-		})
+	if got := fmt.Sprint(byDir[dirs[0]], byDir[dirs[1]]); got != "[Foo Baz] [Bar]" {
+		t.Errorf("got %s", got)
 	}
-}
-
-func TestInterfaceRejection_AnyKeyword(t *testing.T) {
-	// Test that the 'any' keyword (alias for interface{}) is rejected
-	source := `
-type Chat struct {
-	Content any ` + "`protobuf:\"1\"`" + `
-}
-`
-	_, err := parseTestStruct(t, "Chat", source)
-	if err == nil {
-		t.Fatal("expected error for 'any' field without oneof tag")
+	if _, err := namesByDir(dirs, []string{"Foo"}); err == nil || !strings.Contains(err.Error(), "must be qualified") {
+		t.Errorf("expected an error for an unqualified name, got: %v", err)
 	}
-	if !strings.Contains(err.Error(), "interface types are not supported") {
-		t.Errorf("expected 'interface types are not supported' error, got: %v", err)
+	if _, err := namesByDir(dirs, []string{"c.Foo"}); err == nil {
+		t.Errorf("expected an error for an unknown package")
+	}
+	if byDir, err := namesByDir(dirs[:1], []string{"Foo"}); err != nil || len(byDir[dirs[0]]) != 1 {
+		t.Errorf("unqualified names must be accepted with a single directory, got %v, %v", byDir, err)
 	}
 }
 
-func TestInterfaceRejection_InlineInterface(t *testing.T) {
-	// Test that inline interface{} is rejected
-	source := `
-type Chat struct {
-	Content interface{} ` + "`protobuf:\"1\"`" + `
-}
-`
-	_, err := parseTestStruct(t, "Chat", source)
-	if err == nil {
-		t.Fatal("expected error for inline interface{} field without oneof tag")
+func TestMoveReport(t *testing.T) {
+	moves, err := parseMoves("Note=2, Items=3")
+	if err != nil {
+		t.Fatalf("parseMoves failed: %v", err)
 	}
-	if !strings.Contains(err.Error(), "interface types are not supported") {
-		t.Errorf("expected 'interface types are not supported' error, got: %v", err)
+	if moves["Note"] != 2 || moves["Items"] != 3 {
+		t.Errorf("got moves %v", moves)
 	}
-}
-
-// Note: Named interface types (like `type Message interface{}`) are NOT detected
-// at parse time because the AST only sees the identifier, not the underlying type.
-// Users must use the `oneof` tag for polymorphic interface fields.
-// Example: `Content Message `protobuf:"oneof,TextMessage:1,ImageMessage:2"``
-
-func TestOneofTagValidation_InvalidFieldTypes(t *testing.T) {
-	tests := []struct {
-		name    string
-		source  string
-		wantErr string
-	}{
-		{
-			name: "primitive string",
-			source: `type Chat struct {
-	Content string ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-			wantErr: "primitive type",
-		},
-		{
-			name: "primitive int",
-			source: `type Chat struct {
-	Content int ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-			wantErr: "primitive type",
-		},
-		{
-			name: "primitive bool",
-			source: `type Chat struct {
-	Content bool ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-			wantErr: "primitive type",
-		},
-		{
-			name: "slice type",
-			source: `type Chat struct {
-	Content []string ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-			wantErr: "slice/array type",
-		},
-		{
-			name: "map type",
-			source: `type Chat struct {
-	Content map[string]int ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-			wantErr: "map type",
-		},
-		{
-			name: "pointer type",
-			source: `type Message interface{}
-type Chat struct {
-	Content *Message ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-			wantErr: "pointer type",
-		},
+	if _, err := parseMoves("Note"); err == nil {
+		t.Errorf("expected an error for a move without number")
 	}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			_, err := parseTestStruct(t, "Chat", tc.source)
-			if err == nil {
-				t.Fatal("expected error for invalid oneof field type")
-			}
-			if !strings.Contains(err.Error(), tc.wantErr) {
-				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
-			}
-		})
+	// The number of Items is taken by Note, so only 6 is freed.
+	report := moveReport("Order", []easyprotogen.FieldMove{{Field: "Note", From: 6, To: 3}, {Field: "Items", From: 3, To: 7}})
+	want := "Order.Note: 6 -> 3\nOrder.Items: 3 -> 7\nreserve the old numbers in the doc comment of Order: //protogen:reserved 6\n"
+	if report != want {
+		t.Errorf("got report:\n%s\nwant:\n%s", report, want)
 	}
 }
 
-func TestOneofTagValidation_ValidFieldTypes(t *testing.T) {
-	tests := []struct {
-		name   string
-		source string
-	}{
-		{
-			name: "named interface type",
-			source: `type Message interface{}
-type Chat struct {
-	Content Message ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-		},
-		{
-			name: "any keyword",
-			source: `type Chat struct {
-	Content any ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-		},
-		{
-			name: "inline interface",
-			source: `type Chat struct {
-	Content interface{} ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-		},
-		{
-			name: "interface with methods",
-			source: `type Chat struct {
-	Content interface{ Method() } ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
-}`,
-		},
+func TestScanDirs(t *testing.T) {
+	root := t.TempDir()
+	for path, src := range map[string]string{
+		"a/types.go":          "package a\n\n//protogen:generate\ntype A struct{}\n",
+		"b/types.go":          "package b\n\ntype B struct{}\n",
+		"c/d/types.go":        "package d\n\ntype (\n\t//protogen:generate\n\tD struct{}\n)\n",
+		"e/types_test.go":     "package e\n\n//protogen:generate\ntype E struct{}\n",
+		"testdata/x/types.go": "package x\n\n//protogen:generate\ntype X struct{}\n",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			info, err := parseTestStruct(t, "Chat", tc.source)
-			if err != nil {
-				t.Fatalf("expected valid oneof field type, got error: %v", err)
-			}
-			if !info.Fields[0].IsOneof {
-				t.Error("expected IsOneof to be true")
-			}
-		})
+	dirs, err := scanDirs([]string{root + "/...", "other"}, false)
+	if err != nil {
+		t.Fatalf("scanDirs failed: %v", err)
 	}
-}
-
-func TestZeroValue(t *testing.T) {
-	// zeroValue uses *new(T) for all types, which correctly returns the zero value
-	tests := []string{
-		"string", "bool", "int", "int32", "int64", "uint32", "float64",
-		"[]byte", "*MyType", "[]MyType", "map[string]int",
-		"MyStruct", "MyInt", "pkg.ExternalType",
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "c", "d"), "other"}
+	if !slices.Equal(dirs, want) {
+		t.Errorf("got %v, want %v", dirs, want)
 	}
-
-	for _, goType := range tests {
-		t.Run(goType, func(t *testing.T) {
-			expected := fmt.Sprintf("*new(%s)", goType)
-			result := zeroValue(goType)
-			if result != expected {
-				t.Errorf("zeroValue(%q) = %q, want %q", goType, result, expected)
-			}
-		})
+	if dirs, err := scanDirs([]string{filepath.Join(root, "e") + "/..."}, true); err != nil || len(dirs) != 1 {
+		t.Errorf("expected the directory of a test file with -include-tests, got %v, %v", dirs, err)
+	}
+	if _, err := scanDirs([]string{filepath.Join(root, "b") + "/..."}, false); err == nil {
+		t.Errorf("expected an error for a tree without generate directives")
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
+
+// runRenumber implements the renumber subcommand and returns the process exit code.
+func runRenumber(args []string) int {
+	fs := flag.NewFlagSet("renumber", flag.ExitOnError)
+	typeName := fs.String("type", "", "struct type whose fields are renumbered")
+	compact := fs.Bool("compact", false, "number the fields 1, 2, ... in the order of their field numbers, closing gaps")
+	move := fs.String("move", "", "comma-separated Field=N pairs moving fields to new numbers")
+	force := fs.Bool("force", false, "rewrite the tags even if the changes are wire-incompatible")
+	dryRun := fs.Bool("n", false, "print the changes without rewriting the source file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protogen renumber -type=Type [-compact] [-move=Field=N,...] [-force] [-n] [dir]")
+		fmt.Fprintln(fs.Output(), "Changes the field numbers of a type, refusing wire-incompatible changes unless forced.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *typeName == "" || (!*compact && *move == "") || fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	moves, err := parseMoves(*move)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	files, changes, err := easyprotogen.Renumber(dir, *typeName, easyprotogen.Renumbering{Compact: *compact, Moves: moves, Force: *force})
+	if ce := (*easyprotogen.CompatError)(nil); errors.As(err, &ce) {
+		fmt.Fprintf(os.Stderr, "%v\nuse -force to renumber anyway\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(changes) == 0 {
+		fmt.Println("no field numbers change")
+		return 0
+	}
+	fmt.Print(moveReport(*typeName, changes))
+	if *dryRun {
+		return 0
+	}
+	for _, f := range files {
+		if _, err := writeFileIfChanged(f.Path, f.Content); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Rewrote %s; regenerate the code of %s\n", f.Path, *typeName)
+	}
+	return 0
+}
+
+// parseMoves parses the Field=N pairs of -move.
+func parseMoves(spec string) (map[string]int, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	moves := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		field, num, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(num)
+		if !ok || field == "" || err != nil {
+			return nil, fmt.Errorf("invalid move %q: expected Field=N", pair)
+		}
+		moves[field] = n
+	}
+	return moves, nil
+}
+
+// moveReport returns a line per field number change of typeName, followed by a reserved
+// directive suggestion for the numbers no field uses anymore.
+func moveReport(typeName string, changes []easyprotogen.FieldMove) string {
+	var sb strings.Builder
+	var freed []string
+	for _, c := range changes {
+		fmt.Fprintf(&sb, "%s.%s: %d -> %d\n", typeName, c.Field, c.From, c.To)
+		if !slices.ContainsFunc(changes, func(o easyprotogen.FieldMove) bool { return o.To == c.From }) {
+			freed = append(freed, strconv.Itoa(c.From))
+		}
+	}
+	if len(freed) > 0 {
+		fmt.Fprintf(&sb, "reserve the old numbers in the doc comment of %s: //protogen:reserved %s\n", typeName, strings.Join(freed, ","))
+	}
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
+
+// runSize implements the size subcommand and returns the process exit code.
+func runSize(args []string) int {
+	fs := flag.NewFlagSet("size", flag.ExitOnError)
+	typeName := fs.String("type", "", "struct type of the payload")
+	dir := fs.String("dir", ".", "directory of the package declaring the type")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protogen size -type=Type [-dir=dir] payload_file|-")
+		fmt.Fprintln(fs.Output(), "Prints the encoded size of each field of a protobuf payload decoded as the type, largest first, reading stdin for -.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *typeName == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := readPayload(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sizes, err := easyprotogen.SizeBreakdown(*dir, *typeName, data)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tNUMBER\tCOUNT\tBYTES\tSHARE")
+	for _, size := range sizes {
+		name := size.Field
+		if name == "" {
+			name = "(unknown)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\n", name, size.Num, size.Count, size.Bytes, 100*float64(size.Bytes)/float64(len(data)))
+	}
+	tw.Flush()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("total %d bytes\n", len(data))
+	return 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
+
+// runTag implements the tag subcommand and returns the process exit code.
+func runTag(args []string) int {
+	fs := flag.NewFlagSet("tag", flag.ExitOnError)
+	typeNames := fs.String("type", "", "comma-separated list of struct types whose untagged exported fields get protobuf tags")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protogen tag -type=Type1,Type2 [dir]")
+		fmt.Fprintln(fs.Output(), "Adds protobuf tags with new field numbers to the untagged exported fields of the types, rewriting their source files.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *typeNames == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	types := strings.Split(*typeNames, ",")
+	for i := range types {
+		types[i] = strings.TrimSpace(types[i])
+	}
+	files, err := easyprotogen.Tag(dir, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Println("all exported fields are tagged")
+	}
+	for _, f := range files {
+		if _, err := writeFileIfChanged(f.Path, f.Content); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Tagged %s\n", f.Path)
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	easyprotogen "github.com/aryehlev/easyproto-gen"
+)
+
+// isVetTool reports whether protogen was invoked by go vet -vettool.
+func isVetTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	return args[0] == "-V=full" || args[0] == "-flags" || strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+// runVetTool runs easyprotogen.Analyzer with the go vet command-line protocol. It does not return.
+func runVetTool() {
+	// The generator flags are meaningless to go vet.
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	unitchecker.Main(easyprotogen.Analyzer)
+}
@@ -0,0 +1,213 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// schemaField describes the wire format of a field.
+type schemaField struct {
+	Name     string `json:"name"`
+	Num      int    `json:"num"`
+	Type     string `json:"type"` // Proto type; "message" for oneof variants, "map<K,V>" for maps
+	Repeated bool   `json:"repeated,omitempty"`
+	Optional bool   `json:"optional,omitempty"` // Absence is a valid value: pointers and oneof variants
+	Removed  bool   `json:"removed,omitempty"`  // Field was removed; only recorded in lock files
+}
+
+// schema maps type names to their fields sorted by field number.
+type schema map[string][]schemaField
+
+// buildSchema returns the schema of the given types.
+func buildSchema(typeInfos map[string]*TypeInfo) schema {
+	s := make(schema, len(typeInfos))
+	for typeName, info := range typeInfos {
+		var fields []schemaField
+		for _, f := range info.Fields {
+			switch {
+			case f.IsOneof:
+				for _, v := range f.OneofVariants {
+					protoType := v.ProtoType
+					if protoType == "" {
+						protoType = "message"
+					}
+					fields = append(fields, schemaField{
+						Name:     f.Name + "(" + v.TypeName + ")",
+						Num:      v.FieldNum,
+						Type:     protoType,
+						Optional: true,
+					})
+				}
+			case f.IsMap:
+				fields = append(fields, schemaField{
+					Name: f.Name,
+					Num:  f.FieldNum,
+					Type: "map<" + f.MapKeyProto + "," + f.MapValueProto + ">",
+				})
+			default:
+				protoType := schemaType(f)
+				if f.IsEnum {
+					protoType = "enum"
+				} else if f.IsWrapper {
+					protoType = wrapperTypes[protoType]
+				}
+				fields = append(fields, schemaField{
+					Name:     f.Name,
+					Num:      f.FieldNum,
+					Type:     protoType,
+					Repeated: f.IsRepeated,
+					Optional: f.IsOptional,
+				})
+			}
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Num < fields[j].Num
+		})
+		s[typeName] = fields
+	}
+	return s
+}
+
+// parseSchema returns the schema of all struct types with protobuf tags in the package in dir.
+func parseSchema(dir string) (schema, error) {
+	typeInfos, err := parseTaggedTypes(dir)
+	if err != nil {
+		return nil, err
+	}
+	return buildSchema(typeInfos), nil
+}
+
+// parseTaggedTypes returns all struct types with protobuf tags in the package in dir.
+func parseTaggedTypes(dir string) (map[string]*TypeInfo, error) {
+	_, files, err := parsePackage(token.NewFileSet(), dir, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	typeInfos := make(map[string]*TypeInfo)
+	err = forEachStruct(files, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+		info, err := parseStruct(typeName, structType)
+		if err == nil {
+			err = applyDirectives(info, doc)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse struct %s: %w", typeName, err)
+		}
+		if len(info.Fields) > 0 {
+			typeInfos[typeName] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return typeInfos, nil
+}
+
+// reservedNumbers returns the field numbers reserved by the //protogen:reserved directives of
+// the given types, by type name.
+func reservedNumbers(typeInfos map[string]*TypeInfo) map[string][]reservedRange {
+	reserved := make(map[string][]reservedRange, len(typeInfos))
+	for typeName, info := range typeInfos {
+		reserved[typeName] = info.reserved
+	}
+	return reserved
+}
+
+// wireKind returns the encoding of values of field f. Fields with equal kinds
+// can read each other's data.
+func wireKind(f schemaField) string {
+	var kind string
+	switch f.Type {
+	case "int32", "int64", "uint32", "uint64", "bool", "enum":
+		kind = "varint"
+	case "sint32", "sint64":
+		kind = "zigzag varint"
+	case "fixed32", "sfixed32", "float":
+		kind = "fixed32"
+	case "fixed64", "sfixed64", "double":
+		kind = "fixed64"
+	case "string", "bytes":
+		return "bytes"
+	default:
+		// Messages and maps are length-delimited, and repeated ones are encoded
+		// as a sequence of singular ones.
+		return f.Type
+	}
+	if f.Repeated {
+		return "packed " + kind
+	}
+	return kind
+}
+
+// checkCompat returns the wire-incompatible changes between the old and new schemas:
+// field numbers reused with an incompatible type or after the field was removed, and
+// removed fields without presence, for which old readers would silently see the zero value,
+// unless their numbers are in reserved, by type name, or marked as removed in oldSchema.
+func checkCompat(oldSchema, newSchema schema, reserved map[string][]reservedRange) []string {
+	var issues []string
+	for typeName, oldFields := range oldSchema {
+		newFields, ok := newSchema[typeName]
+		if !ok {
+			continue
+		}
+		for _, of := range oldFields {
+			i := sort.Search(len(newFields), func(i int) bool { return newFields[i].Num >= of.Num })
+			found := i < len(newFields) && newFields[i].Num == of.Num
+			if of.Removed {
+				if found {
+					issues = append(issues, fmt.Sprintf("%s.%s (%d): field number of removed field %s reused", typeName, newFields[i].Name, of.Num, of.Name))
+				}
+				continue
+			}
+			if !found {
+				isReserved := slices.ContainsFunc(reserved[typeName], func(r reservedRange) bool { return r.contains(of.Num) })
+				if !of.Optional && !of.Repeated && !strings.HasPrefix(of.Type, "map<") && !isReserved {
+					issues = append(issues, fmt.Sprintf("%s.%s (%d): field removed without reserving its number", typeName, of.Name, of.Num))
+				}
+				continue
+			}
+			nf := newFields[i]
+			if oldKind, newKind := wireKind(of), wireKind(nf); oldKind != newKind {
+				issues = append(issues, fmt.Sprintf("%s.%s (%d): wire type changed from %s to %s", typeName, nf.Name, nf.Num, oldKind, newKind))
+				continue
+			}
+			if of.Name != nf.Name && of.Type != nf.Type {
+				issues = append(issues, fmt.Sprintf("%s.%s (%d): field number reused by %s with type %s (was %s)", typeName, of.Name, of.Num, nf.Name, nf.Type, of.Type))
+			}
+		}
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+// loadSchema returns the schema of the package in dir, or the schema recorded in
+// the lock file if path is a file.
+func loadSchema(path string) (schema, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return readLock(path)
+	}
+	return parseSchema(path)
+}
+
+// Compat returns the wire-incompatible changes of the protobuf-tagged types in the package
+// in newDir since oldPath, a directory with the old version of the package or a protogen.lock file.
+func Compat(oldPath, newDir string) ([]string, error) {
+	oldSchema, err := loadSchema(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	typeInfos, err := parseTaggedTypes(newDir)
+	if err != nil {
+		return nil, err
+	}
+	return checkCompat(oldSchema, buildSchema(typeInfos), reservedNumbers(typeInfos)), nil
+}
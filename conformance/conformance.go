@@ -0,0 +1,348 @@
+// Package conformance checks that types generated by protogen are wire compatible with
+// the corresponding types generated by protoc-gen-go.
+//
+// Types generated with protogen -conformance=Message=pb.Message get a test calling Check:
+//
+//	func TestMessageConformance(t *testing.T) {
+//	    conformance.Check(t, &Message{}, &pb.Message{})
+//	}
+package conformance
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxDepth is the nesting depth up to which Fill populates message fields,
+// which bounds the size of recursive messages.
+const maxDepth = 3
+
+// Message is implemented by types generated by protogen.
+type Message interface {
+	MarshalProtobuf(dst []byte) []byte
+	UnmarshalProtobuf(src []byte) error
+}
+
+// Check verifies that m reads and writes the same messages as ref.
+//
+// A message of the type of ref is populated by Fill, encoded with proto.Marshal and
+// decoded into m, which must then hold the same values: fields of m are matched to
+// fields of ref by the numbers in their protobuf tags. The encoding of m must then
+// decode to an equal message with proto.Unmarshal.
+func Check(t testing.TB, m Message, ref proto.Message) {
+	t.Helper()
+	want := ref.ProtoReflect().New()
+	Fill(want)
+	data, err := proto.Marshal(want.Interface())
+	if err != nil {
+		t.Fatalf("cannot marshal %T: %v", ref, err)
+	}
+	if err := m.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("cannot unmarshal %T encoded by proto.Marshal into %T: %v", ref, m, err)
+	}
+	if d := diffMessage(reflect.TypeOf(m).Elem().Name(), reflect.ValueOf(m), want); d != "" {
+		t.Errorf("%T decoded %T differently: %s", m, ref, d)
+	}
+	got := ref.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(m.MarshalProtobuf(nil), got); err != nil {
+		t.Fatalf("cannot unmarshal %T encoded by %T: %v", ref, m, err)
+	}
+	if !proto.Equal(got, want.Interface()) {
+		t.Errorf("%T changed in a round trip through %T:\ngot:  %s\nwant: %s", ref, m, prototext.Format(got), prototext.Format(want.Interface()))
+	}
+}
+
+// Fill sets the fields of m to non-zero sample values. Repeated and map fields get two
+// elements, only the first field of each oneof is set, and nested messages are populated
+// up to a fixed depth.
+func Fill(m protoreflect.Message) {
+	fill(m, 0)
+}
+
+func fill(m protoreflect.Message, depth int) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && oneof.Fields().Get(0) != fd {
+			continue
+		}
+		isMessage := fd.Message() != nil && !fd.IsMap()
+		if (isMessage || fd.IsMap() && fd.MapValue().Message() != nil) && depth >= maxDepth {
+			continue
+		}
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for j := 0; j < 2; j++ {
+				if isMessage {
+					v := list.NewElement()
+					fill(v.Message(), depth+1)
+					list.Append(v)
+				} else {
+					list.Append(sampleValue(fd, j))
+				}
+			}
+		case fd.IsMap():
+			mp := m.Mutable(fd).Map()
+			for j := 0; j < 2; j++ {
+				key := sampleValue(fd.MapKey(), j).MapKey()
+				if fd.MapValue().Message() != nil {
+					v := mp.NewValue()
+					fill(v.Message(), depth+1)
+					mp.Set(key, v)
+				} else {
+					mp.Set(key, sampleValue(fd.MapValue(), j))
+				}
+			}
+		case isMessage:
+			fill(m.Mutable(fd).Message(), depth+1)
+		default:
+			m.Set(fd, sampleValue(fd, 0))
+		}
+	}
+}
+
+// sampleValue returns the j-th sample value of the scalar field fd. Values differ by field
+// and by j, and signed integers are negative to exercise sign extension and zigzag encoding.
+func sampleValue(fd protoreflect.FieldDescriptor, j int) protoreflect.Value {
+	n := int64(fd.Number())*10 + int64(j) + 1
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(j == 0)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(max(0, values.Len()-1-j)).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(-n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(-n << 33)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(n) << 20)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(n) << 40)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(n) + 0.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(float64(n) + 0.25)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(fmt.Sprintf("%s-%d-ü", fd.Name(), j))
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte{byte(n), 0, 0xff})
+	}
+	panic(fmt.Sprintf("BUG: unexpected kind %s of field %s", fd.Kind(), fd.FullName()))
+}
+
+// diffMessage returns the first difference between the generated message v and m,
+// or "" if they hold the same values.
+func diffMessage(path string, v reflect.Value, m protoreflect.Message) string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "" // custom types are opaque
+	}
+	fields := m.Descriptor().Fields()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("protobuf")
+		if !ok || !sf.IsExported() {
+			continue
+		}
+		fieldPath := path + "." + sf.Name
+		num, variants, _ := strings.Cut(tag, ",")
+		if num == "oneof" {
+			if d := diffOneof(fieldPath, v.Field(i), m, variants); d != "" {
+				return d
+			}
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			// protoc-gen-go tags start with the wire encoding.
+			num, _, _ = strings.Cut(variants, ",")
+			n, err = strconv.Atoi(num)
+		}
+		if err != nil {
+			return fmt.Sprintf("%s: invalid protobuf tag %q", fieldPath, tag)
+		}
+		fd := fields.ByNumber(protoreflect.FieldNumber(n))
+		if fd == nil {
+			return fmt.Sprintf("%s: field number %d is not defined by %s", fieldPath, n, m.Descriptor().FullName())
+		}
+		if d := diffField(fieldPath, v.Field(i), m, fd); d != "" {
+			return d
+		}
+	}
+	return ""
+}
+
+// diffOneof compares the oneof field v with the tag variants "TypeA:1,TypeB:2" to m.
+func diffOneof(path string, v reflect.Value, m protoreflect.Message, variants string) string {
+	var typeName string
+	if !v.IsNil() {
+		typeName = reflect.Indirect(v.Elem()).Type().Name()
+	}
+	for _, variant := range strings.Split(variants, ",") {
+		name, num, _ := strings.Cut(strings.TrimSpace(variant), ":")
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return fmt.Sprintf("%s: invalid oneof variant %q", path, variant)
+		}
+		fd := m.Descriptor().Fields().ByNumber(protoreflect.FieldNumber(n))
+		if fd == nil {
+			return fmt.Sprintf("%s: field number %d is not defined by %s", path, n, m.Descriptor().FullName())
+		}
+		switch {
+		case name == typeName && !m.Has(fd):
+			return fmt.Sprintf("%s: got %s, want %s unset", path, typeName, fd.Name())
+		case name == typeName:
+			return diffMessage(path+"("+typeName+")", v.Elem(), m.Get(fd).Message())
+		case m.Has(fd):
+			return fmt.Sprintf("%s: got %s, want %s set", path, cmp.Or(typeName, "nil"), fd.Name())
+		}
+	}
+	return ""
+}
+
+// diffField compares the field v to the field fd of m.
+func diffField(path string, v reflect.Value, m protoreflect.Message, fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsList():
+		list := m.Get(fd).List()
+		if v.Len() != list.Len() {
+			return fmt.Sprintf("%s: got %d elements, want %d", path, v.Len(), list.Len())
+		}
+		for j := 0; j < list.Len(); j++ {
+			if d := diffValue(fmt.Sprintf("%s[%d]", path, j), v.Index(j), list.Get(j), fd); d != "" {
+				return d
+			}
+		}
+		return ""
+	case fd.IsMap():
+		mp := m.Get(fd).Map()
+		if v.Len() != mp.Len() {
+			return fmt.Sprintf("%s: got %d entries, want %d", path, v.Len(), mp.Len())
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			entryPath := fmt.Sprintf("%s[%v]", path, iter.Key())
+			key, ok := mapKey(iter.Key(), fd.MapKey())
+			if !ok || !mp.Has(key) {
+				return fmt.Sprintf("%s: unexpected entry", entryPath)
+			}
+			if d := diffValue(entryPath, iter.Value(), mp.Get(key), fd.MapValue()); d != "" {
+				return d
+			}
+		}
+		return ""
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() != !m.Has(fd) {
+			return fmt.Sprintf("%s: got set=%t, want set=%t", path, !v.IsNil(), m.Has(fd))
+		}
+		if v.IsNil() {
+			return ""
+		}
+		if fd.Message() == nil {
+			v = v.Elem()
+		}
+	}
+	return diffValue(path, v, m.Get(fd), fd)
+}
+
+// diffValue compares the value v to the singular value pv of a field described by fd.
+func diffValue(path string, v reflect.Value, pv protoreflect.Value, fd protoreflect.FieldDescriptor) string {
+	if fd.Message() != nil {
+		return diffMessage(path, v, pv.Message())
+	}
+	var got, want any
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		got = v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		got = v.Uint()
+	case reflect.Float32, reflect.Float64:
+		got = v.Float()
+	case reflect.Bool:
+		got = v.Bool()
+	case reflect.String:
+		got = v.String()
+	case reflect.Slice:
+		got = string(v.Bytes())
+	default:
+		return "" // custom types are opaque
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		want = int64(pv.Enum())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		want = pv.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		want = pv.Uint()
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		want = pv.Float()
+	case protoreflect.BoolKind:
+		want = pv.Bool()
+	case protoreflect.StringKind:
+		want = pv.String()
+	case protoreflect.BytesKind:
+		want = string(pv.Bytes())
+	}
+	if got != want {
+		return fmt.Sprintf("%s: got %v (%s), want %v (%s)", path, got, v.Type(), want, fd.Kind())
+	}
+	return ""
+}
+
+// mapKey converts the map key k of a generated type to a key of a map field with key fd.
+func mapKey(k reflect.Value, fd protoreflect.FieldDescriptor) (protoreflect.MapKey, bool) {
+	var pv protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if k.Kind() != reflect.String {
+			return protoreflect.MapKey{}, false
+		}
+		pv = protoreflect.ValueOfString(k.String())
+	case protoreflect.BoolKind:
+		if k.Kind() != reflect.Bool {
+			return protoreflect.MapKey{}, false
+		}
+		pv = protoreflect.ValueOfBool(k.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if !k.CanInt() {
+			return protoreflect.MapKey{}, false
+		}
+		pv = protoreflect.ValueOfInt32(int32(k.Int()))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if !k.CanInt() {
+			return protoreflect.MapKey{}, false
+		}
+		pv = protoreflect.ValueOfInt64(k.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if !k.CanUint() {
+			return protoreflect.MapKey{}, false
+		}
+		pv = protoreflect.ValueOfUint32(uint32(k.Uint()))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if !k.CanUint() {
+			return protoreflect.MapKey{}, false
+		}
+		pv = protoreflect.ValueOfUint64(k.Uint())
+	default:
+		return protoreflect.MapKey{}, false
+	}
+	return pv.MapKey(), true
+}
@@ -0,0 +1,116 @@
+package conformance
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// int64Value mirrors wrapperspb.Int64Value, encoding its value as sint64 if zigzag is set.
+type int64Value struct {
+	Value  int64 `protobuf:"1"`
+	zigzag bool
+}
+
+func (x *int64Value) MarshalProtobuf(dst []byte) []byte {
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	if x.zigzag {
+		mm.AppendSint64(1, x.Value)
+	} else {
+		mm.AppendInt64(1, x.Value)
+	}
+	return m.Marshal(dst)
+}
+
+func (x *int64Value) UnmarshalProtobuf(src []byte) (err error) {
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		if src, err = fc.NextField(src); err != nil {
+			return err
+		}
+		if fc.FieldNum == 1 {
+			v, ok := fc.Int64()
+			if x.zigzag {
+				v, ok = fc.Sint64()
+			}
+			if !ok {
+				return fmt.Errorf("cannot read value")
+			}
+			x.Value = v
+		}
+	}
+	return nil
+}
+
+// recorder records the failures of a check.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func TestCheck(t *testing.T) {
+	Check(t, &int64Value{}, &wrapperspb.Int64Value{})
+
+	r := &recorder{TB: t}
+	Check(r, &int64Value{zigzag: true}, &wrapperspb.Int64Value{})
+	if len(r.failures) == 0 {
+		t.Fatal("expected a failure for an incompatible encoding")
+	}
+}
+
+func TestFill(t *testing.T) {
+	var v wrapperspb.Int64Value
+	Fill(v.ProtoReflect())
+	if v.Value >= 0 {
+		t.Errorf("expected a negative sample value, got %d", v.Value)
+	}
+
+	// Struct is recursive through Value, so filling must stop at maxDepth.
+	var s structpb.Struct
+	Fill(s.ProtoReflect())
+	if len(s.Fields) != 2 {
+		t.Fatalf("expected 2 map entries, got %d", len(s.Fields))
+	}
+	for _, v := range s.Fields {
+		if v.GetNullValue() != structpb.NullValue_NULL_VALUE {
+			t.Errorf("expected the first oneof field to be set, got %v", v)
+		}
+	}
+}
+
+func TestDiffMessage(t *testing.T) {
+	want := wrapperspb.Int64(-5)
+	for _, tc := range []struct {
+		v    any
+		diff string
+	}{
+		{&int64Value{Value: -5}, ""},
+		{&int64Value{Value: 5}, "int64Value.Value: got 5 (int64), want -5 (int64)"},
+		{&struct {
+			Value string `protobuf:"1"`
+		}{"x"}, ".Value: got x (string), want -5 (int64)"},
+		{&struct {
+			Other int64 `protobuf:"2"`
+		}{}, ".Other: field number 2 is not defined by google.protobuf.Int64Value"},
+	} {
+		v := reflect.ValueOf(tc.v)
+		if d := diffMessage(v.Elem().Type().Name(), v, want.ProtoReflect()); d != tc.diff {
+			t.Errorf("diffMessage(%+v) = %q, want %q", tc.v, d, tc.diff)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deprecatedTestUses returns a warning for every composite literal in the _test.go files
+// in dir that sets a deprecated field of the given types.
+func deprecatedTestUses(dir string, typeInfos map[string]*TypeInfo) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", entry.Name(), err)
+		}
+		files = append(files, file)
+	}
+	return deprecatedUses(fset, files, typeInfos), nil
+}
+
+// deprecatedUses returns a warning for every composite literal in files that sets
+// a deprecated field of the given types.
+func deprecatedUses(fset *token.FileSet, files []*ast.File, typeInfos map[string]*TypeInfo) []string {
+	deprecated := make(map[string]map[string]bool)
+	for typeName, info := range typeInfos {
+		for _, f := range info.Fields {
+			if !f.IsDeprecated {
+				continue
+			}
+			if deprecated[typeName] == nil {
+				deprecated[typeName] = make(map[string]bool)
+			}
+			deprecated[typeName][f.Name] = true
+		}
+	}
+	if len(deprecated) == 0 {
+		return nil
+	}
+
+	var warnings []string
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+			// Qualified type names match literals in external test packages.
+			typeName := getTypeName(lit.Type)
+			fields := deprecated[typeName]
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*ast.Ident); ok && fields[key.Name] {
+					warnings = append(warnings, fmt.Sprintf("%s: deprecated field %s.%s is set", fset.Position(kv.Pos()), typeName, key.Name))
+				}
+			}
+			return true
+		})
+	}
+	return warnings
+}
@@ -0,0 +1,174 @@
+package easyprotogen
+
+import (
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// descriptorTypes maps protobuf scalar types to their descriptor types.
+var descriptorTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double":   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int64":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint64":   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int32":    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"fixed64":  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32":  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	"bool":     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"uint32":   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	"sint32":   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+	"enum":     descriptorpb.FieldDescriptorProto_TYPE_INT32,
+}
+
+// buildFileDescriptor returns a proto3 file descriptor of the types of pkg, named like the
+// messages exported by the proto backend. Like there, enums are described as int32 fields,
+// and custom types and messages not generated together as bytes fields.
+func buildFileDescriptor(pkg *Package, fileName string) *descriptorpb.FileDescriptorProto {
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(fileName),
+		Package: proto.String(pkg.Name),
+		Syntax:  proto.String("proto3"),
+	}
+	for _, typeName := range pkg.Types {
+		fd.MessageType = append(fd.MessageType, buildMessageDescriptor(pkg, typeName))
+	}
+	if anyField(pkg.Types, pkg.TypeInfos, func(f *FieldInfo) bool { return f.IsWrapper }) {
+		fd.Dependency = []string{"google/protobuf/wrappers.proto"}
+	}
+	return fd
+}
+
+// descriptorPath returns the path of the file descriptor of the given types of a package,
+// e.g. example/message.proto for the types Message and User of package example.
+func descriptorPath(pkgName string, typeNames []string) string {
+	return pkgName + "/" + snakeCase(typeNames[0]) + ".proto"
+}
+
+// buildMessageDescriptor returns the descriptor of the type typeName of pkg.
+func buildMessageDescriptor(pkg *Package, typeName string) *descriptorpb.DescriptorProto {
+	md := &descriptorpb.DescriptorProto{Name: proto.String(typeName)}
+	// messageRef returns the type of fields of the named message type.
+	messageRef := func(f *descriptorpb.FieldDescriptorProto, goType string, custom bool) {
+		name := strings.TrimPrefix(goType, "*")
+		if _, ok := pkg.TypeInfos[name]; !ok || custom {
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()
+			return
+		}
+		f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		f.TypeName = proto.String("." + pkg.Name + "." + name)
+	}
+
+	// Synthetic oneofs of proto3 optional fields must follow the real oneofs.
+	var optional []*descriptorpb.FieldDescriptorProto
+	for _, fi := range pkg.TypeInfos[typeName].Fields {
+		if fi.IsOneof {
+			index := proto.Int32(int32(len(md.OneofDecl)))
+			md.OneofDecl = append(md.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(snakeCase(fi.Name))})
+			for _, v := range fi.OneofVariants {
+				f := &descriptorpb.FieldDescriptorProto{
+					Name:       proto.String(snakeCase(fi.Name) + "_" + snakeCase(v.TypeName)),
+					Number:     proto.Int32(int32(v.FieldNum)),
+					Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					OneofIndex: index,
+				}
+				if v.ProtoType != "" {
+					f.Type = descriptorTypes[v.ProtoType].Enum()
+				} else {
+					messageRef(f, v.TypeName, false)
+				}
+				md.Field = append(md.Field, f)
+			}
+			continue
+		}
+
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(snakeCase(fi.Name)),
+			Number: proto.Int32(int32(fi.FieldNum)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if fi.IsDeprecated {
+			f.Options = &descriptorpb.FieldOptions{Deprecated: proto.Bool(true)}
+		}
+		if fi.IsRepeated && (fi.IsEnum || fi.IsUnpacked) {
+			// The generated code writes repeated enums and unpacked fields unpacked.
+			if f.Options == nil {
+				f.Options = &descriptorpb.FieldOptions{}
+			}
+			f.Options.Packed = proto.Bool(false)
+		}
+		switch {
+		case fi.IsMap:
+			entry := &descriptorpb.DescriptorProto{
+				Name:    proto.String(mapEntryName(f.GetName())),
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("key"), Number: proto.Int32(1), Label: f.Label, Type: descriptorTypes[fi.MapKeyProto].Enum()},
+					{Name: proto.String("value"), Number: proto.Int32(2), Label: f.Label},
+				},
+			}
+			if fi.MapValueIsMsg || fi.MapValueCustom {
+				messageRef(entry.Field[1], fi.MapValueType, fi.MapValueCustom)
+			} else {
+				entry.Field[1].Type = descriptorTypes[fi.MapValueProto].Enum()
+			}
+			md.NestedType = append(md.NestedType, entry)
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			f.TypeName = proto.String("." + pkg.Name + "." + typeName + "." + entry.GetName())
+		case fi.IsWrapper:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			f.TypeName = proto.String("." + wrapperTypes[fi.ProtoType])
+		case fi.IsMessage || fi.IsCustom:
+			goType := fi.BaseType
+			if fi.IsRepeated || fi.IsPointer {
+				goType = fi.ElemType
+			}
+			messageRef(f, goType, fi.IsCustom)
+		default:
+			f.Type = descriptorTypes[schemaType(fi)].Enum()
+			if fi.IsEnum {
+				f.Type = descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()
+			}
+		}
+		if fi.IsRepeated && !fi.IsMap {
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		} else if fi.IsOptional && !fi.IsMessage && !fi.IsCustom && !fi.IsWrapper {
+			f.Proto3Optional = proto.Bool(true)
+			optional = append(optional, f)
+		}
+		md.Field = append(md.Field, f)
+	}
+	for _, f := range optional {
+		f.OneofIndex = proto.Int32(int32(len(md.OneofDecl)))
+		md.OneofDecl = append(md.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.GetName())})
+	}
+	return md
+}
+
+// mapEntryName returns the name of the entry message of a map field, e.g. LabelsByIdEntry for
+// labels_by_id, as protoc derives it.
+func mapEntryName(fieldName string) string {
+	var sb strings.Builder
+	upperNext := true
+	for _, r := range fieldName {
+		switch {
+		case r == '_':
+			upperNext = true
+		case upperNext:
+			sb.WriteRune(unicode.ToUpper(r))
+			upperNext = false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString("Entry")
+	return sb.String()
+}
@@ -0,0 +1,144 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// directContext is the data passed to the sizeMessage and appendMessage templates: a nested
+// message expr written as the field fieldNum.
+type directContext struct {
+	FieldNum int
+	Expr     string
+}
+
+// checkDirect returns an error if a field of the types generated with Direct is of a kind
+// their SizeProtobuf and AppendProtobuf methods don't encode. Nested messages must be
+// generated together with them, since only those have sizeProtobuf and appendProtobuf methods.
+func checkDirect(typeNames []string, typeInfos map[string]*TypeInfo, generated map[string]bool) error {
+	for _, typeName := range typeNames {
+		for _, f := range typeInfos[typeName].Fields {
+			if reason := directUnsupported(f, generated); reason != "" {
+				return fmt.Errorf("field %q in type %s is not supported with -direct: %s", f.Name, typeName, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// directUnsupported returns why f can't be encoded by the methods generated with Direct, or ""
+// if it can.
+func directUnsupported(f *FieldInfo, generated map[string]bool) string {
+	switch {
+	case f.IsOneof:
+		for _, v := range f.OneofVariants {
+			if v.ProtoType == "" && !generated[v.TypeName] {
+				return fmt.Sprintf("message %s is not generated with it", v.TypeName)
+			}
+		}
+	case f.IsMap:
+		if f.MapValueIsMsg && (f.MapValueCustom || !generated[strings.TrimPrefix(f.MapValueType, "*")]) {
+			return fmt.Sprintf("message %s is not generated with it", strings.TrimPrefix(f.MapValueType, "*"))
+		}
+	case f.IsIndirect:
+		return "pointers to slices and maps are not supported"
+	case f.IsCustom:
+		return fmt.Sprintf("custom type %s has no sizeProtobuf method", f.ElemType)
+	case f.IsMessage && !generated[f.ElemType]:
+		return fmt.Sprintf("message %s is not generated with it", f.ElemType)
+	case f.IsUUID || f.IsBigInt || f.IsBigRat || f.IsDecimal || f.IsSQLNull || f.IsWrapper:
+		return "converted values are not supported"
+	}
+	return ""
+}
+
+// packedVarintType returns the protobuf type whose encoding the elements of f, a packed
+// repeated varint field, are written with: packed.MarshalVarints extends negative int32
+// values to 64 bits, unlike easyproto.
+func packedVarintType(f *FieldInfo) string {
+	if f.NeedsTypeConv && f.ConvType == "int32" {
+		return "int64"
+	}
+	return f.ProtoType
+}
+
+// wireTag returns the bytes of the tag of the field fieldNum with the given wire type as Go
+// byte literals, like 0x0a or 0x82, 0x01.
+func wireTag(fieldNum, wireType int) string {
+	v := uint64(fieldNum)<<3 | uint64(wireType)
+	var b []string
+	for v >= 0x80 {
+		b = append(b, fmt.Sprintf("0x%02x", byte(v)|0x80))
+		v >>= 7
+	}
+	return strings.Join(append(b, fmt.Sprintf("0x%02x", byte(v))), ", ")
+}
+
+// tagSize returns the number of bytes of the tag of the field fieldNum.
+func tagSize(fieldNum int) int {
+	return strings.Count(wireTag(fieldNum, 0), ",") + 1
+}
+
+// valueWireType returns the wire type of a protobuf type, including length-delimited
+// strings, bytes and messages.
+func valueWireType(protoType string) int {
+	switch protoType {
+	case "string", "bytes", "message":
+		return 2
+	}
+	return wireTypeOf(protoType)
+}
+
+// wireSize returns an expression of the number of bytes of expr, of the Go type read by
+// easyproto for protoType, encoded without its tag. Like easyproto, int32 and enum values are
+// encoded as uint32, so that negative values take 5 bytes.
+func wireSize(protoType, expr string) string {
+	switch protoType {
+	case "int32", "enum":
+		return fmt.Sprintf("wire.SizeVarint(uint64(uint32(%s)))", expr)
+	case "string", "bytes":
+		return fmt.Sprintf("wire.SizeBytes(len(%s))", expr)
+	case "bool":
+		return "1"
+	case "sint32":
+		return fmt.Sprintf("wire.SizeVarint(wire.ZigZag32(%s))", expr)
+	case "sint64":
+		return fmt.Sprintf("wire.SizeVarint(wire.ZigZag64(%s))", expr)
+	}
+	if n := fixedSize(protoType); n > 0 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("wire.SizeVarint(uint64(%s))", expr)
+}
+
+// wireAppend returns an expression appending expr, of the Go type read by easyproto for
+// protoType, to dst without its tag.
+func wireAppend(protoType, expr string) string {
+	switch protoType {
+	case "int32", "enum":
+		return fmt.Sprintf("wire.AppendVarint(dst, uint64(uint32(%s)))", expr)
+	case "string":
+		return fmt.Sprintf("wire.AppendString(dst, %s)", expr)
+	case "bytes":
+		return fmt.Sprintf("wire.AppendBytes(dst, %s)", expr)
+	case "bool":
+		return fmt.Sprintf("wire.AppendBool(dst, %s)", expr)
+	case "sint32":
+		return fmt.Sprintf("wire.AppendVarint(dst, wire.ZigZag32(%s))", expr)
+	case "sint64":
+		return fmt.Sprintf("wire.AppendVarint(dst, wire.ZigZag64(%s))", expr)
+	case "fixed32":
+		return fmt.Sprintf("wire.AppendFixed32(dst, %s)", expr)
+	case "sfixed32":
+		return fmt.Sprintf("wire.AppendFixed32(dst, uint32(%s))", expr)
+	case "fixed64":
+		return fmt.Sprintf("wire.AppendFixed64(dst, %s)", expr)
+	case "sfixed64":
+		return fmt.Sprintf("wire.AppendFixed64(dst, uint64(%s))", expr)
+	case "float":
+		return fmt.Sprintf("wire.AppendFloat(dst, %s)", expr)
+	case "double":
+		return fmt.Sprintf("wire.AppendDouble(dst, %s)", expr)
+	}
+	return fmt.Sprintf("wire.AppendVarint(dst, uint64(%s))", expr)
+}
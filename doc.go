@@ -16,9 +16,9 @@
 //
 //	go install github.com/aryehlev/easyproto-gen/cmd/protogen@latest
 //
-// Add the easyproto runtime dependency to your project:
+// Add the runtime dependencies to your project:
 //
-//	go get github.com/VictoriaMetrics/easyproto
+//	go get github.com/VictoriaMetrics/easyproto github.com/aryehlev/easyproto-gen
 //
 // # Usage
 //
@@ -50,6 +50,20 @@
 //	var msg2 Message
 //	err := msg2.UnmarshalProtobuf(data)
 //
+// MarshalProtobuf uses an easyproto.Marshaler of a package-level pool; MarshalProtobufWith
+// takes the Marshaler from the caller instead, so that goroutines can keep their own.
+//
+// Unmarshal errors are *easyprotoerr.Error values with the path of the failing field
+// and its byte offset, e.g. "Message.Sender(User).Email: cannot read string: wire type mismatch at offset 42".
+// They wrap sentinel errors such as easyprotoerr.ErrTruncated for use with errors.Is.
+// Unknown fields are skipped, including the groups of proto2 messages.
+//
+// MergeFromProtobuf applies an encoded message on top of an existing value using protobuf
+// merge semantics: scalars overwrite, repeated fields append and nested messages merge.
+//
+// CloneProtobuf returns a deep copy of a message, and CloneProtobufInto copies it into an
+// existing value.
+//
 // # Tag Format
 //
 // The protobuf tag format is:
@@ -62,14 +76,89 @@
 //	[]byte    -> bytes        int64   -> int64      float64 -> double
 //	bool      -> bool         uint32  -> uint32     CustomType -> message
 //	int       -> int64        uint64  -> uint64     map[K]V -> map
+//	int8, int16 -> int32      uint8, uint16 -> uint32   uint -> uint64
+//	uuid.UUID, [16]byte -> bytes, or string with the uuidstring option
+//	big.Int, big.Rat -> bytes, with the bigint and bigrat options
+//	decimal.Decimal -> string, or bytes with the unscaled option
+//	sql.NullInt64, sql.Null[T], ... -> optional type of the value, left out unless Valid
+//	*[]T, *map[K]V -> like []T and map[K]V, left out if nil; empty ones decode as nil
+//
+// Decoded int8, int16, uint8 and uint16 values out of the range of their field fail with
+// easyprotoerr.ErrOutOfRange unless the field has the truncate option, and so do int and uint
+// values on 32-bit platforms. With -int32, int and uint fields are encoded as int32 and uint32.
 //
 // Explicit wire types can be specified when needed:
 //
 //	Delta int32  `protobuf:"1,sint32"`  // zigzag encoding for negative values
 //	ID    uint64 `protobuf:"2,fixed64"` // fixed-width encoding
 //
-// Options include:
+// Options follow the type, or directly follow the field number when the type is inferred:
+//   - always: on singular scalar, enum, string and bytes fields, encodes the field even when it
+//     holds the zero value, which is left out by default
+//   - bigint: on big.Int and *big.Int fields, encodes the number as bytes of its sign and
+//     magnitude
+//   - bigrat: on big.Rat and *big.Rat fields, encodes the number as a message of its numerator
+//     and denominator, encoded like big.Int
+//   - deprecated: marks the generated accessors of the field as deprecated; with
+//     -warn-deprecated, literals in the package's tests that set the field are reported
+//   - emitempty: on packed repeated scalar fields, encodes empty non-nil slices as empty packed
+//     fields, which decode as empty non-nil slices
 //   - enum: marks field as enum type (uses int32 wire format)
+//   - extract: generates an Extract<Type><Field>(src []byte) function that scans
+//     src for this field only, without unmarshaling the whole message; on pointer fields it
+//     returns nil if the field is absent
+//   - func: on repeated message fields, generates an UnmarshalProtobuf<Field>Func method
+//     that calls a function for every element instead of building the slice
+//   - intern: on string fields and maps with string keys or values, deduplicates the
+//     decoded strings with an intern.Table, per call or passed to a generated
+//     UnmarshalProtobufIntern(src []byte, t *intern.Table) method
+//   - iter: on repeated message fields, generates a <Type><Field>Iter(src []byte) function
+//     returning an iter.Seq2 that decodes the elements lazily
+//   - merge: on map fields, makes UnmarshalProtobuf add the decoded entries to the existing
+//     ones instead of clearing the map first
+//   - nilempty: on message pointer and []*T message fields, encodes nil messages as empty
+//     ones, which are skipped by default
+//   - nilerror: on message pointer, []*T message and map fields, makes marshal methods panic
+//     with an error wrapping easyprotoerr.ErrNilField for a nil field, or
+//     easyprotoerr.ErrNilElement for nil elements, which are skipped by default
+//   - nonnil: on repeated and map fields, decodes absent fields as empty non-nil values instead
+//     of nil
+//   - prealloc, prealloc=N: on map fields, allocates the decoded map for the number of
+//     entries counted in the message, or for N entries; prealloc=N also pre-grows
+//     repeated fields to a capacity of N elements
+//   - parallel: on repeated message fields, decodes the elements concurrently in a
+//     generated UnmarshalProtobufParallel(src []byte, workers int) method
+//   - redact: omits the field from MarshalProtobufRedacted output, which is generated
+//     for all types of the invocation when any field is redacted
+//   - reuse: on []byte and [][]byte fields, copies decoded bytes into the backing arrays
+//     left by the previous unmarshal instead of aliasing the input buffer
+//   - stringbytes: on []byte and [][]byte fields, decodes them as bytes but declares them
+//     as strings in .proto files and descriptors
+//   - truncate: on int8, int16, uint8, uint16, int and uint values, truncates decoded integers
+//     out of range like a Go conversion instead of returning an error
+//   - unpacked: on repeated scalar fields other than strings and bytes, encodes the elements
+//     as separate values instead of packed, for proto2 peers; both forms are decoded either way
+//   - unscaled: on decimal.Decimal fields, encodes the decimals as messages of their unscaled
+//     value and exponent instead of strings
+//   - uuidstring: on uuid.UUID and [16]byte fields, encodes the UUIDs as strings in the
+//     canonical form instead of 16 bytes
+//   - wrapper: on pointers to scalars, encodes the value as a google.protobuf wrapper message
+//     like Int64Value, for APIs using the wrapper types for optional values
+//
+// Fields tagged protobuf:"-", like mutexes and caches, are neither serialized nor cloned. With
+// -strict-fields, exported fields need either a protobuf tag or protobuf:"-".
+//
+// Generic structs get generic methods. Their fields of type parameters are nested messages,
+// whose type arguments are checked to implement ProtobufMarshaler and ProtobufUnmarshaler at
+// run time.
+//
+// Field numbers and names of deleted fields can be reserved in the doc comment of a type:
+//
+//	//protogen:reserved 3,4,10-15,OldName
+//
+// With -scan, the types with a //protogen:generate directive are generated, and a
+// //protogen:options directive sets the options of a type: value-receiver for marshal methods
+// with value receivers and hot=Field1,Field2 for the fields unmarshal methods check first.
 //
 // # Performance
 //
@@ -86,11 +175,142 @@
 //
 // The protogen command accepts the following flags:
 //
-//	protogen -type=Type1,Type2 [-output=file.go] [-noheader]
-//
-//	-type      Comma-separated struct names (required)
-//	-output    Output file (default: <type>_proto.go or <pkg>_proto.go)
-//	-noheader  Skip pool/interface declarations (for multiple generate calls)
+//	protogen -type=Type1,Type2 [-exclude-type=Type3,...] [-scan] [-service=Service1,...] [-output=file.go] [-split] [-include-tests] [-tags=t1,...] [-buildtags=expr] [-noheader] [-runtime=import/path] [-copy-strings] [-arena] [-mask] [-filter] [-deterministic] [-random] [-quick] [-protoc-types] [-proto-adapter] [-descriptor-set] [-register] [-int32] [-size-breakdown] [-stringer] [-limit] [-observe] [-pool] [-direct] [-tinygo] [-standalone] [-method-prefix=P] [-method-suffix=S] [-value-receivers=Type1,...] [-template=file.tmpl,...] [-emit=easyproto,proto] [-hot=hints.txt] [-profile=cpu.pprof] [-gen-fuzz] [-conformance=T=pb.T,...] [-doc=schema.md] [-graph=dot|mermaid] [-strict-fields] [-force] [-check] [-warn-deprecated] [-lock [-allow-breaking]] [dir ...]
+//
+//	-type            Comma-separated struct names (required unless -scan), or patterns like Event* matching the
+//	                 struct types with protobuf tags
+//	-exclude-type    Comma-separated struct names or patterns not to generate code for, even if
+//	                 -type matches them
+//	-scan            Also generate the struct types with a //protogen:generate directive; a dir/...
+//	                 argument selects every package below dir with such types
+//	-service         Comma-separated interface names to generate HTTP handlers and clients for
+//	                 (see package protohttp)
+//	-output          Output file (default: <type>_proto.go or <pkg>_proto.go), or - to print the
+//	                 generated files to stdout without writing them
+//	-split           Write the code of every type to its own <type>_proto.go file; the header is
+//	                 in the file of the first type
+//	-include-tests   Also parse the _test.go files of the package, for types only used by tests;
+//	                 the output is a _test.go file (default: <type>_proto_test.go)
+//	-tags            Comma-separated build tags selecting the files of the package in addition to
+//	                 the GOOS and GOARCH of the environment, like go build -tags
+//	-buildtags       Build constraint expression added as a //go:build line to the generated files
+//	-noheader        Skip pool/interface declarations; those declared by other generated files of the
+//	                 package are skipped anyway
+//	-runtime         Import path of a package declaring the pool, interfaces and helpers shared by
+//	                 generated files instead of their headers (see package protoruntime)
+//	-copy-strings    Copy decoded strings and bytes instead of aliasing the input buffer
+//	-arena           Generate UnmarshalProtobufArena methods (see package arena)
+//	-mask            Generate <Type>FieldMask types and MarshalProtobufMasked methods
+//	-filter          Generate Filter<Type>Protobuf functions removing fields from encoded messages
+//	-deterministic   Generate MarshalProtobufDeterministic and HashProtobuf methods with map entries sorted by key
+//	-random          Generate FuzzFill(r *rand.Rand) methods setting all fields to random values
+//	-quick           Generate Generate methods making *Type implement testing/quick.Generator; implies -random
+//	-protoc-types    The types are protoc-gen-go structs; read their tags in the protoc-gen-go
+//	                 format and generate the fast path methods next to their proto.Message methods
+//	-proto-adapter   Embed a descriptor of the types and generate ProtoAdapter methods returning
+//	                 proto.Message adapters for protojson and the like (see package protoadapter)
+//	-descriptor-set  Embed a descriptor of the types, register it with protoregistry.GlobalFiles for
+//	                 server reflection and export it as a serialized <Type>FileDescriptorSet
+//	-register        Register the types under <package>.<Type> for lookup by name (see package easyprotoreg)
+//	-int32           Encode int and uint fields without an explicit protobuf type as int32 and uint32,
+//	                 which 32-bit platforms decode without overflow
+//	-size-breakdown  Generate SizeBreakdown methods returning the number of bytes of each field in
+//	                 the encoded message
+//	-stringer        Generate String methods printing the set fields, with redacted fields masked
+//	-limit           Generate MarshalProtobufLimit methods failing with easyprotoerr.ErrTooLarge for
+//	                 messages longer than a byte limit, without encoding them in full
+//	-observe         Report MarshalProtobuf and UnmarshalProtobuf calls to the observer of package
+//	                 protoobserve in builds with the protogen_observe build tag
+//	-pool            Generate Acquire<Type> and Release<Type> functions pooling the types; the
+//	                 elements of []*Type fields are decoded into values acquired from the pool
+//	-direct          Generate SizeProtobuf and AppendProtobuf methods, used by MarshalProtobuf,
+//	                 writing the length prefixes of nested messages without shifting their bytes
+//	-tinygo          Generate code without sync.Pool, unsafe and reflection for TinyGo builds;
+//	                 MarshalProtobuf allocates a Marshaler per call instead of pooling them
+//	-standalone      Declare a minimal protobuf codec and the helpers of the easyproto-gen packages
+//	                 in the generated file, so that it imports only the standard library
+//	-method-prefix   Prefix of the generated method names, to avoid collisions with existing methods
+//	-method-suffix   Replacement of Protobuf in the generated method names, e.g. PB for MarshalPB
+//	-value-receivers Comma-separated types whose marshal methods have value receivers, so that
+//	                 small types stored by value are marshaled without taking their address
+//	-template        Additional text/template files executed after the built-in template; their
+//	                 named templates replace the built-in ones and their output is appended
+//	-emit            Comma-separated backends to run: easyproto (default) for the marshaling code,
+//	                 proto for a proto3 .proto file next to the output file, jsonschema for a
+//	                 JSON Schema of the proto3 JSON mapping of the types next to it and openapi for
+//	                 an OpenAPI 3.1 document of their schemas and the operations of the services
+//	-hot             File listing hot fields as Type.Field lines, hottest first; unmarshal methods
+//	                 check them before switching on the field number
+//	-profile         CPU profile of a program built with the current output file; the fields with
+//	                 the most samples in the unmarshal methods of their type are checked first
+//	-gen-fuzz        Also generate <output>_fuzz_test.go with fuzz tests of the UnmarshalProtobuf methods
+//	-conformance     Type=ProtoType pairs; also generate <output>_conformance_test.go checking
+//	                 wire compatibility with protoc-gen-go types (see package conformance)
+//	-doc             Also write a Markdown document of the fields of the types, with their numbers,
+//	                 wire types and comments, to this file, like schema.md
+//	-graph           Also write a diagram of the message types referencing each other through their
+//	                 fields: dot for <output>.dot in the Graphviz format, mermaid for <output>.mmd
+//	-strict-fields   Fail if an exported field of the types has no protobuf tag; fields are
+//	                 excluded with protobuf:"-"
+//	-force           Generate the methods even if the types already declare methods with the same names
+//	-check           Print a diff and exit with status 1 if the output file is out of date, without writing it
+//	-warn-deprecated Warn about deprecated fields still set in composite literals of the package's tests
+//	-lock            Record the wire schema in protogen.lock and fail on incompatible changes to it
+//	-allow-breaking  With -lock, accept incompatible changes and update protogen.lock
+//
+// Several package directories can be generated in one run, with the types and services qualified
+// with their package names:
+//
+//	protogen -type=orders.Order,billing.Invoice ./orders ./billing
+//
+// The output file is only written when its contents change. Its header records a hash of
+// the package sources, the flags and the protogen version, which lets released builds of
+// protogen skip parsing when nothing changed.
+//
+// The compat subcommand reports wire-incompatible changes between two versions of a package,
+// such as field numbers reused with a different wire type:
+//
+//	protogen compat old_dir new_dir
+//
+// With -lock, the same rules are enforced against the schema recorded in protogen.lock.
+//
+// The tag subcommand adds protobuf tags to the untagged exported fields of existing structs,
+// numbering them after the highest field number of their type:
+//
+//	protogen tag -type=Order,Item [dir]
+//
+// The renumber subcommand changes the field numbers of a type, closing gaps or moving fields,
+// and refuses to move fields that may have old data, or other wire-incompatible changes,
+// unless forced:
+//
+//	protogen renumber -type=Order [-compact] [-move=Field=N,...] [-force] [-n] [dir]
+//
+// The inspect subcommand prints the fields of an encoded message of a type, with their offsets,
+// numbers and decoded values, in the text format of protoscope:
+//
+//	protogen inspect -type=Order [-dir=dir] payload.bin
+//
+// The size subcommand prints the bytes taken by each field of a payload, largest first. With
+// -size-breakdown, SizeBreakdown methods return them for the values of the types:
+//
+//	protogen size -type=Order [-dir=dir] payload.bin
+//
+// The protogen binary doubles as a go vet tool running the protogenvet analyzer, which
+// reports invalid protobuf tags and generated code that is out of date:
+//
+//	go vet -vettool=$(which protogen) ./...
+//
+// # Library API
+//
+// The generator can also run in-process: [Generate] takes a [Config] with the same settings
+// as the CLI flags and returns the generated files without writing them. Invalid declarations
+// are reported as [*Error] values locating the type and field; for methods that the types
+// already declare with the names of generated ones, they wrap [ErrMethodExists]. [Tag] adds
+// protobuf tags to existing structs like the tag subcommand, and [Renumber] changes their field
+// numbers like the renumber subcommand. [Inspect] decodes a payload like the inspect
+// subcommand, and [SizeBreakdown] reports its field sizes like the size subcommand. The vet
+// analyzer is available as [Analyzer]. Implementations of [Backend] emit additional files from
+// the parsed types.
 //
 // [easyproto]: https://github.com/VictoriaMetrics/easyproto
 package easyprotogen
@@ -0,0 +1,133 @@
+// Package easyprotoerr defines the errors returned by code generated with protogen.
+//
+// Errors returned by generated UnmarshalProtobuf, MergeFromProtobuf and UnmarshalProtobufArena
+// methods are *Error values describing where decoding failed:
+//
+//	Message.Sender(User).Email: cannot read string: wire type mismatch at offset 42
+//
+// Use errors.As to access the path and the offset:
+//
+//	var e *easyprotoerr.Error
+//	if errors.As(err, &e) {
+//	    log.Printf("bad field %s at byte %d", e.Path, e.Offset)
+//	}
+//
+// The underlying errors wrap the sentinel errors defined by this package, so callers
+// can branch with errors.Is instead of matching error strings:
+//
+//	if errors.Is(err, easyprotoerr.ErrTruncated) {
+//	    // wait for more data
+//	}
+package easyprotoerr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	// ErrTruncated means the message ends in the middle of a field.
+	ErrTruncated = errors.New("truncated message")
+
+	// ErrInvalidWireType means a field tag contains a wire type not supported by protobuf.
+	ErrInvalidWireType = errors.New("invalid wire type")
+
+	// ErrInvalidFieldNumber means a field tag contains a field number out of the uint32 range.
+	ErrInvalidFieldNumber = errors.New("invalid field number")
+
+	// ErrWireTypeMismatch means a field is encoded with a wire type other than the one of its declared type.
+	ErrWireTypeMismatch = errors.New("wire type mismatch")
+
+	// ErrUnknownField means a field number isn't defined by the message type.
+	ErrUnknownField = errors.New("unknown field")
+
+	// ErrOutOfRange means a decoded integer doesn't fit into the Go type of its field, like
+	// 300 in an int8 field.
+	ErrOutOfRange = errors.New("value out of range")
+
+	// ErrInvalidValue means a decoded value isn't valid for the Go type of its field, like a
+	// UUID of 15 bytes.
+	ErrInvalidValue = errors.New("invalid value")
+
+	// ErrTooLarge means a message is longer than the limit passed to a MarshalProtobufLimit method.
+	ErrTooLarge = errors.New("message too large")
+
+	// ErrNilElement means a []*T field with the nilerror option holds a nil element, which
+	// generated marshal methods panic with since they don't return errors.
+	ErrNilElement = errors.New("nil element")
+
+	// ErrNilField means a message pointer or map field with the nilerror option is nil, which
+	// generated marshal methods panic with since they don't return errors.
+	ErrNilField = errors.New("nil field")
+)
+
+// Error is an error at a specific field of a protobuf message.
+type Error struct {
+	// Path identifies the field, starting with the type of the unmarshaled message.
+	// Nested messages are separated by dots, with their type in parentheses,
+	// e.g. "Message.Sender(User).Email". Path is the type name alone for errors
+	// not attributable to a field.
+	Path string
+
+	// Offset is the byte offset in the unmarshaled message where the field starts.
+	Offset int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s at offset %d", e.Path, e.Err, e.Offset)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Field returns an Error for field of typeName starting offset bytes into the message.
+// The field is empty for errors not attributable to a field.
+func Field(typeName, field string, offset int, err error) error {
+	path := typeName
+	if field != "" {
+		path += "." + field
+	}
+	return &Error{Path: path, Offset: offset, Err: err}
+}
+
+// Nested returns an Error for err returned when unmarshaling the nested message stored
+// in field of typeName, whose data starts offset bytes into the message.
+//
+// If err is an *Error, its path and offset are extended to be relative to the outer message.
+func Nested(typeName, field string, offset int, err error) error {
+	path := typeName + "." + field
+	var e *Error
+	if !errors.As(err, &e) {
+		return &Error{Path: path, Offset: offset, Err: err}
+	}
+	nestedType, nestedPath, _ := strings.Cut(e.Path, ".")
+	path += "(" + nestedType + ")"
+	if nestedPath != "" {
+		path += "." + nestedPath
+	}
+	return &Error{Path: path, Offset: offset + e.Offset, Err: e.Err}
+}
+
+// NextField returns err returned by easyproto FieldContext.NextField(src) wrapped with the
+// sentinel error describing why the field at the start of src couldn't be read.
+func NextField(src []byte, err error) error {
+	sentinel := ErrTruncated
+	if tag, n := binary.Uvarint(src); n > 0 {
+		switch wireType := tag & 0x07; {
+		case tag>>3 > math.MaxUint32:
+			sentinel = ErrInvalidFieldNumber
+		case wireType != 0 && wireType != 1 && wireType != 2 && wireType != 5:
+			sentinel = ErrInvalidWireType
+		}
+	}
+	return fmt.Errorf("cannot read field: %w: %w", sentinel, err)
+}
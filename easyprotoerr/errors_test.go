@@ -0,0 +1,77 @@
+package easyprotoerr
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestField(t *testing.T) {
+	err := Field("User", "Email", 3, errors.New("cannot read string"))
+	if got, want := err.Error(), "User.Email: cannot read string at offset 3"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	err = Field("User", "", 7, io.ErrUnexpectedEOF)
+	if got, want := err.Error(), "User: unexpected EOF at offset 7"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("expected Field to wrap err")
+	}
+}
+
+func TestNested(t *testing.T) {
+	inner := Field("User", "Email", 2, errors.New("cannot read string"))
+	err := Nested("Chat", "Last", 10, Nested("Message", "Sender", 30, inner))
+	if got, want := err.Error(), "Chat.Last(Message).Sender(User).Email: cannot read string at offset 42"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	var e *Error
+	if !errors.As(err, &e) || e.Offset != 42 {
+		t.Fatalf("expected *Error with offset 42, got %#v", err)
+	}
+
+	err = Nested("Message", "Sender", 5, Field("User", "", 1, io.ErrUnexpectedEOF))
+	if got, want := err.Error(), "Message.Sender(User): unexpected EOF at offset 6"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestNestedForeignError(t *testing.T) {
+	custom := errors.New("invalid UUID")
+	err := Nested("Message", "ID", 4, custom)
+	if got, want := err.Error(), "Message.ID: invalid UUID at offset 4"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !errors.Is(err, custom) {
+		t.Fatal("expected Nested to wrap err")
+	}
+}
+
+func TestNextField(t *testing.T) {
+	cause := errors.New("cause")
+	for _, tc := range []struct {
+		name string
+		src  []byte
+		want error
+	}{
+		{"empty", nil, ErrTruncated},
+		{"truncated tag", []byte{0x80}, ErrTruncated},
+		{"truncated length", []byte{0x0a}, ErrTruncated},
+		{"truncated data", []byte{0x0a, 0x05, 'a'}, ErrTruncated},
+		{"truncated fixed64", []byte{0x09, 1, 2}, ErrTruncated},
+		{"group", []byte{0x0b}, ErrInvalidWireType},
+		{"wire type 7", []byte{0x0f, 0x00}, ErrInvalidWireType},
+		{"field number", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, ErrInvalidFieldNumber},
+	} {
+		err := NextField(tc.src, cause)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+		if !errors.Is(err, cause) {
+			t.Errorf("%s: expected the cause to be wrapped", tc.name)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package easyprotoreg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// TypeURLPrefix is the prefix of the type URLs written by MarshalAny, the one used by the
+// protobuf runtimes.
+const TypeURLPrefix = "type.googleapis.com/"
+
+// ErrMissingTypeURL means an Any message has no type URL.
+var ErrMissingTypeURL = errors.New("missing type URL")
+
+// Message is implemented by types generated by protogen.
+type Message interface {
+	Unmarshaler
+	MarshalProtobuf(dst []byte) []byte
+}
+
+var mp easyproto.MarshalerPool
+
+// MarshalAny returns m wrapped in a google.protobuf.Any message: its type URL is the name m is
+// registered under prefixed by TypeURLPrefix, and its value the encoding of m.
+// The error wraps ErrNotRegistered if the type of m is not registered.
+func MarshalAny(m Message) ([]byte, error) {
+	name, ok := Name(m)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotRegistered, m)
+	}
+	mm := mp.Get()
+	w := mm.MessageMarshaler()
+	w.AppendString(1, TypeURLPrefix+name)
+	w.AppendBytes(2, m.MarshalProtobuf(nil))
+	data := mm.Marshal(nil)
+	mp.Put(mm)
+	return data, nil
+}
+
+// UnmarshalAny decodes a google.protobuf.Any message into a new message of the type registered
+// under the last path segment of its type URL, so URLs with any prefix are accepted.
+// The error wraps ErrNotRegistered if no type is registered under that name.
+func UnmarshalAny(data []byte) (Unmarshaler, error) {
+	var typeURL string
+	var value []byte
+	var fc easyproto.FieldContext
+	for len(data) > 0 {
+		var err error
+		data, err = fc.NextField(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read Any field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			v, ok := fc.String()
+			if !ok {
+				return nil, fmt.Errorf("cannot read Any type URL")
+			}
+			typeURL = v
+		case 2:
+			v, ok := fc.Bytes()
+			if !ok {
+				return nil, fmt.Errorf("cannot read Any value")
+			}
+			value = v
+		}
+	}
+	if typeURL == "" {
+		return nil, ErrMissingTypeURL
+	}
+	m, err := New(typeURL[strings.LastIndexByte(typeURL, '/')+1:])
+	if err != nil {
+		return nil, err
+	}
+	if err := m.UnmarshalProtobuf(value); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal %s: %w", typeURL, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,97 @@
+// Package easyprotoreg maps fully-qualified message names to types generated by protogen,
+// to decode messages whose type is only known at runtime, e.g. from an envelope header.
+//
+// Types generated with protogen -register add themselves in an init function under the name
+// <package>.<Type>, where package is the Go package name:
+//
+//	m, err := easyprotoreg.New(header.Type) // e.g. "events.UserCreated"
+//	if err != nil {
+//	    return err
+//	}
+//	if err := m.UnmarshalProtobuf(payload); err != nil {
+//	    return err
+//	}
+//	switch m := m.(type) {
+//	case *events.UserCreated:
+//	    // ...
+//	}
+//
+// MarshalAny and UnmarshalAny use the registered names as type URLs of google.protobuf.Any
+// envelopes, to carry heterogeneous messages:
+//
+//	data, err := easyprotoreg.MarshalAny(&events.UserCreated{ID: 1})
+//	// ...
+//	m, err := easyprotoreg.UnmarshalAny(data) // *events.UserCreated
+package easyprotoreg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// ErrNotRegistered means no type is registered under the requested name.
+var ErrNotRegistered = errors.New("type not registered")
+
+// Unmarshaler is implemented by types generated by protogen.
+type Unmarshaler interface {
+	UnmarshalProtobuf(src []byte) error
+}
+
+var (
+	mu     sync.RWMutex
+	byName = map[string]func() Unmarshaler{}
+	byType = map[reflect.Type]string{}
+)
+
+// Register registers the type of the values returned by newFunc under name.
+//
+// It panics if name or the type is already registered: generated init functions register
+// each type once, so a duplicate means two packages claim the same name.
+func Register(name string, newFunc func() Unmarshaler) {
+	t := reflect.TypeOf(newFunc())
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := byName[name]; ok {
+		panic(fmt.Sprintf("easyprotoreg: %s is already registered", name))
+	}
+	if other, ok := byType[t]; ok {
+		panic(fmt.Sprintf("easyprotoreg: %s is already registered as %s", t, other))
+	}
+	byName[name] = newFunc
+	byType[t] = name
+}
+
+// New returns a new zero message of the type registered under name.
+// The error wraps ErrNotRegistered if there is no such type.
+func New(name string) (Unmarshaler, error) {
+	mu.RLock()
+	newFunc, ok := byName[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotRegistered, name)
+	}
+	return newFunc(), nil
+}
+
+// Name returns the name the type of m is registered under, and whether it is registered.
+func Name(m any) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	name, ok := byType[reflect.TypeOf(m)]
+	return name, ok
+}
+
+// Names returns the registered names in sorted order.
+func Names() []string {
+	mu.RLock()
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	mu.RUnlock()
+	slices.Sort(names)
+	return names
+}
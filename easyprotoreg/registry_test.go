@@ -0,0 +1,169 @@
+package easyprotoreg
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type ping struct{ Seq uint64 }
+
+func (p *ping) MarshalProtobuf(dst []byte) []byte {
+	m := mp.Get()
+	m.MessageMarshaler().AppendUint64(1, p.Seq)
+	dst = m.Marshal(dst)
+	mp.Put(m)
+	return dst
+}
+
+func (p *ping) UnmarshalProtobuf(src []byte) (err error) {
+	p.Seq = 0
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		if src, err = fc.NextField(src); err != nil {
+			return err
+		}
+		if fc.FieldNum == 1 {
+			v, ok := fc.Uint64()
+			if !ok {
+				return errors.New("cannot read seq")
+			}
+			p.Seq = v
+		}
+	}
+	return nil
+}
+
+type pong struct{ ping }
+
+func init() {
+	Register("test.Ping", func() Unmarshaler { return &ping{} })
+	Register("test.Pong", func() Unmarshaler { return &pong{} })
+}
+
+func TestNew(t *testing.T) {
+	m, err := New("test.Ping")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	p, ok := m.(*ping)
+	if !ok {
+		t.Fatalf("New returned %T, want *ping", m)
+	}
+	if p.Seq != 0 {
+		t.Errorf("New returned a non-zero message %+v", p)
+	}
+	if other, _ := New("test.Ping"); other == m {
+		t.Error("New returned the same message twice")
+	}
+}
+
+func TestNewNotRegistered(t *testing.T) {
+	_, err := New("test.Missing")
+	if !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("expected ErrNotRegistered, got %v", err)
+	}
+	if want := `type not registered: "test.Missing"`; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestName(t *testing.T) {
+	if name, ok := Name(&pong{}); !ok || name != "test.Pong" {
+		t.Errorf("Name(*pong) = %q, %t; want test.Pong, true", name, ok)
+	}
+	if _, ok := Name(ping{}); ok {
+		t.Error("Name found unregistered type ping")
+	}
+}
+
+func TestNames(t *testing.T) {
+	if names := Names(); !slices.Equal(names, []string{"test.Ping", "test.Pong"}) {
+		t.Errorf("Names = %q", names)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		newFunc func() Unmarshaler
+	}{
+		{"test.Ping", func() Unmarshaler { return &pong{} }},
+		{"test.Ping2", func() Unmarshaler { return &ping{} }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", tt.name)
+				}
+			}()
+			Register(tt.name, tt.newFunc)
+		}()
+	}
+}
+
+func TestMarshalAny(t *testing.T) {
+	data, err := MarshalAny(&ping{Seq: 7})
+	if err != nil {
+		t.Fatalf("MarshalAny failed: %v", err)
+	}
+	var a anypb.Any
+	if err := proto.Unmarshal(data, &a); err != nil {
+		t.Fatalf("cannot unmarshal google.protobuf.Any: %v", err)
+	}
+	if a.TypeUrl != "type.googleapis.com/test.Ping" {
+		t.Errorf("type URL = %q", a.TypeUrl)
+	}
+
+	m, err := UnmarshalAny(data)
+	if err != nil {
+		t.Fatalf("UnmarshalAny failed: %v", err)
+	}
+	if p, ok := m.(*ping); !ok || p.Seq != 7 {
+		t.Errorf("UnmarshalAny = %#v, want &ping{Seq: 7}", m)
+	}
+}
+
+func TestUnmarshalAnyFromAnypb(t *testing.T) {
+	data, err := proto.Marshal(&anypb.Any{TypeUrl: "example.com/types/test.Ping", Value: (&ping{Seq: 3}).MarshalProtobuf(nil)})
+	if err != nil {
+		t.Fatalf("cannot marshal google.protobuf.Any: %v", err)
+	}
+	m, err := UnmarshalAny(data)
+	if err != nil {
+		t.Fatalf("UnmarshalAny failed: %v", err)
+	}
+	if p, ok := m.(*ping); !ok || p.Seq != 3 {
+		t.Errorf("UnmarshalAny = %#v, want &ping{Seq: 3}", m)
+	}
+}
+
+func TestAnyErrors(t *testing.T) {
+	if _, err := MarshalAny(&unregistered{}); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("MarshalAny of an unregistered type: expected ErrNotRegistered, got %v", err)
+	}
+	for _, tt := range []struct {
+		a    *anypb.Any
+		want error
+	}{
+		{&anypb.Any{TypeUrl: "type.googleapis.com/test.Missing"}, ErrNotRegistered},
+		{&anypb.Any{Value: []byte{8, 1}}, ErrMissingTypeURL},
+	} {
+		data, err := proto.Marshal(tt.a)
+		if err != nil {
+			t.Fatalf("cannot marshal google.protobuf.Any: %v", err)
+		}
+		if _, err := UnmarshalAny(data); !errors.Is(err, tt.want) {
+			t.Errorf("UnmarshalAny(%v): expected %v, got %v", tt.a, tt.want, err)
+		}
+	}
+	if _, err := UnmarshalAny([]byte{0x0a, 5}); err == nil {
+		t.Error("UnmarshalAny accepted a truncated message")
+	}
+}
+
+type unregistered struct{ ping }
@@ -1,11 +1,16 @@
 // Code generated by protogen. DO NOT EDIT.
+// Source hash: 570ad88f6a5b506a82270af651f926979100ca94ff94f12d7c2cbdb6e83d759d
 
 package example
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/VictoriaMetrics/easyproto"
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+	"github.com/aryehlev/easyproto-gen/groups"
 )
 
 var _mp easyproto.MarshalerPool
@@ -22,69 +27,115 @@ type ProtobufUnmarshaler interface {
 	UnmarshalProtobuf(src []byte) error
 }
 
+// cloneProtobuf copies src to dst by marshaling and unmarshaling it.
+// It is used for custom types, which have no CloneProtobufInto method.
+func cloneProtobuf(dst ProtobufUnmarshaler, src ProtobufMarshaler) {
+	m := _mp.Get()
+	src.MarshalProtobufTo(m.MessageMarshaler())
+	data := m.Marshal(nil)
+	_mp.Put(m)
+	if err := dst.UnmarshalProtobuf(data); err != nil {
+		panic(fmt.Errorf("BUG: cannot unmarshal %T marshaled by %T: %w", dst, src, err))
+	}
+}
+
 // MarshalProtobuf marshals Message into protobuf message, appends this message to dst and returns the result.
 func (x *Message) MarshalProtobuf(dst []byte) []byte {
 	m := _mp.Get()
+	dst = x.MarshalProtobufWith(m, dst)
+	_mp.Put(m)
+	return dst
+}
+
+// MarshalProtobufWith marshals Message like MarshalProtobuf, using m instead of a Marshaler
+// of the package pool, so that callers marshaling many messages can keep a Marshaler per goroutine.
+// m is reset first, so it needs no Reset between calls; it must not be used concurrently.
+func (x *Message) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {
+	m.Reset()
 	x.MarshalProtobufTo(m.MessageMarshaler())
 	dst = m.Marshal(dst)
-	_mp.Put(m)
 	return dst
 }
 
 // MarshalProtobufTo marshals Message fields to the given MessageMarshaler.
 // Implements ProtobufMarshaler interface.
 func (x *Message) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
-	mm.AppendInt64(1, x.ID)
-	mm.AppendString(2, x.Text)
+	if x.ID != 0 {
+		mm.AppendInt64(1, x.ID)
+	}
+	if len(x.Text) > 0 {
+		mm.AppendString(2, x.Text)
+	}
 	if x.Sender != nil {
 		x.Sender.MarshalProtobufTo(mm.AppendMessage(3))
 	}
-	mm.AppendInt64(4, x.Timestamp)
+	if x.Timestamp != 0 {
+		mm.AppendInt64(4, x.Timestamp)
+	}
 }
 
 // UnmarshalProtobuf unmarshals Message from protobuf message at src.
-func (x *Message) UnmarshalProtobuf(src []byte) (err error) {
+//
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Message) UnmarshalProtobuf(src []byte) error {
 	// Set default values
 	x.ID = *new(int64)
 	x.Text = *new(string)
 	x.Sender = nil
 	x.Timestamp = *new(int64)
+	return x.MergeFromProtobuf(src)
+}
 
+// MergeFromProtobuf merges protobuf message at src into Message.
+//
+// Scalar fields present in src overwrite the existing values, repeated and map fields
+// are appended to and nested messages are merged recursively.
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Message) MergeFromProtobuf(src []byte) (err error) {
 	// Parse message
+	n := len(src)
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
-		src, err = fc.NextField(src)
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
 		if err != nil {
-			return fmt.Errorf("cannot read next field in Message: %w", err)
+			// Skip the groups of proto2 messages, which easyproto can't read, like other
+			// unknown fields.
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return easyprotoerr.Field("Message", "", offset, easyprotoerr.NextField(src, err))
 		}
+		src = tail
 		switch fc.FieldNum {
 		case 1:
 			v, ok := fc.Int64()
 			if !ok {
-				return fmt.Errorf("cannot read Message.ID")
+				return easyprotoerr.Field("Message", "ID", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.ID = v
 		case 2:
 			v, ok := fc.String()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Text")
+				return easyprotoerr.Field("Message", "Text", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Text = v
 		case 3:
 			data, ok := fc.MessageData()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Sender data")
+				return easyprotoerr.Field("Message", "Sender", offset, fmt.Errorf("cannot read message: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			if x.Sender == nil {
 				x.Sender = &User{}
 			}
-			if err := x.Sender.UnmarshalProtobuf(data); err != nil {
-				return fmt.Errorf("cannot unmarshal Message.Sender: %w", err)
+			if err := x.Sender.MergeFromProtobuf(data); err != nil {
+				return easyprotoerr.Nested("Message", "Sender", n-len(src)-len(data), err)
 			}
 		case 4:
 			v, ok := fc.Int64()
 			if !ok {
-				return fmt.Errorf("cannot read Message.Timestamp")
+				return easyprotoerr.Field("Message", "Timestamp", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Timestamp = v
 		}
@@ -92,49 +143,304 @@ func (x *Message) UnmarshalProtobuf(src []byte) (err error) {
 	return nil
 }
 
+// CloneProtobuf returns a deep copy of Message, or nil if x is nil.
+func (x *Message) CloneProtobuf() *Message {
+	if x == nil {
+		return nil
+	}
+	c := &Message{}
+	x.CloneProtobufInto(c)
+	return c
+}
+
+// CloneProtobufInto deep copies Message into dst, overwriting all of its fields.
+//
+// Slices, maps and nested messages of protobuf fields are copied, so x and dst share no memory
+// through them. Fields without protobuf tags are copied shallowly.
+// Strings are copied as well, so dst doesn't alias the buffer x was unmarshaled from.
+func (x *Message) CloneProtobufInto(dst *Message) {
+	*dst = *x
+	dst.Text = strings.Clone(x.Text)
+	dst.Sender = x.Sender.CloneProtobuf()
+}
+
+// ExtractMessageID returns Message.ID from protobuf message at src
+// without unmarshaling the other fields. The zero value is returned if src doesn't contain the field,
+// and its last value if src contains it several times, like UnmarshalProtobuf does.
+func ExtractMessageID(src []byte) (int64, error) {
+	// Scan all fields, since the last occurrence of a field wins like in UnmarshalProtobuf.
+	var v int64
+	n := len(src)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
+		if err != nil {
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return *new(int64), easyprotoerr.Field("Message", "", offset, easyprotoerr.NextField(src, err))
+		}
+		src = tail
+		if fc.FieldNum != 1 {
+			continue
+		}
+		var ok bool
+		if v, ok = fc.Int64(); !ok {
+			return *new(int64), easyprotoerr.Field("Message", "ID", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+		}
+	}
+	return v, nil
+}
+
 // MarshalProtobuf marshals User into protobuf message, appends this message to dst and returns the result.
 func (x *User) MarshalProtobuf(dst []byte) []byte {
 	m := _mp.Get()
+	dst = x.MarshalProtobufWith(m, dst)
+	_mp.Put(m)
+	return dst
+}
+
+// MarshalProtobufWith marshals User like MarshalProtobuf, using m instead of a Marshaler
+// of the package pool, so that callers marshaling many messages can keep a Marshaler per goroutine.
+// m is reset first, so it needs no Reset between calls; it must not be used concurrently.
+func (x *User) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {
+	m.Reset()
 	x.MarshalProtobufTo(m.MessageMarshaler())
 	dst = m.Marshal(dst)
-	_mp.Put(m)
 	return dst
 }
 
 // MarshalProtobufTo marshals User fields to the given MessageMarshaler.
 // Implements ProtobufMarshaler interface.
 func (x *User) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
-	mm.AppendInt64(1, x.ID)
-	mm.AppendString(2, x.Name)
+	if x.ID != 0 {
+		mm.AppendInt64(1, x.ID)
+	}
+	if len(x.Name) > 0 {
+		mm.AppendString(2, x.Name)
+	}
 }
 
 // UnmarshalProtobuf unmarshals User from protobuf message at src.
-func (x *User) UnmarshalProtobuf(src []byte) (err error) {
+//
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *User) UnmarshalProtobuf(src []byte) error {
 	// Set default values
 	x.ID = *new(int64)
 	x.Name = *new(string)
+	return x.MergeFromProtobuf(src)
+}
 
+// MergeFromProtobuf merges protobuf message at src into User.
+//
+// Scalar fields present in src overwrite the existing values, repeated and map fields
+// are appended to and nested messages are merged recursively.
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *User) MergeFromProtobuf(src []byte) (err error) {
 	// Parse message
+	n := len(src)
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
-		src, err = fc.NextField(src)
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
 		if err != nil {
-			return fmt.Errorf("cannot read next field in User: %w", err)
+			// Skip the groups of proto2 messages, which easyproto can't read, like other
+			// unknown fields.
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return easyprotoerr.Field("User", "", offset, easyprotoerr.NextField(src, err))
 		}
+		src = tail
 		switch fc.FieldNum {
 		case 1:
 			v, ok := fc.Int64()
 			if !ok {
-				return fmt.Errorf("cannot read User.ID")
+				return easyprotoerr.Field("User", "ID", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.ID = v
 		case 2:
 			v, ok := fc.String()
 			if !ok {
-				return fmt.Errorf("cannot read User.Name")
+				return easyprotoerr.Field("User", "Name", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))
 			}
 			x.Name = v
 		}
 	}
 	return nil
 }
+
+// CloneProtobuf returns a deep copy of User, or nil if x is nil.
+func (x *User) CloneProtobuf() *User {
+	if x == nil {
+		return nil
+	}
+	c := &User{}
+	x.CloneProtobufInto(c)
+	return c
+}
+
+// CloneProtobufInto deep copies User into dst, overwriting all of its fields.
+//
+// Slices, maps and nested messages of protobuf fields are copied, so x and dst share no memory
+// through them. Fields without protobuf tags are copied shallowly.
+// Strings are copied as well, so dst doesn't alias the buffer x was unmarshaled from.
+func (x *User) CloneProtobufInto(dst *User) {
+	*dst = *x
+	dst.Name = strings.Clone(x.Name)
+}
+
+// MarshalProtobuf marshals Stats into protobuf message, appends this message to dst and returns the result.
+func (x *Stats) MarshalProtobuf(dst []byte) []byte {
+	m := _mp.Get()
+	dst = x.MarshalProtobufWith(m, dst)
+	_mp.Put(m)
+	return dst
+}
+
+// MarshalProtobufWith marshals Stats like MarshalProtobuf, using m instead of a Marshaler
+// of the package pool, so that callers marshaling many messages can keep a Marshaler per goroutine.
+// m is reset first, so it needs no Reset between calls; it must not be used concurrently.
+func (x *Stats) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {
+	m.Reset()
+	x.MarshalProtobufTo(m.MessageMarshaler())
+	dst = m.Marshal(dst)
+	return dst
+}
+
+// MarshalProtobufTo marshals Stats fields to the given MessageMarshaler.
+// Implements ProtobufMarshaler interface.
+func (x *Stats) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
+	if x.Counts != nil && len(x.Counts) == 0 {
+		mm.AppendBytes(1, nil)
+	}
+	if len(x.Counts) > 0 {
+		mm.AppendInt64s(1, x.Counts)
+	}
+	for k, v := range x.Totals {
+		mm2 := mm.AppendMessage(2)
+		mm2.AppendString(1, k)
+		mm2.AppendInt64(2, v)
+	}
+}
+
+// UnmarshalProtobuf unmarshals Stats from protobuf message at src.
+//
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Stats) UnmarshalProtobuf(src []byte) error {
+	// Set default values
+	x.Counts = nil
+	for k := range x.Totals {
+		delete(x.Totals, k)
+	}
+	return x.MergeFromProtobuf(src)
+}
+
+// MergeFromProtobuf merges protobuf message at src into Stats.
+//
+// Scalar fields present in src overwrite the existing values, repeated and map fields
+// are appended to and nested messages are merged recursively.
+// Decoded strings and bytes alias src, so they are valid only while src is alive and unmodified.
+func (x *Stats) MergeFromProtobuf(src []byte) (err error) {
+	// Parse message
+	n := len(src)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		offset := n - len(src)
+		tail, err := fc.NextField(src)
+		if err != nil {
+			// Skip the groups of proto2 messages, which easyproto can't read, like other
+			// unknown fields.
+			if tail, ok := groups.Skip(src); ok {
+				src = tail
+				continue
+			}
+			return easyprotoerr.Field("Stats", "", offset, easyprotoerr.NextField(src, err))
+		}
+		src = tail
+		switch fc.FieldNum {
+		case 1:
+			var ok bool
+			x.Counts, ok = fc.UnpackInt64s(x.Counts)
+			if !ok {
+				return easyprotoerr.Field("Stats", "Counts", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			if x.Counts == nil {
+				x.Counts = []int64{}
+			}
+		case 2:
+			data, ok := fc.MessageData()
+			if !ok {
+				return easyprotoerr.Field("Stats", "Totals", offset, fmt.Errorf("cannot read map entry: %w", easyprotoerr.ErrWireTypeMismatch))
+			}
+			// Missing keys and values are zero values, or empty messages, and the key and the value
+			// may come in either order.
+			var mk string
+			var mv int64
+			var fc2 easyproto.FieldContext
+			for len(data) > 0 {
+				rest, err := fc2.NextField(data)
+				if err != nil {
+					if rest, ok := groups.Skip(data); ok {
+						data = rest
+						continue
+					}
+					return easyprotoerr.Field("Stats", "Totals", offset, fmt.Errorf("cannot read map entry: %w", easyprotoerr.NextField(data, err)))
+				}
+				data = rest
+				switch fc2.FieldNum {
+				case 1:
+					kv, ok := fc2.String()
+					if !ok {
+						return easyprotoerr.Field("Stats", "Totals", offset, fmt.Errorf("cannot read map key: %w", easyprotoerr.ErrWireTypeMismatch))
+					}
+					mk = kv
+				case 2:
+					vv, ok := fc2.Int64()
+					if !ok {
+						return easyprotoerr.Field("Stats", "Totals", offset, fmt.Errorf("cannot read map value: %w", easyprotoerr.ErrWireTypeMismatch))
+					}
+					mv = vv
+				}
+			}
+			if x.Totals == nil {
+				x.Totals = make(map[string]int64)
+			}
+			x.Totals[mk] = mv
+		}
+	}
+	if x.Totals == nil {
+		x.Totals = map[string]int64{}
+	}
+	return nil
+}
+
+// CloneProtobuf returns a deep copy of Stats, or nil if x is nil.
+func (x *Stats) CloneProtobuf() *Stats {
+	if x == nil {
+		return nil
+	}
+	c := &Stats{}
+	x.CloneProtobufInto(c)
+	return c
+}
+
+// CloneProtobufInto deep copies Stats into dst, overwriting all of its fields.
+//
+// Slices, maps and nested messages of protobuf fields are copied, so x and dst share no memory
+// through them. Fields without protobuf tags are copied shallowly.
+// Strings are copied as well, so dst doesn't alias the buffer x was unmarshaled from.
+func (x *Stats) CloneProtobufInto(dst *Stats) {
+	*dst = *x
+	dst.Counts = slices.Clone(x.Counts)
+	if x.Totals != nil {
+		dst.Totals = make(map[string]int64, len(x.Totals))
+		for k, v := range x.Totals {
+			k = strings.Clone(k)
+			dst.Totals[k] = v
+		}
+	}
+}
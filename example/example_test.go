@@ -1,9 +1,14 @@
 package example_test
 
 import (
+	"errors"
 	"fmt"
+	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
 	"github.com/aryehlev/easyproto-gen/example"
 )
 
@@ -58,6 +63,22 @@ func ExampleMessage_MarshalProtobuf() {
 	// Encoded to pre-allocated buffer: 16 bytes
 }
 
+func ExampleMessage_MarshalProtobufWith() {
+	// A worker marshaling many messages keeps its own Marshaler instead of sharing the package pool
+	var m easyproto.Marshaler
+	var buf []byte
+	for i := range 3 {
+		msg := &example.Message{ID: int64(i + 1), Text: "Test message"}
+		buf = msg.MarshalProtobufWith(&m, buf[:0])
+		fmt.Printf("Message %d: %d bytes\n", msg.ID, len(buf))
+	}
+
+	// Output:
+	// Message 1: 16 bytes
+	// Message 2: 16 bytes
+	// Message 3: 16 bytes
+}
+
 func ExampleMessage_UnmarshalProtobuf() {
 	// First, create and marshal a message
 	original := &example.Message{
@@ -85,4 +106,66 @@ func ExampleMessage_UnmarshalProtobuf() {
 	// Message ID: 999
 	// Message Text: Roundtrip test
 	// Has Sender: true
-}
\ No newline at end of file
+}
+
+func ExampleExtractMessageID() {
+	data := (&example.Message{ID: 7, Text: "routed by ID only"}).MarshalProtobuf(nil)
+
+	// ExtractMessageID scans data for field 1 without decoding the rest of the message
+	id, err := example.ExtractMessageID(data)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("ID: %d\n", id)
+
+	// Output:
+	// ID: 7
+}
+
+func TestExtractMessageID_Duplicate(t *testing.T) {
+	// Concatenated messages are merged, so the ID of the second one wins.
+	data := (&example.Message{ID: 1, Text: "first"}).MarshalProtobuf(nil)
+	data = (&example.Message{ID: 2}).MarshalProtobuf(data)
+	var msg example.Message
+	if err := msg.UnmarshalProtobuf(data); err != nil || msg.ID != 2 {
+		t.Fatalf("UnmarshalProtobuf = %d, %v; want 2", msg.ID, err)
+	}
+	if id, err := example.ExtractMessageID(data); err != nil || id != 2 {
+		t.Errorf("ExtractMessageID = %d, %v; want the last value 2", id, err)
+	}
+
+	_, err := example.ExtractMessageID(data[:len(data)-1])
+	var e *easyprotoerr.Error
+	if !errors.Is(err, easyprotoerr.ErrTruncated) || !errors.As(err, &e) || e.Path != "Message" {
+		t.Errorf("expected an *easyprotoerr.Error wrapping ErrTruncated, got %v", err)
+	}
+}
+
+func TestStats_UnmarshalReused(t *testing.T) {
+	var stats example.Stats
+	if err := stats.UnmarshalProtobuf((&example.Stats{Counts: []int64{1, 2}, Totals: map[string]int64{"a": 1}}).MarshalProtobuf(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// An absent emitempty field decodes as nil into a struct decoded before, and stays absent.
+	if err := stats.UnmarshalProtobuf(nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Counts != nil {
+		t.Fatalf("Counts = %#v, want nil", stats.Counts)
+	}
+	if len(stats.Totals) != 0 || stats.Totals == nil {
+		t.Fatalf("Totals = %#v, want an empty map", stats.Totals)
+	}
+	if data := stats.MarshalProtobuf(nil); len(data) != 0 {
+		t.Fatalf("marshaled %x, want no fields", data)
+	}
+
+	// An empty one still decodes as empty.
+	if err := stats.UnmarshalProtobuf((&example.Stats{Counts: []int64{}}).MarshalProtobuf(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Counts == nil || len(stats.Counts) != 0 {
+		t.Fatalf("Counts = %#v, want an empty slice", stats.Counts)
+	}
+}
@@ -1,10 +1,10 @@
 package example
 
-//go:generate go run ../cmd/protogen -type=Message,User
+//go:generate go run ../cmd/protogen -type=Message,User,Stats
 
 // Message represents a chat message.
 type Message struct {
-	ID        int64  `protobuf:"1"`
+	ID        int64  `protobuf:"1,extract"`
 	Text      string `protobuf:"2"`
 	Sender    *User  `protobuf:"3"`
 	Timestamp int64  `protobuf:"4"`
@@ -15,3 +15,9 @@ type User struct {
 	ID   int64  `protobuf:"1"`
 	Name string `protobuf:"2"`
 }
+
+// Stats holds counters, telling the ones not collected (nil) from those with no samples (empty).
+type Stats struct {
+	Counts []int64          `protobuf:"1,emitempty"`
+	Totals map[string]int64 `protobuf:"2,nonnil"`
+}
@@ -0,0 +1,327 @@
+package easyprotogen
+
+import "fmt"
+
+// appendFunc returns the MessageMarshaler append function name for a protobuf type.
+func appendFunc(protoType string, isRepeated bool) string {
+	if isRepeated {
+		switch protoType {
+		case "int32":
+			return "AppendInt32s"
+		case "int64":
+			return "AppendInt64s"
+		case "uint32":
+			return "AppendUint32s"
+		case "uint64":
+			return "AppendUint64s"
+		case "sint32":
+			return "AppendSint32s"
+		case "sint64":
+			return "AppendSint64s"
+		case "bool":
+			return "AppendBools"
+		case "double":
+			return "AppendDoubles"
+		case "float":
+			return "AppendFloats"
+		case "fixed32":
+			return "AppendFixed32s"
+		case "fixed64":
+			return "AppendFixed64s"
+		case "sfixed32":
+			return "AppendSfixed32s"
+		case "sfixed64":
+			return "AppendSfixed64s"
+		}
+	}
+
+	switch protoType {
+	case "string":
+		return "AppendString"
+	case "bytes":
+		return "AppendBytes"
+	case "int32", "enum":
+		return "AppendInt32"
+	case "int64":
+		return "AppendInt64"
+	case "uint32":
+		return "AppendUint32"
+	case "uint64":
+		return "AppendUint64"
+	case "sint32":
+		return "AppendSint32"
+	case "sint64":
+		return "AppendSint64"
+	case "bool":
+		return "AppendBool"
+	case "double":
+		return "AppendDouble"
+	case "float":
+		return "AppendFloat"
+	case "fixed32":
+		return "AppendFixed32"
+	case "fixed64":
+		return "AppendFixed64"
+	case "sfixed32":
+		return "AppendSfixed32"
+	case "sfixed64":
+		return "AppendSfixed64"
+	default:
+		return "AppendBytes"
+	}
+}
+
+// readFunc returns the FieldContext read function name for a protobuf type.
+func readFunc(protoType string) string {
+	switch protoType {
+	case "string":
+		return "String"
+	case "bytes":
+		return "Bytes"
+	case "int32", "enum":
+		return "Int32"
+	case "int64":
+		return "Int64"
+	case "uint32":
+		return "Uint32"
+	case "uint64":
+		return "Uint64"
+	case "sint32":
+		return "Sint32"
+	case "sint64":
+		return "Sint64"
+	case "bool":
+		return "Bool"
+	case "double":
+		return "Double"
+	case "float":
+		return "Float"
+	case "fixed32":
+		return "Fixed32"
+	case "fixed64":
+		return "Fixed64"
+	case "sfixed32":
+		return "Sfixed32"
+	case "sfixed64":
+		return "Sfixed64"
+	default:
+		return "Bytes"
+	}
+}
+
+// unpackFunc returns the FieldContext unpack function name for packed repeated fields.
+func unpackFunc(protoType string) string {
+	switch protoType {
+	case "int32", "enum":
+		return "UnpackInt32s"
+	case "int64":
+		return "UnpackInt64s"
+	case "uint32":
+		return "UnpackUint32s"
+	case "uint64":
+		return "UnpackUint64s"
+	case "sint32":
+		return "UnpackSint32s"
+	case "sint64":
+		return "UnpackSint64s"
+	case "bool":
+		return "UnpackBools"
+	case "double":
+		return "UnpackDoubles"
+	case "float":
+		return "UnpackFloats"
+	case "fixed32":
+		return "UnpackFixed32s"
+	case "fixed64":
+		return "UnpackFixed64s"
+	case "sfixed32":
+		return "UnpackSfixed32s"
+	case "sfixed64":
+		return "UnpackSfixed64s"
+	default:
+		return "Bytes"
+	}
+}
+
+// readType returns the Go type returned by the FieldContext read function for a protobuf type.
+func readType(protoType string) string {
+	switch protoType {
+	case "string":
+		return "string"
+	case "bytes":
+		return "[]byte"
+	case "int32", "enum", "sint32", "sfixed32":
+		return "int32"
+	case "int64", "sint64", "sfixed64":
+		return "int64"
+	case "uint32", "fixed32":
+		return "uint32"
+	case "uint64", "fixed64":
+		return "uint64"
+	case "bool":
+		return "bool"
+	case "double":
+		return "float64"
+	case "float":
+		return "float32"
+	default:
+		return "[]byte"
+	}
+}
+
+// fixedSize returns the size of the fixed-width encoding of a protobuf type, or 0 for
+// types encoded as varints.
+func fixedSize(protoType string) int {
+	switch protoType {
+	case "fixed64", "sfixed64", "double":
+		return 8
+	case "fixed32", "sfixed32", "float":
+		return 4
+	}
+	return 0
+}
+
+// wireTypeOf returns the wire type of a scalar protobuf type.
+func wireTypeOf(protoType string) int {
+	switch fixedSize(protoType) {
+	case 8:
+		return 1
+	case 4:
+		return 5
+	}
+	return 0
+}
+
+// convertValue returns expr converted from fromType to goType, or expr itself if the types match.
+func convertValue(goType, fromType, expr string) string {
+	if goType == fromType {
+		return expr
+	}
+	return fmt.Sprintf("%s(%s)", goType, expr)
+}
+
+// outOfRange returns a condition true if expr, of Go type fromType like the protobuf type of a
+// field, doesn't fit into its Go type goType, or "" if every value fits. The conditions of int
+// and uint values are constant false on 64-bit platforms and compiled away.
+func outOfRange(goType, fromType, expr string) string {
+	switch {
+	case goType == "int8":
+		return fmt.Sprintf("%s < math.MinInt8 || %s > math.MaxInt8", expr, expr)
+	case goType == "int16":
+		return fmt.Sprintf("%s < math.MinInt16 || %s > math.MaxInt16", expr, expr)
+	case goType == "uint8" || goType == "byte":
+		return fmt.Sprintf("%s > math.MaxUint8", expr)
+	case goType == "uint16":
+		return fmt.Sprintf("%s > math.MaxUint16", expr)
+	case goType == "int" && fromType == "int64":
+		return fmt.Sprintf("%s < math.MinInt || %s > math.MaxInt", expr, expr)
+	case goType == "uint" && fromType == "uint64":
+		return fmt.Sprintf("%s > math.MaxUint", expr)
+	}
+	return ""
+}
+
+// nonZero returns a condition that is true if expr, a singular field of a protobuf type, doesn't
+// hold the zero value, which proto3 leaves out of the encoding.
+func nonZero(protoType, expr string) string {
+	switch protoType {
+	case "string", "bytes":
+		return fmt.Sprintf("len(%s) > 0", expr)
+	case "bool":
+		return expr
+	}
+	return expr + " != 0"
+}
+
+// isSet returns a condition that is true if the field x.<Name> is set and printed by String,
+// or "" for singular non-pointer message fields, which are always printed.
+func isSet(f *FieldInfo) string {
+	expr := "x." + f.Name
+	switch {
+	case f.IsOneof || f.IsIndirect || (f.IsPointer && !f.IsRepeated):
+		return expr + " != nil"
+	case f.IsRepeated || f.IsMap:
+		return fmt.Sprintf("len(%s) > 0", expr)
+	case f.IsMessage:
+		return ""
+	case f.IsUUID:
+		return expr + " != [16]byte{}"
+	case f.IsBigInt || f.IsBigRat:
+		return expr + ".Sign() != 0"
+	case f.IsDecimal:
+		return "!" + expr + ".IsZero()"
+	case f.IsSQLNull:
+		return expr + ".Valid"
+	case f.IsEnum:
+		return expr + " != 0"
+	}
+	return nonZero(f.ProtoType, expr)
+}
+
+// appendText returns an expression appending the text of expr, a value of a scalar protobuf
+// type, to the []byte b: numbers and bools as by strconv, strings and bytes quoted, and enums
+// by fmt, which prints the names of enums with String methods.
+func appendText(protoType, expr string) string {
+	switch protoType {
+	case "int32", "int64", "sint32", "sint64", "sfixed32", "sfixed64":
+		return fmt.Sprintf("strconv.AppendInt(b, int64(%s), 10)", expr)
+	case "uint32", "uint64", "fixed32", "fixed64":
+		return fmt.Sprintf("strconv.AppendUint(b, uint64(%s), 10)", expr)
+	case "float":
+		return fmt.Sprintf("strconv.AppendFloat(b, float64(%s), 'g', -1, 32)", expr)
+	case "double":
+		return fmt.Sprintf("strconv.AppendFloat(b, float64(%s), 'g', -1, 64)", expr)
+	case "bool":
+		return fmt.Sprintf("strconv.AppendBool(b, bool(%s))", expr)
+	case "string", "bytes":
+		return fmt.Sprintf("strconv.AppendQuote(b, string(%s))", expr)
+	}
+	return fmt.Sprintf("fmt.Append(b, %s)", expr)
+}
+
+// appendUUID returns a statement appending expr, a UUID, to the MessageMarshaler mm as the field
+// fieldNum: as 16 bytes, or in the canonical form if protoType is string.
+func appendUUID(protoType string, fieldNum int, expr string) string {
+	if protoType == "string" {
+		return fmt.Sprintf("mm.AppendBytes(%d, protoconv.AppendUUIDString(make([]byte, 0, 36), %s))", fieldNum, expr)
+	}
+	return fmt.Sprintf("mm.AppendBytes(%d, %s[:])", fieldNum, expr)
+}
+
+// zeroValue returns the zero value literal for a Go type.
+func zeroValue(goType string) string {
+	return fmt.Sprintf("*new(%s)", goType)
+}
+
+// randomValue returns an expression of Go type goType with a random value of a protobuf
+// type, drawn from the *rand.Rand named r. Enums get small values, which are more likely
+// to be defined.
+func randomValue(protoType, goType string, isEnum bool) string {
+	var expr string
+	switch {
+	case isEnum || protoType == "enum":
+		expr = "r.Int31n(8)"
+	case protoType == "string":
+		expr = "randomProtobufString(r)"
+	case protoType == "bytes":
+		expr = "randomProtobufBytes(r)"
+	case protoType == "bool":
+		expr = "r.Intn(2) == 1"
+	case protoType == "double":
+		expr = "r.NormFloat64()"
+	case protoType == "float":
+		expr = "float32(r.NormFloat64())"
+	case protoType == "uint32" || protoType == "fixed32":
+		expr = "r.Uint32()"
+	case protoType == "uint64" || protoType == "fixed64":
+		expr = "r.Uint64()"
+	case protoType == "int32" || protoType == "sint32" || protoType == "sfixed32":
+		expr = "int32(r.Uint32())"
+	default:
+		expr = "int64(r.Uint64())"
+	}
+	if isEnum || protoType == "enum" {
+		return convertValue(goType, "int32", expr)
+	}
+	return convertValue(goType, readType(protoType), expr)
+}
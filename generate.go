@@ -0,0 +1,892 @@
+package easyprotogen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Config describes the code to generate for a package.
+type Config struct {
+	Dir     string   // Package directory; default "."
+	Types   []string // Names of the struct types to generate code for, or patterns like Event* (required unless Scan)
+	Output  string   // Output file; default Dir/<type>_proto.go for a single type name and Dir/<pkg>_proto.go otherwise
+	Options Options
+
+	// Scan adds the struct types with a //protogen:generate directive in their doc comment to
+	// Types, sorted by name. Types may then be empty.
+	Scan bool
+
+	// ExcludeTypes are names or patterns of types not to generate code for, even if Types
+	// matches them. Patterns have the syntax of path.Match and only match struct types with
+	// protobuf tags, in the order of their names.
+	ExcludeTypes []string
+
+	// Split writes the marshaling code of every type to its own Dir/<type>_proto.go file, the
+	// first of which has the header. Other files, like those of the proto backend, are named
+	// after the default Output. Split can't be combined with Output, ProtoAdapter and DescriptorSet.
+	Split bool
+
+	// IncludeTests parses the _test.go files of the package as well, for types only used by
+	// tests. The output file must be a _test.go file; the default one is named
+	// <type>_proto_test.go or <pkg>_proto_test.go.
+	IncludeTests bool
+
+	// Tags are build tags selecting the files of the package in addition to those of the
+	// GOOS and GOARCH of the environment, like the -tags flag of go build.
+	Tags []string
+
+	// Services are names of interface types to generate HTTP handlers and clients for, with
+	// methods like Send(ctx context.Context, req *Message) (*Ack, error).
+	Services []string
+
+	Emit     []string  // Names of the backends to run; default DefaultBackend
+	Backends []Backend // Backends selectable by Emit in addition to the built-in ones
+
+	// HotFields is the path of a hint file listing the fields that unmarshal methods check
+	// before switching on the field number, as Type.Field lines, hottest first.
+	HotFields string
+	// Profile is the path of a CPU profile in the pprof format of a program built with the
+	// previously generated output file. Fields with at least 10% of the samples of the unmarshal
+	// cases of their type are checked first, hottest first. Profile and HotFields are combined.
+	Profile string
+
+	Fuzz        bool   // Also generate <output>_fuzz_test.go with fuzz tests of UnmarshalProtobuf
+	Conformance string // Comma-separated Type=ProtoType pairs; also generate <output>_conformance_test.go
+	Doc         string // Path of a Markdown document of the fields of the types to also generate, like schema.md
+
+	// Graph also generates a diagram of the message types referencing each other through their
+	// fields, including oneof variants and map values: "dot" writes <output>.dot in the Graphviz
+	// DOT format and "mermaid" writes <output>.mmd in the Mermaid format.
+	Graph string
+
+	Lock          bool // Check the wire schema against protogen.lock in Dir and return the updated lock file
+	AllowBreaking bool // With Lock, accept incompatible changes instead of returning a *CompatError
+
+	// StrictFields makes exported fields of the types without a protobuf tag an error, so that
+	// a forgotten tag doesn't silently drop the field; fields are excluded with protobuf:"-".
+	StrictFields bool
+
+	// Force generates the methods even if the package already declares methods of the types
+	// with the same names outside of the output files, instead of returning ErrMethodExists.
+	Force bool
+
+	// SkipUnchanged returns the files on disk without parsing the package if the output file
+	// records the hash of the same sources, configuration and released protogen version.
+	SkipUnchanged bool
+
+	// Warn, if set, is called with a warning for every deprecated field of the types set in
+	// a composite literal of the package's _test.go files.
+	Warn func(msg string)
+}
+
+// File is a file generated by Generate.
+type File struct {
+	Path    string // Path of the file, relative to Config.Dir if Config.Dir is relative
+	Content []byte
+}
+
+// ErrMethodExists is wrapped by the *Error returned by Generate when a type already declares
+// a method that would be generated, such as a hand-written MarshalProtobuf.
+var ErrMethodExists = errors.New("generated method already declared")
+
+// Error is an error in the declaration of a type passed to Generate.
+type Error struct {
+	Pos   token.Position // Position of the field or type; invalid if the type wasn't found
+	Type  string         // Name of the type
+	Field string         // Name of the field, or "" for errors of the type as a whole
+	Err   error
+}
+
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Err.Error()
+	}
+	return e.Pos.String() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CompatError is returned by Generate with Config.Lock when the types changed incompatibly
+// since the lock file was written.
+type CompatError struct {
+	Path   string   // Path of the lock file
+	Issues []string // Incompatible changes, as reported by Compat
+}
+
+func (e *CompatError) Error() string {
+	return fmt.Sprintf("incompatible changes to %s:\n\t%s", e.Path, strings.Join(e.Issues, "\n\t"))
+}
+
+// Generate generates the code for the types of the package in cfg.Dir and returns the
+// generated files without writing them. Errors in type declarations are returned as *Error,
+// joined with errors.Join if there are several.
+func Generate(ctx context.Context, cfg Config) ([]File, error) {
+	if len(cfg.Types) == 0 && !cfg.Scan {
+		return nil, errors.New("no types to generate")
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	// The hints are part of the source hash, while the paths are not.
+	var hints, profile []byte
+	var err error
+	if cfg.HotFields != "" {
+		if hints, err = os.ReadFile(cfg.HotFields); err != nil {
+			return nil, fmt.Errorf("failed to read hot fields: %w", err)
+		}
+	}
+	if cfg.Profile != "" {
+		if profile, err = os.ReadFile(cfg.Profile); err != nil {
+			return nil, fmt.Errorf("failed to read profile: %w", err)
+		}
+	}
+
+	hash, pkgName, err := sourceHash(dir, cfg.invocation()+"\x00"+string(hints)+"\x00"+string(profile), cfg.IncludeTests)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Split && cfg.Output != "" {
+		return nil, errors.New("split output files are named after their types; no output file can be set")
+	}
+	if cfg.Split && (cfg.Options.ProtoAdapter || cfg.Options.DescriptorSet) {
+		return nil, errors.New("split output is not supported with proto adapters and descriptor sets, which describe all types in one file")
+	}
+	output := cfg.Output
+	if output == "" {
+		if len(cfg.Types) == 1 && !isTypePattern(cfg.Types[0]) && !cfg.Scan {
+			output = filepath.Join(dir, strings.ToLower(cfg.Types[0])+"_proto.go")
+		} else {
+			output = filepath.Join(dir, pkgName+"_proto.go")
+		}
+		if cfg.IncludeTests {
+			output = strings.TrimSuffix(output, ".go") + "_test.go"
+		}
+	} else if cfg.IncludeTests && !strings.HasSuffix(output, "_test.go") {
+		return nil, fmt.Errorf("output file %s of types in test files must be a _test.go file", output)
+	}
+	if name := methodName(cfg.Options, "UnmarshalProtobuf"); name != "UnmarshalProtobuf" && (cfg.Fuzz || cfg.Conformance != "") {
+		return nil, fmt.Errorf("fuzz and conformance tests require the default UnmarshalProtobuf method name; generated %s instead", name)
+	}
+	if _, ok := graphExtensions[cfg.Graph]; !ok && cfg.Graph != "" {
+		return nil, fmt.Errorf("unknown graph format %q: must be dot or mermaid", cfg.Graph)
+	}
+	backends, err := selectBackends(cfg.Emit, cfg.Backends)
+	if err != nil {
+		return nil, err
+	}
+	var fset *token.FileSet
+	var files []*ast.File
+	if slices.ContainsFunc(cfg.Types, isTypePattern) || len(cfg.ExcludeTypes) > 0 || cfg.Scan {
+		// The types are needed for the paths of split files, so they are resolved before
+		// the unchanged output files are looked for.
+		fset = token.NewFileSet()
+		if pkgName, files, err = parsePackage(fset, dir, cfg.Tags, cfg.IncludeTests); err != nil {
+			return nil, err
+		}
+		if cfg.Types, err = resolveTypes(files, cfg.Types, cfg.ExcludeTypes, cfg.Scan); err != nil {
+			return nil, err
+		}
+	}
+	lockPath := filepath.Join(dir, lockFileName)
+	headerDecls, err := readHeaderDecls(dir, pkgName, cfg.Tags, cfg.IncludeTests, cfg.outputPaths(backends, output, lockPath))
+	if err != nil {
+		return nil, err
+	}
+	if len(headerDecls) > 0 {
+		// The header depends on the other generated files, which the source hash skips.
+		hash = extendHash(hash, slices.Sorted(maps.Keys(headerDecls))...)
+	}
+	hashPath := output
+	if cfg.Split {
+		hashPath = splitPath(output, cfg.Types[0])
+	}
+	if cfg.SkipUnchanged && isStableBuild() && readSourceHash(hashPath) == hash {
+		if files, err := readFiles(cfg.outputPaths(backends, output, lockPath)); err == nil {
+			return files, nil
+		}
+	}
+
+	if files == nil {
+		fset = token.NewFileSet()
+		if pkgName, files, err = parsePackage(fset, dir, cfg.Tags, cfg.IncludeTests); err != nil {
+			return nil, err
+		}
+	}
+	typeInfos, err := parseTypes(fset, files, cfg.Types, cfg.Options.ProtocTypes)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Options.Int32 {
+		for _, info := range typeInfos {
+			inferInt32(info)
+		}
+	}
+	detectCustomTypes(fset, files, typeInfos, cfg.Options, cfg.outputPaths(backends, output, lockPath))
+	for _, typeName := range cfg.Types {
+		if len(typeInfos[typeName].TypeParams) > 0 && (cfg.Fuzz || cfg.Conformance != "") {
+			return nil, fmt.Errorf("fuzz and conformance tests need type arguments; generic type %s is not supported with them", typeName)
+		}
+	}
+	if cfg.StrictFields {
+		if err := checkStrictFields(fset, files, cfg.Types); err != nil {
+			return nil, err
+		}
+	}
+	if !cfg.Force {
+		if err := checkMethods(fset, files, cfg.Types, cfg.Options, cfg.outputPaths(backends, output, lockPath)); err != nil {
+			return nil, err
+		}
+	}
+	services, err := parseServices(fset, files, cfg.Services)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyHints(typeInfos, hints, profile, output); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Warn != nil {
+		warnings, err := deprecatedTestUses(dir, typeInfos)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range warnings {
+			cfg.Warn(w)
+		}
+	}
+
+	// Check the schema against the lock file before generating anything
+	var locked schema
+	if cfg.Lock {
+		locked, err = readLock(lockPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock file: %w", err)
+		}
+		if issues := checkCompat(locked, buildSchema(typeInfos), reservedNumbers(typeInfos)); len(issues) > 0 && !cfg.AllowBreaking {
+			return nil, &CompatError{Path: lockPath, Issues: issues}
+		}
+	}
+
+	pkg := &Package{Name: pkgName, Types: cfg.Types, TypeInfos: typeInfos, Options: cfg.Options, Services: services, SourceHash: hash, HeaderDecls: headerDecls}
+	var generated []File
+	for _, b := range backends {
+		if _, ok := b.(easyprotoBackend); ok && cfg.Split {
+			files, err := generateSplit(b, pkg, output)
+			if err != nil {
+				return nil, fmt.Errorf("%s backend: %w", b.Name(), err)
+			}
+			generated = append(generated, files...)
+			continue
+		}
+		code, err := b.Generate(pkg)
+		if err != nil {
+			return nil, fmt.Errorf("%s backend: %w", b.Name(), err)
+		}
+		generated = append(generated, File{Path: b.Path(output), Content: code})
+	}
+
+	var buf bytes.Buffer
+	if cfg.Fuzz {
+		if err := generateFuzz(&buf, pkgName, cfg.Types); err != nil {
+			return nil, fmt.Errorf("failed to generate fuzz tests: %w", err)
+		}
+		code, err := formatCode(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		code = addBuildConstraint(code, cfg.Options.BuildConstraint)
+		generated = append(generated, File{Path: strings.TrimSuffix(output, ".go") + "_fuzz_test.go", Content: code})
+	}
+
+	if cfg.Conformance != "" {
+		refs, err := parseConformance(cfg.Conformance, cfg.Types)
+		if err != nil {
+			return nil, err
+		}
+		buf.Reset()
+		if err := generateConformance(&buf, pkgName, refs); err != nil {
+			return nil, fmt.Errorf("failed to generate conformance tests: %w", err)
+		}
+		code, err := formatCode(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		code = addBuildConstraint(code, cfg.Options.BuildConstraint)
+		generated = append(generated, File{Path: strings.TrimSuffix(output, ".go") + "_conformance_test.go", Content: code})
+	}
+
+	if cfg.Doc != "" {
+		buf.Reset()
+		if err := generateDoc(&buf, pkg); err != nil {
+			return nil, fmt.Errorf("failed to generate schema document: %w", err)
+		}
+		generated = append(generated, File{Path: cfg.Doc, Content: bytes.Clone(buf.Bytes())})
+	}
+
+	if cfg.Graph != "" {
+		buf.Reset()
+		if err := generateGraph(&buf, pkg, cfg.Graph); err != nil {
+			return nil, fmt.Errorf("failed to generate graph: %w", err)
+		}
+		generated = append(generated, File{Path: strings.TrimSuffix(output, ".go") + graphExtensions[cfg.Graph], Content: bytes.Clone(buf.Bytes())})
+	}
+
+	if cfg.Lock {
+		data, err := marshalLock(updateLock(locked, buildSchema(typeInfos)))
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, File{Path: lockPath, Content: data})
+	}
+	return generated, nil
+}
+
+// invocation returns the configuration affecting the generated files, which is part of their source hash.
+func (cfg *Config) invocation() string {
+	var backends []string
+	for _, b := range cfg.Backends {
+		backends = append(backends, fmt.Sprintf("%s=%T", b.Name(), b))
+	}
+	return fmt.Sprintf("%q exclude=%q scan=%t %+v services=%q emit=%q backends=%q split=%t tags=%q strict=%t fuzz=%t conformance=%q doc=%q graph=%q lock=%t", cfg.Types, cfg.ExcludeTypes, cfg.Scan, cfg.Options, cfg.Services, cfg.Emit, backends, cfg.Split, cfg.Tags, cfg.StrictFields, cfg.Fuzz, cfg.Conformance, cfg.Doc, cfg.Graph, cfg.Lock)
+}
+
+// outputPaths returns the paths of the files generated with cfg, in the order Generate returns them.
+func (cfg *Config) outputPaths(backends []Backend, output, lockPath string) []string {
+	var paths []string
+	for _, b := range backends {
+		if _, ok := b.(easyprotoBackend); ok && cfg.Split {
+			for _, typeName := range cfg.Types {
+				paths = append(paths, splitPath(output, typeName))
+			}
+			continue
+		}
+		paths = append(paths, b.Path(output))
+	}
+	if cfg.Fuzz {
+		paths = append(paths, strings.TrimSuffix(output, ".go")+"_fuzz_test.go")
+	}
+	if cfg.Conformance != "" {
+		paths = append(paths, strings.TrimSuffix(output, ".go")+"_conformance_test.go")
+	}
+	if cfg.Doc != "" {
+		paths = append(paths, cfg.Doc)
+	}
+	if cfg.Graph != "" {
+		paths = append(paths, strings.TrimSuffix(output, ".go")+graphExtensions[cfg.Graph])
+	}
+	if cfg.Lock {
+		paths = append(paths, lockPath)
+	}
+	return paths
+}
+
+// isTypePattern reports whether a name of Config.Types is a pattern matching several types.
+func isTypePattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// resolveTypes replaces the patterns of names with the struct types with protobuf tags of files
+// they match, sorted by name, adds the types with a //protogen:generate directive if scan is
+// set, and removes the types matching exclude. A pattern matching no type is an error, so that
+// a typo doesn't silently generate nothing.
+func resolveTypes(files []*ast.File, names, exclude []string, scan bool) ([]string, error) {
+	var tagged, marked []string
+	forEachStruct(files, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+		if hasProtobufTags(structType) {
+			tagged = append(tagged, typeName)
+		}
+		if scan && hasGenerateDirective(doc) {
+			marked = append(marked, typeName)
+		}
+		return nil
+	})
+	slices.Sort(tagged)
+	slices.Sort(marked)
+	if scan && len(marked) == 0 && len(names) == 0 {
+		return nil, fmt.Errorf("no types with a %sgenerate directive found", directivePrefix)
+	}
+
+	var types []string
+	for _, name := range names {
+		if !isTypePattern(name) {
+			if !slices.Contains(types, name) {
+				types = append(types, name)
+			}
+			continue
+		}
+		matched := false
+		for _, typeName := range tagged {
+			ok, err := path.Match(name, typeName)
+			if err != nil {
+				return nil, fmt.Errorf("invalid type pattern %q: %w", name, err)
+			}
+			if ok {
+				matched = true
+				if !slices.Contains(types, typeName) {
+					types = append(types, typeName)
+				}
+			}
+		}
+		if base, _, ok := strings.Cut(name, "["); !matched && ok && isGenericType(files, base) {
+			return nil, fmt.Errorf("%s instantiates generic type %s, whose methods are generated for all type arguments; generate %s instead", name, base, base)
+		}
+		if !matched {
+			return nil, fmt.Errorf("no struct type with protobuf tags matches %q", name)
+		}
+	}
+	for _, typeName := range marked {
+		if !slices.Contains(types, typeName) {
+			types = append(types, typeName)
+		}
+	}
+
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid type pattern %q: %w", pattern, err)
+		}
+		types = slices.DeleteFunc(types, func(typeName string) bool {
+			ok, _ := path.Match(pattern, typeName)
+			return ok
+		})
+	}
+	if len(types) == 0 {
+		return nil, errors.New("no types to generate after exclusions")
+	}
+	return types, nil
+}
+
+// hasProtobufTags reports whether a field of structType has a protobuf tag.
+func hasProtobufTags(structType *ast.StructType) bool {
+	for _, field := range structType.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		if _, ok := tag.Lookup("protobuf"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPath returns the path of the file of typeName with Config.Split, next to output.
+func splitPath(output, typeName string) string {
+	return filepath.Join(filepath.Dir(output), strings.ToLower(typeName)+"_proto.go")
+}
+
+// generateSplit runs the easyproto backend b once per type of pkg and returns their files,
+// named by splitPath. Only the first file has the header and the services.
+func generateSplit(b Backend, pkg *Package, output string) ([]File, error) {
+	files := make([]File, 0, len(pkg.Types))
+	for i, typeName := range pkg.Types {
+		p := *pkg
+		p.Types = []string{typeName}
+		p.AllTypes = pkg.Types
+		if i > 0 {
+			p.Services = nil
+			p.HeaderDecls = make(map[string]bool, len(runtimeNames))
+			for name := range runtimeNames {
+				p.HeaderDecls[name] = true
+			}
+		}
+		code, err := b.Generate(&p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: splitPath(output, typeName), Content: code})
+	}
+	return files, nil
+}
+
+// readFiles reads the files at paths.
+func readFiles(paths []string) ([]File, error) {
+	files := make([]File, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: path, Content: data})
+	}
+	return files, nil
+}
+
+// formatCode formats generated code. On failure, the unformatted code is saved to a
+// temporary file for debugging.
+func formatCode(code []byte) ([]byte, error) {
+	formatted, err := format.Source(code)
+	if err == nil {
+		return formatted, nil
+	}
+	tmpFile, tmpErr := os.CreateTemp("", "protogen_debug_*.go")
+	if tmpErr != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	tmpFile.Write(code)
+	tmpFile.Close()
+	return nil, fmt.Errorf("failed to format generated code (debug output: %s): %w", tmpFile.Name(), err)
+}
+
+// parseTypes parses the given struct types declared in files. With protoc, their tags are
+// read in the protoc-gen-go format.
+func parseTypes(fset *token.FileSet, files []*ast.File, typeNames []string, protoc bool) (map[string]*TypeInfo, error) {
+	typeInfos := make(map[string]*TypeInfo)
+	var errs []error
+	forEachStruct(files, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+		if !slices.Contains(typeNames, typeName) {
+			return nil
+		}
+		var declErrs []declError
+		if protoc {
+			structType, declErrs = convertProtocStruct(typeName, structType)
+		}
+		var info *TypeInfo
+		if len(declErrs) == 0 {
+			info, declErrs = checkStruct(typeName, structType, doc)
+		}
+		for _, de := range declErrs {
+			errs = append(errs, &Error{Pos: fset.Position(de.pos), Type: typeName, Field: de.field, Err: de.err})
+		}
+		if info != nil {
+			if err := applyTypeParams(info, lookupType(files, typeName).Decl.(*ast.TypeSpec).TypeParams); err != nil {
+				errs = append(errs, &Error{Pos: fset.Position(structType.Pos()), Type: typeName, Err: err})
+				return nil
+			}
+			if err := resolveScalarVariants(files, info); err != nil {
+				errs = append(errs, &Error{Pos: fset.Position(structType.Pos()), Type: typeName, Err: err})
+				return nil
+			}
+			typeInfos[typeName] = info
+		}
+		return nil
+	})
+	for _, typeName := range typeNames {
+		if _, ok := typeInfos[typeName]; ok {
+			continue
+		}
+		if obj := lookupType(files, typeName); obj == nil {
+			errs = append(errs, &Error{Type: typeName, Err: fmt.Errorf("type %s not found", typeName)})
+		} else if _, ok := obj.Decl.(*ast.TypeSpec).Type.(*ast.StructType); !ok {
+			errs = append(errs, &Error{Pos: fset.Position(obj.Pos()), Type: typeName, Err: fmt.Errorf("type %s is not a struct", typeName)})
+		}
+	}
+	return typeInfos, errors.Join(errs...)
+}
+
+// checkStrictFields returns an *Error for every exported field of the named types without
+// a protobuf tag, joined with errors.Join.
+func checkStrictFields(fset *token.FileSet, files []*ast.File, typeNames []string) error {
+	var errs []error
+	forEachStruct(files, func(typeName string, structType *ast.StructType, _ *ast.CommentGroup) error {
+		if !slices.Contains(typeNames, typeName) {
+			return nil
+		}
+		for _, field := range structType.Fields.List {
+			if field.Tag != nil {
+				tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+				_, tagged := tag.Lookup("protobuf")
+				_, oneof := tag.Lookup("protobuf_oneof") // Oneof fields of protoc-gen-go types
+				if tagged || oneof {
+					continue
+				}
+			}
+			names := field.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{{NamePos: field.Type.Pos(), Name: getTypeName(field.Type)}}
+			}
+			for _, name := range names {
+				if !name.IsExported() {
+					continue
+				}
+				err := fmt.Errorf("exported field %q in type %s has no protobuf tag; tag it, or exclude it with protobuf:\"-\"", name.Name, typeName)
+				errs = append(errs, &Error{Pos: fset.Position(name.Pos()), Type: typeName, Field: name.Name, Err: err})
+			}
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// declError is an error in the declaration of a struct field, or of the struct type if field is "".
+type declError struct {
+	pos   token.Pos
+	field string
+	err   error
+}
+
+// checkStruct parses the struct type and the directives in its doc comment. On failure,
+// it returns a nil TypeInfo and an error for every invalid field, or a single error for
+// the struct type if its fields are only invalid together, like duplicate field numbers.
+func checkStruct(typeName string, structType *ast.StructType, doc *ast.CommentGroup) (*TypeInfo, []declError) {
+	// Parse fields one at a time to attribute every invalid tag to its field.
+	var errs []declError
+	for _, field := range structType.Fields.List {
+		single := &ast.StructType{Fields: &ast.FieldList{List: []*ast.Field{field}}}
+		if _, err := parseStruct(typeName, single); err != nil {
+			name := getTypeName(field.Type)
+			if len(field.Names) > 0 {
+				name = field.Names[0].Name
+			}
+			errs = append(errs, declError{pos: field.Pos(), field: name, err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	info, err := parseStruct(typeName, structType)
+	if err == nil {
+		err = applyDirectives(info, doc)
+	}
+	if err != nil {
+		return nil, []declError{{pos: structType.Pos(), err: err}}
+	}
+	return info, nil
+}
+
+// buildContext returns the build context of the environment with the additional build tags.
+func buildContext(tags []string) *build.Context {
+	ctxt := build.Default
+	ctxt.BuildTags = append(slices.Clip(ctxt.BuildTags), tags...)
+	return &ctxt
+}
+
+// parsePackage parses the Go files of the package in dir that match the build constraints of
+// the environment and the additional build tags. Test files are parsed with tests, except
+// those of the external test package.
+func parsePackage(fset *token.FileSet, dir string, tags []string, tests bool) (pkgName string, files []*ast.File, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	ctxt := buildContext(tags)
+	for _, entry := range entries {
+		test := strings.HasSuffix(entry.Name(), "_test.go")
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || (test && !tests) {
+			continue
+		}
+		if ok, err := ctxt.MatchFile(dir, entry.Name()); err != nil {
+			return "", nil, err
+		} else if !ok {
+			continue
+		}
+		filePath := filepath.Join(dir, entry.Name())
+		file, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse file %s: %w", filePath, err)
+		}
+		if test && strings.HasSuffix(file.Name.Name, "_test") {
+			continue // skip the external test package
+		}
+		if pkgName == "" {
+			pkgName = file.Name.Name
+		} else if file.Name.Name != pkgName {
+			continue // skip files from different packages
+		}
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("no Go files found in %s", dir)
+	}
+	return pkgName, files, nil
+}
+
+// readHeaderDecls returns the declarations of the header of generated Go files found in the
+// files generated by protogen in the package in dir that match the build constraints, except
+// the files at outputs. Test files are only read with tests, as other files can't use their
+// declarations.
+func readHeaderDecls(dir, pkgName string, tags []string, tests bool, outputs []string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	skip := make(map[string]bool)
+	for _, path := range outputs {
+		if abs, err := filepath.Abs(path); err == nil {
+			skip[abs] = true
+		}
+	}
+
+	decls := make(map[string]bool)
+	fset := token.NewFileSet()
+	ctxt := buildContext(tags)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (strings.HasSuffix(name, "_test.go") && !tests) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if abs, err := filepath.Abs(path); err != nil || skip[abs] {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(data, []byte(generatedHeader)) {
+			continue
+		}
+		if ok, err := ctxt.MatchFile(dir, name); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, data, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", path, err)
+		}
+		if file.Name.Name != pkgName {
+			continue
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && runtimeNames[decl.Name.Name] != "" {
+					decls[decl.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						for _, ident := range spec.Names {
+							if runtimeNames[ident.Name] != "" {
+								decls[ident.Name] = true
+							}
+						}
+					case *ast.TypeSpec:
+						if runtimeNames[spec.Name.Name] != "" {
+							decls[spec.Name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+// forEachStruct calls fn for every struct type declared in files, passing the doc comment of the type.
+func forEachStruct(files []*ast.File, fn func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error) error {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				doc := typeSpec.Doc
+				if doc == nil && len(genDecl.Specs) == 1 {
+					doc = genDecl.Doc
+				}
+				if structType, ok := typeSpec.Type.(*ast.StructType); ok {
+					if err := fn(typeSpec.Name.Name, structType, doc); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveScalarVariants sets the value fields of the oneof variants of info that wrap scalars,
+// which must be structs declared in files with a single field.
+func resolveScalarVariants(files []*ast.File, info *TypeInfo) error {
+	for _, f := range info.Fields {
+		for i := range f.OneofVariants {
+			v := &f.OneofVariants[i]
+			if v.ProtoType == "" {
+				continue
+			}
+			var st *ast.StructType
+			if obj := lookupType(files, v.TypeName); obj != nil {
+				st, _ = obj.Decl.(*ast.TypeSpec).Type.(*ast.StructType)
+			}
+			if st == nil || len(st.Fields.List) != 1 || len(st.Fields.List[0].Names) != 1 {
+				return fmt.Errorf("oneof variant %s of field %q wraps a %s, so it must be a struct declared in the package with a single named field", v.TypeName, f.Name, v.ProtoType)
+			}
+			v.ValueField = st.Fields.List[0].Names[0].Name
+			v.ValueType = exprToString(st.Fields.List[0].Type)
+		}
+	}
+	return nil
+}
+
+// lookupType returns the package-level type named typeName declared in files, or nil.
+func lookupType(files []*ast.File, typeName string) *ast.Object {
+	for _, file := range files {
+		if obj := file.Scope.Lookup(typeName); obj != nil && obj.Kind == ast.Typ {
+			return obj
+		}
+	}
+	return nil
+}
+
+// checkMethods returns an *Error wrapping ErrMethodExists for every method of the types
+// declared in files that has the name of a method generated for every type, including String
+// with Stringer. The files at outputs are skipped, as they are replaced by the generated code.
+func checkMethods(fset *token.FileSet, files []*ast.File, typeNames []string, opts Options, outputs []string) error {
+	skip := make(map[string]bool)
+	for _, path := range outputs {
+		if abs, err := filepath.Abs(path); err == nil {
+			skip[abs] = true
+		}
+	}
+	generated := make(map[string]bool)
+	for _, name := range []string{"MarshalProtobuf", "MarshalProtobufWith", "MarshalProtobufTo", "UnmarshalProtobuf", "MergeFromProtobuf", "CloneProtobuf", "CloneProtobufInto"} {
+		generated[methodName(opts, name)] = true
+	}
+	if opts.Stringer {
+		generated["String"] = true
+	}
+
+	var errs []error
+	for _, file := range files {
+		pos := fset.Position(file.Pos())
+		if abs, err := filepath.Abs(pos.Filename); err == nil && skip[abs] {
+			continue
+		}
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 || !generated[funcDecl.Name.Name] {
+				continue
+			}
+			typeName := getTypeName(funcDecl.Recv.List[0].Type)
+			if !slices.Contains(typeNames, typeName) {
+				continue
+			}
+			errs = append(errs, &Error{
+				Pos:  fset.Position(funcDecl.Pos()),
+				Type: typeName,
+				Err:  fmt.Errorf("%w: %s.%s", ErrMethodExists, typeName, funcDecl.Name.Name),
+			})
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,800 @@
+package easyprotogen
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/build/constraint"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"google.golang.org/protobuf/proto"
+)
+
+//go:embed templates/proto.tmpl
+var protoTemplate string
+
+//go:embed templates/standalone.tmpl
+var standaloneTemplate string
+
+//go:embed templates/fuzz.tmpl
+var fuzzTemplate string
+
+//go:embed templates/conformance.tmpl
+var conformanceTemplate string
+
+//go:embed templates/protofile.tmpl
+var protoFileTemplate string
+
+//go:embed templates/doc.tmpl
+var docTemplate string
+
+//go:embed templates/graph.tmpl
+var graphTemplate string
+
+// Options controls optional code generation behavior.
+type Options struct {
+	SkipHeader    bool // Skip the _mp pool and interface definitions
+	CopyStrings   bool // Decoded strings and bytes are copied instead of aliasing the source buffer
+	Arena         bool // Generate UnmarshalProtobufArena methods
+	Mask          bool // Generate field mask types and MarshalProtobufMasked methods
+	Filter        bool // Generate Filter<Type>Protobuf functions
+	Deterministic bool // Generate MarshalProtobufDeterministic and HashProtobuf methods
+	Random        bool // Generate FuzzFill methods setting random values
+	Quick         bool // Generate Generate methods implementing quick.Generator; requires Random
+	ProtocTypes   bool // The types are protoc-gen-go structs; read their protobuf tags in its format
+	ProtoAdapter  bool // Embed a descriptor and generate ProtoAdapter methods returning proto.Message adapters
+	DescriptorSet bool // Embed a descriptor, export it as a FileDescriptorSet and register it
+	Register      bool // Register the types with easyprotoreg under <package>.<Type>
+	Int32         bool // Encode int and uint values without an explicit protobuf type as int32 and uint32
+	SizeBreakdown bool // Generate SizeBreakdown methods returning the encoded size of each field
+	Stringer      bool // Generate String methods printing the set fields, with redacted fields masked
+	Limit         bool // Generate MarshalProtobufLimit methods failing for messages over a size limit
+	Observe       bool // MarshalProtobuf and UnmarshalProtobuf report their calls to protoobserve with the protogen_observe build tag
+	Pool          bool // Generate Acquire<Type> and Release<Type> functions; []*Type fields decode their elements from the pool
+	Direct        bool // Generate SizeProtobuf and AppendProtobuf methods writing messages directly, used by MarshalProtobuf
+	TinyGo        bool // Generate code without sync.Pool, unsafe and reflection, for TinyGo builds
+	Standalone    bool // Declare a minimal protobuf codec and the easyproto-gen helpers in the header, importing only the standard library
+
+	// MethodPrefix is prepended to the names of the generated methods, and MethodSuffix
+	// replaces Protobuf in them: MethodSuffix "PB" generates MarshalPB, UnmarshalPB and so on.
+	// Custom types keep implementing the default names.
+	MethodPrefix string
+	MethodSuffix string
+
+	// BuildConstraint is the expression of a //go:build line added to the generated Go files,
+	// like "linux || darwin", for types declared in files with build constraints.
+	BuildConstraint string
+
+	// Runtime is the import path of a package declaring the marshaler pool, interfaces and
+	// helpers otherwise declared by the header of the generated file, like package protoruntime.
+	// The generated file imports it instead, so that any number of files can be generated into
+	// a package without SkipHeader.
+	Runtime string
+
+	// ValueReceivers are names of generated types whose marshal methods have value receivers,
+	// so that small types stored by value can be marshaled without taking their address.
+	ValueReceivers []string
+
+	// Templates are additional text/template sources parsed after the built-in template, with
+	// the same functions. Their named templates replace the built-in ones of the same name, and
+	// their bodies are executed in order with the same data, appending to the generated file.
+	Templates []string
+}
+
+// maxMaskFields is the maximum number of fields in a type generated with Options.Mask.
+const maxMaskFields = 64
+
+// marshalContext is the data passed to the marshalField template.
+type marshalContext struct {
+	Field         *FieldInfo
+	Redacted      bool // Skip redacted fields and marshal nested messages redacted
+	Deterministic bool // Sort map entries by key and marshal nested messages deterministically
+}
+
+// unmarshalContext is the data passed to the resetFields and parseFields templates.
+type unmarshalContext struct {
+	Options
+	TypeName string
+	Info     *TypeInfo
+	Arena    bool   // Allocate from the arena.Arena named a
+	Func     string // Field whose elements are passed to the function named fn instead of appended
+	Parallel bool   // Collect the elements of parallel fields into parts<Field> instead of decoding them
+	Intern   bool   // Intern the strings of intern fields with the intern.Table named t and pass it on
+}
+
+// fieldContext is the data passed to the parseField template.
+type fieldContext struct {
+	unmarshalContext
+	Field *FieldInfo
+}
+
+// generateCode writes the marshaling code of the types of pkg.
+func generateCode(buf *bytes.Buffer, pkg *Package) error {
+	pkgName, typeNames, typeInfos, opts := pkg.Name, pkg.Types, pkg.TypeInfos, pkg.Options
+	// The methods generated for a type depend on the other types generated with it, also
+	// when they are in other files.
+	allTypes := typeNames
+	if pkg.AllTypes != nil {
+		allTypes = pkg.AllTypes
+	}
+	redacted := hasRedactedFields(allTypes, typeInfos)
+	generated := make(map[string]bool, len(allTypes))
+	for _, typeName := range allTypes {
+		generated[typeName] = true
+	}
+	interned := internedTypes(allTypes, typeInfos)
+	for _, typeName := range allTypes {
+		if typeInfos[typeName].ValueReceiver && !slices.Contains(opts.ValueReceivers, typeName) {
+			opts.ValueReceivers = append(slices.Clip(opts.ValueReceivers), typeName)
+		}
+	}
+
+	if err := checkMethodNames(opts, len(pkg.Services) > 0); err != nil {
+		return err
+	}
+	if opts.BuildConstraint != "" {
+		if _, err := constraint.Parse("//go:build " + opts.BuildConstraint); err != nil {
+			return fmt.Errorf("invalid build constraint %q: %w", opts.BuildConstraint, err)
+		}
+	}
+	for _, typeName := range opts.ValueReceivers {
+		if !generated[typeName] {
+			return fmt.Errorf("value receiver type %s is not generated", typeName)
+		}
+	}
+	for _, typeName := range typeNames {
+		if len(typeInfos[typeName].TypeParams) > 0 {
+			if err := checkGenericOptions(typeName, opts); err != nil {
+				return err
+			}
+		}
+	}
+	if opts.TinyGo {
+		if err := checkTinyGoOptions(opts); err != nil {
+			return err
+		}
+	}
+	if opts.Standalone {
+		if err := checkStandalone(opts, len(pkg.Services) > 0, typeNames, typeInfos, generated); err != nil {
+			return err
+		}
+	}
+	if opts.Direct {
+		if err := checkDirect(typeNames, typeInfos, generated); err != nil {
+			return err
+		}
+	}
+	if opts.Mask {
+		for _, typeName := range typeNames {
+			if n := len(typeInfos[typeName].Fields); n > maxMaskFields {
+				return fmt.Errorf("type %s has %d fields; field masks support at most %d", typeName, n, maxMaskFields)
+			}
+		}
+	}
+
+	base, err := protoTmpl()
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	tmpl, err := base.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(templateFuncs(opts, generated, interned))
+	extra := make([]string, len(opts.Templates))
+	for i, src := range opts.Templates {
+		extra[i] = fmt.Sprintf("template%d", i)
+		if _, err := tmpl.New(extra[i]).Parse(src); err != nil {
+			return fmt.Errorf("failed to parse template %d: %w", i, err)
+		}
+	}
+
+	data := struct {
+		Options
+		Package   string
+		Imports   []string
+		Types     []string
+		TypeInfos map[string]*TypeInfo
+		Redacted  bool
+		Services  []*ServiceInfo
+		Packed    bool // Some fields are repeated fixed-width scalars, converted integers or enums, handled by package packed
+		Intern    bool // Some fields are interned, decoded with package intern
+		Conv      bool // Some fields are UUIDs, numbers of package math/big or unscaled decimals, converted with package protoconv
+		Decimal   bool // Some fields are decimal.Decimal of github.com/shopspring/decimal
+		Generic   bool // Some types of allTypes are generic, with fields asserted to be messages at run time
+		// BytesMarshaler is set if some fields of allTypes are custom types marshaled into bytes.
+		BytesMarshaler bool
+		// HeaderDecls are the declarations of the header in other generated files, which are omitted.
+		HeaderDecls map[string]bool
+		// Descriptor is the serialized file descriptor of the types as a Go string literal,
+		// set with ProtoAdapter or DescriptorSet.
+		Descriptor string
+	}{
+		Options:   opts,
+		Redacted:  redacted,
+		Package:   pkgName,
+		Imports:   collectImports(pkg),
+		Types:     typeNames,
+		TypeInfos: typeInfos,
+		Services:  pkg.Services,
+		Packed:    anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return isPackedFixed(f) || isPackedVarint(f) }),
+		Intern:    slices.ContainsFunc(typeNames, func(typeName string) bool { return interned[typeName] }),
+		Conv:      anyField(typeNames, typeInfos, convertsValue),
+		Decimal:   anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return f.IsDecimal }),
+		Generic:   anyField(allTypes, typeInfos, func(f *FieldInfo) bool { return f.IsTypeParam }),
+
+		BytesMarshaler: anyField(allTypes, typeInfos, func(f *FieldInfo) bool { return f.IsBytesMarshaler }),
+
+		HeaderDecls: pkg.HeaderDecls,
+	}
+	if opts.ProtoAdapter || opts.DescriptorSet {
+		raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(buildFileDescriptor(pkg, descriptorPath(pkgName, typeNames)))
+		if err != nil {
+			return fmt.Errorf("failed to marshal file descriptor: %w", err)
+		}
+		data.Descriptor = strconv.Quote(string(raw))
+	}
+
+	if err := tmpl.ExecuteTemplate(buf, "proto", data); err != nil {
+		return err
+	}
+	for _, name := range extra {
+		if err := tmpl.ExecuteTemplate(buf, name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateFuncs returns the functions of the built-in template for the given options, types
+// generated together and types with UnmarshalProtobufIntern methods.
+func templateFuncs(opts Options, generated, interned map[string]bool) template.FuncMap {
+	// The functions up to trimPrefix are documented for custom templates; keep their signatures stable.
+	return template.FuncMap{
+		"appendFunc":        appendFunc,
+		"readFunc":          readFunc,
+		"unpackFunc":        unpackFunc,
+		"zeroValue":         zeroValue,
+		"nonZero":           nonZero,
+		"readType":          readType,
+		"convertValue":      convertValue,
+		"outOfRange":        outOfRange,
+		"isLengthDelimited": isLengthDelimited,
+		"codec": func(name string) string {
+			return codecName(opts, name)
+		},
+		"trimPrefix": strings.TrimPrefix,
+		"randomValue": func(protoType, goType string, isEnum bool) string {
+			expr := randomValue(protoType, goType, isEnum)
+			for _, name := range []string{"randomProtobufString", "randomProtobufBytes"} {
+				expr = strings.Replace(expr, name, runtimeName(opts, name), 1)
+			}
+			return expr
+		},
+		"fixedSize":  fixedSize,
+		"wireTypeOf": wireTypeOf,
+		// wireTag, tagSize, valueWireType, wireSize and wireAppend write the SizeProtobuf and
+		// AppendProtobuf methods generated with Direct.
+		"wireTag":       wireTag,
+		"tagSize":       tagSize,
+		"valueWireType": valueWireType,
+		"wireSize": func(protoType, expr string) string {
+			return codecExpr(opts, wireSize(protoType, expr))
+		},
+		"wireAppend": func(protoType, expr string) string {
+			return codecExpr(opts, wireAppend(protoType, expr))
+		},
+		"packedVarintType": packedVarintType,
+		"directContext": func(fieldNum int, expr string) directContext {
+			return directContext{FieldNum: fieldNum, Expr: expr}
+		},
+		"appendText": appendText,
+		"appendUUID": appendUUID,
+		"isSet":      isSet,
+		"cloneString": func(protoType string) bool {
+			return protoType == "string" && opts.CopyStrings
+		},
+		"cloneBytes": func(protoType string) bool {
+			return protoType == "bytes" && opts.CopyStrings
+		},
+		// marshalContext returns the marshalField data for the given mode:
+		// "" for MarshalProtobufTo, "redacted" or "deterministic".
+		"marshalContext": func(field *FieldInfo, mode string) marshalContext {
+			return marshalContext{Field: field, Redacted: mode == "redacted", Deterministic: mode == "deterministic"}
+		},
+		// marshalMethod returns the method marshaling a nested message of the given type.
+		// Only types generated together have MarshalProtobufRedactedTo methods, and custom
+		// types have neither of the variants.
+		"marshalMethod": func(ctx marshalContext, typeName string, custom bool) string {
+			switch {
+			case ctx.Redacted && generated[genericName(typeName)]:
+				return methodName(opts, "MarshalProtobufRedactedTo")
+			case ctx.Deterministic && !custom:
+				return methodName(opts, "MarshalProtobufDeterministicTo")
+			case custom:
+				return "MarshalProtobufTo"
+			}
+			return methodName(opts, "MarshalProtobufTo")
+		},
+		// method returns the name of a generated method given its default name.
+		"method": func(name string) string {
+			return methodName(opts, name)
+		},
+		// runtime returns the name of a declaration of the header, qualified with the
+		// package imported with Runtime.
+		"runtime": func(name string) string {
+			return runtimeName(opts, name)
+		},
+		// marshalReceiver returns the receiver of the marshal methods of the given type.
+		"marshalReceiver": func(typeName string, info *TypeInfo) string {
+			if slices.Contains(opts.ValueReceivers, typeName) {
+				return "x " + typeName + info.TypeArgs()
+			}
+			return "x *" + typeName + info.TypeArgs()
+		},
+		// newMessage and zeroMessage return a pointer to a new element of a message field and
+		// its zero value; composite literals of type parameters are invalid.
+		"newMessage": func(field *FieldInfo) string {
+			if field.IsTypeParam {
+				return "new(" + field.ElemType + ")"
+			}
+			return "&" + field.ElemType + "{}"
+		},
+		"zeroMessage": func(field *FieldInfo) string {
+			if field.IsTypeParam {
+				return zeroValue(field.ElemType)
+			}
+			return field.ElemType + "{}"
+		},
+		// custom returns an expression of a pointer to an element of a custom message field
+		// for calling the methods of ProtobufMarshaler and ProtobufUnmarshaler; elements of
+		// type parameters are asserted to implement them. customAddr takes the element itself.
+		"custom": func(field *FieldInfo, expr string) string {
+			if field.IsTypeParam {
+				return runtimeName(opts, "protobufMessageOf") + "(" + expr + ")"
+			}
+			return expr
+		},
+		// cloneFunc returns the header declaration cloning the custom field.
+		"cloneFunc": func(field *FieldInfo) string {
+			if field.IsBytesMarshaler {
+				return "cloneProtobufBytes"
+			}
+			return "cloneProtobuf"
+		},
+		"customAddr": func(field *FieldInfo, expr string) string {
+			if field.IsTypeParam {
+				return runtimeName(opts, "protobufMessageOf") + "(&" + expr + ")"
+			}
+			return expr
+		},
+		"unmarshalContext": func(typeName string, info *TypeInfo, arena bool) unmarshalContext {
+			return unmarshalContext{Options: opts, TypeName: typeName, Info: info, Arena: arena}
+		},
+		"unmarshalFuncContext": func(typeName string, info *TypeInfo, field string) unmarshalContext {
+			return unmarshalContext{Options: opts, TypeName: typeName, Info: info, Func: field, Intern: interned[typeName]}
+		},
+		"unmarshalParallelContext": func(typeName string, info *TypeInfo) unmarshalContext {
+			// The elements of parallel fields are interned by their goroutines.
+			intern := slices.ContainsFunc(info.Fields, func(f *FieldInfo) bool { return !f.IsParallel && usesIntern(f, interned) })
+			return unmarshalContext{Options: opts, TypeName: typeName, Info: info, Parallel: true, Intern: intern}
+		},
+		// unmarshalInternContext returns the parseFields data of mergeFromProtobufIntern for
+		// interned types, and of MergeFromProtobuf for the others.
+		"unmarshalInternContext": func(typeName string, info *TypeInfo) unmarshalContext {
+			return unmarshalContext{Options: opts, TypeName: typeName, Info: info, Intern: interned[typeName]}
+		},
+		// interned returns true if the type, possibly a pointer, has UnmarshalProtobufIntern methods.
+		"interned": func(typeName string) bool {
+			return interned[genericName(strings.TrimPrefix(typeName, "*"))]
+		},
+		// stringer returns true if the message type has the appendString method of String,
+		// which types generated together have with Stringer.
+		"stringer": func(typeName string, custom bool) bool {
+			return !custom && generated[genericName(strings.TrimPrefix(typeName, "*"))]
+		},
+		// pooled returns true if the elements of the []*T field are acquired from the pool of
+		// their type, which types generated together have with Pool.
+		"pooled": func(field *FieldInfo) bool {
+			return opts.Pool && !field.IsCustom && !field.IsTypeParam && generated[field.ElemType]
+		},
+		"fieldContext": func(ctx unmarshalContext, field *FieldInfo) fieldContext {
+			return fieldContext{unmarshalContext: ctx, Field: field}
+		},
+	}
+}
+
+// protoTmpl returns the built-in template, parsed once per process. Every execution clones it
+// and replaces its functions with those of its options.
+var protoTmpl = sync.OnceValues(func() (*template.Template, error) {
+	tmpl, err := template.New("proto").Funcs(templateFuncs(Options{}, nil, nil)).Parse(protoTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.New("standalone").Parse(standaloneTemplate)
+})
+
+// generateFuzz writes fuzz tests of the UnmarshalProtobuf methods of the given types.
+func generateFuzz(buf *bytes.Buffer, pkgName string, typeNames []string) error {
+	tmpl, err := template.New("fuzz").Parse(fuzzTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse fuzz template: %w", err)
+	}
+	data := struct {
+		Package string
+		Types   []string
+	}{
+		Package: pkgName,
+		Types:   typeNames,
+	}
+	return tmpl.Execute(buf, data)
+}
+
+// conformanceRef pairs a generated type with the protoc-gen-go type it must be compatible with.
+type conformanceRef struct {
+	Type       string
+	ImportPath string // Empty for types in the generated package
+	Name       string
+}
+
+// parseConformance parses the -conformance flag: comma-separated Type=ProtoType pairs,
+// where ProtoType is a type name in the generated package or an import path followed
+// by a dot and a type name.
+func parseConformance(spec string, typeNames []string) ([]conformanceRef, error) {
+	var refs []conformanceRef
+	for _, pair := range strings.Split(spec, ",") {
+		typeName, protoType, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || typeName == "" || protoType == "" {
+			return nil, fmt.Errorf("invalid conformance pair %q: expected Type=ProtoType", pair)
+		}
+		if !slices.Contains(typeNames, typeName) {
+			return nil, fmt.Errorf("conformance type %s is not generated", typeName)
+		}
+		ref := conformanceRef{Type: typeName, Name: protoType}
+		if i := strings.LastIndex(protoType, "."); i >= 0 {
+			ref.ImportPath, ref.Name = protoType[:i], protoType[i+1:]
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// generateConformance writes tests checking the given types against their protoc-gen-go
+// counterparts with the conformance package.
+func generateConformance(buf *bytes.Buffer, pkgName string, refs []conformanceRef) error {
+	tmpl, err := template.New("conformance").Parse(conformanceTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse conformance template: %w", err)
+	}
+
+	type importSpec struct {
+		Alias, Path string
+	}
+	type testSpec struct {
+		Type, Proto string
+	}
+	var imports []importSpec
+	aliases := make(map[string]string)
+	tests := make([]testSpec, 0, len(refs))
+	for _, ref := range refs {
+		proto := ref.Name
+		if ref.ImportPath != "" {
+			alias, ok := aliases[ref.ImportPath]
+			if !ok {
+				alias = fmt.Sprintf("pb%d", len(imports))
+				aliases[ref.ImportPath] = alias
+				imports = append(imports, importSpec{Alias: alias, Path: ref.ImportPath})
+			}
+			proto = alias + "." + ref.Name
+		}
+		tests = append(tests, testSpec{Type: ref.Type, Proto: proto})
+	}
+
+	data := struct {
+		Package string
+		Imports []importSpec
+		Refs    []testSpec
+	}{
+		Package: pkgName,
+		Imports: imports,
+		Refs:    tests,
+	}
+	return tmpl.Execute(buf, data)
+}
+
+// collectImports returns the standard library packages used by the generated code.
+func collectImports(pkg *Package) []string {
+	typeNames, typeInfos, opts := pkg.Types, pkg.TypeInfos, pkg.Options
+	imports := []string{"fmt"}
+	if anyField(typeNames, typeInfos, clonesBytes) {
+		imports = append(imports, "bytes")
+	}
+	if opts.Deterministic {
+		imports = append(imports, "hash")
+	}
+	var scalarOnly []string
+	for _, typeName := range typeNames {
+		if typeInfos[typeName].ScalarOnly() {
+			scalarOnly = append(scalarOnly, typeName)
+		}
+	}
+	if len(scalarOnly) > 0 {
+		imports = append(imports, "encoding/binary")
+	}
+	// The fast path checks the range of 32-bit varints and converts the bits of floats, and
+	// integers narrower than 32 bits are checked against the range of their Go type.
+	if anyField(scalarOnly, typeInfos, func(f *FieldInfo) bool {
+		return slices.Contains([]string{"int32", "enum", "uint32", "sint32", "double", "float"}, f.ProtoType)
+	}) || anyField(typeNames, typeInfos, checksRange) {
+		imports = append(imports, "math")
+	}
+	if anyField(typeNames, typeInfos, isBigNumber) {
+		imports = append(imports, "math/big")
+	}
+	if anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return f.IsSQLNull }) {
+		imports = append(imports, "database/sql")
+	}
+	if opts.Random {
+		imports = append(imports, "math/rand")
+	}
+	if opts.Quick {
+		imports = append(imports, "reflect")
+	}
+	sortsMapKeys := opts.Deterministic && anyField(typeNames, typeInfos, func(f *FieldInfo) bool {
+		return f.IsMap && f.MapKeyProto != "bool"
+	})
+	parallel := anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return f.IsParallel })
+	if opts.Filter || opts.Direct || sortsMapKeys || parallel || anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return clonesSlice(f, opts) }) {
+		imports = append(imports, "slices")
+	}
+	// Strings are cloned on decode with CopyStrings unless interned, by CloneProtobuf
+	// otherwise and by Extract functions.
+	if anyField(typeNames, typeInfos, func(f *FieldInfo) bool {
+		return hasStrings(f) && (!f.IsIntern || !opts.CopyStrings || f.IsExtract)
+	}) {
+		imports = append(imports, "strings")
+	}
+	if parallel {
+		imports = append(imports, "runtime")
+	}
+	if (opts.Deterministic && !opts.TinyGo && !opts.SkipHeader && opts.Runtime == "" && !pkg.HeaderDecls["_hashBufPool"]) || parallel || opts.Pool {
+		imports = append(imports, "sync")
+	}
+	if anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return f.IsIter }) {
+		imports = append(imports, "iter")
+	}
+	if len(pkg.Services) > 0 {
+		imports = append(imports, "context", "net/http")
+	}
+	if opts.Stringer && anyField(typeNames, typeInfos, printsScalar) {
+		imports = append(imports, "strconv")
+	}
+	if opts.Observe {
+		imports = append(imports, "time")
+	}
+	// The codec declared with Standalone encodes with packages encoding/binary, math and slices,
+	// declares errors with packages errors and strings, pools Marshalers with package sync and
+	// aliases strings with package unsafe, except with TinyGo.
+	if opts.Standalone && !opts.SkipHeader && opts.Runtime == "" && !pkg.HeaderDecls["_mp"] {
+		codec := []string{"encoding/binary", "errors", "math", "slices", "strings"}
+		if !opts.TinyGo {
+			codec = append(codec, "sync", "unsafe")
+		}
+		for _, path := range codec {
+			if !slices.Contains(imports, path) {
+				imports = append(imports, path)
+			}
+		}
+	}
+	return imports
+}
+
+// isBigNumber returns true if f is a big.Int or big.Rat field, or a pointer to one.
+func isBigNumber(f *FieldInfo) bool {
+	return f.IsBigInt || f.IsBigRat
+}
+
+// convertsValue returns true if f is converted to and from its encoding with package protoconv.
+func convertsValue(f *FieldInfo) bool {
+	return f.IsUUID || isBigNumber(f) || (f.IsDecimal && f.ProtoType == "bytes")
+}
+
+// hasRedactedFields returns true if any of the given types has a redacted field.
+func hasRedactedFields(typeNames []string, typeInfos map[string]*TypeInfo) bool {
+	return anyField(typeNames, typeInfos, func(f *FieldInfo) bool { return f.IsRedact })
+}
+
+// printsScalar returns true if String prints f, or a variant of the oneof f, with package strconv.
+func printsScalar(f *FieldInfo) bool {
+	if f.IsOneof {
+		return slices.ContainsFunc(f.OneofVariants, func(v OneofVariant) bool { return v.ProtoType != "" })
+	}
+	return !f.IsRedact && !f.IsMap && !f.IsMessage && !f.IsEnum && !f.IsUUID && !isBigNumber(f) && !f.IsDecimal && strings.HasPrefix(appendText(f.ProtoType, ""), "strconv.")
+}
+
+// anyField returns true if pred returns true for any field of the given types.
+func anyField(typeNames []string, typeInfos map[string]*TypeInfo, pred func(f *FieldInfo) bool) bool {
+	for _, typeName := range typeNames {
+		for _, f := range typeInfos[typeName].Fields {
+			if pred(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPackedFixed returns true if f is a repeated fixed-width scalar field, decoded in bulk by
+// package packed.
+func isPackedFixed(f *FieldInfo) bool {
+	return f.IsRepeated && !f.IsMessage && fixedSize(f.ProtoType) > 0
+}
+
+// isPackedVarint returns true if f is a repeated field of integers of another Go type than
+// easyproto reads, like narrow integers and enums, decoded by package packed when packed.
+func isPackedVarint(f *FieldInfo) bool {
+	return f.IsRepeated && f.NeedsTypeConv
+}
+
+// checksRange returns true if the generated code of f checks decoded integers against the range
+// of their Go type.
+func checksRange(f *FieldInfo) bool {
+	switch {
+	case f.IsOneof:
+		return slices.ContainsFunc(f.OneofVariants, func(v OneofVariant) bool {
+			return v.ProtoType != "" && outOfRange(v.ValueType, readType(v.ProtoType), "") != ""
+		})
+	case f.IsTruncate || f.IsEnum || f.IsMessage:
+		return false
+	case f.IsSQLNull:
+		return outOfRange(f.NullValueType, readType(f.ProtoType), "") != ""
+	case f.IsMap:
+		return outOfRange(f.MapKeyType, readType(f.MapKeyProto), "") != "" || outOfRange(f.MapValueType, readType(f.MapValueProto), "") != ""
+	}
+	return outOfRange(f.BaseType, readType(f.ProtoType), "") != ""
+}
+
+// runtimeNames maps the declarations of the header of generated files to their names in
+// package protoruntime.
+var runtimeNames = map[string]string{
+	"_mp":                  "Pool",
+	"ProtobufMarshaler":    "Marshaler",
+	"ProtobufUnmarshaler":  "Unmarshaler",
+	"cloneProtobuf":        "Clone",
+	"cloneProtobufBytes":   "CloneBytes",
+	"_hashBufPool":         "HashBufPool",
+	"randomProtobufString": "RandomString",
+	"randomProtobufBytes":  "RandomBytes",
+	"protobufMessageOf":    "MessageOf",
+}
+
+// runtimeName returns the name of a declaration of the header, qualified with the package
+// imported as protoruntime if opts.Runtime is set.
+func runtimeName(opts Options, name string) string {
+	if opts.Runtime == "" {
+		return name
+	}
+	return "protoruntime." + runtimeNames[name]
+}
+
+// methodName returns the name of a generated method given its default name, like
+// MarshalProtobuf, with opts.MethodPrefix and opts.MethodSuffix applied.
+func methodName(opts Options, name string) string {
+	if opts.MethodSuffix != "" {
+		name = strings.Replace(name, "Protobuf", opts.MethodSuffix, 1)
+	}
+	return opts.MethodPrefix + name
+}
+
+// checkMethodNames returns an error if the method names of opts are not exported identifiers,
+// or are changed while generating code for packages that require the default names.
+func checkMethodNames(opts Options, services bool) error {
+	name := methodName(opts, "MarshalProtobuf")
+	if name == "MarshalProtobuf" {
+		return nil
+	}
+	if !token.IsIdentifier(name) || !token.IsExported(name) {
+		return fmt.Errorf("invalid method prefix %q or suffix %q: %s is not an exported identifier", opts.MethodPrefix, opts.MethodSuffix, name)
+	}
+	var option string
+	switch {
+	case opts.ProtoAdapter:
+		option = "ProtoAdapter"
+	case opts.Register:
+		option = "Register"
+	case services:
+		option = "services"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s requires the default MarshalProtobuf and UnmarshalProtobuf method names; generated %s instead", option, name)
+}
+
+// internedTypes returns the set of types with intern fields or nested messages of such types,
+// which get UnmarshalProtobufIntern methods passing their intern.Table on to nested messages.
+func internedTypes(typeNames []string, typeInfos map[string]*TypeInfo) map[string]bool {
+	interned := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, typeName := range typeNames {
+			if interned[typeName] {
+				continue
+			}
+			for _, f := range typeInfos[typeName].Fields {
+				if usesIntern(f, interned) {
+					interned[typeName] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return interned
+}
+
+// usesIntern returns true if f is an intern field or holds messages of the interned types.
+func usesIntern(f *FieldInfo, interned map[string]bool) bool {
+	switch {
+	case f.IsIntern:
+		return true
+	case f.IsOneof:
+		return slices.ContainsFunc(f.OneofVariants, func(v OneofVariant) bool { return interned[v.TypeName] })
+	case f.IsMap:
+		return f.MapValueIsMsg && !f.MapValueCustom && interned[strings.TrimPrefix(f.MapValueType, "*")]
+	case f.IsMessage:
+		return !f.IsCustom && interned[f.ElemType]
+	}
+	return false
+}
+
+// schemaType returns the protobuf type of a scalar field in schemas and descriptors, which is
+// string for stringbytes fields decoded as bytes.
+func schemaType(f *FieldInfo) string {
+	if f.IsStringBytes {
+		return "string"
+	}
+	return f.ProtoType
+}
+
+// hasStrings returns true if f is a string field or a map with string keys or values.
+func hasStrings(f *FieldInfo) bool {
+	return (f.ProtoType == "string" && !f.IsUUID && !f.IsDecimal) || (f.IsMap && (f.MapKeyProto == "string" || f.MapValueProto == "string")) || wrapsScalar(f, "string")
+}
+
+// clonesBytes returns true if CloneProtobuf copies f with bytes.Clone.
+func clonesBytes(f *FieldInfo) bool {
+	return (f.ProtoType == "bytes" && !f.IsUUID && !isBigNumber(f) && !f.IsDecimal) || (f.IsMap && f.MapValueProto == "bytes") || wrapsScalar(f, "bytes")
+}
+
+// wrapsScalar returns true if f is a oneof with a variant wrapping a scalar of protoType.
+func wrapsScalar(f *FieldInfo, protoType string) bool {
+	return slices.ContainsFunc(f.OneofVariants, func(v OneofVariant) bool { return v.ProtoType == protoType })
+}
+
+// clonesSlice returns true if CloneProtobuf copies f with slices.Clone.
+func clonesSlice(f *FieldInfo, opts Options) bool {
+	if !f.IsRepeated || f.IsMessage || (f.ProtoType == "bytes" && !f.IsUUID) {
+		return false
+	}
+	return f.ProtoType != "string" || f.IsUUID || opts.CopyStrings
+}
+
+// isLengthDelimited returns true for types that are length-delimited (not packed).
+func isLengthDelimited(protoType string) bool {
+	return protoType == "string" || protoType == "bytes"
+}
+
+// addBuildConstraint adds a //go:build line with expr after the first line of code, the
+// generated code comment. Code is returned unchanged if expr is "".
+func addBuildConstraint(code []byte, expr string) []byte {
+	if expr == "" {
+		return code
+	}
+	header, rest, _ := bytes.Cut(code, []byte("\n"))
+	return slices.Concat(header, []byte("\n\n//go:build "+expr+"\n"), rest)
+}
@@ -0,0 +1,4926 @@
+package easyprotogen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+	"golang.org/x/tools/go/analysis"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// parseTestStruct parses a struct definition from source code and returns the TypeInfo
+func parseTestStruct(t *testing.T, typeName, source string) (*TypeInfo, error) {
+	t.Helper()
+	fset := token.NewFileSet()
+	src := "package test\n\n" + source
+	f, err := parser.ParseFile(fset, "test.go", src, 0)
+	if err != nil {
+		t.Fatalf("failed to parse source: %v", err)
+	}
+
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.Name.Name != typeName {
+				continue
+			}
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				t.Fatalf("type %s is not a struct", typeName)
+			}
+			return parseStruct(typeName, structType)
+		}
+	}
+	t.Fatalf("type %s not found", typeName)
+	return nil, nil
+}
+
+// generateTestCode parses the given types from source and returns the formatted generated code.
+func generateTestCode(t *testing.T, source string, typeNames ...string) string {
+	t.Helper()
+	return generateTestCodeWithOptions(t, source, Options{}, typeNames...)
+}
+
+// generateTestCodeWithOptions is like generateTestCode but uses the given generation options.
+func generateTestCodeWithOptions(t *testing.T, source string, opts Options, typeNames ...string) string {
+	t.Helper()
+	typeInfos := make(map[string]*TypeInfo)
+	for _, typeName := range typeNames {
+		info, err := parseTestStruct(t, typeName, source)
+		if err != nil {
+			t.Fatalf("failed to parse struct %s: %v", typeName, err)
+		}
+		typeInfos[typeName] = info
+	}
+
+	var buf bytes.Buffer
+	if err := generateCode(&buf, &Package{Name: "test", Types: typeNames, TypeInfos: typeInfos, Options: opts}); err != nil {
+		t.Fatalf("failed to generate code: %v", err)
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to format generated code: %v\n%s", err, buf.String())
+	}
+	return string(formatted)
+}
+
+func TestOneofTagParsing_ValidTag(t *testing.T) {
+	source := `
+type Message interface{ MessageType() string }
+type TextMessage struct{}
+type ImageMessage struct{}
+
+type Chat struct {
+	ID      int64   ` + "`protobuf:\"1\"`" + `
+	Content Message ` + "`protobuf:\"oneof,TextMessage:2,ImageMessage:3\"`" + `
+}
+`
+	info, err := parseTestStruct(t, "Chat", source)
+	if err != nil {
+		t.Fatalf("expected valid oneof tag, got error: %v", err)
+	}
+
+	if len(info.Fields) != 2 {
+		t.Fatalf("expected 2 fields, got %d", len(info.Fields))
+	}
+
+	// Find the Content field
+	var contentField *FieldInfo
+	for _, f := range info.Fields {
+		if f.Name == "Content" {
+			contentField = f
+			break
+		}
+	}
+
+	if contentField == nil {
+		t.Fatal("Content field not found")
+	}
+
+	if !contentField.IsOneof {
+		t.Error("expected IsOneof to be true")
+	}
+
+	if len(contentField.OneofVariants) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(contentField.OneofVariants))
+	}
+
+	if contentField.OneofVariants[0].TypeName != "TextMessage" || contentField.OneofVariants[0].FieldNum != 2 {
+		t.Errorf("variant 0 mismatch: got %+v", contentField.OneofVariants[0])
+	}
+	if contentField.OneofVariants[1].TypeName != "ImageMessage" || contentField.OneofVariants[1].FieldNum != 3 {
+		t.Errorf("variant 1 mismatch: got %+v", contentField.OneofVariants[1])
+	}
+}
+
+func TestOneofTagParsing_MissingVariants(t *testing.T) {
+	source := `
+type Message interface{}
+type Chat struct {
+	Content Message ` + "`protobuf:\"oneof\"`" + `
+}
+`
+	_, err := parseTestStruct(t, "Chat", source)
+	if err == nil {
+		t.Fatal("expected error for oneof with no variants")
+	}
+	if !strings.Contains(err.Error(), "at least one variant") {
+		t.Errorf("expected 'at least one variant' error, got: %v", err)
+	}
+}
+
+func TestOneofTagParsing_InvalidVariantFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		wantErr string
+	}{
+		{
+			name:    "missing colon",
+			tag:     "`protobuf:\"oneof,TextMessage\"`",
+			wantErr: "expected Type:FieldNum format",
+		},
+		{
+			name:    "non-numeric field number",
+			tag:     "`protobuf:\"oneof,TextMessage:abc\"`",
+			wantErr: "invalid field number",
+		},
+		{
+			name:    "field number too low",
+			tag:     "`protobuf:\"oneof,TextMessage:0\"`",
+			wantErr: "must be 1-536870911",
+		},
+		{
+			name:    "field number too high",
+			tag:     "`protobuf:\"oneof,TextMessage:536870912\"`",
+			wantErr: "must be 1-536870911",
+		},
+		{
+			name:    "reserved field number",
+			tag:     "`protobuf:\"oneof,TextMessage:19000\"`",
+			wantErr: "reserved",
+		},
+		{
+			name:    "reserved field number high end",
+			tag:     "`protobuf:\"oneof,TextMessage:19999\"`",
+			wantErr: "reserved",
+		},
+		{
+			name:    "message wrapper type",
+			tag:     "`protobuf:\"oneof,TextMessage:1:message\"`",
+			wantErr: "must be a scalar type",
+		},
+		{
+			name:    "too many parts",
+			tag:     "`protobuf:\"oneof,TextMessage:1:string:2\"`",
+			wantErr: "expected Type:FieldNum format",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			source := `
+type Message interface{}
+type TextMessage struct{}
+type Chat struct {
+	Content Message ` + tc.tag + `
+}
+`
+			_, err := parseTestStruct(t, "Chat", source)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOneofTagParsing_DuplicateFieldNumbers(t *testing.T) {
+	source := `
+type Message interface{}
+type TextMessage struct{}
+type ImageMessage struct{}
+type Chat struct {
+	Content Message ` + "`protobuf:\"oneof,TextMessage:2,ImageMessage:2\"`" + `
+}
+`
+	_, err := parseTestStruct(t, "Chat", source)
+	if err == nil {
+		t.Fatal("expected error for duplicate field numbers in oneof")
+	}
+	if !strings.Contains(err.Error(), "duplicate field number") {
+		t.Errorf("expected 'duplicate field number' error, got: %v", err)
+	}
+}
+
+func TestOneofTagParsing_DuplicateWithRegularField(t *testing.T) {
+	source := `
+type Message interface{}
+type TextMessage struct{}
+type Chat struct {
+	ID      int64   ` + "`protobuf:\"2\"`" + `
+	Content Message ` + "`protobuf:\"oneof,TextMessage:2\"`" + `
+}
+`
+	_, err := parseTestStruct(t, "Chat", source)
+	if err == nil {
+		t.Fatal("expected error for field number collision between oneof variant and regular field")
+	}
+	if !strings.Contains(err.Error(), "duplicate field number") {
+		t.Errorf("expected 'duplicate field number' error, got: %v", err)
+	}
+}
+
+func TestOneofTagParsing_MultipleVariants(t *testing.T) {
+	source := `
+type Message interface{}
+type TextMessage struct{}
+type ImageMessage struct{}
+type VideoMessage struct{}
+type AudioMessage struct{}
+type Chat struct {
+	Content Message ` + "`protobuf:\"oneof,TextMessage:1,ImageMessage:2,VideoMessage:3,AudioMessage:4\"`" + `
+}
+`
+	info, err := parseTestStruct(t, "Chat", source)
+	if err != nil {
+		t.Fatalf("expected valid oneof tag with 4 variants, got error: %v", err)
+	}
+
+	if len(info.Fields) != 1 {
+		t.Fatalf("expected 1 field, got %d", len(info.Fields))
+	}
+
+	contentField := info.Fields[0]
+	if len(contentField.OneofVariants) != 4 {
+		t.Fatalf("expected 4 variants, got %d", len(contentField.OneofVariants))
+	}
+
+	expected := []struct {
+		name     string
+		fieldNum int
+	}{
+		{"TextMessage", 1},
+		{"ImageMessage", 2},
+		{"VideoMessage", 3},
+		{"AudioMessage", 4},
+	}
+
+	for i, exp := range expected {
+		if contentField.OneofVariants[i].TypeName != exp.name {
+			t.Errorf("variant %d name: got %q, want %q", i, contentField.OneofVariants[i].TypeName, exp.name)
+		}
+		if contentField.OneofVariants[i].FieldNum != exp.fieldNum {
+			t.Errorf("variant %d field num: got %d, want %d", i, contentField.OneofVariants[i].FieldNum, exp.fieldNum)
+		}
+	}
+}
+
+func TestOneofTagParsing_ValidFieldNumberEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		fieldNum string
+		want     int
+	}{
+		{"minimum field number", "1", 1},
+		{"maximum field number", "536870911", 536870911},
+		{"just below reserved", "18999", 18999},
+		{"just above reserved", "20000", 20000},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			source := `
+type Message interface{}
+type TextMessage struct{}
+type Chat struct {
+	Content Message ` + "`protobuf:\"oneof,TextMessage:" + tc.fieldNum + "\"`" + `
+}
+`
+			info, err := parseTestStruct(t, "Chat", source)
+			if err != nil {
+				t.Fatalf("expected valid tag, got error: %v", err)
+			}
+
+			if len(info.Fields[0].OneofVariants) != 1 {
+				t.Fatal("expected 1 variant")
+			}
+
+			if info.Fields[0].OneofVariants[0].FieldNum != tc.want {
+				t.Errorf("field num: got %d, want %d", info.Fields[0].OneofVariants[0].FieldNum, tc.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_OneofScalarVariants(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Value interface{ isValue() }
+
+type StringValue struct{ V string }
+type IntValue struct{ V int64 }
+type SmallValue struct{ V int16 }
+type Nested struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+func (*StringValue) isValue() {}
+func (*IntValue) isValue()    {}
+func (*SmallValue) isValue()  {}
+func (*Nested) isValue()      {}
+
+type Holder struct {
+	Value Value ` + "`protobuf:\"oneof,StringValue:1:string,IntValue:2:int64,SmallValue:3:sint32,Nested:4\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"Holder", "Nested"}, Output: "-", Emit: []string{"easyproto", "proto"}}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		// Wrappers are written as their scalar, even when it is zero.
+		"case *StringValue:\n\t\tmm.AppendString(1, v.V)",
+		"case *IntValue:\n\t\tmm.AppendInt64(2, v.V)",
+		"case *SmallValue:\n\t\tmm.AppendSint32(3, int32(v.V))",
+		"case *Nested:\n\t\tv.MarshalProtobufTo(mm.AppendMessage(4))",
+		"v, ok := fc.String()",
+		"x.Value = &StringValue{V: v}",
+		"if v < math.MinInt16 || v > math.MaxInt16 {",
+		"x.Value = &SmallValue{V: int16(v)}",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	schema := string(files[1].Content)
+	for _, want := range []string{
+		"string value_string_value = 1;",
+		"int64 value_int_value = 2;",
+		"sint32 value_small_value = 3;",
+		"Nested value_nested = 4;",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("proto file missing %q:\n%s", want, schema)
+		}
+	}
+
+	src = strings.Replace(src, "type IntValue struct{ V int64 }", "type IntValue struct{ V, W int64 }", 1)
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "single named field") {
+		t.Errorf("expected single field error, got: %v", err)
+	}
+}
+
+func TestInterfaceRejection_AnyKeyword(t *testing.T) {
+	// Test that the 'any' keyword (alias for interface{}) is rejected
+	source := `
+type Chat struct {
+	Content any ` + "`protobuf:\"1\"`" + `
+}
+`
+	_, err := parseTestStruct(t, "Chat", source)
+	if err == nil {
+		t.Fatal("expected error for 'any' field without oneof tag")
+	}
+	if !strings.Contains(err.Error(), "interface types are not supported") {
+		t.Errorf("expected 'interface types are not supported' error, got: %v", err)
+	}
+}
+
+func TestInterfaceRejection_InlineInterface(t *testing.T) {
+	// Test that inline interface{} is rejected
+	source := `
+type Chat struct {
+	Content interface{} ` + "`protobuf:\"1\"`" + `
+}
+`
+	_, err := parseTestStruct(t, "Chat", source)
+	if err == nil {
+		t.Fatal("expected error for inline interface{} field without oneof tag")
+	}
+	if !strings.Contains(err.Error(), "interface types are not supported") {
+		t.Errorf("expected 'interface types are not supported' error, got: %v", err)
+	}
+}
+
+// Note: Named interface types (like `type Message interface{}`) are NOT detected
+// at parse time because the AST only sees the identifier, not the underlying type.
+// Users must use the `oneof` tag for polymorphic interface fields.
+// Example: `Content Message `protobuf:"oneof,TextMessage:1,ImageMessage:2"``
+
+func TestOneofTagValidation_InvalidFieldTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name: "primitive string",
+			source: `type Chat struct {
+	Content string ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+			wantErr: "primitive type",
+		},
+		{
+			name: "primitive int",
+			source: `type Chat struct {
+	Content int ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+			wantErr: "primitive type",
+		},
+		{
+			name: "primitive bool",
+			source: `type Chat struct {
+	Content bool ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+			wantErr: "primitive type",
+		},
+		{
+			name: "slice type",
+			source: `type Chat struct {
+	Content []string ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+			wantErr: "slice/array type",
+		},
+		{
+			name: "map type",
+			source: `type Chat struct {
+	Content map[string]int ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+			wantErr: "map type",
+		},
+		{
+			name: "pointer type",
+			source: `type Message interface{}
+type Chat struct {
+	Content *Message ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+			wantErr: "pointer type",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseTestStruct(t, "Chat", tc.source)
+			if err == nil {
+				t.Fatal("expected error for invalid oneof field type")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOneofTagValidation_ValidFieldTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name: "named interface type",
+			source: `type Message interface{}
+type Chat struct {
+	Content Message ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+		},
+		{
+			name: "any keyword",
+			source: `type Chat struct {
+	Content any ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+		},
+		{
+			name: "inline interface",
+			source: `type Chat struct {
+	Content interface{} ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+		},
+		{
+			name: "interface with methods",
+			source: `type Chat struct {
+	Content interface{ Method() } ` + "`protobuf:\"oneof,TextMessage:1\"`" + `
+}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := parseTestStruct(t, "Chat", tc.source)
+			if err != nil {
+				t.Fatalf("expected valid oneof field type, got error: %v", err)
+			}
+			if !info.Fields[0].IsOneof {
+				t.Error("expected IsOneof to be true")
+			}
+		})
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	// zeroValue uses *new(T) for all types, which correctly returns the zero value
+	tests := []string{
+		"string", "bool", "int", "int32", "int64", "uint32", "float64",
+		"[]byte", "*MyType", "[]MyType", "map[string]int",
+		"MyStruct", "MyInt", "pkg.ExternalType",
+	}
+
+	for _, goType := range tests {
+		t.Run(goType, func(t *testing.T) {
+			expected := fmt.Sprintf("*new(%s)", goType)
+			result := zeroValue(goType)
+			if result != expected {
+				t.Errorf("zeroValue(%q) = %q, want %q", goType, result, expected)
+			}
+		})
+	}
+}
+
+func TestGenerate_ReusesRepeatedMessageElements(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type Series struct {
+	Samples []Sample  ` + "`protobuf:\"1\"`" + `
+	Ptrs    []*Sample ` + "`protobuf:\"2\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series", "Sample")
+
+	for _, want := range []string{
+		"if n := len(x.Samples); n < cap(x.Samples) {",
+		"x.Samples = x.Samples[:n+1]",
+		"if n := len(x.Ptrs); n < cap(x.Ptrs) {",
+		"item := x.Ptrs[len(x.Ptrs)-1]",
+		"if item == nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_CopyStrings(t *testing.T) {
+	source := `
+type Labels struct {
+	Name   string            ` + "`protobuf:\"1\"`" + `
+	Values []string          ` + "`protobuf:\"2\"`" + `
+	Extra  map[string]string ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Labels")
+	merge := code[strings.Index(code, "MergeFromProtobuf(src []byte)"):]
+	merge = merge[:strings.Index(merge, "\n}\n")]
+	if strings.Contains(merge, "strings.Clone(") {
+		t.Error("expected no string copies on decode by default")
+	}
+	if !strings.Contains(code, "dst.Name = strings.Clone(x.Name)") {
+		t.Error("expected CloneProtobufInto to copy aliased strings")
+	}
+	if !strings.Contains(code, "valid only while src is alive") {
+		t.Error("expected aliasing note in UnmarshalProtobuf doc comment")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{CopyStrings: true}, "Labels")
+	if got := strings.Count(code, "strings.Clone("); got != 4 {
+		t.Errorf("expected 4 strings.Clone calls with CopyStrings, got %d", got)
+	}
+	if strings.Contains(code, "valid only while src is alive") {
+		t.Error("unexpected aliasing note with CopyStrings")
+	}
+
+	// Bytes are copied like strings.
+	source = `
+type Blob struct {
+	Data   []byte            ` + "`protobuf:\"1,extract\"`" + `
+	Chunks [][]byte          ` + "`protobuf:\"2\"`" + `
+	Parts  map[string][]byte ` + "`protobuf:\"3\"`" + `
+	Cached []byte            ` + "`protobuf:\"4,reuse\"`" + `
+}
+`
+	code = generateTestCode(t, source, "Blob")
+	merge = code[strings.Index(code, "MergeFromProtobuf(src []byte)"):]
+	merge = merge[:strings.Index(merge, "\n}\n")]
+	if strings.Contains(merge, "bytes.Clone(") {
+		t.Error("expected no bytes copies on decode by default")
+	}
+	if !strings.Contains(code, "x.Cached = append(x.Cached[:0], v...)") {
+		t.Error("expected reuse fields to be copied into their backing arrays")
+	}
+	if !strings.Contains(code, "The returned slice aliases src") {
+		t.Error("expected aliasing note in ExtractBlobData doc comment")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{CopyStrings: true}, "Blob")
+	merge = code[strings.Index(code, "MergeFromProtobuf(src []byte)"):]
+	merge = merge[:strings.Index(merge, "\n}\n")]
+	if got := strings.Count(merge, "bytes.Clone("); got != 3 {
+		t.Errorf("expected 3 bytes.Clone calls on decode with CopyStrings, got %d", got)
+	}
+	extract := code[strings.Index(code, "func ExtractBlobData("):]
+	if !strings.Contains(extract, "v = bytes.Clone(v)") || strings.Contains(code, "The returned slice aliases src") {
+		t.Error("expected ExtractBlobData to copy the bytes with CopyStrings")
+	}
+}
+
+func TestGenerate_Arena(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type Series struct {
+	Name    string    ` + "`protobuf:\"1\"`" + `
+	Samples []*Sample ` + "`protobuf:\"2\"`" + `
+	Latest  *Sample   ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series", "Sample")
+	if strings.Contains(code, "UnmarshalProtobufArena") || strings.Contains(code, "easyproto-gen/arena") {
+		t.Error("expected no arena code by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Arena: true, CopyStrings: true}, "Series", "Sample")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/arena"`,
+		"func (x *Series) UnmarshalProtobufArena(src []byte, a *arena.Arena) (err error) {",
+		"func (x *Sample) UnmarshalProtobufArena(src []byte, a *arena.Arena) (err error) {",
+		"v = a.CloneString(v)",
+		"item := arena.New[Sample](a)",
+		"x.Samples = arena.Append(a, x.Samples, item)",
+		"x.Latest = arena.New[Sample](a)",
+		"x.Latest.UnmarshalProtobufArena(data, a)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestParseOptionsWithoutType(t *testing.T) {
+	source := `
+type Status int32
+type Message struct {
+	ID     int64  ` + "`protobuf:\"1,extract\"`" + `
+	Status Status ` + "`protobuf:\"2,enum,extract\"`" + `
+	Labels map[string]string ` + "`protobuf:\"3,custom\"`" + `
+}
+`
+	info, err := parseTestStruct(t, "Message", source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f := info.Fields[0]; f.ProtoType != "int64" || !f.IsExtract {
+		t.Errorf("ID: got type %q, extract %v; want int64, true", f.ProtoType, f.IsExtract)
+	}
+	if f := info.Fields[1]; f.ProtoType != "enum" || !f.IsEnum || !f.IsExtract {
+		t.Errorf("Status: got type %q, enum %v, extract %v", f.ProtoType, f.IsEnum, f.IsExtract)
+	}
+	if f := info.Fields[2]; !f.IsMap || f.MapKeyProto != "string" || !f.MapValueCustom {
+		t.Errorf("Labels: got map %v, key %q, custom %v", f.IsMap, f.MapKeyProto, f.MapValueCustom)
+	}
+
+	_, err = parseTestStruct(t, "Message", `
+type Message struct {
+	ID int64 `+"`protobuf:\"1,int46\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "invalid protobuf type") {
+		t.Errorf("expected 'invalid protobuf type' error, got: %v", err)
+	}
+}
+
+func TestGenerate_Extract(t *testing.T) {
+	source := `
+type Status int32
+type Message struct {
+	ID     int    ` + "`protobuf:\"1,extract\"`" + `
+	Topic  string ` + "`protobuf:\"2,extract\"`" + `
+	Status Status ` + "`protobuf:\"3,enum,extract\"`" + `
+	Text   string ` + "`protobuf:\"4\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Message")
+	for _, want := range []string{
+		"func ExtractMessageID(src []byte) (int, error) {",
+		"if fc.FieldNum != 1 {\n\t\t\tcontinue\n\t\t}",
+		"if v, ok = fc.Int64(); !ok {",
+		`easyprotoerr.Field("Message", "ID", offset, fmt.Errorf("cannot read int64: %w", easyprotoerr.ErrWireTypeMismatch))`,
+		`easyprotoerr.Field("Message", "", offset, easyprotoerr.NextField(src, err))`,
+		"return int(v), nil",
+		"func ExtractMessageTopic(src []byte) (string, error) {",
+		"func ExtractMessageStatus(src []byte) (Status, error) {",
+		"return Status(v), nil",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "ExtractMessageText") {
+		t.Error("unexpected extract function for field without extract option")
+	}
+
+	_, err := parseTestStruct(t, "Message", `
+type Message struct {
+	IDs []int64 `+"`protobuf:\"1,extract\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "only supported on scalar fields") {
+		t.Errorf("expected scalar-only error, got: %v", err)
+	}
+}
+
+func TestGenerate_Func(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type Series struct {
+	Name    string    ` + "`protobuf:\"1\"`" + `
+	Samples []Sample  ` + "`protobuf:\"2,func\"`" + `
+	Extra   []*Sample ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series", "Sample")
+	for _, want := range []string{
+		"func (x *Series) UnmarshalProtobufSamplesFunc(src []byte, fn func(*Sample) error) (err error) {",
+		"var elem Sample",
+		"if err := elem.UnmarshalProtobuf(data); err != nil {",
+		"if err := fn(&elem); err != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "UnmarshalProtobufExtraFunc") {
+		t.Error("unexpected func method for field without func option")
+	}
+
+	_, err := parseTestStruct(t, "Series", `
+type Series struct {
+	Names []string `+"`protobuf:\"1,func\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "only supported on repeated message fields") {
+		t.Errorf("expected repeated-message-only error, got: %v", err)
+	}
+}
+
+func TestGenerate_Iter(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type Series struct {
+	Name    string   ` + "`protobuf:\"1\"`" + `
+	Samples []Sample ` + "`protobuf:\"2,iter\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series", "Sample")
+	for _, want := range []string{
+		`"iter"`,
+		"func SeriesSamplesIter(src []byte) iter.Seq2[*Sample, error] {",
+		"if fc.FieldNum != 2 {",
+		"if !yield(&elem, nil) {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	_, err := parseTestStruct(t, "Series", `
+type Series struct {
+	Sample *Sample `+"`protobuf:\"1,iter\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "only supported on repeated message fields") {
+		t.Errorf("expected repeated-message-only error, got: %v", err)
+	}
+}
+
+func TestGenerate_Parallel(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type WriteRequest struct {
+	Series  []Sample  ` + "`protobuf:\"1,parallel\"`" + `
+	Pending []*Sample ` + "`protobuf:\"2,parallel\"`" + `
+	Source  string    ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "WriteRequest", "Sample")
+	for _, want := range []string{
+		`"runtime"`,
+		`"sync"`,
+		"func (x *WriteRequest) UnmarshalProtobufParallel(src []byte, workers int) error {",
+		"partsSeries = append(partsSeries, data)",
+		"partsPending = append(partsPending, data)",
+		"x.Pending[i] = &Sample{}",
+		"if err := x.Series[i].UnmarshalProtobuf(partsSeries[i]); err != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Count(code, "UnmarshalProtobufParallel(") != 1 {
+		t.Error("expected UnmarshalProtobufParallel only for WriteRequest")
+	}
+
+	_, err := parseTestStruct(t, "WriteRequest", `
+type WriteRequest struct {
+	IDs []int64 `+"`protobuf:\"1,parallel\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "only supported on repeated message fields") {
+		t.Errorf("expected repeated-message-only error, got: %v", err)
+	}
+}
+
+func TestGenerate_ScalarFastPath(t *testing.T) {
+	source := `
+type Status int32
+type Sample struct {
+	Value     float64 ` + "`protobuf:\"1\"`" + `
+	Timestamp int64   ` + "`protobuf:\"2\"`" + `
+	Delta     int32   ` + "`protobuf:\"3,sint32\"`" + `
+	Status    Status  ` + "`protobuf:\"4,enum\"`" + `
+	Ok        bool    ` + "`protobuf:\"5\"`" + `
+}
+type Labeled struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+	Name  string  ` + "`protobuf:\"2\"`" + `
+}
+type Wide struct {
+	Value float64 ` + "`protobuf:\"16\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample", "Labeled", "Wide")
+	for _, want := range []string{
+		`"encoding/binary"`,
+		"if x.mergeFromProtobufFast(src) {",
+		"func (x *Sample) mergeFromProtobufFast(src []byte) bool {",
+		"case 1<<3 | 1:",
+		"v := math.Float64frombits(u)",
+		"case 2<<3 | 0:",
+		"v := int32(uint32(u)>>1) ^ -int32(u&1)",
+		"x.Status = Status(v)",
+		"v := u == 1",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	for _, typeName := range []string{"Labeled", "Wide"} {
+		if strings.Contains(code, "func (x *"+typeName+") mergeFromProtobufFast(") {
+			t.Errorf("unexpected fast path for %s", typeName)
+		}
+	}
+
+	// The fast path of 64-bit integers doesn't use package math.
+	code = generateTestCode(t, "type Counter struct {\n\tN uint64 `protobuf:\"1\"`\n}\n", "Counter")
+	if !strings.Contains(code, `"encoding/binary"`) || strings.Contains(code, `"math"`) {
+		t.Errorf("generated code of a type with a 64-bit integer must import encoding/binary but not math")
+	}
+}
+
+func TestGenerate_PackedFixed(t *testing.T) {
+	source := `
+type Series struct {
+	Values     []float64 ` + "`protobuf:\"1\"`" + `
+	Timestamps []int64   ` + "`protobuf:\"2,sfixed64\"`" + `
+	Counts     []int64   ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/packed"`,
+		"x.Values, ok = packed.Append(x.Values, data)",
+		"x.Values, ok = fc.UnpackDoubles(x.Values)",
+		"x.Timestamps, ok = packed.Append(x.Timestamps, data)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "packed.Append(x.Counts") {
+		t.Error("unexpected bulk decoding of varint field")
+	}
+}
+
+func TestGenerate_Prealloc(t *testing.T) {
+	source := `
+type Series struct {
+	Labels map[string]string ` + "`protobuf:\"1,prealloc=16\"`" + `
+	Points map[int64]float64 ` + "`protobuf:\"2,prealloc\"`" + `
+	Attrs  map[string]int64  ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series")
+	for _, want := range []string{
+		"x.Labels = make(map[string]string, 16)",
+		"if cfc.FieldNum == 2 {",
+		"x.Points = make(map[int64]float64, size)",
+		"x.Attrs = make(map[string]int64)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	for tag, want := range map[string]string{
+		"1,prealloc=0": "must be a positive integer",
+		"1,prealloc=x": "must be a positive integer",
+		"1,prealloc":   "only supported on map fields",
+		"1,prealloc=8": "only supported on map and repeated fields",
+	} {
+		_, err := parseTestStruct(t, "Series", `
+type Series struct {
+	Name string `+"`protobuf:\""+tag+"\"`"+`
+}
+`)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got: %v", tag, want, err)
+		}
+	}
+}
+
+func TestGenerate_PreallocSlice(t *testing.T) {
+	source := `
+type Event struct {
+	Name string ` + "`protobuf:\"1\"`" + `
+}
+
+type Batch struct {
+	Events []Event  ` + "`protobuf:\"1,prealloc=64\"`" + `
+	IDs    []int64  ` + "`protobuf:\"2,prealloc=64\"`" + `
+	Tags   []string ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Event", "Batch")
+	for _, want := range []string{
+		"x.Events = make([]Event, 0, 64)",
+		"x.IDs = make([]int64, 0, 64)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "x.Tags = make(") {
+		t.Error("unexpected preallocation of field without prealloc option")
+	}
+
+	_, err := parseTestStruct(t, "Batch", `
+type Batch struct {
+	IDs []int64 `+"`protobuf:\"1,prealloc\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "without a size is only supported on map fields") {
+		t.Errorf("expected error for prealloc without a size on a repeated field, got: %v", err)
+	}
+}
+
+func TestGenerate_Intern(t *testing.T) {
+	source := `
+type Label struct {
+	Name  string ` + "`protobuf:\"1,intern\"`" + `
+	Value string ` + "`protobuf:\"2\"`" + `
+}
+
+type Series struct {
+	Labels []Label           ` + "`protobuf:\"1\"`" + `
+	Extra  map[string]string ` + "`protobuf:\"2,intern\"`" + `
+}
+
+type Point struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Label", "Series", "Point")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/intern"`,
+		"func (x *Label) UnmarshalProtobufIntern(src []byte, t *intern.Table) error {",
+		"func (x *Series) UnmarshalProtobufIntern(src []byte, t *intern.Table) error {",
+		"return x.mergeFromProtobufIntern(src, &intern.Table{})",
+		"v = t.String(v)",
+		"kv = t.String(kv)",
+		"vv = t.String(vv)",
+		"UnmarshalProtobufIntern(data, t)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "func (x *Point) UnmarshalProtobufIntern") {
+		t.Error("unexpected UnmarshalProtobufIntern method on type without interned strings")
+	}
+	code = generateTestCodeWithOptions(t, source, Options{CopyStrings: true}, "Label", "Series", "Point")
+	merge := code[strings.Index(code, "func (x *Label) mergeFromProtobufIntern("):]
+	if merge = merge[:strings.Index(merge, "\n}\n")]; strings.Count(merge, "v = strings.Clone(v)") != 1 {
+		t.Error("expected only the field without intern option to be cloned with CopyStrings")
+	}
+
+	_, err := parseTestStruct(t, "Point", `
+type Point struct {
+	Value float64 `+"`protobuf:\"1,intern\"`"+`
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "intern option is only supported on string fields") {
+		t.Errorf("expected error for intern on a float field, got: %v", err)
+	}
+}
+
+func TestGenerate_StringBytes(t *testing.T) {
+	source := `
+type Document struct {
+	Title []byte   ` + "`protobuf:\"1,stringbytes\"`" + `
+	Lines [][]byte ` + "`protobuf:\"2,string,stringbytes\"`" + `
+	Raw   []byte   ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Document")
+	for _, want := range []string{
+		"mm.AppendBytes(1, x.Title)",
+		"v, ok := fc.Bytes()",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Document", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	for _, f := range info.Fields {
+		want := "string"
+		if f.Name == "Raw" {
+			want = "bytes"
+		}
+		if got := protoFieldType(f); got != want {
+			t.Errorf("%s: .proto type = %q, want %q", f.Name, got, want)
+		}
+	}
+	pkg := &Package{Name: "test", Types: []string{"Document"}, TypeInfos: map[string]*TypeInfo{"Document": info}}
+	fd, err := protodesc.NewFile(buildFileDescriptor(pkg, descriptorPath("test", pkg.Types)), nil)
+	if err != nil {
+		t.Fatalf("invalid file descriptor: %v", err)
+	}
+	if f := fd.Messages().ByName("Document").Fields().ByName("lines"); !f.IsList() || f.Kind() != protoreflect.StringKind {
+		t.Errorf("lines is not a repeated string field")
+	}
+
+	for _, goType := range []string{"string", "[]string", "*[]byte"} {
+		_, err := parseTestStruct(t, "Document", `
+type Document struct {
+	Title `+goType+" `protobuf:\"1,stringbytes\"`"+`
+}
+`)
+		if err == nil || !strings.Contains(err.Error(), "stringbytes option is only supported on []byte and [][]byte fields") {
+			t.Errorf("%s: expected stringbytes error, got: %v", goType, err)
+		}
+	}
+}
+
+func TestGenerate_ReuseBytes(t *testing.T) {
+	source := `
+type Blob struct {
+	Payload []byte   ` + "`protobuf:\"1,reuse\"`" + `
+	Chunks  [][]byte ` + "`protobuf:\"2,reuse\"`" + `
+	Raw     []byte   ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Blob")
+	for _, want := range []string{
+		"x.Payload = x.Payload[:0]",
+		"x.Raw = *new([]byte)",
+		"// Copy into the backing array left by a previous unmarshal.\n\t\t\tx.Payload = append(x.Payload[:0], v...)",
+		"x.Chunks[len(x.Chunks)-1] = append(x.Chunks[len(x.Chunks)-1][:0], v...)",
+		"x.Raw = v",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	for _, goType := range []string{"string", "*[]byte", "map[string][]byte"} {
+		_, err := parseTestStruct(t, "Blob", "type Blob struct {\n\tA "+goType+" `protobuf:\"1,reuse\"`\n}\n")
+		if err == nil || !strings.Contains(err.Error(), "reuse option is only supported on []byte and [][]byte fields") {
+			t.Errorf("%s: expected reuse error, got: %v", goType, err)
+		}
+	}
+}
+
+func TestGenerate_MarshalProtobufWith(t *testing.T) {
+	source := `
+type Event struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{MethodSuffix: "PB"}, "Event")
+	for _, want := range []string{
+		"m := _mp.Get()\n\tdst = x.MarshalPBWith(m, dst)\n\t_mp.Put(m)",
+		"func (x *Event) MarshalPBWith(m *easyproto.Marshaler, dst []byte) []byte {\n\tm.Reset()\n\tx.MarshalPBTo(m.MessageMarshaler())\n\tdst = m.Marshal(dst)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Direct: true}, "Event")
+	for _, want := range []string{
+		"return x.MarshalProtobufWith(nil, dst)",
+		"func (x *Event) MarshalProtobufWith(m *easyproto.Marshaler, dst []byte) []byte {\n\tdst = x.AppendProtobuf(dst)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code with Direct missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Direct(t *testing.T) {
+	source := `
+type Leaf struct {
+	Name string  ` + "`protobuf:\"1\"`" + `
+	IDs  []int64 ` + "`protobuf:\"2\"`" + `
+}
+
+type Node struct {
+	Leaf     *Leaf            ` + "`protobuf:\"1\"`" + `
+	Children []*Node          ` + "`protobuf:\"2,nilempty\"`" + `
+	ByName   map[string]*Leaf ` + "`protobuf:\"300\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Direct: true}, "Leaf", "Node")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/wire"`,
+		"func (x *Node) SizeProtobuf() int {",
+		"func (x *Node) AppendProtobuf(dst []byte) []byte {",
+		"func (x *Node) sizeProtobuf(sizes []int) (int, []int) {",
+		"func (x *Node) appendProtobuf(dst []byte, sizes []int) ([]byte, []int) {",
+		"m, sizes = x.Leaf.sizeProtobuf(sizes)",
+		"dst = append(dst, 0x0a)",
+		"dst = append(dst, 0xe2, 0x12)",
+		"dst = wire.AppendString(dst, x.Name)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	// Nested messages must have sizeProtobuf methods.
+	info, err := parseTestStruct(t, "Node", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Node"}, TypeInfos: map[string]*TypeInfo{"Node": info}, Options: Options{Direct: true}}
+	err = generateCode(new(bytes.Buffer), pkg)
+	if err == nil || !strings.Contains(err.Error(), `field "Leaf" in type Node is not supported with -direct: message Leaf is not generated with it`) {
+		t.Errorf("expected -direct error, got: %v", err)
+	}
+}
+
+func TestGenerate_TinyGo(t *testing.T) {
+	source := `
+type Reading struct {
+	Sensor string            ` + "`protobuf:\"1\"`" + `
+	Values []float64         ` + "`protobuf:\"2\"`" + `
+	Tags   map[string]string ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{TinyGo: true, Deterministic: true}, "Reading")
+	for _, want := range []string{
+		"var _mp protobufMarshalers",
+		"func (protobufMarshalers) Get() *easyproto.Marshaler { return &easyproto.Marshaler{} }",
+		"bp := new([]byte)",
+		"x.Values, ok = packed.Append(x.Values, data)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{`"sync"`, `"unsafe"`, `"reflect"`, "_hashBufPool", "var _mp easyproto.MarshalerPool"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Reading", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	for _, tt := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{TinyGo: true, Pool: true}, "-tinygo can't be combined with -pool"},
+		{Options{TinyGo: true, Arena: true}, "-tinygo can't be combined with -arena"},
+		{Options{TinyGo: true, Random: true, Quick: true}, "-tinygo can't be combined with -quick"},
+		{Options{TinyGo: true, Register: true}, "-tinygo can't be combined with -register"},
+	} {
+		pkg := &Package{Name: "test", Types: []string{"Reading"}, TypeInfos: map[string]*TypeInfo{"Reading": info}, Options: tt.opts}
+		if err := generateCode(new(bytes.Buffer), pkg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected %q, got: %v", tt.want, err)
+		}
+	}
+}
+
+func TestGenerate_Standalone(t *testing.T) {
+	source := `
+type Reading struct {
+	Sensor string            ` + "`protobuf:\"1\"`" + `
+	Values []float64         ` + "`protobuf:\"2\"`" + `
+	Tags   map[string]string ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Standalone: true}, "Reading")
+	for _, want := range []string{
+		"var _mp pbMarshalerPool",
+		"type pbMessageMarshaler struct {",
+		"func (x *Reading) MarshalProtobufTo(mm *pbMessageMarshaler) {",
+		"var fc pbFieldContext",
+		`"encoding/binary"`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	// The generated code imports only the standard library.
+	for _, unwanted := range []string{`"github.com/`, "easyproto.MessageMarshaler)", "easyprotoerr.Field(", "groups.Skip(", "packed.Append("} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+	for _, want := range []string{"pbField(", "pbSkipGroup(src)", "x.Values, ok = pbAppendFixed(x.Values, data)"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Standalone: true, TinyGo: true}, "Reading")
+	for _, unwanted := range []string{`"sync"`, `"unsafe"`, "pbMarshalerPool"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code with -tinygo contains %q", unwanted)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Reading", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	for _, tt := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Standalone: true, Runtime: "example.com/pb"}, "-standalone can't be combined with -runtime"},
+		{Options{Standalone: true, Register: true}, "-standalone can't be combined with -register"},
+		{Options{Standalone: true, Arena: true}, "-standalone can't be combined with -arena"},
+		{Options{Standalone: true, Observe: true}, "-standalone can't be combined with -observe"},
+	} {
+		pkg := &Package{Name: "test", Types: []string{"Reading"}, TypeInfos: map[string]*TypeInfo{"Reading": info}, Options: tt.opts}
+		if err := generateCode(new(bytes.Buffer), pkg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected %q, got: %v", tt.want, err)
+		}
+	}
+
+	uuidInfo, err := parseTestStruct(t, "Sample", "type Sample struct {\n\tID uuid.UUID `protobuf:\"1\"`\n}\n")
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Sample"}, TypeInfos: map[string]*TypeInfo{"Sample": uuidInfo}, Options: Options{Standalone: true}}
+	if err := generateCode(new(bytes.Buffer), pkg); err == nil || !strings.Contains(err.Error(), "package protoconv converts the value with easyproto") {
+		t.Errorf("expected an error for a UUID, got: %v", err)
+	}
+	direct := generateTestCodeWithOptions(t, "type Leaf struct {\n\tS string `protobuf:\"1\"`\n}\n", Options{Standalone: true, Direct: true}, "Leaf")
+	if !strings.Contains(direct, "dst = pbAppendString(dst, x.S)") || strings.Contains(direct, "wire.Append") || strings.Contains(direct, "wire.Size") {
+		t.Errorf("AppendProtobuf generated with -direct doesn't use the declared functions:\n%s", direct)
+	}
+
+	info.Fields[0].IsCustom = true
+	pkg = &Package{Name: "test", Types: []string{"Reading"}, TypeInfos: map[string]*TypeInfo{"Reading": info}, Options: Options{Standalone: true}}
+	if err := generateCode(new(bytes.Buffer), pkg); err == nil || !strings.Contains(err.Error(), "custom types implement MarshalProtobufTo") {
+		t.Errorf("expected an error for a custom type, got: %v", err)
+	}
+}
+
+func TestGenerate_NarrowInts(t *testing.T) {
+	source := `
+type Sample struct {
+	Level   int8            ` + "`protobuf:\"1\"`" + `
+	Port    uint16          ` + "`protobuf:\"2\"`" + `
+	Flags   byte            ` + "`protobuf:\"3\"`" + `
+	Delta   *int16          ` + "`protobuf:\"4\"`" + `
+	Offsets []int16         ` + "`protobuf:\"5\"`" + `
+	Raw     []uint8         ` + "`protobuf:\"6\"`" + `
+	Counts  map[int8]uint16 ` + "`protobuf:\"7\"`" + `
+	Lossy   int8            ` + "`protobuf:\"8,truncate\"`" + `
+}
+`
+	info, err := parseTestStruct(t, "Sample", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	for i, want := range []string{"int32", "uint32", "uint32", "int32", "int32", "bytes", "map", "int32"} {
+		if got := info.Fields[i].ProtoType; got != want {
+			t.Errorf("%s: type = %q, want %q", info.Fields[i].Name, got, want)
+		}
+	}
+	if f := info.Fields[6]; f.MapKeyProto != "int32" || f.MapValueProto != "uint32" {
+		t.Errorf("Counts: got map<%s, %s>, want map<int32, uint32>", f.MapKeyProto, f.MapValueProto)
+	}
+
+	code := generateTestCode(t, source, "Sample")
+	for _, want := range []string{
+		"mm.AppendInt32(1, int32(x.Level))",
+		"mm.AppendUint32(2, uint32(x.Port))",
+		"mm.AppendInt32(4, int32(*x.Delta))",
+		"mm.AppendBytes(5, packed.MarshalVarints[int32](buf[:0], x.Offsets))",
+		"mm2.AppendUint32(2, uint32(v))",
+		"if v < math.MinInt8 || v > math.MaxInt8 {",
+		"if v > math.MaxUint16 {",
+		"if v > math.MaxUint8 {",
+		"easyprotoerr.ErrOutOfRange",
+		"x.Offsets, err = packed.AppendVarints[int32](x.Offsets, data, false)",
+		"mk = int8(kv)",
+		"mv = uint16(vv)",
+		"x.Lossy = int8(v)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if n := strings.Count(code, "if v < math.MinInt8 || v > math.MaxInt8 {"); n != 1 {
+		t.Errorf("got %d range checks of int8 values, want 1 for Level and none for the truncated Lossy", n)
+	}
+
+	for _, tc := range []struct {
+		field string
+		err   string
+	}{
+		{"A int8 `protobuf:\"1,sint32\"`", "int8 values must be encoded as int32, not sint32"},
+		{"A map[string]uint16 `protobuf:\"1\"`", ""},
+		{"A map[uint16]string `protobuf:\"1,map,int32,string\"`", "uint16 values must be encoded as uint32, not int32"},
+		{"A int32 `protobuf:\"1,truncate\"`", "truncate option is only supported on int8, int16, uint8, uint16, int and uint values"},
+	} {
+		_, err := parseTestStruct(t, "Sample", "type Sample struct {\n\t"+tc.field+"\n}\n")
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.field, err, tc.err)
+		}
+	}
+}
+
+func TestGenerate_PlatformInts(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Sample struct {
+	Count  int            ` + "`protobuf:\"1\"`" + `
+	Size   uint           ` + "`protobuf:\"2\"`" + `
+	IDs    []int          ` + "`protobuf:\"3\"`" + `
+	Totals map[string]int ` + "`protobuf:\"4\"`" + `
+	Delta  int            ` + "`protobuf:\"5,sint64\"`" + `
+	Wide   int            ` + "`protobuf:\"6,int64\"`" + `
+	Lossy  uint           ` + "`protobuf:\"7,truncate\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"Sample"}, Output: "-"}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		"mm.AppendInt64(1, int64(x.Count))",
+		"mm.AppendUint64(2, uint64(x.Size))",
+		"mm.AppendBytes(3, packed.MarshalVarints[int64](buf[:0], x.IDs))",
+		"if v < math.MinInt || v > math.MaxInt {",
+		"if v > math.MaxUint {",
+		"x.IDs, err = packed.AppendVarints[int64](x.IDs, data, false)",
+		"if vv < math.MinInt || vv > math.MaxInt {",
+		"x.Lossy = uint(v)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	cfg.Options.Int32 = true
+	cfg.Emit = []string{"easyproto", "proto"}
+	files, err = Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate with Int32 failed: %v", err)
+	}
+	code = string(files[0].Content)
+	for _, want := range []string{
+		"mm.AppendInt32(1, int32(x.Count))",
+		"mm.AppendUint32(2, uint32(x.Size))",
+		"mm.AppendBytes(3, packed.MarshalVarints[int32](buf[:0], x.IDs))",
+		"mm.AppendSint64(5, int64(x.Delta))",
+		"mm.AppendInt64(6, int64(x.Wide))",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code with Int32 missing %q", want)
+		}
+	}
+	if strings.Contains(code, "math.MaxUint {") {
+		t.Errorf("generated code with Int32 checks the range of uint32 values")
+	}
+	for _, want := range []string{"int32 count = 1;", "map<string, int32> totals = 4;", "sint64 delta = 5;", "int64 wide = 6;"} {
+		if !strings.Contains(string(files[1].Content), want) {
+			t.Errorf(".proto file with Int32 missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Unpacked(t *testing.T) {
+	source := `
+type Sample struct {
+	Codes  []int32   ` + "`protobuf:\"1,unpacked\"`" + `
+	Scores []float64 ` + "`protobuf:\"2,unpacked,deprecated\"`" + `
+	Levels []int8    ` + "`protobuf:\"3,unpacked\"`" + `
+	IDs    []int64   ` + "`protobuf:\"4\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample")
+	for _, want := range []string{
+		"for _, v := range x.Codes {\n\t\tmm.AppendInt32(1, v)\n\t}",
+		"for _, v := range x.Scores {\n\t\tmm.AppendDouble(2, v)\n\t}",
+		"for _, v := range x.Levels {\n\t\tmm.AppendInt32(3, int32(v))\n\t}",
+		"mm.AppendInt64s(4, x.IDs)",
+		// Both forms are decoded regardless of the option.
+		"x.Codes, ok = fc.UnpackInt32s(x.Codes)",
+		"x.Levels, err = packed.AppendVarints[int32](x.Levels, data, false)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Sample", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Sample"}, TypeInfos: map[string]*TypeInfo{"Sample": info}}
+	protoFile, err := protoFileBackend{}.Generate(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"repeated int32 codes = 1 [packed = false];",
+		"repeated double scores = 2 [packed = false, deprecated = true];",
+		"repeated int64 ids = 4;",
+	} {
+		if !strings.Contains(string(protoFile), want) {
+			t.Errorf(".proto file missing %q", want)
+		}
+	}
+	fd, err := protodesc.NewFile(buildFileDescriptor(pkg, descriptorPath("test", pkg.Types)), nil)
+	if err != nil {
+		t.Fatalf("invalid file descriptor: %v", err)
+	}
+	fields := fd.Messages().ByName("Sample").Fields()
+	if fields.ByName("codes").IsPacked() || !fields.ByName("ids").IsPacked() {
+		t.Errorf("codes should be unpacked and ids packed in the descriptor")
+	}
+
+	for _, goType := range []string{"int32", "[]string", "[][]byte", "[]*Sample", "map[string]int32"} {
+		_, err := parseTestStruct(t, "Sample", `
+type Sample struct {
+	Codes `+goType+" `protobuf:\"1,unpacked\"`"+`
+}
+`)
+		if err == nil || !strings.Contains(err.Error(), "unpacked option is only supported on repeated scalar fields") {
+			t.Errorf("%s: expected unpacked error, got: %v", goType, err)
+		}
+	}
+}
+
+func TestGenerate_RepeatedPackedAndUnpacked(t *testing.T) {
+	source := `
+type Status int32
+type Sample struct {
+	Statuses []Status  ` + "`protobuf:\"1,enum\"`" + `
+	Counts   []uint64  ` + "`protobuf:\"2\"`" + `
+	Deltas   []int32   ` + "`protobuf:\"3,sint32\"`" + `
+	Weights  []float32 ` + "`protobuf:\"4\"`" + `
+	Flags    []bool    ` + "`protobuf:\"5\"`" + `
+	Levels   []int16   ` + "`protobuf:\"6\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample")
+	// Every repeated scalar accepts both a packed field and separate values: the easyproto
+	// Unpack functions read both, and the other fields check MessageData first.
+	for _, want := range []string{
+		"x.Statuses, err = packed.AppendVarints[int32](x.Statuses, data, false)",
+		"} else if v, ok := fc.Int32(); ok {",
+		"x.Counts, ok = fc.UnpackUint64s(x.Counts)",
+		"x.Deltas, ok = fc.UnpackSint32s(x.Deltas)",
+		"x.Weights, ok = packed.Append(x.Weights, data)",
+		"x.Weights, ok = fc.UnpackFloats(x.Weights)",
+		"x.Flags, ok = fc.UnpackBools(x.Flags)",
+		"x.Levels, err = packed.AppendVarints[int32](x.Levels, data, false)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_SkipsGroups(t *testing.T) {
+	source := `
+type Entry struct {
+	ID     int64            ` + "`protobuf:\"1\"`" + `
+	Labels map[string]int32 ` + "`protobuf:\"2,prealloc\"`" + `
+	Items  []*Entry         ` + "`protobuf:\"3,iter\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Filter: true}, "Entry")
+	for _, want := range []struct {
+		code  string
+		count int
+	}{
+		{`"github.com/aryehlev/easyproto-gen/groups"`, 1},
+		// UnmarshalProtobuf and the counting of map entries
+		{"if tail, ok := groups.Skip(src); ok {", 1},
+		{"if tail, ok := groups.Skip(rest); ok {", 2},
+		{"if rest, ok := groups.Skip(data); ok {", 1},
+		{"groupTail, ok := groups.Skip(src)", 1},
+	} {
+		if got := strings.Count(code, want.code); got != want.count {
+			t.Errorf("generated code has %d of %q, want %d", got, want.code, want.count)
+		}
+	}
+}
+
+func TestGenerate_MapEntries(t *testing.T) {
+	source := `
+type Sample struct {
+	Value int64 ` + "`protobuf:\"1\"`" + `
+}
+type Index struct {
+	Counts map[string]int64  ` + "`protobuf:\"1\"`" + `
+	ByID   map[int64]*Sample ` + "`protobuf:\"2\"`" + `
+	ByName map[string]Sample ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Index", "Sample")
+	for _, want := range []string{
+		// Keys and values are decoded in any order, starting from zero values.
+		"var mk string\n\t\t\tvar mv int64\n",
+		"switch fc2.FieldNum {\n\t\t\t\tcase 1:",
+		// A missing message value is an empty message, and repeated ones are merged.
+		"var mk int64\n\t\t\tmv := &Sample{}\n",
+		"var mk string\n\t\t\tvar mv Sample\n",
+		"if err := mv.MergeFromProtobuf(vdata); err != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "mv.UnmarshalProtobuf(vdata)") {
+		t.Error("repeated map values of message type replace each other instead of being merged")
+	}
+}
+
+func TestGenerate_MergeMaps(t *testing.T) {
+	source := `
+type Totals struct {
+	Latest map[string]int64 ` + "`protobuf:\"1\"`" + `
+	Sums   map[string]int64 ` + "`protobuf:\"2,merge\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Pool: true}, "Totals")
+	unmarshal := code[strings.Index(code, "func (x *Totals) UnmarshalProtobuf("):]
+	unmarshal = unmarshal[:strings.Index(unmarshal, "\n}\n")]
+	if !strings.Contains(unmarshal, "delete(x.Latest, k)") || strings.Contains(unmarshal, "x.Sums") {
+		t.Errorf("UnmarshalProtobuf must clear Latest and keep the entries of Sums:\n%s", unmarshal)
+	}
+	if !strings.Contains(code, "delete(x.Sums, k)\n\t}\n\t_poolTotals.Put(x)") {
+		t.Error("ReleaseTotals must clear the maps with the merge option too")
+	}
+
+	for field, want := range map[string]string{
+		"A []int64 `protobuf:\"1,merge\"`":           "merge option is only supported on map fields",
+		"A *map[string]int64 `protobuf:\"1,merge\"`": "pointers to slices and maps are not supported",
+	} {
+		_, err := parseTestStruct(t, "Row", "type Row struct {\n\t"+field+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_NilAndEmpty(t *testing.T) {
+	source := `
+type Sample struct {
+	IDs    []int64          ` + "`protobuf:\"1,nonnil\"`" + `
+	Labels map[string]int32 ` + "`protobuf:\"2,nonnil\"`" + `
+	Scores []float64        ` + "`protobuf:\"3,emitempty\"`" + `
+	Counts []uint32         ` + "`protobuf:\"4\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample")
+	for _, want := range []string{
+		// Absent nonnil fields are set to empty values after the fields are parsed.
+		"\tif x.IDs == nil {\n\t\tx.IDs = []int64{}\n\t}",
+		"\tif x.Labels == nil {\n\t\tx.Labels = map[string]int32{}\n\t}",
+		// Empty emitempty fields are written as empty packed fields and read back as empty.
+		"if x.Scores != nil && len(x.Scores) == 0 {\n\t\tmm.AppendBytes(3, nil)\n\t}",
+		"\t\t\tif x.Scores == nil {\n\t\t\t\tx.Scores = []float64{}\n\t\t\t}",
+		// emitempty fields of a reused struct are reset to nil, so absent fields decode as nil.
+		"\tx.Scores = nil\n",
+		"\tx.Counts = x.Counts[:0]\n",
+		// Other empty slices are not written.
+		"if len(x.Counts) > 0 {\n\t\tmm.AppendUint32s(4, x.Counts)\n\t}",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "x.Counts = []uint32{}") {
+		t.Errorf("generated code sets Counts to an empty slice")
+	}
+
+	for _, tc := range []struct {
+		field string
+		err   string
+	}{
+		{"A int64 `protobuf:\"1,nonnil\"`", "nonnil option is only supported on repeated and map fields"},
+		{"A []string `protobuf:\"1,emitempty\"`", "emitempty option is only supported on packed repeated scalar fields"},
+		{"A []int32 `protobuf:\"1,unpacked,emitempty\"`", "emitempty option is only supported on packed repeated scalar fields"},
+		{"A map[string]int32 `protobuf:\"1,emitempty\"`", "emitempty option is only supported on packed repeated scalar fields"},
+	} {
+		_, err := parseTestStruct(t, "Sample", "type Sample struct {\n\t"+tc.field+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.field, err, tc.err)
+		}
+	}
+}
+
+func TestGenerate_Always(t *testing.T) {
+	source := `
+type Sample struct {
+	Count  int64   ` + "`protobuf:\"1\"`" + `
+	Name   string  ` + "`protobuf:\"2\"`" + `
+	Data   []byte  ` + "`protobuf:\"3\"`" + `
+	Active bool    ` + "`protobuf:\"4\"`" + `
+	Status int32   ` + "`protobuf:\"5,enum\"`" + `
+	Small  int8    ` + "`protobuf:\"6\"`" + `
+	Total  int64   ` + "`protobuf:\"7,always\"`" + `
+	Label  string  ` + "`protobuf:\"8,always\"`" + `
+	Kind   int32   ` + "`protobuf:\"9,enum,always\"`" + `
+	Ratio  float64 ` + "`protobuf:\"10,always\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample")
+	for _, want := range []string{
+		// Zero values are left out by default.
+		"if x.Count != 0 {\n\t\tmm.AppendInt64(1, x.Count)\n\t}",
+		"if len(x.Name) > 0 {\n\t\tmm.AppendString(2, x.Name)\n\t}",
+		"if len(x.Data) > 0 {\n\t\tmm.AppendBytes(3, x.Data)\n\t}",
+		"if x.Active {\n\t\tmm.AppendBool(4, x.Active)\n\t}",
+		"if x.Status != 0 {\n\t\tmm.AppendInt32(5, int32(x.Status))\n\t}",
+		"if x.Small != 0 {\n\t\tmm.AppendInt32(6, int32(x.Small))\n\t}",
+		// Fields with the always option are written unconditionally.
+		"\tmm.AppendInt64(7, x.Total)\n",
+		"\tmm.AppendString(8, x.Label)\n",
+		"\tmm.AppendInt32(9, int32(x.Kind))\n",
+		"\tmm.AppendDouble(10, x.Ratio)\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"if x.Total != 0", "if len(x.Label) > 0", "if x.Kind != 0", "if x.Ratio != 0"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+
+	for _, field := range []string{
+		"A []int64 `protobuf:\"1,always\"`",
+		"A *int64 `protobuf:\"1,always\"`",
+		"A map[string]int32 `protobuf:\"1,always\"`",
+		"A Sample `protobuf:\"1,always\"`",
+	} {
+		_, err := parseTestStruct(t, "Sample", "type Sample struct {\n\t"+field+"\n}\n")
+		want := "always option is only supported on singular non-pointer scalar, enum, string and bytes fields"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_OptionalScalars(t *testing.T) {
+	source := `
+type Status int32
+type Sample struct {
+	Count  *int64  ` + "`protobuf:\"1,extract\"`" + `
+	Name   *string ` + "`protobuf:\"2\"`" + `
+	Status *Status ` + "`protobuf:\"3,enum\"`" + `
+	Small  *int16  ` + "`protobuf:\"4,extract\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample")
+	for _, want := range []string{
+		// Nil pointers are left out and pointers to zero values are written.
+		"if x.Count != nil {\n\t\tmm.AppendInt64(1, *x.Count)\n\t}",
+		"if x.Name != nil {\n\t\tmm.AppendString(2, *x.Name)\n\t}",
+		"if x.Status != nil {\n\t\tmm.AppendInt32(3, int32(*x.Status))\n\t}",
+		// Absent fields stay nil; present ones are allocated.
+		"\tx.Count = nil\n",
+		"\t\t\tx.Count = &v\n",
+		// Extract functions tell absent fields from zero values.
+		"func ExtractSampleCount(src []byte) (*int64, error) {",
+		"\tif !found {\n\t\treturn nil, nil\n\t}\n\treturn &v, nil\n}",
+		"func ExtractSampleSmall(src []byte) (*int16, error) {",
+		"\tx := int16(v)\n\treturn &x, nil\n}",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Wrapper(t *testing.T) {
+	source := `
+type Sample struct {
+	Count *int64  ` + "`protobuf:\"1,wrapper\"`" + `
+	Name  *string ` + "`protobuf:\"2,wrapper\"`" + `
+	Small *int16  ` + "`protobuf:\"3,wrapper\"`" + `
+	Plain *int64  ` + "`protobuf:\"4\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Sample")
+	for _, want := range []string{
+		// Wrappers are nested messages holding the value, left out when zero, in field 1.
+		"if x.Count != nil {\n\t\tmm2 := mm.AppendMessage(1)\n\t\tif *x.Count != 0 {\n\t\t\tmm2.AppendInt64(1, *x.Count)\n\t\t}\n\t}",
+		"if len(*x.Name) > 0 {\n\t\t\tmm2.AppendString(1, *x.Name)",
+		"mm2.AppendInt32(1, int32(*x.Small))",
+		"v, _, err := easyproto.GetInt64(data, 1)",
+		"v, _, err := easyproto.GetString(data, 1)",
+		"if v < math.MinInt16 || v > math.MaxInt16 {",
+		"if x.Plain != nil {\n\t\tmm.AppendInt64(4, *x.Plain)\n\t}",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Sample", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Sample"}, TypeInfos: map[string]*TypeInfo{"Sample": info}}
+	protoFile, err := protoFileBackend{}.Generate(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`import "google/protobuf/wrappers.proto";`,
+		"google.protobuf.Int64Value count = 1;",
+		"google.protobuf.StringValue name = 2;",
+		"google.protobuf.Int32Value small = 3;",
+		"optional int64 plain = 4;",
+	} {
+		if !strings.Contains(string(protoFile), want) {
+			t.Errorf(".proto file missing %q", want)
+		}
+	}
+	fd, err := protodesc.NewFile(buildFileDescriptor(pkg, descriptorPath("test", pkg.Types)), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("invalid file descriptor: %v", err)
+	}
+	want := (&wrapperspb.Int64Value{}).ProtoReflect().Descriptor()
+	if got := fd.Messages().ByName("Sample").Fields().ByName("count").Message(); got != want {
+		t.Errorf("count has message type %v in the descriptor, want %v", got, want.FullName())
+	}
+
+	for _, field := range []string{
+		"A int64 `protobuf:\"1,wrapper\"`",
+		"A []int64 `protobuf:\"1,wrapper\"`",
+		"A *int64 `protobuf:\"1,sint64,wrapper\"`",
+		"A *Sample `protobuf:\"1,wrapper\"`",
+	} {
+		_, err := parseTestStruct(t, "Sample", "type Sample struct {\n\t"+field+"\n}\n")
+		want := "wrapper option is only supported on pointers to double, float, int64, uint64, int32, uint32, bool, string and bytes values"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_UUID(t *testing.T) {
+	source := `
+type Sample struct {
+	ID    uuid.UUID   ` + "`protobuf:\"1\"`" + `
+	Org   uuid.UUID   ` + "`protobuf:\"2,uuidstring\"`" + `
+	Raw   [16]byte    ` + "`protobuf:\"3\"`" + `
+	Refs  []uuid.UUID ` + "`protobuf:\"4\"`" + `
+	Names []uuid.UUID ` + "`protobuf:\"5,string\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true, Stringer: true}, "Sample")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/protoconv"`,
+		"if x.ID != [16]byte{} {\n\t\tmm.AppendBytes(1, x.ID[:])\n\t}",
+		"mm.AppendBytes(2, protoconv.AppendUUIDString(make([]byte, 0, 36), x.Org))",
+		"mm.AppendBytes(3, x.Raw[:])",
+		"mm.AppendBytes(4, x.Refs[i][:])",
+		"mm.AppendBytes(5, protoconv.AppendUUIDString(make([]byte, 0, 36), x.Names[i]))",
+		"u, err := protoconv.UUIDFromBytes(v)",
+		"u, err := protoconv.UUIDFromString(v)",
+		"x.Refs = append(x.Refs, u)",
+		"x.ID = [16]byte{}",
+		"r.Read(x.ID[:])",
+		"b = protoconv.AppendUUIDString(b, x.Org)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	// UUIDs are neither cloned nor decoded as bytes and strings.
+	for _, unwanted := range []string{"bytes.Clone", "strings.Clone", "uuid.UUID"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Sample", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Sample"}, TypeInfos: map[string]*TypeInfo{"Sample": info}}
+	protoFile, err := protoFileBackend{}.Generate(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := jsonSchemas(pkg, "#/$defs/")["Sample"].(map[string]any)["properties"].(map[string]any)
+	if got := properties["org"].(map[string]any)["format"]; got != "uuid" {
+		t.Errorf("org has format %v in the JSON Schema, want uuid", got)
+	}
+	for _, want := range []string{"bytes id = 1;", "string org = 2;", "bytes raw = 3;", "repeated bytes refs = 4;", "repeated string names = 5;"} {
+		if !strings.Contains(string(protoFile), want) {
+			t.Errorf(".proto file missing %q", want)
+		}
+	}
+
+	for field, want := range map[string]string{
+		"A *uuid.UUID `protobuf:\"1\"`":                 "UUIDs are only supported as values and slices of values",
+		"A uuid.UUID `protobuf:\"1,extract\"`":          "UUIDs are only supported as values and slices of values",
+		"A uuid.UUID `protobuf:\"1,int64\"`":            "UUIDs are encoded as bytes, or as strings with the uuidstring option",
+		"A uuid.UUID `protobuf:\"1,bytes,uuidstring\"`": "UUIDs are encoded as bytes, or as strings with the uuidstring option",
+		"A string `protobuf:\"1,uuidstring\"`":          "uuidstring option is only supported on uuid.UUID and [16]byte fields",
+		"A [8]byte `protobuf:\"1,uuidstring\"`":         "uuidstring option is only supported on uuid.UUID and [16]byte fields",
+	} {
+		_, err := parseTestStruct(t, "Sample", "type Sample struct {\n\t"+field+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_BigNumbers(t *testing.T) {
+	source := `
+type Payment struct {
+	Amount  *big.Int ` + "`protobuf:\"1,bigint\"`" + `
+	Balance big.Int  ` + "`protobuf:\"2,bytes,bigint\"`" + `
+	Rate    *big.Rat ` + "`protobuf:\"3,bigrat\"`" + `
+	Share   big.Rat  ` + "`protobuf:\"4,message,bigrat\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true, Stringer: true}, "Payment")
+	for _, want := range []string{
+		`"math/big"`,
+		`"github.com/aryehlev/easyproto-gen/protoconv"`,
+		"if x.Amount != nil {\n\t\tmm.AppendBytes(1, protoconv.AppendBigInt(nil, x.Amount))\n\t}",
+		"if x.Balance.Sign() != 0 {\n\t\tmm.AppendBytes(2, protoconv.AppendBigInt(nil, &x.Balance))\n\t}",
+		"protoconv.MarshalBigRat(mm.AppendMessage(3), x.Rate)",
+		"protoconv.MarshalBigRat(mm.AppendMessage(4), &x.Share)",
+		"x.Amount = new(big.Int)\n\t\t\tif err := protoconv.SetBigInt(x.Amount, v); err != nil {",
+		"if err := protoconv.SetBigRat(&x.Share, v); err != nil {",
+		"dst.Rate = new(big.Rat).Set(x.Rate)",
+		"dst.Balance = big.Int{}\n\tdst.Balance.Set(&x.Balance)",
+		"b = x.Amount.Append(b, 10)",
+		"b = append(b, x.Share.RatString()...)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	// Big numbers are neither cloned as bytes nor printed with strconv.
+	for _, unwanted := range []string{"bytes.Clone", "strconv"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Payment", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Payment"}, TypeInfos: map[string]*TypeInfo{"Payment": info}}
+	protoFile, err := protoFileBackend{}.Generate(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"optional bytes amount = 1; // big.Int", "bytes share = 4; // big.Rat"} {
+		if !strings.Contains(string(protoFile), want) {
+			t.Errorf(".proto file missing %q", want)
+		}
+	}
+
+	for field, want := range map[string]string{
+		"A *big.Int `protobuf:\"1\"`":               "big.Int fields need the bigint option",
+		"A big.Rat `protobuf:\"1,bigint\"`":         "big.Rat fields need the bigrat option",
+		"A int64 `protobuf:\"1,bigint\"`":           "bigint and bigrat options are only supported on big.Int and big.Rat fields",
+		"A big.Int `protobuf:\"1,message,bigint\"`": "big.Int is encoded as bytes",
+		"A []*big.Int `protobuf:\"1,bigint\"`":      "bigint and bigrat options are only supported on singular fields",
+		"A big.Rat `protobuf:\"1,bigrat,always\"`":  "bigint and bigrat options are only supported on singular fields",
+	} {
+		_, err := parseTestStruct(t, "Payment", "type Payment struct {\n\t"+field+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_Decimal(t *testing.T) {
+	source := `
+type Order struct {
+	Price decimal.Decimal  ` + "`protobuf:\"1\"`" + `
+	Tax   *decimal.Decimal ` + "`protobuf:\"2,string\"`" + `
+	Total decimal.Decimal  ` + "`protobuf:\"3,unscaled\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true, Stringer: true}, "Order")
+	for _, want := range []string{
+		`"github.com/shopspring/decimal"`,
+		`"github.com/aryehlev/easyproto-gen/protoconv"`,
+		"if !x.Price.IsZero() {\n\t\tmm.AppendString(1, x.Price.String())\n\t}",
+		"if x.Tax != nil {\n\t\tmm.AppendString(2, x.Tax.String())\n\t}",
+		"protoconv.MarshalDecimal(mm.AppendMessage(3), x.Total.Coefficient(), x.Total.Exponent())",
+		"if d, err = decimal.NewFromString(v); err != nil {",
+		"unscaled, exponent, err := protoconv.UnmarshalDecimal(v)",
+		"d := decimal.NewFromBigInt(unscaled, exponent)",
+		"x.Tax = &d",
+		"b = append(b, x.Price.String()...)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	// Decimals are neither cloned nor printed as strings and bytes.
+	for _, unwanted := range []string{"bytes.Clone", "strings.Clone", "strconv"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+
+	// Without unscaled decimals, package protoconv isn't imported.
+	code = generateTestCodeWithOptions(t, "type Order struct {\n\tPrice decimal.Decimal `protobuf:\"1\"`\n}\n", Options{}, "Order")
+	if strings.Contains(code, "protoconv") {
+		t.Error("generated code imports protoconv for decimal strings")
+	}
+
+	for field, want := range map[string]string{
+		"A decimal.Decimal `protobuf:\"1,double\"`":          "decimals are encoded as strings, or as messages with the unscaled option",
+		"A decimal.Decimal `protobuf:\"1,string,unscaled\"`": "decimals are encoded as strings, or as messages with the unscaled option",
+		"A []decimal.Decimal `protobuf:\"1\"`":               "decimals are only supported in singular fields",
+		"A int64 `protobuf:\"1,unscaled\"`":                  "unscaled option is only supported on decimal.Decimal fields",
+	} {
+		_, err := parseTestStruct(t, "Order", "type Order struct {\n\t"+field+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_SQLNull(t *testing.T) {
+	source := `
+type Row struct {
+	Name  sql.NullString ` + "`protobuf:\"1\"`" + `
+	Count sql.NullInt64  ` + "`protobuf:\"2,sint64\"`" + `
+	Small sql.NullInt16  ` + "`protobuf:\"3\"`" + `
+	Size  sql.Null[int]  ` + "`protobuf:\"4\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true, Stringer: true}, "Row")
+	for _, want := range []string{
+		`"database/sql"`,
+		"if x.Name.Valid {\n\t\tmm.AppendString(1, x.Name.String)\n\t}",
+		"if x.Count.Valid {\n\t\tmm.AppendSint64(2, x.Count.Int64)\n\t}",
+		"mm.AppendInt32(3, int32(x.Small.Int16))",
+		"mm.AppendInt64(4, int64(x.Size.V))",
+		"x.Name.String = v\n\t\t\tx.Name.Valid = true",
+		"x.Small.Int16 = int16(v)\n\t\t\tx.Small.Valid = true",
+		"value %d out of range of int16",
+		"x.Name = *new(sql.NullString)",
+		"x.Size = sql.Null[int]{}",
+		"b = strconv.AppendInt(b, int64(x.Count.Int64), 10)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Row", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	if info.ScalarOnly() {
+		t.Error("types with sql.Null fields use the fast path of scalar types")
+	}
+	pkg := &Package{Name: "test", Types: []string{"Row"}, TypeInfos: map[string]*TypeInfo{"Row": info}}
+	protoFile, err := protoFileBackend{}.Generate(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"optional string name = 1;", "optional sint64 count = 2;", "optional int32 small = 3;", "optional int64 size = 4;"} {
+		if !strings.Contains(string(protoFile), want) {
+			t.Errorf(".proto file missing %q", want)
+		}
+	}
+
+	for field, want := range map[string]string{
+		"A sql.NullTime `protobuf:\"1\"`":             "type sql.NullTime is not supported",
+		"A sql.Null[[]byte] `protobuf:\"1\"`":         "type sql.Null[[]byte] is not supported",
+		"A sql.NullInt64 `protobuf:\"1,string\"`":     "sql.NullInt64 holds int64 values",
+		"A sql.NullInt16 `protobuf:\"1,sint32\"`":     "int16 values must be encoded as int32",
+		"A *sql.NullString `protobuf:\"1\"`":          "sql.Null types are only supported in singular non-pointer fields",
+		"A []sql.NullString `protobuf:\"1\"`":         "sql.Null types are only supported in singular non-pointer fields",
+		"A sql.NullBool `protobuf:\"1,bool,always\"`": "sql.Null types are only supported in singular non-pointer fields",
+	} {
+		_, err := parseTestStruct(t, "Row", "type Row struct {\n\t"+field+"\n}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_Indirect(t *testing.T) {
+	source := `
+type Holder struct {
+	Samples *[]Sample          ` + "`protobuf:\"1\"`" + `
+	Counts  *map[string]int64  ` + "`protobuf:\"2\"`" + `
+	Blob    *[]byte            ` + "`protobuf:\"3\"`" + `
+}
+
+type Sample struct {
+	Value int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true, Stringer: true}, "Holder", "Sample")
+	for _, want := range []string{
+		"if x.Samples != nil {\n\t\t// The slice or map the field points to is encoded like a field of its type.\n\t\tx := &struct{ Samples []Sample }{Samples: *x.Samples}\n\t\tfor i := range x.Samples {",
+		"x := &struct{ Counts map[string]int64 }{Counts: *x.Counts}\n\t\tfor k, v := range x.Counts {",
+		"x.Samples = nil\n\tx.Counts = nil",
+		"if x.Samples == nil {\n\t\t\t\tx.Samples = new([]Sample)\n\t\t\t}",
+		"indirect := x.Counts\n\t\t\tx := &struct{ Counts map[string]int64 }{Counts: *indirect}",
+		"*indirect = x.Counts",
+		"indirect := &dst.Samples",
+		"*indirect = &dst.Samples",
+		"if len(x.Samples) > 0 {\n\t\t\t*indirect = &x.Samples\n\t\t}",
+		"if x.Counts != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Holder", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	samples, counts, blob := info.Fields[0], info.Fields[1], info.Fields[2]
+	if !samples.IsIndirect || !samples.IsRepeated || !samples.IsMessage || samples.GoType != "[]Sample" {
+		t.Errorf("Samples: got %+v, want an indirect repeated message field of type []Sample", samples)
+	}
+	if !counts.IsIndirect || !counts.IsMap || counts.MapKeyProto != "string" || counts.MapValueProto != "int64" {
+		t.Errorf("Counts: got %+v, want an indirect map<string, int64> field", counts)
+	}
+	if blob.IsIndirect || !blob.IsPointer || blob.ProtoType != "bytes" {
+		t.Errorf("Blob: got %+v, want an optional bytes field", blob)
+	}
+
+	for field, want := range map[string]string{
+		"A *[]Sample `protobuf:\"1,iter\"`":     "pointers to slices and maps are not supported with the func, iter",
+		"A *[]int64 `protobuf:\"1,nonnil\"`":    "pointers to slices and maps are not supported",
+		"A *[]int64 `protobuf:\"1,emitempty\"`": "pointers to slices and maps are not supported",
+	} {
+		_, err := parseTestStruct(t, "Row", "type Row struct {\n\t"+field+"\n}\n\ntype Sample struct{}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", field, err, want)
+		}
+	}
+}
+
+func TestGenerate_Mask(t *testing.T) {
+	source := `
+type Update struct {
+	ID    int64  ` + "`protobuf:\"1\"`" + `
+	Name  string ` + "`protobuf:\"2\"`" + `
+	Email string ` + "`protobuf:\"5\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Mask: true}, "Update")
+	for _, want := range []string{
+		"type UpdateFieldMask uint64",
+		"UpdateMaskID    UpdateFieldMask = 1 << 0",
+		"UpdateMaskEmail UpdateFieldMask = 1 << 2",
+		"UpdateMaskAll UpdateFieldMask = 1<<3 - 1",
+		"func (x *Update) MarshalProtobufMasked(dst []byte, mask UpdateFieldMask) []byte {",
+		"if mask&UpdateMaskEmail != 0 {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_MaskTooManyFields(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("type Wide struct {\n")
+	for i := 1; i <= maxMaskFields+1; i++ {
+		fmt.Fprintf(&sb, "\tF%d int64 `protobuf:\"%d\"`\n", i, i)
+	}
+	sb.WriteString("}\n")
+
+	info, err := parseTestStruct(t, "Wide", sb.String())
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	var buf bytes.Buffer
+	err = generateCode(&buf, &Package{Name: "test", Types: []string{"Wide"}, TypeInfos: map[string]*TypeInfo{"Wide": info}, Options: Options{Mask: true}})
+	if err == nil || !strings.Contains(err.Error(), "at most 64") {
+		t.Errorf("expected field count error, got: %v", err)
+	}
+}
+
+func TestGenerate_ValueReceivers(t *testing.T) {
+	source := `
+type Point struct {
+	X int64 ` + "`protobuf:\"1\"`" + `
+	Y int64 ` + "`protobuf:\"2\"`" + `
+}
+
+type Track struct {
+	Points []Point ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{ValueReceivers: []string{"Point"}, Deterministic: true}, "Point", "Track")
+	for _, want := range []string{
+		"func (x Point) MarshalProtobuf(dst []byte) []byte {",
+		"func (x Point) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {",
+		"func (x Point) MarshalProtobufDeterministicTo(mm *easyproto.MessageMarshaler) {",
+		"func (x Point) HashProtobuf(h hash.Hash) {",
+		"func (x *Point) UnmarshalProtobuf(src []byte) error {",
+		"func (x *Track) MarshalProtobuf(dst []byte) []byte {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	info, err := parseTestStruct(t, "Point", source)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	var buf bytes.Buffer
+	err = generateCode(&buf, &Package{Name: "test", Types: []string{"Point"}, TypeInfos: map[string]*TypeInfo{"Point": info}, Options: Options{ValueReceivers: []string{"Track"}}})
+	if err == nil || !strings.Contains(err.Error(), "value receiver type Track is not generated") {
+		t.Errorf("expected error for a value receiver type that is not generated, got: %v", err)
+	}
+}
+
+func TestGenerate_Runtime(t *testing.T) {
+	source := `
+type Event struct {
+	Name string ` + "`protobuf:\"1\"`" + `
+	Ext  *Ext   ` + "`protobuf:\"2,message,custom\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Runtime: "github.com/aryehlev/easyproto-gen/protoruntime", Deterministic: true, Random: true}, "Event")
+	for _, want := range []string{
+		`protoruntime "github.com/aryehlev/easyproto-gen/protoruntime"`,
+		"m := protoruntime.Pool.Get()",
+		"protoruntime.Clone(dst.Ext, x.Ext)",
+		"bp, _ := protoruntime.HashBufPool.Get().(*[]byte)",
+		"x.Name = protoruntime.RandomString(r)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"var _mp", "type ProtobufMarshaler", "func cloneProtobuf", "_hashBufPool", "func randomProtobufString"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated code contains header declaration %q", unwanted)
+		}
+	}
+}
+
+func TestGenerate_DetectCustomTypes(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+import "github.com/VictoriaMetrics/easyproto"
+
+type Event struct {
+	Name  string  ` + "`protobuf:\"1\"`" + `
+	Raw   *Raw    ` + "`protobuf:\"2\"`" + `
+	Raws  []Raw   ` + "`protobuf:\"3\"`" + `
+	Meta  *Meta   ` + "`protobuf:\"4\"`" + `
+	Point *Point  ` + "`protobuf:\"5\"`" + `
+}
+
+// Raw is hand-written easyproto code.
+type Raw struct{ N int64 }
+
+func (r *Raw) MarshalProtobuf(dst []byte) []byte { return dst }
+
+func (r *Raw) UnmarshalProtobuf(src []byte) error { return nil }
+
+// Meta implements the methods of custom types.
+type Meta struct{ S string }
+
+func (m *Meta) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {}
+
+func (m *Meta) UnmarshalProtobuf(src []byte) error { return nil }
+
+// Point is generated by another invocation.
+type Point struct{ X int64 }
+
+func (p *Point) MarshalProtobuf(dst []byte) []byte { return dst }
+
+func (p *Point) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {}
+
+func (p *Point) UnmarshalProtobuf(src []byte) error { return nil }
+
+func (p *Point) CloneProtobufInto(dst *Point) {}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"Event"}, Output: "-"}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		"mm.AppendBytes(2, x.Raw.MarshalProtobuf(nil))",
+		"bufRaws = x.Raws[i].MarshalProtobuf(bufRaws[:0])",
+		"x.Raw.UnmarshalProtobuf(data)",
+		"cloneProtobufBytes(dst.Raw, x.Raw)",
+		"cloneProtobufBytes(&dst.Raws[i], &x.Raws[i])",
+		"func cloneProtobufBytes(",
+		"x.Meta.MarshalProtobufTo(mm.AppendMessage(4))",
+		"x.Meta.UnmarshalProtobuf(data)",
+		"cloneProtobuf(dst.Meta, x.Meta)",
+		"x.Point.MergeFromProtobuf(data)",
+		"dst.Point = x.Point.CloneProtobuf()",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	cfg.Options.Runtime = "github.com/aryehlev/easyproto-gen/protoruntime"
+	if files, err = Generate(context.Background(), cfg); err != nil {
+		t.Fatalf("Generate with Runtime failed: %v", err)
+	}
+	code = string(files[0].Content)
+	if !strings.Contains(code, "protoruntime.CloneBytes(dst.Raw, x.Raw)") || strings.Contains(code, "func cloneProtobufBytes(") {
+		t.Error("generated code with Runtime does not use protoruntime.CloneBytes")
+	}
+}
+
+func TestGenerate_MethodNames(t *testing.T) {
+	source := `
+type Point struct {
+	X int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type Track struct {
+	Start  *Point    ` + "`protobuf:\"1\"`" + `
+	Points []Point   ` + "`protobuf:\"2\"`" + `
+	Ext    *Ext      ` + "`protobuf:\"3,message,custom\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{MethodPrefix: "Fast", MethodSuffix: "PB"}, "Point", "Track")
+	for _, want := range []string{
+		"func (x *Track) FastMarshalPB(dst []byte) []byte {",
+		"func (x *Track) FastMarshalPBTo(mm *easyproto.MessageMarshaler) {",
+		"func (x *Track) FastUnmarshalPB(src []byte) error {",
+		"func (x *Track) FastMergeFromPB(src []byte) (err error) {",
+		"x.Start.FastMarshalPBTo(",
+		"x.Ext.UnmarshalProtobuf(data)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, ") MarshalProtobuf(") {
+		t.Error("generated code still declares MarshalProtobuf")
+	}
+
+	info, err := parseTestStruct(t, "Point", source)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	for _, tt := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{MethodSuffix: "PB", ProtoAdapter: true}, "requires the default MarshalProtobuf and UnmarshalProtobuf method names"},
+		{Options{MethodPrefix: "fast"}, "is not an exported identifier"},
+	} {
+		var buf bytes.Buffer
+		err := generateCode(&buf, &Package{Name: "test", Types: []string{"Point"}, TypeInfos: map[string]*TypeInfo{"Point": info}, Options: tt.opts})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: expected error containing %q, got: %v", tt.opts, tt.want, err)
+		}
+	}
+}
+
+func TestGenerate_Filter(t *testing.T) {
+	source := `
+type Content interface{}
+type Text struct{}
+type Image struct{}
+type Event struct {
+	ID      int64   ` + "`protobuf:\"1\"`" + `
+	Email   string  ` + "`protobuf:\"4\"`" + `
+	Payload Content ` + "`protobuf:\"oneof,Text:2,Image:3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Event")
+	if strings.Contains(code, "FilterEventProtobuf") {
+		t.Error("expected no filter function by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Filter: true}, "Event")
+	for _, want := range []string{
+		`"slices"`,
+		"func FilterEventProtobuf(dst, src []byte, drop ...int) ([]byte, error) {",
+		"case 2, 3, 1, 4:",
+		"if !slices.Contains(drop, int(fc.FieldNum)) {",
+		"easyprotoerr.ErrUnknownField",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_SizeBreakdown(t *testing.T) {
+	source := `
+type Content interface{}
+type Text struct{}
+type Image struct{}
+type Event struct {
+	ID      int64   ` + "`protobuf:\"1\"`" + `
+	Payload Content ` + "`protobuf:\"oneof,Text:2,Image:3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Event")
+	if strings.Contains(code, "SizeBreakdown") {
+		t.Error("expected no SizeBreakdown method by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{SizeBreakdown: true}, "Event")
+	for _, want := range []string{
+		"func (x *Event) SizeBreakdown() map[string]int {",
+		"src := x.MarshalProtobuf(nil)",
+		"case 1:\n\t\t\tsizes[\"ID\"] += len(src) - len(tail)",
+		"case 2, 3:\n\t\t\tsizes[\"Payload\"] += len(src) - len(tail)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Stringer(t *testing.T) {
+	source := `
+type User struct {
+	ID    int64  ` + "`protobuf:\"1\"`" + `
+	Email string ` + "`protobuf:\"2,redact\"`" + `
+}
+type Message struct {
+	Text   string  ` + "`protobuf:\"1\"`" + `
+	Sender *User   ` + "`protobuf:\"2\"`" + `
+	Tags   []int32 ` + "`protobuf:\"3\"`" + `
+	Owner  User    ` + "`protobuf:\"4\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Message", "User")
+	if strings.Contains(code, "String() string") {
+		t.Error("expected no String method by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Stringer: true}, "Message", "User")
+	for _, want := range []string{
+		"func (x *Message) String() string {",
+		"func (x *User) appendString(b []byte) []byte {",
+		"if len(x.Text) > 0 {",
+		"b = strconv.AppendQuote(b, string(x.Text))",
+		"b = x.Sender.appendString(b)",
+		"b = strconv.AppendInt(b, int64(x.Tags[i]), 10)",
+		"b = append(b, \"<redacted>\"...)",
+		// Owner is stored by value, and left out when empty.
+		"n := len(b)",
+		"b = x.Owner.appendString(b)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	appendString := code[strings.Index(code, "func (x *User) appendString"):]
+	appendString = appendString[:strings.Index(appendString, "\n}\n")]
+	if strings.Contains(appendString, "b = strconv.AppendQuote(b, string(x.Email))") {
+		t.Errorf("String must not print the redacted Email:\n%s", appendString)
+	}
+
+	// Types printing no scalars don't import strconv.
+	source = `
+type Secret struct {
+	Key string ` + "`protobuf:\"1,redact\"`" + `
+}
+`
+	code = generateTestCodeWithOptions(t, source, Options{Stringer: true}, "Secret")
+	if strings.Contains(code, `"strconv"`) {
+		t.Error("generated code imports strconv without printing scalars")
+	}
+}
+
+func TestGenerate_Limit(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type Series struct {
+	Name    string    ` + "`protobuf:\"1\"`" + `
+	Samples []*Sample ` + "`protobuf:\"2\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series", "Sample")
+	if strings.Contains(code, "MarshalProtobufLimit") {
+		t.Error("expected no MarshalProtobufLimit method by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Limit: true}, "Series", "Sample")
+	for _, want := range []string{
+		"func (x *Series) MarshalProtobufLimit(dst []byte, max int) ([]byte, error) {",
+		"mm := m.MessageMarshaler()\n\t\tif len(x.Name) > 0 {",
+		// Repeated messages are checked after every element.
+		"x.Samples[i].MarshalProtobufTo(m.MessageMarshaler().AppendMessage(2))",
+		`return dst[:start], fmt.Errorf("cannot marshal Series: %w: longer than %d bytes at field Samples", easyprotoerr.ErrTooLarge, max)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Pool(t *testing.T) {
+	source := `
+type Sample struct {
+	Value float64 ` + "`protobuf:\"1\"`" + `
+}
+type Series struct {
+	Samples []*Sample ` + "`protobuf:\"1\"`" + `
+	Strict  []*Sample ` + "`protobuf:\"2,nilerror\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Series", "Sample")
+	if strings.Contains(code, "AcquireSample") {
+		t.Error("expected no pool functions by default")
+	}
+	for _, want := range []string{
+		"for _, v := range x.Samples {\n\t\tif v != nil {",
+		"for i, v := range x.Strict {\n\t\tif v == nil {\n\t\t\tpanic(fmt.Errorf(\"cannot marshal field Strict: %w at index %d\", easyprotoerr.ErrNilElement, i))\n\t\t}",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Pool: true}, "Series", "Sample")
+	for _, want := range []string{
+		"var _poolSeries sync.Pool",
+		"func AcquireSample() *Sample {\n\tif x, ok := _poolSample.Get().(*Sample); ok {\n\t\treturn x\n\t}\n\treturn &Sample{}\n}",
+		"func ReleaseSeries(x *Series) {\n\t// Set default values\n\tx.Samples = x.Samples[:0]",
+		"_poolSeries.Put(x)",
+		"if item == nil {\n\t\t\t\titem = AcquireSample()",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	_, err := parseTestStruct(t, "Row", "type Row struct {\n\tA []Sample `protobuf:\"1,nilerror\"`\n}\n\ntype Sample struct{}\n")
+	if err == nil || !strings.Contains(err.Error(), "nilerror option is only supported on message pointer") {
+		t.Errorf("got error %v, want the nilerror option rejected on []Sample", err)
+	}
+}
+
+func TestGenerate_NilPolicies(t *testing.T) {
+	source := `
+type Line struct {
+	Qty int64 ` + "`protobuf:\"1\"`" + `
+}
+type Order struct {
+	Customer *Line            ` + "`protobuf:\"1,nilerror\"`" + `
+	Discount *Line            ` + "`protobuf:\"2,nilempty\"`" + `
+	Lines    []*Line          ` + "`protobuf:\"3,nilempty\"`" + `
+	Prices   map[string]int64 ` + "`protobuf:\"4,nilerror\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Limit: true}, "Order", "Line")
+	for _, want := range []string{
+		"if x.Customer == nil {\n\t\tpanic(fmt.Errorf(\"cannot marshal field Customer: %w\", easyprotoerr.ErrNilField))\n\t}",
+		"} else {\n\t\t// A nil message is encoded as an empty one.\n\t\tmm.AppendMessage(2)\n\t}",
+		"for _, v := range x.Lines {\n\t\tif v == nil {\n\t\t\t// A nil element is encoded as an empty message.\n\t\t\tmm.AppendMessage(3)\n\t\t\tcontinue\n\t\t}",
+		"if x.Lines[i] == nil {\n\t\t\t// A nil element is encoded as an empty message.\n\t\t\tm.MessageMarshaler().AppendMessage(3)\n\t\t} else {",
+		"if x.Prices == nil {\n\t\tpanic(fmt.Errorf(\"cannot marshal field Prices: %w\", easyprotoerr.ErrNilField))\n\t}",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	for _, tc := range []struct {
+		field, want string
+	}{
+		{"A map[string]int64 `protobuf:\"1,nilempty\"`", "nilempty option is only supported on message pointer"},
+		{"A *int64 `protobuf:\"1,nilerror\"`", "nilerror option is only supported on message pointer"},
+		{"A *Line `protobuf:\"1,nilerror,nilempty\"`", "nilerror and nilempty options can't be combined"},
+	} {
+		_, err := parseTestStruct(t, "Row", "type Row struct {\n\t"+tc.field+"\n}\n\ntype Line struct{}\n")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.field, err, tc.want)
+		}
+	}
+}
+
+func TestGenerate_Observe(t *testing.T) {
+	source := `
+type Event struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Event")
+	if strings.Contains(code, "protoobserve") {
+		t.Error("expected no observer calls by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Observe: true}, "Event")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/protoobserve"`,
+		"\"time\"",
+		"if protoobserve.Enabled {\n\t\tstart, n := time.Now(), len(dst)",
+		`protoobserve.Observe("test.Event", protoobserve.OpMarshal, len(dst)-n, start)`,
+		`defer protoobserve.Observe("test.Event", protoobserve.OpUnmarshal, len(src), time.Now())`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Redact(t *testing.T) {
+	source := `
+type User struct {
+	ID    int64  ` + "`protobuf:\"1\"`" + `
+	Email string ` + "`protobuf:\"2,redact\"`" + `
+}
+type Message struct {
+	Text   string ` + "`protobuf:\"1\"`" + `
+	Sender *User  ` + "`protobuf:\"2\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Message")
+	if strings.Contains(code, "MarshalProtobufRedacted") {
+		t.Error("expected no redacted methods without redacted fields")
+	}
+
+	code = generateTestCode(t, source, "Message", "User")
+	for _, want := range []string{
+		"func (x *Message) MarshalProtobufRedacted(dst []byte) []byte {",
+		"func (x *User) MarshalProtobufRedactedTo(mm *easyproto.MessageMarshaler) {",
+		"x.Sender.MarshalProtobufRedactedTo(mm.AppendMessage(2))",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	redactedTo := code[strings.Index(code, "func (x *User) MarshalProtobufRedactedTo"):]
+	redactedTo = redactedTo[:strings.Index(redactedTo, "\n}\n")]
+	if strings.Contains(redactedTo, "x.Email") {
+		t.Errorf("redacted marshaler must not encode Email:\n%s", redactedTo)
+	}
+}
+
+func TestGenerate_MergeFromProtobuf(t *testing.T) {
+	source := `
+type Content interface{}
+type Text struct{}
+type User struct{}
+type Ext struct{}
+type Message struct {
+	Sender  *User   ` + "`protobuf:\"1\"`" + `
+	Owner   User    ` + "`protobuf:\"2\"`" + `
+	Ext     *Ext    ` + "`protobuf:\"3,message,custom\"`" + `
+	Content Content ` + "`protobuf:\"oneof,Text:4\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Message")
+	for _, want := range []string{
+		"return x.MergeFromProtobuf(src)",
+		"func (x *Message) MergeFromProtobuf(src []byte) (err error) {",
+		"x.Sender.MergeFromProtobuf(data)",
+		"x.Owner.MergeFromProtobuf(data)",
+		"x.Ext.UnmarshalProtobuf(data)",
+		"v, _ := x.Content.(*Text)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_CloneProtobuf(t *testing.T) {
+	source := `
+type Status int32
+type Content interface{}
+type Text struct{}
+type Ext struct{}
+type User struct{}
+type Message struct {
+	Data    []byte            ` + "`protobuf:\"1\"`" + `
+	Nums    []int64           ` + "`protobuf:\"2\"`" + `
+	Sender  *User             ` + "`protobuf:\"3\"`" + `
+	Owner   User              ` + "`protobuf:\"4\"`" + `
+	Users   []User            ` + "`protobuf:\"5\"`" + `
+	Labels  map[string]*User  ` + "`protobuf:\"6\"`" + `
+	Status  *Status           ` + "`protobuf:\"7,enum\"`" + `
+	Ext     *Ext              ` + "`protobuf:\"8,message,custom\"`" + `
+	Content Content           ` + "`protobuf:\"oneof,Text:9\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Message")
+	for _, want := range []string{
+		"func (x *Message) CloneProtobuf() *Message {",
+		"func (x *Message) CloneProtobufInto(dst *Message) {",
+		"*dst = *x",
+		"dst.Data = bytes.Clone(x.Data)",
+		"dst.Nums = slices.Clone(x.Nums)",
+		"dst.Sender = x.Sender.CloneProtobuf()",
+		"x.Owner.CloneProtobufInto(&dst.Owner)",
+		"x.Users[i].CloneProtobufInto(&dst.Users[i])",
+		"dst.Labels = make(map[string]*User, len(x.Labels))",
+		"v = v.CloneProtobuf()",
+		"dst.Status = &v",
+		"cloneProtobuf(dst.Ext, x.Ext)",
+		"dst.Content = v.CloneProtobuf()",
+		"func cloneProtobuf(dst ProtobufUnmarshaler, src ProtobufMarshaler) {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "strings.Clone(x.") {
+		t.Error("expected strings not to be copied by default")
+	}
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	source := `
+type Ext struct{}
+type Item struct{}
+type Index struct {
+	Names map[string]int64 ` + "`protobuf:\"1\"`" + `
+	Flags map[bool]*Item   ` + "`protobuf:\"2\"`" + `
+	Ext   *Ext             ` + "`protobuf:\"3,message,custom\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Index")
+	if strings.Contains(code, "MarshalProtobufDeterministic") || strings.Contains(code, "HashProtobuf") {
+		t.Error("expected no deterministic marshaling by default")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Deterministic: true}, "Index")
+	for _, want := range []string{
+		"func (x *Index) MarshalProtobufDeterministic(dst []byte) []byte {",
+		"func (x *Index) MarshalProtobufDeterministicTo(mm *easyproto.MessageMarshaler) {",
+		"keys := make([]string, 0, len(x.Names))",
+		"slices.Sort(keys)",
+		"for _, k := range [...]bool{false, true} {",
+		"v.MarshalProtobufDeterministicTo(mm2.AppendMessage(2))",
+		"x.Ext.MarshalProtobufTo(mm.AppendMessage(3))",
+		"func (x *Index) HashProtobuf(h hash.Hash) {",
+		"var _hashBufPool sync.Pool",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_ErrorPaths(t *testing.T) {
+	source := `
+type User struct{}
+type Message struct {
+	Text   string           ` + "`protobuf:\"1\"`" + `
+	Sender *User            ` + "`protobuf:\"2\"`" + `
+	Users  map[string]*User ` + "`protobuf:\"3\"`" + `
+}
+`
+	code := generateTestCode(t, source, "Message")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/easyprotoerr"`,
+		"offset := n - len(src)",
+		`return easyprotoerr.Field("Message", "", offset, easyprotoerr.NextField(src, err))`,
+		`return easyprotoerr.Field("Message", "Text", offset, fmt.Errorf("cannot read string: %w", easyprotoerr.ErrWireTypeMismatch))`,
+		`return easyprotoerr.Nested("Message", "Sender", n-len(src)-len(data), err)`,
+		`return easyprotoerr.Nested("Message", "Users", n-len(src)-len(data)-len(vdata), err)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+}
+
+func TestCheckCompat(t *testing.T) {
+	schemaOf := func(source string) schema {
+		t.Helper()
+		info, err := parseTestStruct(t, "Event", source)
+		if err != nil {
+			t.Fatalf("failed to parse struct: %v", err)
+		}
+		return buildSchema(map[string]*TypeInfo{"Event": info})
+	}
+	old := schemaOf(`
+type Event struct {
+	ID      int64             ` + "`protobuf:\"1\"`" + `
+	Name    string            ` + "`protobuf:\"2\"`" + `
+	Score   float64           ` + "`protobuf:\"3\"`" + `
+	Tags    []string          ` + "`protobuf:\"4\"`" + `
+	Note    *string           ` + "`protobuf:\"5\"`" + `
+	Count   int32             ` + "`protobuf:\"6\"`" + `
+	Labels  map[string]string ` + "`protobuf:\"7\"`" + `
+	Kind    int32             ` + "`protobuf:\"8\"`" + `
+}
+`)
+	cur := schemaOf(`
+type Event struct {
+	ID      int32   ` + "`protobuf:\"1\"`" + `
+	Title   []byte  ` + "`protobuf:\"2\"`" + `
+	Score   int64   ` + "`protobuf:\"3\"`" + `
+	Tags    string  ` + "`protobuf:\"4\"`" + `
+	Total   int64   ` + "`protobuf:\"6\"`" + `
+	Kind    []int32 ` + "`protobuf:\"8\"`" + `
+}
+`)
+	got := checkCompat(old, cur, nil)
+	want := []string{
+		"Event.Count (6): field number reused by Total with type int64 (was int32)",
+		"Event.Kind (8): wire type changed from varint to packed varint",
+		"Event.Name (2): field number reused by Title with type bytes (was string)",
+		"Event.Score (3): wire type changed from fixed64 to varint",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if issues := checkCompat(old, old, nil); len(issues) != 0 {
+		t.Errorf("expected no issues comparing a schema with itself, got %v", issues)
+	}
+
+	removed := schemaOf(`
+type Event struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`)
+	got = checkCompat(old, removed, nil)
+	want = []string{
+		"Event.Count (6): field removed without reserving its number",
+		"Event.Kind (8): field removed without reserving its number",
+		"Event.Name (2): field removed without reserving its number",
+		"Event.Score (3): field removed without reserving its number",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Reserving the numbers of removed fields deletes them safely.
+	reserved := map[string][]reservedRange{"Event": {{2, 3}, {6, 6}, {8, 8}}}
+	if issues := checkCompat(old, removed, reserved); len(issues) != 0 {
+		t.Errorf("expected no issues for reserved removed fields, got %v", issues)
+	}
+}
+
+func TestTag(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Status int32
+
+//protogen:reserved 5
+type Order struct {
+	ID     int64 ` + "`protobuf:\"1\"`" + `
+	Name   string ` + "`json:\"name\"`" + `
+	Items  []*Item
+	Status Status
+	note   string
+}
+
+type Item struct {
+	SKU string
+}
+`
+	path := filepath.Join(dir, "types.go")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Tag(dir, []string{"Order", "Item"})
+	if err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != path {
+		t.Fatalf("expected %s to be rewritten, got %v", path, files)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		"ID     int64   `protobuf:\"1\"`",
+		"Name   string  `json:\"name\" protobuf:\"6\"`",
+		"Items  []*Item `protobuf:\"7\"`",
+		"Status Status  `protobuf:\"8,enum\"`",
+		"note   string\n",
+		"SKU string `protobuf:\"1\"`",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("tagged code doesn't contain %q:\n%s", want, code)
+		}
+	}
+
+	// Tagged types are left alone.
+	if err := os.WriteFile(path, files[0].Content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := Tag(dir, []string{"Order"}); err != nil || len(files) != 0 {
+		t.Errorf("expected no changes, got %v, %v", files, err)
+	}
+
+	for _, field := range []string{"Handler interface{ Handle() }", "Time time.Time", "A, B string", "Kind Kind"} {
+		src := "package test\n\ntype Kind string\n\ntype Event struct {\n\t" + field + "\n}\n"
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var declErr *Error
+		if _, err := Tag(dir, []string{"Event"}); !errors.As(err, &declErr) {
+			t.Errorf("%s: expected an *Error, got %v", field, err)
+		}
+	}
+
+	// Numbers of package math/big get the options encoding them.
+	src = "package test\n\nimport \"math/big\"\n\ntype Account struct {\n\tBalance *big.Int\n\tRate    big.Rat\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err = Tag(dir, []string{"Account"})
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Tag failed: %v, %v", files, err)
+	}
+	for _, want := range []string{"Balance *big.Int `protobuf:\"1,bigint\"`", "Rate    big.Rat  `protobuf:\"2,bigrat\"`"} {
+		if !strings.Contains(string(files[0].Content), want) {
+			t.Errorf("tagged code doesn't contain %q:\n%s", want, files[0].Content)
+		}
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+//protogen:reserved 2
+type Order struct {
+	ID    int64    ` + "`protobuf:\"1\"`" + `
+	Note  *string  ` + "`json:\"note\" protobuf:\"6\"`" + `
+	Items []string ` + "`protobuf:\"9,deprecated\"`" + `
+	Tags  []string ` + "`protobuf:\"12\"`" + `
+	Total int64    ` + "`protobuf:\"15\"`" + `
+}
+`
+	path := filepath.Join(dir, "types.go")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	renumber := func(r Renumbering) ([]File, []FieldMove, error) {
+		t.Helper()
+		return Renumber(dir, "Order", r)
+	}
+
+	// Without a lock file, all fields may have old data, which moving them would lose. Moving
+	// Total also reuses the number of Note.
+	_, _, err := renumber(Renumbering{Compact: true})
+	var ce *CompatError
+	if want := []string{
+		"Order.Items (9): field number changed to 4",
+		"Order.Note (6): field number changed to 3",
+		"Order.Tags (12): field number changed to 5",
+		"Order.Total (15): field number changed to 6",
+		"Order.Total (15): field removed without reserving its number",
+		"Order.Total (6): wire type changed from bytes to varint",
+	}; !errors.As(err, &ce) || !slices.Equal(ce.Issues, want) {
+		t.Fatalf("expected the compat issues of the renumbering, got %v", err)
+	}
+	// Swapped fields would decode each other's old data.
+	_, _, err = renumber(Renumbering{Moves: map[string]int{"Items": 12, "Tags": 9}})
+	if want := []string{
+		"Order.Items (12): field number of Tags taken over",
+		"Order.Items (9): field number changed to 12",
+		"Order.Tags (12): field number changed to 9",
+		"Order.Tags (9): field number of Items taken over",
+	}; !errors.As(err, &ce) || !slices.Equal(ce.Issues, want) {
+		t.Fatalf("expected the swap to be rejected, got %v", err)
+	}
+
+	// Fields added since the lock file was written have no old data.
+	data, err := marshalLock(schema{"Order": {{Name: "ID", Num: 1, Type: "int64"}, {Name: "Total", Num: 15, Type: "int64"}}})
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, lockFileName), data, 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, moves, err := renumber(Renumbering{Compact: true, Moves: map[string]int{"Total": 15, "Tags": 20}})
+	if err != nil {
+		t.Fatalf("Renumber failed: %v", err)
+	}
+	if want := []FieldMove{{"Note", 6, 3}, {"Items", 9, 4}, {"Tags", 12, 20}}; !slices.Equal(moves, want) {
+		t.Errorf("got moves %v, want %v", moves, want)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{"`json:\"note\" protobuf:\"3\"`", "`protobuf:\"4,deprecated\"`", "`protobuf:\"20\"`", "`protobuf:\"15\"`"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("renumbered code doesn't contain %q:\n%s", want, code)
+		}
+	}
+
+	if _, _, err := renumber(Renumbering{Compact: true, Force: true}); err != nil {
+		t.Errorf("expected Force to accept incompatible changes, got %v", err)
+	}
+	for _, moves := range []map[string]int{{"Note": 2}, {"Note": 9}, {"Missing": 3}, {"Note": 19500}} {
+		var declErr *Error
+		if _, _, err := renumber(Renumbering{Moves: moves}); !errors.As(err, &declErr) {
+			t.Errorf("expected an *Error for moves %v, got %v", moves, err)
+		}
+	}
+
+	// Compacting optional, repeated and map fields changes the numbers of their old data too.
+	src = `package test
+
+type Event struct {
+	A int64            ` + "`protobuf:\"1\"`" + `
+	B *string          ` + "`protobuf:\"5\"`" + `
+	C []int32          ` + "`protobuf:\"7\"`" + `
+	D map[string]int64 ` + "`protobuf:\"9\"`" + `
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Renumber(dir, "Event", Renumbering{Compact: true})
+	if want := []string{
+		"Event.B (5): field number changed to 2",
+		"Event.C (7): field number changed to 3",
+		"Event.D (9): field number changed to 4",
+	}; !errors.As(err, &ce) || !slices.Equal(ce.Issues, want) {
+		t.Errorf("expected the compaction of Event to be rejected, got %v", err)
+	}
+	if _, moves, err := Renumber(dir, "Event", Renumbering{Compact: true, Force: true}); err != nil || len(moves) != 3 {
+		t.Errorf("expected Force to compact Event, got %v, %v", moves, err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Order struct {
+	ID    int64            ` + "`protobuf:\"1\"`" + `
+	Delta int32            ` + "`protobuf:\"2,sint32\"`" + `
+	Items []*Item          ` + "`protobuf:\"3\"`" + `
+	Attrs map[string]int64 ` + "`protobuf:\"4\"`" + `
+}
+
+type Item struct {
+	SKU   string  ` + "`protobuf:\"1\"`" + `
+	Sizes []int32 ` + "`protobuf:\"2\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mp easyproto.MarshalerPool
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 150)
+	mm.AppendSint32(2, -5)
+	item := mm.AppendMessage(3)
+	item.AppendString(1, "a")
+	item.AppendInt32s(2, []int32{1, 2})
+	entry := mm.AppendMessage(4)
+	entry.AppendString(1, "k")
+	entry.AppendInt64(2, 7)
+	mm.AppendString(9, "extra")
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	out, err := Inspect(dir, "Order", data)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	want := `0   1: 150        # ID int64
+3   2: -5z        # Delta int32 sint32
+5   3: {          # Items []*Item, 7 bytes
+7     1: {"a"}    # SKU string, 1 byte
+10    2: {1 2}    # Sizes []int32, 2 bytes
+    }
+14  4: {          # Attrs map[string]int64, 5 bytes
+16    1: {"k"}    # key string, 1 byte
+19    2: 7        # value int64
+    }
+21  9: {"extra"}  # unknown, 5 bytes
+`
+	if out != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = Inspect(dir, "Order", data[:len(data)-1])
+	if err == nil || !strings.Contains(err.Error(), "offset 22") {
+		t.Errorf("expected an error at offset 22 for truncated data, got %v", err)
+	}
+	if !strings.HasSuffix(out, "# value int64\n    }\n") {
+		t.Errorf("expected the fields before the error, got:\n%s", out)
+	}
+
+	if _, err := Inspect(dir, "Missing", data); err == nil || !strings.Contains(err.Error(), "type Missing not found") {
+		t.Errorf("expected an error for a missing type, got %v", err)
+	}
+}
+
+func TestSizeBreakdown(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Order struct {
+	ID    int64   ` + "`protobuf:\"1\"`" + `
+	Items []*Item ` + "`protobuf:\"2\"`" + `
+}
+
+type Item struct {
+	SKU string ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mp easyproto.MarshalerPool
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, 150)
+	mm.AppendMessage(2).AppendString(1, "abc")
+	mm.AppendMessage(2).AppendString(1, "de")
+	mm.AppendString(9, "extra")
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	sizes, err := SizeBreakdown(dir, "Order", data)
+	if err != nil {
+		t.Fatalf("SizeBreakdown failed: %v", err)
+	}
+	want := []FieldSize{
+		{Field: "Items", Num: 2, Count: 2, Bytes: 13},
+		{Field: "", Num: 9, Count: 1, Bytes: 7},
+		{Field: "ID", Num: 1, Count: 1, Bytes: 3},
+	}
+	if !slices.Equal(sizes, want) {
+		t.Errorf("got %+v, want %+v", sizes, want)
+	}
+
+	sizes, err = SizeBreakdown(dir, "Order", data[:len(data)-1])
+	if err == nil || !strings.Contains(err.Error(), "malformed field 9 at offset 16") {
+		t.Errorf("expected an error for truncated data, got %v", err)
+	}
+	if len(sizes) != 2 {
+		t.Errorf("expected the sizes of the fields before the error, got %+v", sizes)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFileName)
+	locked, err := readLock(path)
+	if err != nil || len(locked) != 0 {
+		t.Fatalf("expected empty schema for missing lock file, got %v, %v", locked, err)
+	}
+
+	v1 := schema{"Event": {
+		{Name: "ID", Num: 1, Type: "int64"},
+		{Name: "Legacy", Num: 2, Type: "string"},
+	}}
+	writeLock := func(s schema) {
+		t.Helper()
+		data, err := marshalLock(s)
+		if err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+		if err != nil {
+			t.Fatalf("failed to write lock file: %v", err)
+		}
+	}
+	writeLock(updateLock(locked, v1))
+
+	// Removing a field keeps its number reserved in the lock file.
+	v2 := schema{"Event": {{Name: "ID", Num: 1, Type: "int64"}}}
+	locked, err = readLock(path)
+	if err != nil {
+		t.Fatalf("failed to read lock file: %v", err)
+	}
+	if issues := checkCompat(locked, v2, nil); len(issues) != 1 || !strings.Contains(issues[0], "field removed without reserving its number") {
+		t.Fatalf("expected required field removal, got %v", issues)
+	}
+	writeLock(updateLock(locked, v2))
+	locked, err = readLock(path)
+	if err != nil {
+		t.Fatalf("failed to read lock file: %v", err)
+	}
+	if f := locked["Event"][1]; f.Name != "Legacy" || !f.Removed {
+		t.Fatalf("expected Legacy to be recorded as removed, got %+v", locked["Event"])
+	}
+	if issues := checkCompat(locked, v2, nil); len(issues) != 0 {
+		t.Fatalf("expected no issues after the removal was locked, got %v", issues)
+	}
+
+	// Reusing the number of the removed field is rejected even with a compatible type.
+	v3 := schema{"Event": {
+		{Name: "ID", Num: 1, Type: "int64"},
+		{Name: "Title", Num: 2, Type: "string"},
+	}}
+	want := "Event.Title (2): field number of removed field Legacy reused"
+	if issues := checkCompat(locked, v3, nil); len(issues) != 1 || issues[0] != want {
+		t.Fatalf("got %v, want [%s]", issues, want)
+	}
+
+	// Types not being generated are kept as is.
+	other := schema{"Other": {{Name: "X", Num: 1, Type: "bool"}}}
+	if updated := updateLock(other, v3); len(updated["Other"]) != 1 || len(updated["Event"]) != 2 {
+		t.Fatalf("unexpected updated lock: %v", updated)
+	}
+}
+
+func TestReservedDirective(t *testing.T) {
+	parse := func(source string) error {
+		t.Helper()
+		f, err := parser.ParseFile(token.NewFileSet(), "test.go", "package test\n\n"+source, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("failed to parse source: %v", err)
+		}
+		return forEachStruct([]*ast.File{f}, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+			info, err := parseStruct(typeName, structType)
+			if err != nil {
+				return err
+			}
+			return applyDirectives(info, doc)
+		})
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			source: `
+// Event is an event.
+//
+//protogen:reserved 3,4,10-15,OldName
+type Event struct {
+	ID   int64  ` + "`protobuf:\"1\"`" + `
+	Name string ` + "`protobuf:\"16\"`" + `
+}`,
+		},
+		{
+			name: "reserved number",
+			source: `
+//protogen:reserved 3,4
+type Event struct {
+	ID int64 ` + "`protobuf:\"4\"`" + `
+}`,
+			wantErr: `field "ID" in type Event uses reserved field number 4`,
+		},
+		{
+			name: "reserved range in grouped declaration",
+			source: `
+type (
+	//protogen:reserved 10-15
+	Event struct {
+		ID int64 ` + "`protobuf:\"12\"`" + `
+	}
+)`,
+			wantErr: "uses reserved field number 12",
+		},
+		{
+			name: "reserved oneof variant",
+			source: `
+type Content interface{}
+//protogen:reserved 5
+type Event struct {
+	Content Content ` + "`protobuf:\"oneof,Text:5\"`" + `
+}`,
+			wantErr: `oneof variant "Text" of field "Content" in type Event uses reserved field number 5`,
+		},
+		{
+			name: "reserved name",
+			source: `
+//protogen:reserved 3,OldName
+type Event struct {
+	OldName string ` + "`protobuf:\"1\"`" + `
+}`,
+			wantErr: `field name "OldName" in type Event is reserved`,
+		},
+		{
+			name: "invalid range",
+			source: `
+//protogen:reserved 15-10
+type Event struct{}`,
+			wantErr: `invalid reserved field number "15-10"`,
+		},
+		{
+			name: "unknown directive",
+			source: `
+//protogen:reserve 3
+type Event struct{}`,
+			wantErr: "unknown directive //protogen:reserve",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parse(tc.source)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGenerate_Deprecated(t *testing.T) {
+	source := `
+type User struct {
+	ID       int64  ` + "`protobuf:\"1\"`" + `
+	Nickname string ` + "`protobuf:\"2,deprecated,extract\"`" + `
+}
+`
+	info, err := parseTestStruct(t, "User", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	if !info.Fields[1].IsDeprecated || !info.Fields[1].IsExtract {
+		t.Fatalf("expected Nickname to be deprecated and extracted, got %+v", info.Fields[1])
+	}
+
+	code := generateTestCodeWithOptions(t, source, Options{Mask: true}, "User")
+	for _, want := range []string{
+		"// Deprecated: User.Nickname is deprecated.\n\tUserMaskNickname",
+		"// Deprecated: User.Nickname is deprecated.\nfunc ExtractUserNickname(",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Count(code, "Deprecated:") != 2 {
+		t.Errorf("expected only the Nickname accessors to be deprecated")
+	}
+
+	test := `package test
+
+func TestUser(t *testing.T) {
+	u := &User{ID: 1, Nickname: "old"}
+	us := []test.User{{Nickname: "x"}}
+	_, _ = u, us
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "user_test.go", test, 0)
+	if err != nil {
+		t.Fatalf("failed to parse test source: %v", err)
+	}
+	got := deprecatedUses(fset, []*ast.File{f}, map[string]*TypeInfo{"User": info})
+	want := []string{"user_test.go:4:20: deprecated field User.Nickname is set"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got warnings %q, want %q", got, want)
+	}
+}
+
+func TestVetAnalyzer(t *testing.T) {
+	source := `package test
+
+type Bad struct {
+	Num  int64              ` + "`protobuf:\"x\"`" + `
+	Keys map[float64]string ` + "`protobuf:\"2\"`" + `
+	Any  any                ` + "`protobuf:\"3\"`" + `
+}
+
+type Dup struct {
+	A int64  ` + "`protobuf:\"1\"`" + `
+	B string ` + "`protobuf:\"1\"`" + `
+}
+
+type Stale struct {
+	ID   int64  ` + "`protobuf:\"1\"`" + `
+	Name string ` + "`protobuf:\"3\"`" + `
+}
+`
+	generated := generatedHeader + `
+
+package test
+
+func (x *Stale) MergeFromProtobuf(src []byte) (err error) {
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		switch fc.FieldNum {
+		case 1:
+			return easyprotoerr.Field("Stale", "ID", 0, nil)
+		case 2:
+			return easyprotoerr.Field("Stale", "Name", 0, nil)
+		}
+	}
+	return nil
+}
+`
+	pbGo := `// Code generated by protoc-gen-go. DO NOT EDIT.
+
+package test
+
+type Message struct {
+	Id int64 ` + "`protobuf:\"varint,1,opt,name=id,proto3\"`" + `
+}
+`
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, src := range map[string]string{"test.go": source, "test_proto.go": generated, "test.pb.go": pbGo} {
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+		files = append(files, f)
+	}
+
+	var got []string
+	pass := &analysis.Pass{
+		Analyzer: Analyzer,
+		Fset:     fset,
+		Files:    files,
+		Report: func(d analysis.Diagnostic) {
+			got = append(got, fmt.Sprintf("%d: %s", fset.Position(d.Pos).Line, d.Message))
+		},
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatalf("analyzer failed: %v", err)
+	}
+	slices.Sort(got)
+
+	want := []string{
+		`9: duplicate field number 1: used by both "A" and "B" in type Dup`,
+		`14: generated code for Stale is out of date: field Name (3) is not handled; rerun go generate`,
+		`14: generated code for Stale is out of date: field Name (2) no longer exists; rerun go generate`,
+		`4: invalid field number in tag "x": must be a number`,
+		`5: invalid map key type "double" in tag "2": must be string, bool, or integer type`,
+		`6: interface types are not supported for protobuf (use oneof tag for polymorphism): field "Any" in type Bad has type any`,
+	}
+	slices.Sort(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+}
+
+func TestSourceHash(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sourceHashOf := func(invocation string) string {
+		t.Helper()
+		hash, pkgName, err := sourceHash(dir, invocation, false)
+		if err != nil {
+			t.Fatalf("sourceHash failed: %v", err)
+		}
+		if pkgName != "test" {
+			t.Errorf("got package name %q, want %q", pkgName, "test")
+		}
+		return hash
+	}
+
+	writeFile("user.go", "package test\n\ntype User struct{}\n")
+	hash := sourceHashOf("-type=User")
+
+	// Generated code and tests don't affect the hash.
+	code := addSourceHash([]byte(generatedHeader+"\n\npackage test\n"), hash)
+	writeFile("user_proto.go", string(code))
+	writeFile("user_test.go", "package test\n")
+	if got := sourceHashOf("-type=User"); got != hash {
+		t.Errorf("hash changed by generated or test files")
+	}
+	if got := readSourceHash(filepath.Join(dir, "user_proto.go")); got != hash {
+		t.Errorf("readSourceHash() = %q, want %q", got, hash)
+	}
+
+	if sourceHashOf("-type=User -arena=true") == hash {
+		t.Errorf("hash not changed by the invocation")
+	}
+	writeFile("user.go", "package test\n\ntype User struct{ ID int64 }\n")
+	if sourceHashOf("-type=User") == hash {
+		t.Errorf("hash not changed by the sources")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "user.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(`package test
+
+type User struct {
+	ID   int64  ` + "`protobuf:\"1\"`" + `
+	Name string ` + "`protobuf:\"2\"`" + `
+}
+`)
+	cfg := Config{Dir: dir, Types: []string{"User"}, Fuzz: true, Lock: true}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, filepath.Base(f.Path))
+	}
+	if want := []string{"user_proto.go", "user_proto_fuzz_test.go", lockFileName}; !slices.Equal(paths, want) {
+		t.Fatalf("got files %v, want %v", paths, want)
+	}
+	if !bytes.Contains(files[0].Content, []byte("func (x *User) MarshalProtobuf(dst []byte) []byte {")) {
+		t.Errorf("generated code missing MarshalProtobuf")
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.Path, f.Content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Errors are attributed to their fields.
+	writeFile(`package test
+
+type User struct {
+	ID   int64  ` + "`protobuf:\"1\"`" + `
+	Name string ` + "`protobuf:\"x\"`" + `
+	Tags any    ` + "`protobuf:\"3\"`" + `
+}
+`)
+	_, err = Generate(context.Background(), cfg)
+	var fieldErrs []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("got %T, want *Error", err)
+		}
+		fieldErrs = append(fieldErrs, fmt.Sprintf("%d %s.%s", e.Pos.Line, e.Type, e.Field))
+	}
+	if want := []string{"5 User.Name", "6 User.Tags"}; !slices.Equal(fieldErrs, want) {
+		t.Errorf("got errors %v, want %v", fieldErrs, want)
+	}
+
+	_, err = Generate(context.Background(), Config{Dir: dir, Types: []string{"Missing"}})
+	var e *Error
+	if !errors.As(err, &e) || e.Type != "Missing" || e.Pos.IsValid() {
+		t.Errorf("expected a position-less *Error for a missing type, got %v", err)
+	}
+
+	// Incompatible changes to the lock file.
+	writeFile(`package test
+
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`)
+	_, err = Generate(context.Background(), cfg)
+	var ce *CompatError
+	if !errors.As(err, &ce) || len(ce.Issues) != 1 || !strings.Contains(ce.Issues[0], "User.Name (2): field removed without reserving its number") {
+		t.Errorf("expected a *CompatError for the removed field, got %v", err)
+	}
+
+	// Reserving the number of the removed field makes the removal compatible, and the lock
+	// file records it so that the number is never reused.
+	writeFile(`package test
+
+//protogen:reserved 2,Name
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`)
+	files, err = Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate after reserving the removed field failed: %v", err)
+	}
+	lock := files[len(files)-1]
+	if err := os.WriteFile(lock.Path, lock.Content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := readLock(lock.Path)
+	if err != nil || len(locked["User"]) != 2 || locked["User"][1].Name != "Name" || !locked["User"][1].Removed {
+		t.Fatalf("expected the lock file to record Name as removed, got %+v, %v", locked["User"], err)
+	}
+	writeFile(`package test
+
+type User struct {
+	ID    int64  ` + "`protobuf:\"1\"`" + `
+	Title string ` + "`protobuf:\"2\"`" + `
+}
+`)
+	_, err = Generate(context.Background(), cfg)
+	if !errors.As(err, &ce) || len(ce.Issues) != 1 || !strings.Contains(ce.Issues[0], "User.Title (2): field number of removed field Name reused") {
+		t.Errorf("expected a *CompatError for the reused number, got %v", err)
+	}
+	cfg.AllowBreaking = true
+	if _, err := Generate(context.Background(), cfg); err != nil {
+		t.Errorf("Generate with AllowBreaking failed: %v", err)
+	}
+
+	// Methods declared outside of the output file collide with the generated ones.
+	if err := os.WriteFile(filepath.Join(dir, "methods.go"), []byte("package test\n\nfunc (u *User) MarshalProtobuf(dst []byte) []byte { return dst }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Generate(context.Background(), cfg)
+	if !errors.Is(err, ErrMethodExists) || !errors.As(err, &e) || e.Type != "User" || e.Pos.Line != 3 {
+		t.Errorf("expected an *Error wrapping ErrMethodExists, got %v", err)
+	}
+	if _, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"User"}, Options: Options{MethodSuffix: "PB"}}); err != nil {
+		t.Errorf("Generate with renamed methods failed: %v", err)
+	}
+	cfg.Force = true
+	if _, err := Generate(context.Background(), cfg); err != nil {
+		t.Errorf("Generate with Force failed: %v", err)
+	}
+}
+
+func TestGenerate_HeaderDecls(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type Item struct {
+	Name string ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generate := func(cfg Config) []byte {
+		t.Helper()
+		files, err := Generate(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if err := os.WriteFile(files[0].Path, files[0].Content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return files[0].Content
+	}
+
+	user := generate(Config{Dir: dir, Types: []string{"User"}})
+	if !bytes.Contains(user, []byte("var _mp easyproto.MarshalerPool")) {
+		t.Errorf("first generated file has no header")
+	}
+	// Only the declarations missing from user_proto.go are declared by item_proto.go.
+	item := generate(Config{Dir: dir, Types: []string{"Item"}, Options: Options{Deterministic: true}})
+	if bytes.Contains(item, []byte("var _mp")) || bytes.Contains(item, []byte("type ProtobufMarshaler")) {
+		t.Errorf("second generated file repeats the header of the first one")
+	}
+	if !bytes.Contains(item, []byte("var _hashBufPool sync.Pool")) {
+		t.Errorf("second generated file is missing _hashBufPool")
+	}
+	// The header of a regenerated file is kept, as the other file doesn't declare it.
+	if user = generate(Config{Dir: dir, Types: []string{"User"}}); !bytes.Contains(user, []byte("var _mp easyproto.MarshalerPool")) {
+		t.Errorf("regenerated file lost its header")
+	}
+}
+
+func TestGenerate_Split(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type User struct {
+	ID    int64  ` + "`protobuf:\"1\"`" + `
+	Email string ` + "`protobuf:\"2,redact\"`" + `
+}
+
+type Team struct {
+	Owner *User ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"User", "Team"}, Split: true, Emit: []string{"easyproto", "proto"}}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, filepath.Base(f.Path))
+	}
+	if want := []string{"user_proto.go", "team_proto.go", "test_proto.proto"}; !slices.Equal(paths, want) {
+		t.Fatalf("got files %v, want %v", paths, want)
+	}
+	user, team := string(files[0].Content), string(files[1].Content)
+	if !strings.Contains(user, "var _mp easyproto.MarshalerPool") || strings.Contains(team, "var _mp") {
+		t.Errorf("the header must only be in the first file")
+	}
+	if !strings.Contains(user, "func (x *User) MarshalProtobuf(") || strings.Contains(user, "func (x *Team)") {
+		t.Errorf("user_proto.go doesn't have exactly the methods of User")
+	}
+	// Team has MarshalProtobufRedacted for the redacted field of User in the other file.
+	if !strings.Contains(team, "x.Owner.MarshalProtobufRedactedTo(") {
+		t.Errorf("team_proto.go doesn't marshal Owner redacted")
+	}
+
+	cfg.Output = filepath.Join(dir, "all_proto.go")
+	if _, err := Generate(context.Background(), cfg); err == nil {
+		t.Errorf("expected an error for Split with Output")
+	}
+}
+
+func TestGenerate_TypePatterns(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type EventOpened struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type EventClosed struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type EventInternal struct {
+	Seq int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type EventHandler struct {
+	Name string
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Event*"}, ExcludeTypes: []string{"EventInternal"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if base := filepath.Base(files[0].Path); base != "test_proto.go" {
+		t.Errorf("got output %s, want test_proto.go", base)
+	}
+	code := string(files[0].Content)
+	closed, opened := strings.Index(code, "func (x *EventClosed) MarshalProtobuf("), strings.Index(code, "func (x *EventOpened) MarshalProtobuf(")
+	if closed < 0 || opened < closed {
+		t.Errorf("expected the methods of EventClosed and EventOpened, sorted by name")
+	}
+	// EventHandler has no protobuf tags and EventInternal is excluded.
+	if strings.Contains(code, "*EventHandler)") || strings.Contains(code, "*EventInternal)") {
+		t.Errorf("generated methods of types the patterns shouldn't match")
+	}
+
+	for _, cfg := range []Config{
+		{Dir: dir, Types: []string{"Message*"}},
+		{Dir: dir, Types: []string{"Event["}},
+		{Dir: dir, Types: []string{"Event*"}, ExcludeTypes: []string{"Event*"}},
+	} {
+		if _, err := Generate(context.Background(), cfg); err == nil {
+			t.Errorf("expected an error for types %q excluding %q", cfg.Types, cfg.ExcludeTypes)
+		}
+	}
+}
+
+func TestGenerate_Scan(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+// Sample is generated with its options.
+//
+//protogen:generate
+//protogen:options value-receiver hot=Value
+type Sample struct {
+	Name  string  ` + "`protobuf:\"1\"`" + `
+	Value float64 ` + "`protobuf:\"2\"`" + `
+}
+
+//protogen:generate
+type Series struct {
+	Samples []Sample ` + "`protobuf:\"1\"`" + `
+}
+
+type Unmarked struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Scan: true})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if base := filepath.Base(files[0].Path); base != "test_proto.go" {
+		t.Errorf("got output %s, want test_proto.go", base)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		"func (x Sample) MarshalProtobufTo(",
+		"func (x *Series) UnmarshalProtobuf(",
+		"if fc.FieldNum == 2 {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(code, "*Unmarked)") {
+		t.Errorf("generated methods of a type without a generate directive")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte("package test\n\ntype Unmarked struct{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(context.Background(), Config{Dir: dir, Scan: true}); err == nil {
+		t.Errorf("expected an error for a package without generate directives")
+	}
+}
+
+func TestTypeOptionsDirective(t *testing.T) {
+	for _, tc := range []struct {
+		directive string
+		wantErr   string
+	}{
+		{"//protogen:options value-receiver", ""},
+		{"//protogen:options hot=Name,ID", ""},
+		{"//protogen:options hot=Missing", "hot field Event.Missing not found"},
+		{"//protogen:options hot", "hot option of type Event requires field names"},
+		{"//protogen:options pool", `unknown option "pool"`},
+		{"//protogen:generate now", "takes no arguments"},
+	} {
+		f, err := parser.ParseFile(token.NewFileSet(), "test.go", "package test\n\n"+tc.directive+"\ntype Event struct {\n\tID int64 `protobuf:\"1\"`\n\tName string `protobuf:\"2\"`\n}\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var info *TypeInfo
+		err = forEachStruct([]*ast.File{f}, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+			if info, err = parseStruct(typeName, structType); err != nil {
+				return err
+			}
+			return applyDirectives(info, doc)
+		})
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.directive, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: got error %v, want %q", tc.directive, err, tc.wantErr)
+		}
+	}
+}
+
+func TestExcludedFields(t *testing.T) {
+	source := `
+type Session struct {
+	ID    string            ` + "`protobuf:\"1\"`" + `
+	mu    sync.Mutex        ` + "`protobuf:\"-\"`" + `
+	Cache map[string]string ` + "`protobuf:\"-\"`" + `
+	Note  string
+}
+`
+	info, err := parseTestStruct(t, "Session", source)
+	if err != nil {
+		t.Fatalf("failed to parse struct: %v", err)
+	}
+	if len(info.Fields) != 1 || !slices.Equal(info.Excluded, []string{"mu", "Cache"}) || !slices.Equal(info.Untagged, []string{"Note"}) {
+		t.Errorf("got fields %d, excluded %v, untagged %v", len(info.Fields), info.Excluded, info.Untagged)
+	}
+
+	code := generateTestCode(t, source, "Session")
+	clone := code[strings.Index(code, "func (x *Session) CloneProtobufInto("):]
+	clone = clone[:strings.Index(clone, "\n}\n")]
+	if strings.Contains(clone, "*dst = *x") || strings.Contains(clone, "x.mu") || strings.Contains(clone, "x.Cache") {
+		t.Errorf("CloneProtobufInto copies excluded fields:\n%s", clone)
+	}
+	if !strings.Contains(clone, "dst.Note = x.Note") || !strings.Contains(clone, "dst.ID = x.ID") {
+		t.Errorf("CloneProtobufInto doesn't copy the other fields:\n%s", clone)
+	}
+
+	if _, err := parseTestStruct(t, "Event", "type Event struct {\n\tID int64 `protobuf:\"-,1\"`\n}"); err == nil || !strings.Contains(err.Error(), "takes no options") {
+		t.Errorf("expected an error for options of an excluded field, got %v", err)
+	}
+}
+
+func TestGenerate_StrictFields(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+import "sync"
+
+type Base struct{}
+
+type Session struct {
+	ID      string ` + "`protobuf:\"1\"`" + `
+	Name    string ` + "`json:\"name\"`" + `
+	Cache   map[string]string ` + "`protobuf:\"-\"`" + `
+	A, B    int64
+	Base
+	mu      sync.Mutex
+	visited bool
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"Session"}}
+	if _, err := Generate(context.Background(), cfg); err != nil {
+		t.Fatalf("Generate without StrictFields failed: %v", err)
+	}
+
+	cfg.StrictFields = true
+	_, err := Generate(context.Background(), cfg)
+	if err == nil {
+		t.Fatal("expected errors for the untagged exported fields")
+	}
+	var fields []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var declErr *Error
+		if !errors.As(e, &declErr) {
+			t.Fatalf("expected *Error values, got %v", e)
+		}
+		fields = append(fields, declErr.Field)
+	}
+	if want := []string{"Name", "A", "B", "Base"}; !slices.Equal(fields, want) {
+		t.Errorf("got errors for fields %v, want %v", fields, want)
+	}
+}
+
+func TestGenerate_GenericTypes(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Item struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type Page[T any] struct {
+	Items []T    ` + "`protobuf:\"1\"`" + `
+	Next  string ` + "`protobuf:\"2\"`" + `
+	First *T     ` + "`protobuf:\"3\"`" + `
+}
+
+type Result struct {
+	Page Page[Item] ` + "`protobuf:\"1\"`" + `
+}
+
+type Index[K comparable] struct {
+	Keys map[string]K ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Item", "Page", "Result"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		"func (x *Page[T]) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {",
+		"protobufMessageOf(&x.Items[i]).MarshalProtobufTo(mm.AppendMessage(1))",
+		"x.Items = append(x.Items, *new(T))",
+		"x.First = new(T)",
+		"func (x *Page[T]) CloneProtobuf() *Page[T] {",
+		"x.Page.MarshalProtobufTo(mm.AppendMessage(1))",
+		"func protobufMessageOf(v any) interface {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code doesn't contain %q", want)
+		}
+	}
+
+	for _, tc := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Types: []string{"Page[Item]"}}, "generate Page instead"},
+		{Config{Types: []string{"Page"}, Options: Options{Arena: true}}, "generic type Page is not supported with arena"},
+		{Config{Types: []string{"Page"}, Options: Options{Pool: true}}, "generic type Page is not supported with pool"},
+		{Config{Types: []string{"Page"}, Options: Options{Direct: true}}, "generic type Page is not supported with direct"},
+		{Config{Types: []string{"Page"}, Fuzz: true}, "fuzz and conformance tests need type arguments"},
+		{Config{Types: []string{"Index"}}, "map field \"Keys\" in generic type Index"},
+	} {
+		tc.cfg.Dir = dir
+		if _, err := Generate(context.Background(), tc.cfg); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Generate(%v) = %v, want an error containing %q", tc.cfg.Types, err, tc.want)
+		}
+	}
+}
+
+func TestGenerate_BuildTags(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"free.go": "//go:build !pro\n\npackage test\n\ntype Plan struct {\n\tID int64 `protobuf:\"1\"`\n}\n",
+		"pro.go":  "//go:build pro\n\npackage test\n\ntype Plan struct {\n\tID    int64  `protobuf:\"1\"`\n\tSeats uint32 `protobuf:\"2\"`\n}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Plan"}, Tags: []string{"pro"}, Options: Options{BuildConstraint: "pro"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	if !strings.Contains(code, "\n\n//go:build pro\n\npackage test\n") {
+		t.Errorf("generated code has no build constraint:\n%s", code)
+	}
+	if !strings.Contains(code, "mm.AppendUint32(2, x.Seats)") {
+		t.Errorf("generated code is not for the type of pro.go")
+	}
+
+	info, err := parseTestStruct(t, "Plan", "type Plan struct {\n\tID int64 `protobuf:\"1\"`\n}\n")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	var buf bytes.Buffer
+	err = generateCode(&buf, &Package{Name: "test", Types: []string{"Plan"}, TypeInfos: map[string]*TypeInfo{"Plan": info}, Options: Options{BuildConstraint: "linux &&"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid build constraint") {
+		t.Errorf("expected an invalid build constraint error, got: %v", err)
+	}
+}
+
+func TestGenerate_IncludeTests(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"user.go":         "package test\n\ntype User struct {\n\tID int64 `protobuf:\"1\"`\n}\n",
+		"fixture_test.go": "package test\n\ntype Fixture struct {\n\tUser *User `protobuf:\"1\"`\n}\n",
+		"example_test.go": "package test_test\n\ntype Fixture struct{}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Fixture"}}); err == nil || !strings.Contains(err.Error(), "type Fixture not found") {
+		t.Errorf("expected test files to be skipped by default, got: %v", err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Fixture"}, IncludeTests: true})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if got := filepath.Base(files[0].Path); got != "fixture_proto_test.go" {
+		t.Errorf("got output %s, want fixture_proto_test.go", got)
+	}
+	if !bytes.Contains(files[0].Content, []byte("func (x *Fixture) MarshalProtobuf(dst []byte) []byte {")) {
+		t.Errorf("generated code missing Fixture.MarshalProtobuf")
+	}
+	_, err = Generate(context.Background(), Config{Dir: dir, Types: []string{"Fixture"}, IncludeTests: true, Output: filepath.Join(dir, "fixture_proto.go")})
+	if err == nil || !strings.Contains(err.Error(), "must be a _test.go file") {
+		t.Errorf("expected an error for a non-test output file, got: %v", err)
+	}
+}
+
+func TestGenerate_Doc(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Content interface{}
+
+// Order is a customer order.
+//
+//protogen:reserved 9
+type Order struct {
+	// ID identifies the order.
+	ID      int64            ` + "`protobuf:\"1\"`" + `
+	Items   []*Item          ` + "`protobuf:\"2\"`" + `
+	Scores  []int32          ` + "`protobuf:\"3\"`" + `
+	Note    string           ` + "`protobuf:\"4,deprecated\"`" + ` // Free text | markdown
+	Labels  map[string]int64 ` + "`protobuf:\"5\"`" + `
+	Payload Content          ` + "`protobuf:\"oneof,Item:6\"`" + `
+}
+
+type Item struct {
+	Price float64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	docPath := filepath.Join(dir, "schema.md")
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Order", "Item"}, Doc: docPath})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(files) != 2 || files[1].Path != docPath {
+		t.Fatalf("expected the code and %s, got %d files", docPath, len(files))
+	}
+	doc := string(files[1].Content)
+	for _, want := range []string{
+		"## Order\n\nOrder is a customer order.\n\n| Field | Number | Type | Wire type | Description |",
+		"| `id` | 1 | `int64` | VARINT | ID identifies the order. |",
+		"| `items` | 2 | `repeated` [`Item`](#item) | LEN |  |",
+		"| `scores` | 3 | `repeated int32` | LEN (packed VARINT) |  |",
+		"| `note` | 4 | `string` | LEN | **Deprecated.** Free text \\| markdown |",
+		"| `labels` | 5 | `map<string, int64>` | LEN |  |",
+		"| `payload_item` | 6 | [`Item`](#item) | LEN | One of `payload`. |",
+		"## Item\n\n| Field",
+		"| `price` | 1 | `double` | I64 |  |",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("schema document missing %q:\n%s", want, doc)
+		}
+	}
+	if strings.Contains(doc, "protogen:reserved") {
+		t.Error("expected directives to be left out of the schema document")
+	}
+}
+
+func TestGenerate_Graph(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Content interface{}
+
+type Order struct {
+	Items    []*Item          ` + "`protobuf:\"1\"`" + `
+	Parent   *Order           ` + "`protobuf:\"2\"`" + `
+	ByName   map[string]*Item ` + "`protobuf:\"3\"`" + `
+	Payload  Content          ` + "`protobuf:\"oneof,Item:4,Customer:5\"`" + `
+	Customer *Customer        ` + "`protobuf:\"6\"`" + `
+}
+
+type Item struct {
+	Price float64 ` + "`protobuf:\"1\"`" + `
+}
+
+type Customer struct {
+	Name string ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Order", "Item"}, Graph: "dot"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(files) != 2 || files[1].Path != filepath.Join(dir, "test_proto.dot") {
+		t.Fatalf("expected the code and test_proto.dot, got %d files", len(files))
+	}
+	dot := string(files[1].Content)
+	for _, want := range []string{
+		"digraph \"test\" {",
+		"\t\"Order\";\n\t\"Item\";\n\t\"Customer\" [style=dashed];\n",
+		"\"Order\" -> \"Item\" [label=\"Items (repeated)\"];",
+		"\"Order\" -> \"Order\" [label=\"Parent\"];",
+		"\"Order\" -> \"Item\" [label=\"ByName (map)\"];",
+		"\"Order\" -> \"Item\" [label=\"Payload (oneof)\"];",
+		"\"Order\" -> \"Customer\" [label=\"Payload (oneof)\"];",
+		"\"Order\" -> \"Customer\" [label=\"Customer\"];",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT graph missing %q:\n%s", want, dot)
+		}
+	}
+
+	files, err = Generate(context.Background(), Config{Dir: dir, Types: []string{"Order", "Item"}, Graph: "mermaid"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	mermaid := string(files[1].Content)
+	for _, want := range []string{
+		"flowchart LR\n",
+		"\tCustomer[\"Customer\"]:::external\n",
+		"\tOrder -->|\"ByName (map)\"| Item\n",
+		"\tclassDef external stroke-dasharray: 5 5\n",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid graph missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	if _, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Order"}, Graph: "svg"}); err == nil || !strings.Contains(err.Error(), "unknown graph format") {
+		t.Errorf("expected an error for an unknown graph format, got: %v", err)
+	}
+}
+
+func TestJSONSchemaBackend(t *testing.T) {
+	dir := t.TempDir()
+	src := `package test
+
+type Content interface{}
+
+type Text struct {
+	Body string
+}
+
+// Order is a customer order.
+type Order struct {
+	UserID  int64            ` + "`protobuf:\"1\"`" + ` // Owner of the order
+	Items   []*Item          ` + "`protobuf:\"2\"`" + `
+	Counts  map[uint32]bool  ` + "`protobuf:\"3\"`" + `
+	Payload Content          ` + "`protobuf:\"oneof,Text:4:string,Item:5\"`" + `
+	Limit   *uint32          ` + "`protobuf:\"6,wrapper\"`" + `
+	Data    []byte           ` + "`protobuf:\"7,deprecated\"`" + `
+}
+
+type Item struct {
+	Price float64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Order", "Item"}, Emit: []string{"jsonschema"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != filepath.Join(dir, "test_proto.schema.json") {
+		t.Fatalf("expected test_proto.schema.json, got %d files", len(files))
+	}
+	var doc struct {
+		Schema string                     `json:"$schema"`
+		Defs   map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(files[0].Content, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.Schema != "https://json-schema.org/draft/2020-12/schema" || len(doc.Defs) != 2 {
+		t.Fatalf("unexpected document: %s", files[0].Content)
+	}
+	var order struct {
+		Description string                     `json:"description"`
+		Properties  map[string]json.RawMessage `json:"properties"`
+		AllOf       []json.RawMessage          `json:"allOf"`
+	}
+	if err := json.Unmarshal(doc.Defs["Order"], &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Description != "Order is a customer order." {
+		t.Errorf("got description %q", order.Description)
+	}
+	for name, want := range map[string]string{
+		"userId":      `{"description":"Owner of the order","format":"int64","pattern":"^-?[0-9]+$","type":["string","integer"]}`,
+		"items":       `{"items":{"$ref":"#/$defs/Item"},"type":"array"}`,
+		"counts":      `{"additionalProperties":{"type":"boolean"},"propertyNames":{"pattern":"^[0-9]+$"},"type":"object"}`,
+		"payloadText": `{"type":"string"}`,
+		"payloadItem": `{"$ref":"#/$defs/Item"}`,
+		"limit":       `{"anyOf":[{"maximum":4294967295,"minimum":0,"type":"integer"},{"type":"null"}]}`,
+		"data":        `{"contentEncoding":"base64","deprecated":true,"type":"string"}`,
+	} {
+		var got bytes.Buffer
+		if err := json.Compact(&got, order.Properties[name]); err != nil || got.String() != want {
+			t.Errorf("property %s: got %s, want %s", name, order.Properties[name], want)
+		}
+	}
+	if len(order.AllOf) != 1 || !strings.Contains(string(order.AllOf[0]), `"not"`) {
+		t.Errorf("expected a constraint allowing at most one variant of the oneof, got %s", order.AllOf)
+	}
+
+	// The names are those protojson writes.
+	fset := token.NewFileSet()
+	_, astFiles, err := parsePackage(fset, dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeInfos, err := parseTypes(fset, astFiles, []string{"Order", "Item"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &Package{Name: "test", Types: []string{"Order", "Item"}, TypeInfos: typeInfos}
+	fd, err := protodesc.NewFile(buildFileDescriptor(pkg, descriptorPath("test", pkg.Types)), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("invalid file descriptor: %v", err)
+	}
+	fields := fd.Messages().ByName("Order").Fields()
+	for i := range fields.Len() {
+		if name := fields.Get(i).JSONName(); order.Properties[name] == nil {
+			t.Errorf("schema has no property %s", name)
+		}
+	}
+}
+
+func TestOpenAPIBackend(t *testing.T) {
+	dir := t.TempDir()
+	src := `package chat
+
+import "context"
+
+// Message is a chat message.
+type Message struct {
+	Text   string ` + "`protobuf:\"1\"`" + `
+	Sender *User  ` + "`protobuf:\"2\"`" + `
+}
+
+type Ack struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type User struct {
+	Name string ` + "`protobuf:\"1\"`" + `
+}
+
+type ChatService interface {
+	Send(ctx context.Context, msg *Message) (*Ack, error)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "chat.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Message", "Ack"}, Services: []string{"ChatService"}, Emit: []string{"openapi"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != filepath.Join(dir, "chat_proto.openapi.json") {
+		t.Fatalf("expected chat_proto.openapi.json, got %d files", len(files))
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(files[0].Content, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "chat" {
+		t.Errorf("unexpected document header: %s", files[0].Content)
+	}
+	if len(doc.Components.Schemas) != 2 {
+		t.Errorf("expected schemas of Message and Ack, got %d", len(doc.Components.Schemas))
+	}
+	for _, want := range []string{
+		`"description":"Message is a chat message."`,
+		`"text":{"type":"string"}`,
+		// User isn't generated, so its fields are unknown.
+		`"sender":{}`,
+	} {
+		var got bytes.Buffer
+		json.Compact(&got, doc.Components.Schemas["Message"])
+		if !strings.Contains(got.String(), want) {
+			t.Errorf("Message schema missing %s: %s", want, got.String())
+		}
+	}
+	var op bytes.Buffer
+	json.Compact(&op, doc.Paths["/chat.ChatService/Send"]["post"])
+	for _, want := range []string{
+		`"operationId":"ChatService_Send"`,
+		`"requestBody":{"content":{"application/x-protobuf":{"schema":{"$ref":"#/components/schemas/Message"}}},"required":true}`,
+		`"200":{"content":{"application/x-protobuf":{"schema":{"$ref":"#/components/schemas/Ack"}}},"description":"Ack message"}`,
+	} {
+		if !strings.Contains(op.String(), want) {
+			t.Errorf("Send operation missing %s: %s", want, op.String())
+		}
+	}
+}
+
+// typeListBackend emits the names of the generated types.
+type typeListBackend struct{}
+
+func (typeListBackend) Name() string { return "types" }
+
+func (typeListBackend) Path(output string) string { return output + ".txt" }
+
+func (typeListBackend) Generate(pkg *Package) ([]byte, error) {
+	return []byte(strings.Join(pkg.Types, "\n")), nil
+}
+
+func TestGenerate_Backends(t *testing.T) {
+	dir := t.TempDir()
+	source := `package test
+
+type Status int32
+
+type Event struct {
+	ID       int64             ` + "`protobuf:\"1\"`" + `
+	UserIDs  []int64           ` + "`protobuf:\"2\"`" + `
+	Labels   map[string]string ` + "`protobuf:\"3\"`" + `
+	Parent   *Event            ` + "`protobuf:\"4\"`" + `
+	Status   Status            ` + "`protobuf:\"5,enum\"`" + `
+	Note     *string           ` + "`protobuf:\"6\"`" + `
+	Legacy   string            ` + "`protobuf:\"7,deprecated\"`" + `
+	HTTPCode sint32Alias       ` + "`protobuf:\"8,sint32\"`" + `
+}
+
+type sint32Alias int32
+`
+	if err := os.WriteFile(filepath.Join(dir, "event.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"Event"}, Emit: []string{"proto", "types"}, Backends: []Backend{typeListBackend{}}}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(files) != 2 || filepath.Base(files[0].Path) != "event_proto.proto" || string(files[1].Content) != "Event" {
+		t.Fatalf("unexpected files %v", files)
+	}
+	for _, want := range []string{
+		"package test;",
+		"message Event {\n  int64 id = 1;\n  repeated int64 user_ids = 2;\n  map<string, string> labels = 3;\n  Event parent = 4;",
+		"  int32 status = 5; // enum Status\n  optional string note = 6;\n  string legacy = 7 [deprecated = true];\n  sint32 http_code = 8;\n}",
+	} {
+		if !strings.Contains(string(files[0].Content), want) {
+			t.Errorf("generated .proto file missing %q:\n%s", want, files[0].Content)
+		}
+	}
+
+	cfg.Emit = []string{"swift"}
+	if _, err := Generate(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), `unknown backend "swift"`) {
+		t.Errorf("expected an unknown backend error, got %v", err)
+	}
+
+	for name, want := range map[string]string{"ID": "id", "UserIDs": "user_ids", "HTTPCode": "http_code", "Name": "name", "parentID": "parent_id"} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGenerate_Templates(t *testing.T) {
+	source := `
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	tracing := `{{define "imports"}}
+	"context"
+{{end}}
+{{range .Types}}
+// MarshalProtobufTraced marshals {{.}} within a span.
+func (x *{{.}}) MarshalProtobufTraced(ctx context.Context, dst []byte) []byte {
+	return x.MarshalProtobuf(dst)
+}
+{{end}}`
+	code := generateTestCodeWithOptions(t, source, Options{Templates: []string{tracing}}, "User")
+	for _, want := range []string{
+		"\t\"github.com/aryehlev/easyproto-gen/groups\"\n\n\t\"context\"\n)",
+		"func (x *User) MarshalProtobufTraced(ctx context.Context, dst []byte) []byte {",
+		"func (x *User) MarshalProtobuf(dst []byte) []byte {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	// Named templates replace the built-in ones.
+	override := `{{define "marshalField"}}
+	// {{.Field.Name}} is not marshaled.
+{{- end}}`
+	code = generateTestCodeWithOptions(t, source, Options{Templates: []string{override}}, "User")
+	if !strings.Contains(code, "// ID is not marshaled.") || strings.Contains(code, "mm.AppendInt64(1, x.ID)") {
+		t.Errorf("marshalField was not replaced:\n%s", code)
+	}
+
+	info, err := parseTestStruct(t, "User", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = generateCode(&buf, &Package{Name: "test", Types: []string{"User"}, TypeInfos: map[string]*TypeInfo{"User": info}, Options: Options{Templates: []string{"{{.Missing"}}})
+	if err == nil || !strings.Contains(err.Error(), "failed to parse template 0") {
+		t.Errorf("expected a template parse error, got %v", err)
+	}
+}
+
+func TestGenerate_ProtocTypes(t *testing.T) {
+	dir := t.TempDir()
+	source := `// Code generated by protoc-gen-go. DO NOT EDIT.
+
+package pb
+
+type Event struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       int64             ` + "`protobuf:\"varint,1,opt,name=id,proto3\" json:\"id,omitempty\"`" + `
+	Delta    int32             ` + "`protobuf:\"zigzag32,2,opt,name=delta,proto3\" json:\"delta,omitempty\"`" + `
+	Score    *float32          ` + "`protobuf:\"fixed32,3,opt,name=score,proto3,oneof\" json:\"score,omitempty\"`" + `
+	Kind     Kind              ` + "`protobuf:\"varint,4,opt,name=kind,proto3,enum=pb.Kind\" json:\"kind,omitempty\"`" + `
+	Children []*Event          ` + "`protobuf:\"bytes,5,rep,name=children,proto3\" json:\"children,omitempty\"`" + `
+	Counts   map[string]uint64 ` + "`protobuf:\"bytes,6,rep,name=counts,proto3\" protobuf_key:\"bytes,1,opt,name=key,proto3\" protobuf_val:\"fixed64,2,opt,name=value,proto3\"`" + `
+	Data     [][]byte          ` + "`protobuf:\"bytes,7,rep,name=data,proto3\" json:\"data,omitempty\"`" + `
+}
+
+type Kind int32
+`
+	if err := os.WriteFile(filepath.Join(dir, "event.pb.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Dir: dir, Types: []string{"Event"}, Options: Options{ProtocTypes: true}}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		"mm.AppendInt64(1, x.Id)",
+		"mm.AppendSint32(2, x.Delta)",
+		"mm.AppendFloat(3, *x.Score)",
+		"mm.AppendInt32(4, int32(x.Kind))",
+		"mm2.AppendFixed64(2, v)",
+		"mm.AppendBytes(7, v)",
+		"dst.Reset()\n\tdst.Id = x.Id",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "*dst = *x") {
+		t.Errorf("generated code copies the message state")
+	}
+
+	oneof := strings.Replace(source, "\tData ", "\tBody isEvent_Body `protobuf_oneof:\"body\"`\n\tData ", 1)
+	if err := os.WriteFile(filepath.Join(dir, "event.pb.go"), []byte(oneof), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Generate(context.Background(), cfg)
+	var e *Error
+	if !errors.As(err, &e) || e.Field != "Body" || !strings.Contains(e.Error(), "oneof fields of protoc-gen-go types are not supported") {
+		t.Errorf("expected an error for the oneof field, got %v", err)
+	}
+}
+
+func TestGenerate_Random(t *testing.T) {
+	source := `
+type Node struct {
+	Name     string           ` + "`protobuf:\"1\"`" + `
+	Next     *Node            ` + "`protobuf:\"2\"`" + `
+	Children []Node           ` + "`protobuf:\"3\"`" + `
+	Weights  map[string]int32 ` + "`protobuf:\"4\"`" + `
+	Score    *float64         ` + "`protobuf:\"5\"`" + `
+	Data     []byte           ` + "`protobuf:\"6\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true}, "Node")
+	for _, want := range []string{
+		`"math/rand"`,
+		"func (x *Node) FuzzFill(r *rand.Rand) {",
+		"func (x *Node) fuzzFill(r *rand.Rand, depth int) {",
+		"x.Name = randomProtobufString(r)",
+		"if depth < 3 && r.Intn(2) == 0 {\n\t\tx.Next = &Node{}\n\t\tx.Next.fuzzFill(r, depth+1)",
+		"x.Children[i].fuzzFill(r, depth+1)",
+		"x.Weights[randomProtobufString(r)] = v",
+		"v := r.NormFloat64()\n\t\tx.Score = &v",
+		"x.Data = randomProtobufBytes(r)",
+		"func randomProtobufString(r *rand.Rand) string {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	code = generateTestCode(t, source, "Node")
+	if strings.Contains(code, "FuzzFill") || strings.Contains(code, "math/rand") {
+		t.Errorf("FuzzFill generated without the Random option")
+	}
+}
+
+func TestGenerate_Quick(t *testing.T) {
+	source := `
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Random: true, Quick: true}, "User")
+	for _, want := range []string{
+		`"reflect"`,
+		"func (*User) Generate(r *rand.Rand, size int) reflect.Value {",
+		"x.FuzzFill(r)\n\treturn reflect.ValueOf(x)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{Random: true}, "User")
+	if strings.Contains(code, "Generate(") || strings.Contains(code, `"reflect"`) {
+		t.Errorf("Generate generated without the Quick option")
+	}
+}
+
+func TestGenerate_ProtoAdapter(t *testing.T) {
+	source := `
+type Status int32
+
+type Content interface{}
+
+type Text struct {
+	Body string ` + "`protobuf:\"1\"`" + `
+}
+
+type User struct {
+	ID       int64             ` + "`protobuf:\"1\"`" + `
+	Nickname *string           ` + "`protobuf:\"2\"`" + `
+	Statuses []Status          ` + "`protobuf:\"3,enum\"`" + `
+	Friends  map[int64]*User   ` + "`protobuf:\"4,map,int64,message\"`" + `
+	Content  Content           ` + "`protobuf:\"oneof,Text:5\"`" + `
+	Labels   map[string]string ` + "`protobuf:\"6,map,string,string\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{ProtoAdapter: true}, "User", "Text")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/protoadapter"`,
+		"var _protoFileUser = protoadapter.NewFile(",
+		"func (x *User) ProtoAdapter() *protoadapter.Adapter {\n\treturn protoadapter.New(x, _protoFileUser.Message(\"User\"))",
+		"func (x *Text) ProtoAdapter() *protoadapter.Adapter {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	typeInfos := make(map[string]*TypeInfo)
+	for _, typeName := range []string{"User", "Text"} {
+		info, err := parseTestStruct(t, typeName, source)
+		if err != nil {
+			t.Fatalf("failed to parse struct %s: %v", typeName, err)
+		}
+		typeInfos[typeName] = info
+	}
+	pkg := &Package{Name: "test", Types: []string{"User", "Text"}, TypeInfos: typeInfos}
+	fd, err := protodesc.NewFile(buildFileDescriptor(pkg, descriptorPath("test", pkg.Types)), nil)
+	if err != nil {
+		t.Fatalf("invalid file descriptor: %v", err)
+	}
+	if fd.Path() != "test/user.proto" {
+		t.Errorf("path = %q, want test/user.proto", fd.Path())
+	}
+	user := fd.Messages().ByName("User")
+	fields := user.Fields()
+	if f := fields.ByName("nickname"); !f.HasPresence() || f.ContainingOneof() == nil || !f.ContainingOneof().IsSynthetic() {
+		t.Errorf("nickname is not a proto3 optional field")
+	}
+	if f := fields.ByName("statuses"); !f.IsList() || f.IsPacked() || f.Kind() != protoreflect.Int32Kind {
+		t.Errorf("statuses is not an unpacked repeated int32 field")
+	}
+	if f := fields.ByName("friends"); !f.IsMap() || f.MapValue().Message().FullName() != "test.User" {
+		t.Errorf("friends is not a map of test.User messages")
+	}
+	if f := fields.ByName("content_text"); f.ContainingOneof().Name() != "content" || f.Message().FullName() != "test.Text" {
+		t.Errorf("content_text is not a test.Text variant of the content oneof")
+	}
+	if oneofs := user.Oneofs(); oneofs.Len() != 2 || oneofs.Get(0).Name() != "content" {
+		t.Errorf("the real oneof must precede the synthetic ones")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{}, "User", "Text")
+	if strings.Contains(code, "protoadapter") {
+		t.Errorf("ProtoAdapter generated without the ProtoAdapter option")
+	}
+}
+
+func TestGenerate_DescriptorSet(t *testing.T) {
+	source := `
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{DescriptorSet: true}, "User")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/protoadapter"`,
+		"var _protoFileUser = protoadapter.NewFile(",
+		"var UserFileDescriptorSet = _protoFileUser.DescriptorSet()",
+		"if err := _protoFileUser.Register(); err != nil {\n\t\tpanic(err)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if strings.Contains(code, "ProtoAdapter()") {
+		t.Errorf("ProtoAdapter generated without the ProtoAdapter option")
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{ProtoAdapter: true}, "User")
+	if strings.Contains(code, "FileDescriptorSet") {
+		t.Errorf("descriptor set generated without the DescriptorSet option")
+	}
+}
+
+func TestGenerate_Register(t *testing.T) {
+	source := `
+type User struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type Group struct {
+	Users []*User ` + "`protobuf:\"1\"`" + `
+}
+`
+	code := generateTestCodeWithOptions(t, source, Options{Register: true}, "User", "Group")
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/easyprotoreg"`,
+		`easyprotoreg.Register("test.User", func() easyprotoreg.Unmarshaler { return &User{} })`,
+		`easyprotoreg.Register("test.Group", func() easyprotoreg.Unmarshaler { return &Group{} })`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	code = generateTestCodeWithOptions(t, source, Options{}, "User", "Group")
+	if strings.Contains(code, "easyprotoreg") {
+		t.Errorf("types registered without the Register option")
+	}
+}
+
+func TestGenerate_Services(t *testing.T) {
+	dir := t.TempDir()
+	src := `package chat
+
+import "context"
+
+type Message struct {
+	Text string ` + "`protobuf:\"1\"`" + `
+}
+
+type Ack struct {
+	ID int64 ` + "`protobuf:\"1\"`" + `
+}
+
+type ChatService interface {
+	Send(ctx context.Context, msg *Message) (*Ack, error)
+	Ping(context.Context, *Ack) (*Ack, error)
+}
+
+type Broken interface {
+	ChatService
+	Send(msg *Message) error
+	Stream(ctx context.Context, msg []Message) (*Ack, error)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "chat.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Generate(context.Background(), Config{Dir: dir, Types: []string{"Message", "Ack"}, Services: []string{"ChatService"}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := string(files[0].Content)
+	for _, want := range []string{
+		`"github.com/aryehlev/easyproto-gen/protohttp"`,
+		"func NewChatServiceHandler(s ChatService) http.Handler {",
+		`mux.Handle("POST /chat.ChatService/Send", protohttp.HandlerFunc(s.Send))`,
+		`mux.Handle("POST /chat.ChatService/Ping", protohttp.HandlerFunc(s.Ping))`,
+		"var _ ChatService = (*ChatServiceClient)(nil)",
+		"func NewChatServiceClient(baseURL string, c *http.Client) *ChatServiceClient {",
+		"// Send calls ChatService.Send by posting req to /chat.ChatService/Send.\nfunc (c *ChatServiceClient) Send(ctx context.Context, req *Message) (*Ack, error) {\n\tresp := &Ack{}\n\tif err := c.Client.Call(ctx, \"/chat.ChatService/Send\", req, resp); err != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+
+	_, err = Generate(context.Background(), Config{Dir: dir, Types: []string{"Message", "Ack"}, Services: []string{"Broken", "Message", "Missing"}})
+	for _, want := range []string{
+		"chat.go:19:2: service Broken embeds ChatService; declare its methods instead",
+		"chat.go:20:2: method Send of service Broken: unsupported signature",
+		"chat.go:21:2: method Stream of service Broken: request type []Message is not a pointer to a type of the package",
+		"chat.go:5:6: service Message is not an interface",
+		"service Missing not found",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestGenerateFuzz(t *testing.T) {
+	var buf bytes.Buffer
+	if err := generateFuzz(&buf, "test", []string{"Message", "User"}); err != nil {
+		t.Fatalf("generateFuzz failed: %v", err)
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		t.Fatalf("generated fuzz tests are not valid Go: %v\n%s", err, buf.String())
+	}
+	code := string(formatted)
+	for _, want := range []string{
+		"func FuzzMessageUnmarshalProtobuf(f *testing.F) {",
+		"func FuzzUserUnmarshalProtobuf(f *testing.F) {",
+		"allocs := testing.AllocsPerRun(1, func() {",
+		"if err := y.UnmarshalProtobuf(x.MarshalProtobuf(nil)); err != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated fuzz tests missing %q", want)
+		}
+	}
+}
+
+func TestGenerateConformance(t *testing.T) {
+	refs, err := parseConformance("Message=ProtoMessage, User=example.com/pb.User", []string{"Message", "User"})
+	if err != nil {
+		t.Fatalf("parseConformance failed: %v", err)
+	}
+	want := []conformanceRef{
+		{Type: "Message", Name: "ProtoMessage"},
+		{Type: "User", ImportPath: "example.com/pb", Name: "User"},
+	}
+	if fmt.Sprint(refs) != fmt.Sprint(want) {
+		t.Errorf("got refs %+v, want %+v", refs, want)
+	}
+
+	for _, spec := range []string{"Message", "Message=", "Other=pb.Other"} {
+		if _, err := parseConformance(spec, []string{"Message"}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := generateConformance(&buf, "test", refs); err != nil {
+		t.Fatalf("generateConformance failed: %v", err)
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		t.Fatalf("generated conformance tests are not valid Go: %v\n%s", err, buf.String())
+	}
+	code := string(formatted)
+	for _, want := range []string{
+		`pb0 "example.com/pb"`,
+		"conformance.Check(t, &Message{}, &ProtoMessage{})",
+		"conformance.Check(t, &User{}, &pb0.User{})",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated conformance tests missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_HotFields(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	writeFile("sample.go", `package test
+
+type Sample struct {
+	Name      string  `+"`protobuf:\"1\"`"+`
+	Value     float64 `+"`protobuf:\"2\"`"+`
+	Timestamp int64   `+"`protobuf:\"3\"`"+`
+}
+`)
+	// mergeFunc returns the MergeFromProtobuf method of the generated code.
+	mergeFunc := func(files []File) string {
+		t.Helper()
+		code := string(files[0].Content)
+		start := strings.Index(code, "func (x *Sample) MergeFromProtobuf(")
+		if start < 0 {
+			t.Fatal("generated code missing MergeFromProtobuf")
+		}
+		end := strings.Index(code[start:], "\n}\n")
+		return code[start : start+end]
+	}
+
+	cfg := Config{Dir: dir, Types: []string{"Sample"}, HotFields: writeFile("hot.txt", "# hottest first\nSample.Value\n\nOther.Field\n")}
+	files, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	merge := mergeFunc(files)
+	hot, sw := strings.Index(merge, "if fc.FieldNum == 2 {"), strings.Index(merge, "switch fc.FieldNum {")
+	if hot < 0 || sw < 0 || hot > sw {
+		t.Errorf("hot field not checked before the switch:\n%s", merge)
+	}
+	if strings.Contains(merge, "case 2:") {
+		t.Errorf("hot field also in the switch:\n%s", merge)
+	}
+
+	// Attribute every sample of a profile to the lines decoding Timestamp.
+	output := filepath.Join(dir, "sample_proto.go")
+	if err := os.WriteFile(output, files[0].Content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases, err := unmarshalCases(files[0].Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var line int
+	for _, c := range cases {
+		if c.typeName == "Sample" && c.fieldNum == 3 {
+			line = c.start + 1
+		}
+	}
+	cfg.HotFields = ""
+	cfg.Profile = writeFile("cpu.pprof", string(testProfile("/src/test/sample_proto.go", line)))
+	files, err = Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if merge := mergeFunc(files); !strings.Contains(merge, "if fc.FieldNum == 3 {") || strings.Contains(merge, "if fc.FieldNum == 2 {") {
+		t.Errorf("expected Timestamp to be hot from the profile:\n%s", merge)
+	}
+
+	cfg.Profile = ""
+	cfg.HotFields = writeFile("hot.txt", "Sample.Missing\n")
+	if _, err := Generate(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "hot field Sample.Missing not found") {
+		t.Errorf("expected missing hot field error, got: %v", err)
+	}
+}
+
+// testProfile returns a pprof profile with a single sample in the given line of file.
+func testProfile(file string, line int) []byte {
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	sample := mm.AppendMessage(2)
+	sample.AppendUint64s(1, []uint64{1})
+	sample.AppendInt64s(2, []int64{1, 10000000})
+	loc := mm.AppendMessage(4)
+	loc.AppendUint64(1, 1)
+	l := loc.AppendMessage(4)
+	l.AppendUint64(1, 1)
+	l.AppendInt64(2, int64(line))
+	fn := mm.AppendMessage(5)
+	fn.AppendUint64(1, 1)
+	fn.AppendInt64(4, 1)
+	mm.AppendString(6, "")
+	mm.AppendString(6, file)
+	return m.Marshal(nil)
+}
@@ -0,0 +1,92 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"slices"
+	"strings"
+)
+
+// applyTypeParams records the type parameters of a generic type in info and marks its fields
+// whose elements are type parameters. Their type arguments must be message types, which are
+// marshaled and unmarshaled through the ProtobufMarshaler and ProtobufUnmarshaler interfaces
+// checked at run time, since type parameters have no methods of their own.
+func applyTypeParams(info *TypeInfo, params *ast.FieldList) error {
+	if params == nil {
+		return nil
+	}
+	for _, field := range params.List {
+		for _, name := range field.Names {
+			info.TypeParams = append(info.TypeParams, name.Name)
+		}
+	}
+	isParam := func(goType string) bool {
+		return slices.Contains(info.TypeParams, strings.TrimPrefix(goType, "*"))
+	}
+	for _, f := range info.Fields {
+		switch {
+		case f.IsOneof:
+			for _, v := range f.OneofVariants {
+				if isParam(v.TypeName) {
+					return fmt.Errorf("oneof field %q in generic type %s has type parameter %s as a variant", f.Name, info.Name, v.TypeName)
+				}
+			}
+		case f.IsMap:
+			if isParam(f.MapKeyType) || isParam(f.MapValueType) {
+				return fmt.Errorf("map field %q in generic type %s has keys or values of a type parameter; use a repeated message field instead", f.Name, info.Name)
+			}
+		case isParam(f.ElemType):
+			if !f.IsMessage {
+				return fmt.Errorf("field %q of type parameter %s in generic type %s must be a message, not %s", f.Name, f.ElemType, info.Name, f.ProtoType)
+			}
+			if f.IsFunc || f.IsIter || f.IsExtract || f.IsParallel {
+				return fmt.Errorf("field %q of type parameter %s in generic type %s: func, iter, extract and parallel options are not supported", f.Name, f.ElemType, info.Name)
+			}
+			f.IsCustom = true
+			f.IsTypeParam = true
+		}
+	}
+	return nil
+}
+
+// checkGenericOptions returns an error if opts generate code that generic types don't support,
+// like functions and registrations, which need a type argument.
+func checkGenericOptions(typeName string, opts Options) error {
+	unsupported := []struct {
+		option string
+		set    bool
+	}{
+		{"arena", opts.Arena},
+		{"mask", opts.Mask},
+		{"filter", opts.Filter},
+		{"random", opts.Random || opts.Quick},
+		{"protoc-types", opts.ProtocTypes},
+		{"proto-adapter", opts.ProtoAdapter},
+		{"descriptor-set", opts.DescriptorSet},
+		{"register", opts.Register},
+		{"pool", opts.Pool},
+		{"direct", opts.Direct},
+		// Type arguments are marshaled with the default method names of custom types.
+		{"method-prefix", opts.MethodPrefix != ""},
+		{"method-suffix", opts.MethodSuffix != ""},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("generic type %s is not supported with %s", typeName, u.option)
+		}
+	}
+	return nil
+}
+
+// genericName returns the name of the generic type of an instantiation, like Page for
+// Page[Message], or typeName itself if it isn't one.
+func genericName(typeName string) string {
+	name, _, _ := strings.Cut(typeName, "[")
+	return name
+}
+
+// isGenericType reports whether files declare typeName as a generic type.
+func isGenericType(files []*ast.File, typeName string) bool {
+	obj := lookupType(files, typeName)
+	return obj != nil && obj.Decl.(*ast.TypeSpec).TypeParams != nil
+}
@@ -8,3 +8,5 @@ require (
 	github.com/VictoriaMetrics/easyproto v1.1.3
 	google.golang.org/protobuf v1.36.11
 )
+
+require golang.org/x/tools v0.30.0
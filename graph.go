@@ -0,0 +1,75 @@
+package easyprotogen
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// graphExtensions are the file extensions of the diagrams of Config.Graph by format.
+var graphExtensions = map[string]string{
+	"dot":     ".dot",
+	"mermaid": ".mmd",
+}
+
+// graphEdge is a field of a message type referencing another message type.
+type graphEdge struct {
+	From, To string
+	Label    string // Name of the field, with its kind if it isn't singular
+}
+
+// graphData is the data passed to the graph template.
+type graphData struct {
+	Format   string
+	Package  string
+	Types    []string // Generated types
+	External []string // Message types referenced by the generated types without being generated
+	Edges    []graphEdge
+}
+
+// generateGraph writes a diagram of the message types of pkg and the fields referencing each
+// other in the Graphviz DOT or Mermaid format.
+func generateGraph(buf *bytes.Buffer, pkg *Package, format string) error {
+	data := graphData{Format: format, Package: pkg.Name, Types: pkg.Types}
+	edge := func(from, to, label string) {
+		to = genericName(strings.TrimPrefix(to, "*"))
+		if !slices.Contains(pkg.Types, to) && !slices.Contains(data.External, to) {
+			data.External = append(data.External, to)
+		}
+		data.Edges = append(data.Edges, graphEdge{From: from, To: to, Label: label})
+	}
+	for _, typeName := range pkg.Types {
+		for _, f := range pkg.TypeInfos[typeName].Fields {
+			switch {
+			case f.IsOneof:
+				for _, v := range f.OneofVariants {
+					if v.ProtoType == "" {
+						edge(typeName, v.TypeName, f.Name+" (oneof)")
+					}
+				}
+			case f.IsMap:
+				if f.MapValueIsMsg {
+					edge(typeName, f.MapValueType, f.Name+" (map)")
+				}
+			case f.IsTypeParam:
+				// Type arguments are only known at instantiation.
+			case f.IsMessage && f.IsRepeated:
+				edge(typeName, f.ElemType, f.Name+" (repeated)")
+			case f.IsMessage:
+				edge(typeName, f.ElemType, f.Name)
+			}
+		}
+	}
+
+	tmpl, err := template.New("graph").Funcs(template.FuncMap{
+		"quote": func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"` },
+		// mermaidID returns a node ID for a type name, which may be qualified by a package.
+		"mermaidID": func(s string) string { return strings.ReplaceAll(s, ".", "_") },
+	}).Parse(graphTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse graph template: %w", err)
+	}
+	return tmpl.Execute(buf, data)
+}
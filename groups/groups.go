@@ -0,0 +1,80 @@
+// Package groups skips the groups of proto2 messages, which easyproto can't read.
+//
+// Groups are a deprecated alternative to nested messages: a start-group tag, the fields of the
+// group and an end-group tag with the same field number, instead of a length-delimited field.
+// Types generated by protogen have no group fields, so groups sent by legacy proto2 producers
+// are unknown fields. Their unmarshal methods skip them like other unknown fields when easyproto
+// fails to read them:
+//
+//	tail, err := fc.NextField(src)
+//	if err != nil {
+//	    if tail, ok := groups.Skip(src); ok {
+//	        src = tail
+//	        continue
+//	    }
+//	    return err
+//	}
+package groups
+
+import "encoding/binary"
+
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireBytes      = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+// Skip returns src after the group at its start, including nested groups, and true. It returns
+// src and false if src doesn't start with a start-group tag or the group is truncated or
+// malformed, like an end-group tag with another field number.
+func Skip(src []byte) ([]byte, bool) {
+	tag, n := binary.Uvarint(src)
+	if n <= 0 || tag&0x07 != wireStartGroup {
+		return src, false
+	}
+	// The field numbers of the open groups, innermost last.
+	open := []uint64{tag >> 3}
+	for rest := src[n:]; ; {
+		tag, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return src, false
+		}
+		rest = rest[n:]
+		switch tag & 0x07 {
+		case wireVarint:
+			if _, n = binary.Uvarint(rest); n <= 0 {
+				return src, false
+			}
+		case wireFixed64:
+			n = 8
+		case wireBytes:
+			size, m := binary.Uvarint(rest)
+			if m <= 0 || size > uint64(len(rest)-m) {
+				return src, false
+			}
+			n = m + int(size)
+		case wireStartGroup:
+			open = append(open, tag>>3)
+			n = 0
+		case wireEndGroup:
+			if open[len(open)-1] != tag>>3 {
+				return src, false
+			}
+			if open = open[:len(open)-1]; len(open) == 0 {
+				return rest, true
+			}
+			n = 0
+		case wireFixed32:
+			n = 4
+		default:
+			return src, false
+		}
+		if len(rest) < n {
+			return src, false
+		}
+		rest = rest[n:]
+	}
+}
@@ -0,0 +1,53 @@
+package groups
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// tag returns the encoded tag of a field.
+func tag(fieldNum, wireType uint64) []byte {
+	return binary.AppendUvarint(nil, fieldNum<<3|wireType)
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestSkip(t *testing.T) {
+	// Group 2 holding a varint, a fixed64, a string, a fixed32 and the nested group 300.
+	group := join(
+		tag(2, wireStartGroup),
+		tag(1, wireVarint), binary.AppendUvarint(nil, 1<<40),
+		tag(2, wireFixed64), make([]byte, 8),
+		tag(3, wireBytes), []byte{3}, []byte("abc"),
+		tag(4, wireFixed32), make([]byte, 4),
+		tag(300, wireStartGroup), tag(1, wireVarint), []byte{1}, tag(300, wireEndGroup),
+		tag(2, wireEndGroup),
+	)
+	next := join(tag(5, wireVarint), []byte{7})
+	rest, ok := Skip(join(group, next))
+	if !ok || !bytes.Equal(rest, next) {
+		t.Fatalf("got %x, %v; want %x, true", rest, ok, next)
+	}
+	if rest, ok := Skip(join(tag(1, wireStartGroup), tag(1, wireEndGroup))); !ok || len(rest) != 0 {
+		t.Fatalf("empty group: got %x, %v", rest, ok)
+	}
+
+	for name, src := range map[string][]byte{
+		"empty":             nil,
+		"not a group":       next,
+		"end-group tag":     tag(2, wireEndGroup),
+		"truncated":         group[:len(group)-1],
+		"truncated varint":  join(tag(2, wireStartGroup), tag(1, wireVarint), []byte{0x80}),
+		"truncated fixed64": join(tag(2, wireStartGroup), tag(1, wireFixed64), make([]byte, 7)),
+		"truncated string":  join(tag(2, wireStartGroup), tag(1, wireBytes), []byte{3}, []byte("ab"), tag(2, wireEndGroup)),
+		"mismatched end":    join(tag(2, wireStartGroup), tag(3, wireEndGroup)),
+		"invalid wire type": join(tag(2, wireStartGroup), tag(1, 7), tag(2, wireEndGroup)),
+	} {
+		if rest, ok := Skip(src); ok || !bytes.Equal(rest, src) {
+			t.Errorf("%s: got %x, %v; want the original src and false", name, rest, ok)
+		}
+	}
+}
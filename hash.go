@@ -0,0 +1,120 @@
+package easyprotogen
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// sourceHashPrefix starts the line of generated files recording their source hash.
+const sourceHashPrefix = "// Source hash: "
+
+// develVersion is the module version of protogen builds that aren't from a module download,
+// such as go run within this repository.
+const develVersion = "(devel)"
+
+// generatorVersion returns the module version of the running protogen.
+func generatorVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return develVersion
+}
+
+// isStableBuild reports whether the running protogen is identified by its version. Development
+// builds and builds from modified checkouts may differ without a version change.
+func isStableBuild() bool {
+	v := generatorVersion()
+	return v != develVersion && !strings.HasSuffix(v, "+dirty")
+}
+
+// extendHash returns a hash of hash and extra.
+func extendHash(hash string, extra ...string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", hash)
+	for _, s := range extra {
+		fmt.Fprintf(h, "%s\x00", s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceHash returns a hash of everything the generated code depends on: the generator
+// version and templates, the invocation and the Go files of the package in dir, except
+// files generated by protogen and, unless tests is set, test files. It also returns the
+// package name.
+func sourceHash(dir, invocation string, tests bool) (hash, pkgName string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", generatorVersion(), invocation)
+	for _, tmpl := range []string{protoTemplate, standaloneTemplate, fuzzTemplate, conformanceTemplate, protoFileTemplate, docTemplate, graphTemplate} {
+		fmt.Fprintf(h, "%s\x00", tmpl)
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		test := strings.HasSuffix(name, "_test.go")
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (test && !tests) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", "", err
+		}
+		if bytes.HasPrefix(data, []byte(generatedHeader)) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, data, parser.PackageClauseOnly)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse file %s: %w", name, err)
+		}
+		if test && strings.HasSuffix(file.Name.Name, "_test") {
+			continue // skip external test packages, like parsePackage
+		}
+		if pkgName == "" {
+			pkgName = file.Name.Name
+		} else if file.Name.Name != pkgName {
+			continue // skip files from different packages, like parsePackage
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), pkgName, nil
+}
+
+// readSourceHash returns the source hash recorded in the generated file at path,
+// or "" if there is none.
+func readSourceHash(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() || sc.Text() != generatedHeader || !sc.Scan() {
+		return ""
+	}
+	hash, ok := strings.CutPrefix(sc.Text(), sourceHashPrefix)
+	if !ok {
+		return ""
+	}
+	return hash
+}
+
+// addSourceHash returns code with the source hash line inserted after the generated code header.
+func addSourceHash(code []byte, hash string) []byte {
+	header, rest, _ := bytes.Cut(code, []byte("\n"))
+	return slices.Concat(header, []byte("\n"+sourceHashPrefix+hash+"\n"), rest)
+}
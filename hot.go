@@ -0,0 +1,401 @@
+package easyprotogen
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// minHotShare is the share of the unmarshal samples of a type a field needs in a profile to be hot.
+const minHotShare = 0.1
+
+// parseHotFields parses a hint file listing hot fields as Type.Field, one per line, hottest
+// first. Blank lines and lines starting with # are ignored.
+func parseHotFields(data []byte) (map[string][]string, error) {
+	hot := make(map[string][]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		typeName, fieldName, ok := strings.Cut(text, ".")
+		if !ok || typeName == "" || fieldName == "" {
+			return nil, fmt.Errorf("line %d: expected Type.Field, got %q", line, text)
+		}
+		hot[typeName] = append(hot[typeName], fieldName)
+	}
+	return hot, sc.Err()
+}
+
+// applyHotFields sets the hot fields of the types in typeInfos. Fields of types that are not
+// generated are ignored, so a hint file can cover several invocations.
+func applyHotFields(typeInfos map[string]*TypeInfo, hot map[string][]string) error {
+	for typeName, names := range hot {
+		info := typeInfos[typeName]
+		if info == nil {
+			continue
+		}
+		for _, name := range names {
+			var field *FieldInfo
+			for _, f := range info.Fields {
+				if f.Name == name {
+					field = f
+				}
+			}
+			switch {
+			case field == nil:
+				return fmt.Errorf("hot field %s.%s not found", typeName, name)
+			case field.IsOneof:
+				return fmt.Errorf("hot field %s.%s is a oneof; only regular fields can be hot", typeName, name)
+			case field.IsHot:
+				continue
+			}
+			field.IsHot = true
+			info.Hot = append(info.Hot, field)
+		}
+	}
+	return nil
+}
+
+// applyHints sets the hot fields of the types in typeInfos from the contents of a hint file
+// and a CPU profile of the code generated in output, either of which may be empty.
+func applyHints(typeInfos map[string]*TypeInfo, hints, profile []byte, output string) error {
+	if len(hints) > 0 {
+		hot, err := parseHotFields(hints)
+		if err != nil {
+			return fmt.Errorf("failed to parse hot fields: %w", err)
+		}
+		if err := applyHotFields(typeInfos, hot); err != nil {
+			return err
+		}
+	}
+	if len(profile) > 0 {
+		src, err := os.ReadFile(output)
+		if err != nil {
+			return fmt.Errorf("failed to read the code the profile was taken with: %w", err)
+		}
+		hot, err := profileHotFields(profile, src, output, typeInfos)
+		if err != nil {
+			return err
+		}
+		if err := applyHotFields(typeInfos, hot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// caseLines is the line range of the code decoding a field in a generated unmarshal method.
+type caseLines struct {
+	typeName   string
+	fieldNum   int
+	start, end int
+}
+
+// profileHotFields returns the hot fields of the types in typeInfos according to a CPU profile
+// in the pprof format of a program built with src, the code previously generated in the file
+// at path. The samples are attributed to the switch cases of the unmarshal methods, and fields
+// with at least minHotShare of the samples of their type are hot.
+func profileHotFields(profile, src []byte, path string, typeInfos map[string]*TypeInfo) (map[string][]string, error) {
+	cases, err := unmarshalCases(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	samples, err := parseProfile(profile, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse profile: %w", err)
+	}
+
+	type key struct {
+		typeName string
+		fieldNum int
+	}
+	weights := make(map[key]int64)
+	totals := make(map[string]int64)
+	for _, s := range samples {
+		// Count every field once per sample, even if it is decoded recursively.
+		seen := make(map[key]bool)
+		seenTypes := make(map[string]bool)
+		for _, line := range s.lines {
+			for _, c := range cases {
+				k := key{c.typeName, c.fieldNum}
+				if line < c.start || line > c.end || seen[k] {
+					continue
+				}
+				seen[k] = true
+				weights[k] += s.value
+				if !seenTypes[c.typeName] {
+					seenTypes[c.typeName] = true
+					totals[c.typeName] += s.value
+				}
+			}
+		}
+	}
+
+	hot := make(map[string][]string)
+	for typeName, info := range typeInfos {
+		var fields []*FieldInfo
+		for _, f := range info.Fields {
+			w := weights[key{typeName, f.FieldNum}]
+			if !f.IsOneof && w > 0 && float64(w) >= minHotShare*float64(totals[typeName]) {
+				fields = append(fields, f)
+			}
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			return weights[key{typeName, fields[i].FieldNum}] > weights[key{typeName, fields[j].FieldNum}]
+		})
+		for _, f := range fields {
+			hot[typeName] = append(hot[typeName], f.Name)
+		}
+	}
+	return hot, nil
+}
+
+// unmarshalCases returns the line ranges of the switch cases and hot field checks on
+// fc.FieldNum in the methods of src.
+func unmarshalCases(src []byte) ([]caseLines, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	var cases []caseLines
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Body == nil {
+			continue
+		}
+		typeName, ok := localPointer(fn.Recv.List[0].Type)
+		if !ok {
+			continue
+		}
+		add := func(num ast.Expr, body ast.Node) {
+			lit, ok := num.(*ast.BasicLit)
+			if !ok || lit.Kind != token.INT {
+				return
+			}
+			n, err := strconv.Atoi(lit.Value)
+			if err != nil {
+				return
+			}
+			cases = append(cases, caseLines{typeName: typeName, fieldNum: n, start: fset.Position(body.Pos()).Line, end: fset.Position(body.End()).Line})
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SwitchStmt:
+				if isFieldNum(n.Tag) {
+					for _, stmt := range n.Body.List {
+						clause := stmt.(*ast.CaseClause)
+						for _, v := range clause.List {
+							add(v, clause)
+						}
+					}
+				}
+			case *ast.IfStmt:
+				if cond, ok := n.Cond.(*ast.BinaryExpr); ok && cond.Op == token.EQL && isFieldNum(cond.X) {
+					add(cond.Y, n.Body)
+				}
+			}
+			return true
+		})
+	}
+	return cases, nil
+}
+
+// isFieldNum returns true if expr is fc.FieldNum.
+func isFieldNum(expr ast.Expr) bool {
+	return exprToString(expr) == "fc.FieldNum"
+}
+
+// profileSample is a sample of a CPU profile.
+type profileSample struct {
+	value int64
+	lines []int // Lines of the stack frames in the generated file
+}
+
+// parseProfile parses a CPU profile in the pprof format, gzipped or not, and returns its samples
+// with the lines of their stack frames in files with the given base name.
+func parseProfile(data []byte, base string) ([]profileSample, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	// The fields of profile.proto used here.
+	type line struct{ function, line uint64 }
+	var (
+		strs      []string
+		fileIdx   = make(map[uint64]uint64) // Function ID to filename string index
+		locations = make(map[uint64][]line) // Location ID to lines
+		raw       [][]byte                  // Samples, decoded once the tables are known
+	)
+	var fc easyproto.FieldContext
+	for len(data) > 0 {
+		tail, err := fc.NextField(data)
+		if err != nil {
+			return nil, err
+		}
+		data = tail
+		switch fc.FieldNum {
+		case 2: // sample
+			msg, ok := fc.MessageData()
+			if !ok {
+				return nil, fmt.Errorf("cannot read sample")
+			}
+			raw = append(raw, msg)
+		case 4: // location
+			msg, ok := fc.MessageData()
+			if !ok {
+				return nil, fmt.Errorf("cannot read location")
+			}
+			var id uint64
+			var lines []line
+			err := forEachField(msg, func(fc *easyproto.FieldContext) bool {
+				var ok bool
+				switch fc.FieldNum {
+				case 1:
+					id, ok = fc.Uint64()
+				case 4:
+					var lmsg []byte
+					if lmsg, ok = fc.MessageData(); !ok {
+						return false
+					}
+					var l line
+					err := forEachField(lmsg, func(fc *easyproto.FieldContext) bool {
+						var ok bool
+						switch fc.FieldNum {
+						case 1:
+							l.function, ok = fc.Uint64()
+						case 2:
+							var v int64
+							v, ok = fc.Int64()
+							l.line = uint64(v)
+						default:
+							ok = true
+						}
+						return ok
+					})
+					lines = append(lines, l)
+					ok = err == nil
+				default:
+					ok = true
+				}
+				return ok
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cannot read location: %w", err)
+			}
+			locations[id] = lines
+		case 5: // function
+			msg, ok := fc.MessageData()
+			if !ok {
+				return nil, fmt.Errorf("cannot read function")
+			}
+			var id, filename uint64
+			err := forEachField(msg, func(fc *easyproto.FieldContext) bool {
+				var ok bool
+				switch fc.FieldNum {
+				case 1:
+					id, ok = fc.Uint64()
+				case 4:
+					var v int64
+					v, ok = fc.Int64()
+					filename = uint64(v)
+				default:
+					ok = true
+				}
+				return ok
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cannot read function: %w", err)
+			}
+			fileIdx[id] = filename
+		case 6: // string_table
+			s, ok := fc.String()
+			if !ok {
+				return nil, fmt.Errorf("cannot read string table")
+			}
+			strs = append(strs, s)
+		}
+	}
+
+	inFile := func(function uint64) bool {
+		i, ok := fileIdx[function]
+		if !ok || i >= uint64(len(strs)) {
+			return false
+		}
+		name := strs[i]
+		return name == base || strings.HasSuffix(name, "/"+base)
+	}
+	samples := make([]profileSample, 0, len(raw))
+	for _, msg := range raw {
+		var s profileSample
+		var locs []uint64
+		var values []int64
+		err := forEachField(msg, func(fc *easyproto.FieldContext) bool {
+			var ok bool
+			switch fc.FieldNum {
+			case 1:
+				locs, ok = fc.UnpackUint64s(locs)
+			case 2:
+				values, ok = fc.UnpackInt64s(values)
+			default:
+				ok = true
+			}
+			return ok
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read sample: %w", err)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		// The last value of CPU profiles is the CPU time.
+		s.value = values[len(values)-1]
+		for _, loc := range locs {
+			for _, l := range locations[loc] {
+				if inFile(l.function) {
+					s.lines = append(s.lines, int(l.line))
+				}
+			}
+		}
+		if len(s.lines) > 0 {
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
+
+// forEachField calls f for every field of the message at src until it returns false.
+func forEachField(src []byte, f func(fc *easyproto.FieldContext) bool) error {
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		tail, err := fc.NextField(src)
+		if err != nil {
+			return err
+		}
+		src = tail
+		if !f(&fc) {
+			return fmt.Errorf("field #%d has an unexpected wire type", fc.FieldNum)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Inspect decodes data, a protobuf message of the struct type typeName of the package in dir,
+// and returns an annotated breakdown in the text format of protoscope: a line per field with
+// its offset in data, its number and value, and the Go field and type it is decoded into.
+// Fields the type doesn't declare, and fields of types not in the package, are decoded by
+// their wire type.
+//
+// If data is malformed, the breakdown of the fields before the error is returned with it.
+func Inspect(dir, typeName string, data []byte) (string, error) {
+	typeInfos, err := loadPayloadTypes(dir, typeName)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	in := &inspector{w: tw, typeInfos: typeInfos}
+	err = in.message(data, 0, 0, in.messageFields(typeName))
+	tw.Flush()
+	// Lines closing messages end with the padding of the empty annotation column.
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(sb.String(), "\n") {
+		if line != "" {
+			out.WriteString(strings.TrimRight(line, " \n") + "\n")
+		}
+	}
+	return out.String(), err
+}
+
+// loadPayloadTypes parses the struct types of the package in dir for decoding payloads of
+// typeName. Other types that are invalid are left out, so their messages are decoded by wire type.
+func loadPayloadTypes(dir, typeName string) (map[string]*TypeInfo, error) {
+	fset := token.NewFileSet()
+	_, files, err := parsePackage(fset, dir, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	typeInfos := make(map[string]*TypeInfo)
+	err = forEachStruct(files, func(name string, structType *ast.StructType, doc *ast.CommentGroup) error {
+		info, err := parseStruct(name, structType)
+		if err == nil {
+			err = applyDirectives(info, doc)
+		}
+		if err == nil {
+			err = resolveScalarVariants(files, info)
+		}
+		if err != nil {
+			if name == typeName {
+				return &Error{Pos: fset.Position(structType.Pos()), Type: name, Err: err}
+			}
+			return nil
+		}
+		typeInfos[name] = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if typeInfos[typeName] == nil {
+		return nil, &Error{Type: typeName, Err: fmt.Errorf("type %s not found", typeName)}
+	}
+	return typeInfos, nil
+}
+
+// inspectField is a field of a message decoded by Inspect.
+type inspectField struct {
+	name      string
+	goType    string
+	protoType string // Scalar type, or "message" for messages, map entries and wrappers
+	packed    bool   // Repeated scalar, which may be packed
+	fields    func() map[int]*inspectField
+}
+
+// inspector writes the breakdown of Inspect.
+type inspector struct {
+	w         *tabwriter.Writer
+	typeInfos map[string]*TypeInfo
+}
+
+// messageFields returns the fields of the message type typeName by number, or nil if the
+// package doesn't declare it.
+func (in *inspector) messageFields(typeName string) map[int]*inspectField {
+	info := in.typeInfos[genericName(strings.TrimPrefix(typeName, "*"))]
+	if info == nil {
+		return nil
+	}
+	fields := make(map[int]*inspectField)
+	for _, f := range info.Fields {
+		switch {
+		case f.IsOneof:
+			for _, v := range f.OneofVariants {
+				field := &inspectField{name: f.Name, goType: "*" + v.TypeName, protoType: "message"}
+				if v.ProtoType != "" {
+					field.protoType = v.ProtoType
+				} else {
+					field.fields = in.fieldsFunc(v.TypeName)
+				}
+				fields[v.FieldNum] = field
+			}
+		case f.IsMap:
+			fields[f.FieldNum] = &inspectField{name: f.Name, goType: f.GoType, protoType: "message", fields: func() map[int]*inspectField {
+				value := &inspectField{name: "value", goType: f.MapValueType, protoType: f.MapValueProto}
+				if f.MapValueIsMsg {
+					value.protoType = "message"
+					value.fields = in.fieldsFunc(f.MapValueType)
+				}
+				return map[int]*inspectField{
+					1: {name: "key", goType: f.MapKeyType, protoType: f.MapKeyProto},
+					2: value,
+				}
+			}}
+		case f.IsWrapper:
+			fields[f.FieldNum] = &inspectField{name: f.Name, goType: f.GoType, protoType: "message", fields: func() map[int]*inspectField {
+				return map[int]*inspectField{1: {name: "value", goType: f.BaseType, protoType: f.ProtoType}}
+			}}
+		case f.IsMessage || f.IsCustom:
+			fields[f.FieldNum] = &inspectField{name: f.Name, goType: f.GoType, protoType: "message", fields: in.fieldsFunc(f.ElemType)}
+		default:
+			fields[f.FieldNum] = &inspectField{
+				name:      f.Name,
+				goType:    f.GoType,
+				protoType: f.ProtoType,
+				packed:    f.IsRepeated && !isLengthDelimited(f.ProtoType),
+			}
+		}
+	}
+	return fields
+}
+
+// fieldsFunc returns a function returning the fields of the message type typeName, which
+// defers the lookup to recursive messages that are present.
+func (in *inspector) fieldsFunc(typeName string) func() map[int]*inspectField {
+	return func() map[int]*inspectField { return in.messageFields(typeName) }
+}
+
+// message writes the fields of the message data, which starts at offset in the inspected
+// data, indented by depth. Fields is nil for messages of unknown types.
+func (in *inspector) message(data []byte, offset, depth int, fields map[int]*inspectField) error {
+	indent := strings.Repeat("  ", depth)
+	for pos := 0; pos < len(data); {
+		num, typ, n := protowire.ConsumeTag(data[pos:])
+		if n < 0 {
+			return fmt.Errorf("malformed tag at offset %d: %w", offset+pos, protowire.ParseError(n))
+		}
+		start := pos
+		pos += n
+		f := fields[int(num)]
+		note := "unknown"
+		if f != nil {
+			note = f.name + " " + f.goType
+			if f.protoType != "message" && f.protoType != strings.TrimLeft(f.goType, "*[]") {
+				note += " " + f.protoType
+			}
+			if want := inspectWireType(f); want != typ && !(f.packed && typ == protowire.BytesType) {
+				note += fmt.Sprintf(", wire type mismatch: want %s", wireTypeName(want))
+				f = nil
+			}
+		}
+
+		var value string
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data[pos:])
+			if n < 0 {
+				return fmt.Errorf("malformed varint at offset %d: %w", offset+pos, protowire.ParseError(n))
+			}
+			pos += n
+			value = formatVarint(f, v)
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(data[pos:])
+			if n < 0 {
+				return fmt.Errorf("malformed fixed32 at offset %d: %w", offset+pos, protowire.ParseError(n))
+			}
+			pos += n
+			value = formatFixed32(f, v)
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data[pos:])
+			if n < 0 {
+				return fmt.Errorf("malformed fixed64 at offset %d: %w", offset+pos, protowire.ParseError(n))
+			}
+			pos += n
+			value = formatFixed64(f, v)
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data[pos:])
+			if n < 0 {
+				return fmt.Errorf("malformed length-delimited field at offset %d: %w", offset+pos, protowire.ParseError(n))
+			}
+			valueOffset := offset + pos + n - len(v)
+			pos += n
+			if len(v) == 1 {
+				note += ", 1 byte"
+			} else {
+				note += fmt.Sprintf(", %d bytes", len(v))
+			}
+			var nested map[int]*inspectField
+			switch {
+			case f != nil && f.packed:
+				values, err := formatPacked(f, v)
+				if err != nil {
+					return fmt.Errorf("malformed packed field at offset %d: %w", valueOffset, err)
+				}
+				value = "{" + values + "}"
+			case f != nil && f.protoType == "string":
+				value = "{" + strconv.Quote(string(v)) + "}"
+			case f != nil && f.protoType == "bytes":
+				value = "{`" + fmt.Sprintf("%x", v) + "`}"
+			case f == nil && len(v) > 0 && utf8.Valid(v) && !strings.ContainsFunc(string(v), isControl):
+				// Text is more likely than a message whose tags happen to be printable.
+				value = "{" + strconv.Quote(string(v)) + "}"
+			case f != nil && f.fields != nil:
+				nested = f.fields()
+				fallthrough
+			case f != nil || isMessage(v):
+				fmt.Fprintf(in.w, "%d\t%s%d: {\t# %s\n", offset+start, indent, num, note)
+				if err := in.message(v, valueOffset, depth+1, nested); err != nil {
+					return err
+				}
+				fmt.Fprintf(in.w, "\t%s}\t\n", indent)
+				continue
+			default:
+				value = "{`" + fmt.Sprintf("%x", v) + "`}"
+			}
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, data[pos:])
+			if n < 0 {
+				return fmt.Errorf("malformed group at offset %d: %w", offset+pos, protowire.ParseError(n))
+			}
+			fmt.Fprintf(in.w, "%d\t%s%d: !{\t# %s, group\n", offset+start, indent, num, note)
+			if err := in.message(v, offset+pos, depth+1, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(in.w, "\t%s}\t\n", indent)
+			pos += n
+			continue
+		default:
+			return fmt.Errorf("unexpected wire type %d of field %d at offset %d", typ, num, offset+start)
+		}
+		fmt.Fprintf(in.w, "%d\t%s%d: %s\t# %s\n", offset+start, indent, num, value, note)
+	}
+	return nil
+}
+
+// inspectWireType returns the wire type of the values of f.
+func inspectWireType(f *inspectField) protowire.Type {
+	if f.protoType == "message" || isLengthDelimited(f.protoType) {
+		return protowire.BytesType
+	}
+	return protowire.Type(wireTypeOf(f.protoType))
+}
+
+// wireTypeName returns the protoscope name of a wire type.
+func wireTypeName(typ protowire.Type) string {
+	switch typ {
+	case protowire.VarintType:
+		return "VARINT"
+	case protowire.Fixed32Type:
+		return "I32"
+	case protowire.Fixed64Type:
+		return "I64"
+	}
+	return "LEN"
+}
+
+// formatVarint returns v decoded as the type of f, or as an unsigned integer if f is nil.
+func formatVarint(f *inspectField, v uint64) string {
+	if f == nil {
+		return strconv.FormatUint(v, 10)
+	}
+	switch f.protoType {
+	case "int32", "enum":
+		return strconv.FormatInt(int64(int32(v)), 10)
+	case "int64":
+		return strconv.FormatInt(int64(v), 10)
+	case "uint32":
+		return strconv.FormatUint(uint64(uint32(v)), 10)
+	case "sint32":
+		return strconv.FormatInt(int64(int32(protowire.DecodeZigZag(v&math.MaxUint32))), 10) + "z"
+	case "sint64":
+		return strconv.FormatInt(protowire.DecodeZigZag(v), 10) + "z"
+	case "bool":
+		return strconv.FormatBool(v != 0)
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// formatFixed32 returns v decoded as the type of f, or as an unsigned integer if f is nil.
+func formatFixed32(f *inspectField, v uint32) string {
+	switch {
+	case f != nil && f.protoType == "float":
+		return strconv.FormatFloat(float64(math.Float32frombits(v)), 'g', -1, 32) + "i32"
+	case f != nil && f.protoType == "sfixed32":
+		return strconv.FormatInt(int64(int32(v)), 10) + "i32"
+	}
+	return strconv.FormatUint(uint64(v), 10) + "i32"
+}
+
+// formatFixed64 returns v decoded as the type of f, or as an unsigned integer if f is nil.
+func formatFixed64(f *inspectField, v uint64) string {
+	switch {
+	case f != nil && f.protoType == "double":
+		return strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64) + "i64"
+	case f != nil && f.protoType == "sfixed64":
+		return strconv.FormatInt(int64(v), 10) + "i64"
+	}
+	return strconv.FormatUint(v, 10) + "i64"
+}
+
+// formatPacked returns the values of the packed repeated field f in data, separated by spaces.
+func formatPacked(f *inspectField, data []byte) (string, error) {
+	var values []string
+	for len(data) > 0 {
+		var value string
+		var n int
+		switch inspectWireType(f) {
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(data)
+			value = formatFixed32(f, v)
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(data)
+			value = formatFixed64(f, v)
+		default:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			value = formatVarint(f, v)
+		}
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		values = append(values, value)
+		data = data[n:]
+	}
+	return strings.Join(values, " "), nil
+}
+
+// isMessage reports whether data, a length-delimited value of unknown type, parses as a
+// non-empty message.
+func isMessage(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return false
+		}
+		m := protowire.ConsumeFieldValue(num, typ, data[n:])
+		if m < 0 || typ == protowire.EndGroupType {
+			return false
+		}
+		data = data[n+m:]
+	}
+	return true
+}
+
+// isControl reports whether r is a control character other than whitespace, which are
+// unlikely in strings.
+func isControl(r rune) bool {
+	return r < 0x20 && r != '\n' && r != '\r' && r != '\t'
+}
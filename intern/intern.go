@@ -0,0 +1,56 @@
+// Package intern deduplicates decoded strings.
+//
+// Fields tagged with the intern option are decoded through a Table, so that equal
+// values share a single copy instead of each holding its own. Label-style fields,
+// which repeat a few distinct values over and over, then cost memory per distinct
+// value rather than per occurrence. UnmarshalProtobuf interns with a table per call;
+// UnmarshalProtobufIntern takes a table that can be kept across calls:
+//
+//	t := &intern.Table{Max: 100000}
+//	for _, data := range batches {
+//	    var batch Batch
+//	    if err := batch.UnmarshalProtobufIntern(data, t); err != nil {
+//	        return err
+//	    }
+//	    handle(&batch)
+//	}
+//
+// The zero value is ready to use. A Table must not be used concurrently.
+package intern
+
+import "strings"
+
+// Table is a set of strings returned in place of equal strings.
+type Table struct {
+	// Max is the number of strings at which the table is cleared before adding another,
+	// bounding its memory if the interned values turn out to be many. 0 means no limit.
+	Max int
+
+	m map[string]string
+}
+
+// String returns the string of t equal to s, adding a copy of s if there is none.
+// s may alias a buffer that is modified later.
+func (t *Table) String(s string) string {
+	if v, ok := t.m[s]; ok {
+		return v
+	}
+	if t.m == nil {
+		t.m = make(map[string]string)
+	} else if t.Max > 0 && len(t.m) >= t.Max {
+		clear(t.m)
+	}
+	v := strings.Clone(s)
+	t.m[v] = v
+	return v
+}
+
+// Len returns the number of strings in t.
+func (t *Table) Len() int {
+	return len(t.m)
+}
+
+// Reset removes all strings from t, keeping its memory for reuse.
+func (t *Table) Reset() {
+	clear(t.m)
+}
@@ -0,0 +1,49 @@
+package intern
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestString(t *testing.T) {
+	var tab Table
+	buf := []byte("label")
+	a := tab.String(string(buf))
+	b := tab.String("label")
+	if a != "label" || unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Fatalf("equal strings are not shared: %q, %q", a, b)
+	}
+
+	// The interned string must not alias the decoded buffer.
+	s := unsafe.String(&buf[0], len(buf))
+	c := tab.String(s)
+	buf[0] = 'L'
+	if c != "label" {
+		t.Fatalf("interned string changed with its source: %q", c)
+	}
+
+	if got := tab.String(""); got != "" {
+		t.Fatalf("got %q", got)
+	}
+	if n := tab.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+	tab.Reset()
+	if n := tab.Len(); n != 0 {
+		t.Fatalf("Len() = %d after Reset, want 0", n)
+	}
+}
+
+func TestMax(t *testing.T) {
+	tab := Table{Max: 2}
+	tab.String("a")
+	tab.String("b")
+	tab.String("a")
+	if n := tab.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+	tab.String("c")
+	if n := tab.Len(); n != 1 {
+		t.Fatalf("Len() = %d after exceeding Max, want 1", n)
+	}
+}
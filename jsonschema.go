@@ -0,0 +1,194 @@
+package easyprotogen
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+)
+
+// jsonSchemaBackend exports the types as a JSON Schema document of their proto3 JSON mapping,
+// the format of protojson.
+type jsonSchemaBackend struct{}
+
+func (jsonSchemaBackend) Name() string { return "jsonschema" }
+
+func (jsonSchemaBackend) Path(output string) string {
+	return strings.TrimSuffix(output, ".go") + ".schema.json"
+}
+
+func (jsonSchemaBackend) Generate(pkg *Package) ([]byte, error) {
+	doc := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$comment":    "Code generated by protogen. DO NOT EDIT.",
+		"title":       pkg.Name,
+		"description": "Message types of package " + pkg.Name + " in the proto3 JSON mapping. Their schemas are in $defs.",
+		"$defs":       jsonSchemas(pkg, "#/$defs/"),
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// jsonSchemas returns the JSON Schemas of the proto3 JSON mapping of the types of pkg by type
+// name. Message fields refer to the schemas of generated types by refPrefix followed by their
+// name; fields of other message types and of custom types accept any value.
+//
+// Field names are the lowerCamelCase JSON names of the .proto field names, which are the names
+// written by protojson; it also accepts the .proto names. Like in protojson, 64-bit integers
+// are strings or numbers, floating-point values may be "NaN", "Infinity" or "-Infinity", bytes
+// are base64 strings and map keys are strings. Enums are numbers, since their value names are
+// unknown.
+func jsonSchemas(pkg *Package, refPrefix string) map[string]any {
+	schemas := make(map[string]any, len(pkg.Types))
+	ref := func(typeName string) map[string]any { return jsonSchemaRef(pkg, refPrefix, typeName) }
+	for _, typeName := range pkg.Types {
+		info := pkg.TypeInfos[typeName]
+		properties := make(map[string]any)
+		var oneofs []any
+		for _, f := range info.Fields {
+			if f.IsOneof {
+				var variants, present []any
+				for _, v := range f.OneofVariants {
+					name := jsonName(snakeCase(f.Name) + "_" + snakeCase(v.TypeName))
+					schema := ref(v.TypeName)
+					if v.ProtoType != "" {
+						schema = jsonScalarSchema(v.ProtoType)
+					}
+					properties[name] = withDescription(schema, f.Doc)
+					required := map[string]any{"required": []string{name}}
+					variants = append(variants, required)
+					present = append(present, required)
+				}
+				// At most one variant is set.
+				oneofs = append(oneofs, map[string]any{
+					"oneOf": append(variants, map[string]any{"not": map[string]any{"anyOf": present}}),
+				})
+				continue
+			}
+
+			var schema map[string]any
+			switch {
+			case f.IsMap:
+				value := jsonScalarSchema(f.MapValueProto)
+				if f.MapValueCustom {
+					value = map[string]any{}
+				} else if f.MapValueIsMsg {
+					value = ref(f.MapValueType)
+				}
+				schema = map[string]any{
+					"type":                 "object",
+					"propertyNames":        jsonMapKeySchema(f.MapKeyProto),
+					"additionalProperties": value,
+				}
+			case f.IsCustom:
+				schema = map[string]any{}
+			case f.IsMessage:
+				schema = ref(f.ElemType)
+			case f.IsWrapper:
+				// Wrappers are represented by their value, or null.
+				schema = map[string]any{"anyOf": []any{jsonScalarSchema(f.ProtoType), map[string]any{"type": "null"}}}
+			default:
+				schema = jsonScalarSchema(schemaType(f))
+				if f.IsUUID && f.ProtoType == "string" {
+					schema["format"] = "uuid"
+				}
+			}
+			if f.IsRepeated && !f.IsMap {
+				schema = map[string]any{"type": "array", "items": schema}
+			}
+			if f.IsDeprecated {
+				schema["deprecated"] = true
+			}
+			properties[jsonName(snakeCase(f.Name))] = withDescription(schema, f.Doc)
+		}
+
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(oneofs) > 0 {
+			schema["allOf"] = oneofs
+		}
+		schemas[typeName] = withDescription(schema, info.Doc)
+	}
+	return schemas
+}
+
+// jsonSchemaRef returns a reference to the schema of a message type by refPrefix followed by
+// its name if pkg generates it, and a schema accepting any value otherwise.
+func jsonSchemaRef(pkg *Package, refPrefix, typeName string) map[string]any {
+	typeName = strings.TrimPrefix(typeName, "*")
+	if slices.Contains(pkg.Types, typeName) {
+		return map[string]any{"$ref": refPrefix + typeName}
+	}
+	return map[string]any{}
+}
+
+// jsonScalarSchema returns the JSON Schema of the proto3 JSON mapping of a scalar protobuf type.
+func jsonScalarSchema(protoType string) map[string]any {
+	switch protoType {
+	case "int32", "sint32", "sfixed32", "enum":
+		return map[string]any{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}
+	case "uint32", "fixed32":
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": math.MaxUint32}
+	case "int64", "sint64", "sfixed64":
+		return map[string]any{"type": []string{"string", "integer"}, "format": "int64", "pattern": "^-?[0-9]+$"}
+	case "uint64", "fixed64":
+		return map[string]any{"type": []string{"string", "integer"}, "format": "uint64", "pattern": "^[0-9]+$", "minimum": 0}
+	case "float", "double":
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "number"},
+			map[string]any{"enum": []string{"NaN", "Infinity", "-Infinity"}},
+		}}
+	case "bool":
+		return map[string]any{"type": "boolean"}
+	case "bytes":
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	}
+	return map[string]any{"type": "string"}
+}
+
+// jsonMapKeySchema returns the JSON Schema of the object keys of a map with keys of protoType.
+func jsonMapKeySchema(protoType string) map[string]any {
+	switch protoType {
+	case "string":
+		return map[string]any{"type": "string"}
+	case "bool":
+		return map[string]any{"enum": []string{"true", "false"}}
+	case "uint32", "fixed32", "uint64", "fixed64":
+		return map[string]any{"pattern": "^[0-9]+$"}
+	}
+	return map[string]any{"pattern": "^-?[0-9]+$"}
+}
+
+// withDescription returns schema with a description set to doc, if it isn't empty.
+func withDescription(schema map[string]any, doc string) map[string]any {
+	if doc = strings.Join(strings.Fields(doc), " "); doc != "" {
+		schema["description"] = doc
+	}
+	return schema
+}
+
+// jsonName returns the JSON name of a .proto field name, which is in lowerCamelCase like
+// the default json_name of protoc: user_id becomes userId.
+func jsonName(name string) string {
+	var sb strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper && 'a' <= r && r <= 'z':
+			sb.WriteRune(r - 'a' + 'A')
+			upper = false
+		default:
+			sb.WriteRune(r)
+			upper = false
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package easyprotogen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// lockFileName is the name of the schema lock file written to the package directory with -lock.
+const lockFileName = "protogen.lock"
+
+// lockFile is the JSON representation of protogen.lock.
+type lockFile struct {
+	Types schema `json:"types"`
+}
+
+// readLock returns the schema recorded in the lock file at path,
+// or an empty schema if the file doesn't exist.
+func readLock(path string) (schema, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return schema{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lf lockFile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	if lf.Types == nil {
+		lf.Types = schema{}
+	}
+	return lf.Types, nil
+}
+
+// marshalLock returns the contents of a lock file recording s.
+func marshalLock(s schema) ([]byte, error) {
+	data, err := json.MarshalIndent(lockFile{Types: s}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// updateLock returns locked with the types in current replaced by their current fields.
+//
+// Fields removed since the lock was written are kept as removed, so that their field
+// numbers are never reused.
+func updateLock(locked, current schema) schema {
+	updated := make(schema, len(locked)+len(current))
+	for typeName, fields := range locked {
+		updated[typeName] = fields
+	}
+	for typeName, fields := range current {
+		merged := append([]schemaField(nil), fields...)
+		for _, f := range locked[typeName] {
+			i := sort.Search(len(fields), func(i int) bool { return fields[i].Num >= f.Num })
+			if i < len(fields) && fields[i].Num == f.Num {
+				continue
+			}
+			f.Removed = true
+			merged = append(merged, f)
+		}
+		sort.Slice(merged, func(i, j int) bool {
+			return merged[i].Num < merged[j].Num
+		})
+		updated[typeName] = merged
+	}
+	return updated
+}
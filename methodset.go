@@ -0,0 +1,81 @@
+package easyprotogen
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+)
+
+// detectCustomTypes marks the message fields of typeInfos whose types are not generated with
+// them, but declare the methods of messages themselves, as custom fields delegating to those
+// methods, so that they need no custom option:
+//
+//   - types declaring MarshalProtobufTo and UnmarshalProtobuf, the methods required by the
+//     custom option;
+//   - types declaring MarshalProtobuf(dst []byte) []byte and UnmarshalProtobuf, like
+//     hand-written easyproto code, which are marshaled into bytes.
+//
+// Types declaring CloneProtobufInto are generated by protogen in another invocation, and are
+// called like the generated types. Only the methods declared in files are known, so types of
+// other packages, and methods promoted from embedded fields, are not detected. The files at
+// outputs are skipped, as they are replaced by the generated code.
+func detectCustomTypes(fset *token.FileSet, files []*ast.File, typeInfos map[string]*TypeInfo, opts Options, outputs []string) {
+	skip := make(map[string]bool)
+	for _, path := range outputs {
+		if abs, err := filepath.Abs(path); err == nil {
+			skip[abs] = true
+		}
+	}
+	var sources []*ast.File
+	for _, file := range files {
+		if abs, err := filepath.Abs(fset.Position(file.Pos()).Filename); err != nil || !skip[abs] {
+			sources = append(sources, file)
+		}
+	}
+	sets := methodSets(sources)
+
+	for _, info := range typeInfos {
+		for _, f := range info.Fields {
+			if !f.IsMessage || f.IsCustom || f.IsMap || f.IsOneof || f.IsTypeParam {
+				continue
+			}
+			if _, ok := typeInfos[genericName(f.ElemType)]; ok {
+				continue
+			}
+			methods := sets[genericName(f.ElemType)]
+			switch {
+			case methods["CloneProtobufInto"] != nil || methods[methodName(opts, "CloneProtobufInto")] != nil:
+			case !isMethod(methods["UnmarshalProtobuf"], 1, 1):
+			case isMethod(methods["MarshalProtobufTo"], 1, 0):
+				f.IsCustom = true
+			case isMethod(methods["MarshalProtobuf"], 1, 1):
+				f.IsCustom = true
+				f.IsBytesMarshaler = true
+			}
+		}
+	}
+}
+
+// isMethod returns true if fn is a method with the given numbers of parameters and results.
+func isMethod(fn *ast.FuncType, params, results int) bool {
+	return fn != nil && fn.Params.NumFields() == params && fn.Results.NumFields() == results
+}
+
+// methodSets returns the methods declared in files by receiver type name and method name.
+func methodSets(files []*ast.File) map[string]map[string]*ast.FuncType {
+	sets := make(map[string]map[string]*ast.FuncType)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 {
+				continue
+			}
+			typeName := getTypeName(funcDecl.Recv.List[0].Type)
+			if sets[typeName] == nil {
+				sets[typeName] = make(map[string]*ast.FuncType)
+			}
+			sets[typeName][funcDecl.Name.Name] = funcDecl.Type
+		}
+	}
+	return sets
+}
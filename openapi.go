@@ -0,0 +1,67 @@
+package easyprotogen
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// openAPIBackend exports the types as the component schemas of an OpenAPI 3.1 document, with
+// the operations of the HTTP handlers generated for services.
+type openAPIBackend struct{}
+
+func (openAPIBackend) Name() string { return "openapi" }
+
+func (openAPIBackend) Path(output string) string {
+	return strings.TrimSuffix(output, ".go") + ".openapi.json"
+}
+
+func (openAPIBackend) Generate(pkg *Package) ([]byte, error) {
+	body := func(typeName string) map[string]any {
+		return map[string]any{"content": map[string]any{
+			"application/x-protobuf": map[string]any{
+				"schema": jsonSchemaRef(pkg, "#/components/schemas/", typeName),
+			},
+		}}
+	}
+	paths := make(map[string]any)
+	for _, s := range pkg.Services {
+		for _, m := range s.Methods {
+			request := body(m.Request)
+			request["required"] = true
+			response := body(m.Response)
+			response["description"] = m.Response + " message"
+			paths["/"+pkg.Name+"."+s.Name+"/"+m.Name] = map[string]any{
+				"post": map[string]any{
+					"operationId": s.Name + "_" + m.Name,
+					"tags":        []string{s.Name},
+					"requestBody": request,
+					"responses": map[string]any{
+						"200": response,
+						"default": map[string]any{
+							"description": "Error returned by the service, or caused by the request",
+							"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+						},
+					},
+				},
+			}
+		}
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       pkg.Name,
+			"version":     "1.0.0",
+			"description": "Code generated by protogen. DO NOT EDIT.",
+		},
+		"components": map[string]any{"schemas": jsonSchemas(pkg, "#/components/schemas/")},
+	}
+	if len(paths) > 0 {
+		doc["paths"] = paths
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
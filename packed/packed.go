@@ -0,0 +1,98 @@
+// Package packed decodes packed repeated fields of fixed-width types in bulk.
+//
+// Packed fixed64, sfixed64, double, fixed32, sfixed32 and float values are stored as a
+// little-endian array, which is the memory layout of a []uint64, []int64, []float64,
+// []uint32, []int32 or []float32 on little-endian machines. Append copies the array into the
+// slice with a single copy there, instead of decoding and appending one value at a time:
+//
+//	case 2:
+//	    data, ok := fc.MessageData()
+//	    if !ok {
+//	        return errors.New("expected packed values")
+//	    }
+//	    if x.Values, ok = packed.Append(x.Values, data); !ok {
+//	        return errors.New("truncated packed values")
+//	    }
+//
+// Types generated by protogen use Append for their repeated fixed-width fields, and
+// AppendVarints and MarshalVarints for repeated integer and enum fields whose Go type differs
+// from the type read and written by easyproto, like []int8 fields encoded as int32.
+//
+// Builds with the tinygo or purego build tag decode the values one at a time instead, without
+// package unsafe.
+package packed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+)
+
+// Fixed is the set of element types of fixed-width packed fields.
+type Fixed interface {
+	~uint64 | ~int64 | ~float64 | ~uint32 | ~int32 | ~float32
+}
+
+// Append appends the values of the packed array src to dst and returns the result. It returns
+// dst unchanged and false if the length of src is not a multiple of the size of T.
+func Append[T Fixed](dst []T, src []byte) ([]T, bool) {
+	size := fixedSize[T]()
+	if len(src)%size != 0 {
+		return dst, false
+	}
+	n := len(src) / size
+	if n == 0 {
+		return dst, true
+	}
+	start := len(dst)
+	dst = slices.Grow(dst, n)[:start+n]
+	copyFixed(dst[start:], src)
+	return dst, true
+}
+
+// Integer is the set of element types of varint-encoded packed fields.
+type Integer interface {
+	~int8 | ~int16 | ~int32 | ~int64 | ~int | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uint
+}
+
+// AppendVarints appends the values of the packed varints of src, read as W like the protobuf
+// type of the field, to dst converted to T and returns the result. Values that don't fit into T
+// are truncated if truncate is set, and an error wrapping easyprotoerr.ErrOutOfRange
+// otherwise; a truncated varint is an easyprotoerr.ErrTruncated error.
+//
+// Varints over the range of an unsigned W, like uint32 values above math.MaxUint32, are
+// ErrOutOfRange errors even if truncate is set, as easyproto rejects them in unpacked fields.
+// Signed W values are truncated to W like protobuf int32 values, which are written either
+// sign-extended to 64 bits or as their 32-bit two's complement.
+func AppendVarints[W, T Integer](dst []T, src []byte, truncate bool) ([]T, error) {
+	for len(src) > 0 {
+		u, n := binary.Uvarint(src)
+		if n <= 0 {
+			return dst, easyprotoerr.ErrTruncated
+		}
+		src = src[n:]
+		w := W(u)
+		if ^W(0) > 0 && uint64(w) != u {
+			return dst, fmt.Errorf("value %d out of range of %T: %w", u, w, easyprotoerr.ErrOutOfRange)
+		}
+		v := T(w)
+		if !truncate && W(v) != w {
+			return dst, fmt.Errorf("value %d out of range of %T: %w", w, v, easyprotoerr.ErrOutOfRange)
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
+
+// MarshalVarints appends the values of src converted to W, like the protobuf type of the field,
+// to dst as packed varints and returns the result. Negative values are sign-extended to 64
+// bits, like negative int32 and int64 protobuf values. Values of src that don't fit into W are
+// truncated to W, so AppendVarints with the same W reads them back truncated.
+func MarshalVarints[W, T Integer](dst []byte, src []T) []byte {
+	for _, v := range src {
+		dst = binary.AppendUvarint(dst, uint64(W(v)))
+	}
+	return dst
+}
@@ -0,0 +1,106 @@
+package packed
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+)
+
+type celsius float64
+
+func TestAppend(t *testing.T) {
+	var src []byte
+	for _, v := range []float64{1.5, -2, math.Inf(1)} {
+		src = binary.LittleEndian.AppendUint64(src, math.Float64bits(v))
+	}
+	got, ok := Append([]celsius{7}, src)
+	if !ok || len(got) != 4 || got[0] != 7 || got[1] != 1.5 || got[2] != -2 || !math.IsInf(float64(got[3]), 1) {
+		t.Fatalf("got %v, %v", got, ok)
+	}
+
+	src = nil
+	for _, v := range []int32{-1, 1 << 30} {
+		src = binary.LittleEndian.AppendUint32(src, uint32(v))
+	}
+	ints, ok := Append[int32](nil, src)
+	if !ok || len(ints) != 2 || ints[0] != -1 || ints[1] != 1<<30 {
+		t.Fatalf("got %v, %v", ints, ok)
+	}
+
+	src = binary.LittleEndian.AppendUint32(nil, math.Float32bits(-0.25))
+	if floats, ok := Append[float32](nil, src); !ok || len(floats) != 1 || floats[0] != -0.25 {
+		t.Fatalf("got %v, %v", floats, ok)
+	}
+	src = binary.LittleEndian.AppendUint32(nil, math.MaxUint32)
+	if u, ok := Append[uint32](nil, src); !ok || len(u) != 1 || u[0] != math.MaxUint32 {
+		t.Fatalf("got %v, %v", u, ok)
+	}
+	src = binary.LittleEndian.AppendUint64(nil, uint64(1<<63))
+	if longs, ok := Append[int64](nil, src); !ok || len(longs) != 1 || longs[0] != math.MinInt64 {
+		t.Fatalf("got %v, %v", longs, ok)
+	}
+
+	if got, ok := Append[int32](nil, nil); !ok || len(got) != 0 {
+		t.Fatalf("empty array: got %v, %v", got, ok)
+	}
+	dst := []uint64{1}
+	if got, ok := Append(dst, make([]byte, 12)); ok || len(got) != 1 {
+		t.Fatalf("truncated array: got %v, %v; want the original slice and false", got, ok)
+	}
+}
+
+func TestAppendReusesCapacity(t *testing.T) {
+	dst := make([]uint64, 0, 4)
+	src := binary.LittleEndian.AppendUint64(nil, 42)
+	got, _ := Append(dst, src)
+	if &got[0] != &dst[:1][0] {
+		t.Fatal("expected Append to reuse the capacity of dst")
+	}
+	if n := testing.AllocsPerRun(100, func() { Append(dst, src) }); n != 0 {
+		t.Fatalf("got %v allocations, want 0", n)
+	}
+}
+
+func TestVarints(t *testing.T) {
+	src := MarshalVarints[int32]([]byte{}, []int8{-128, -1, 0, 127})
+	// Negative int32 values take 10 bytes, like in protoc-gen-go output.
+	if len(src) != 22 {
+		t.Fatalf("got %d bytes, want 22", len(src))
+	}
+	got, err := AppendVarints[int32]([]int8{5}, src, false)
+	if err != nil || !slices.Equal(got, []int8{5, -128, -1, 0, 127}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+
+	src = MarshalVarints[uint32](nil, []uint32{255, 256, 1 << 20})
+	if _, err := AppendVarints[uint32, uint8](nil, src, false); !errors.Is(err, easyprotoerr.ErrOutOfRange) {
+		t.Fatalf("got error %v, want ErrOutOfRange", err)
+	}
+	if got, err := AppendVarints[uint32, uint8](nil, src, true); err != nil || !slices.Equal(got, []uint8{255, 0, 0}) {
+		t.Fatalf("truncated: got %v, %v", got, err)
+	}
+	// Values over the range of an unsigned W are rejected even when truncating to T.
+	src = MarshalVarints[uint64](nil, []uint64{1, 1 << 32})
+	for _, truncate := range []bool{false, true} {
+		if got, err := AppendVarints[uint32, uint64](nil, src, truncate); !errors.Is(err, easyprotoerr.ErrOutOfRange) || !slices.Equal(got, []uint64{1}) {
+			t.Fatalf("truncate=%v: got %v, %v, want [1] and ErrOutOfRange", truncate, got, err)
+		}
+	}
+	// MarshalVarints truncates values of src to W, so they round-trip truncated.
+	src = MarshalVarints[uint32](nil, []uint64{1<<32 + 7})
+	if got, err := AppendVarints[uint32, uint64](nil, src, false); err != nil || !slices.Equal(got, []uint64{7}) {
+		t.Fatalf("got %v, %v, want [7]", got, err)
+	}
+	// Signed 32-bit values round-trip in both encodings of negative int32 values.
+	src = MarshalVarints[uint32](nil, []int32{-1})
+	if got, err := AppendVarints[int32, int32](nil, src, false); err != nil || !slices.Equal(got, []int32{-1}) {
+		t.Fatalf("32-bit negative: got %v, %v", got, err)
+	}
+	if _, err := AppendVarints[int32, int16](nil, []byte{0x80}, false); !errors.Is(err, easyprotoerr.ErrTruncated) {
+		t.Fatalf("got error %v, want ErrTruncated", err)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build tinygo || purego
+
+package packed
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// fixedSize returns the number of bytes of a value of T.
+func fixedSize[T Fixed]() int {
+	size, _ := fixedKind[T]()
+	return size
+}
+
+// fixedKind returns the number of bytes of a value of T and whether T is a floating-point type,
+// found without unsafe.Sizeof: 2^32 overflows 32-bit integers, and 1+2^-30 rounds to 1 in
+// float32 but not in float64.
+func fixedKind[T Fixed]() (size int, float bool) {
+	if half := T(1) / 2; half != 0 {
+		eps := T(1)
+		for range 30 {
+			eps /= 2
+		}
+		if T(1)+eps == T(1) {
+			return 4, true
+		}
+		return 8, true
+	}
+	v := T(1)
+	for range 32 {
+		v *= 2
+	}
+	if v == 0 {
+		return 4, false
+	}
+	return 8, false
+}
+
+// copyFixed decodes the values of the packed array src into dst, which has room for all of them.
+func copyFixed[T Fixed](dst []T, src []byte) {
+	size, float := fixedKind[T]()
+	for i := range dst {
+		switch {
+		case size == 8 && float:
+			dst[i] = T(math.Float64frombits(binary.LittleEndian.Uint64(src[8*i:])))
+		case size == 8:
+			dst[i] = T(binary.LittleEndian.Uint64(src[8*i:]))
+		case float:
+			dst[i] = T(math.Float32frombits(binary.LittleEndian.Uint32(src[4*i:])))
+		default:
+			dst[i] = T(binary.LittleEndian.Uint32(src[4*i:]))
+		}
+	}
+}
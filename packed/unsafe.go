@@ -0,0 +1,36 @@
+//go:build !tinygo && !purego
+
+package packed
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// littleEndian is true if the machine stores integers in little-endian byte order.
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// fixedSize returns the number of bytes of a value of T.
+func fixedSize[T Fixed]() int {
+	return int(unsafe.Sizeof(T(0)))
+}
+
+// copyFixed copies the values of the packed array src into dst, which has room for all of them.
+// On little-endian machines, src has the memory layout of dst and is copied as bytes.
+func copyFixed[T Fixed](dst []T, src []byte) {
+	out := unsafe.Slice((*byte)(unsafe.Pointer(&dst[0])), len(src))
+	if littleEndian {
+		copy(out, src)
+		return
+	}
+	// Reverse the bytes of every value on big-endian machines.
+	size := fixedSize[T]()
+	for i := 0; i < len(src); i += size {
+		switch size {
+		case 8:
+			binary.NativeEndian.PutUint64(out[i:], binary.LittleEndian.Uint64(src[i:]))
+		case 4:
+			binary.NativeEndian.PutUint32(out[i:], binary.LittleEndian.Uint32(src[i:]))
+		}
+	}
+}
@@ -0,0 +1,1039 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func parseStruct(typeName string, structType *ast.StructType) (*TypeInfo, error) {
+	info := &TypeInfo{
+		Name: typeName,
+	}
+
+	// Track field numbers to detect duplicates
+	seenFieldNums := make(map[int]string)
+
+	for _, field := range structType.Fields.List {
+		// Parse the struct tag
+		var protoTag string
+		if field.Tag != nil {
+			tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+			protoTag = tag.Get("protobuf")
+		}
+		if protoTag == "" || protoTag == "-" {
+			names := fieldNames(field)
+			if protoTag == "-" {
+				info.Excluded = append(info.Excluded, names...)
+			} else {
+				info.Untagged = append(info.Untagged, names...)
+			}
+			continue
+		}
+		if strings.HasPrefix(protoTag, "-,") {
+			return nil, fmt.Errorf("invalid tag %q: protobuf:\"-\" excludes the field and takes no options", protoTag)
+		}
+
+		parts := strings.Split(protoTag, ",")
+
+		// Check for oneof tag format: `protobuf:"oneof,TypeA:1,TypeB:2"`
+		isOneof := strings.TrimSpace(parts[0]) == "oneof"
+		var oneofVariants []OneofVariant
+		var fieldNum int
+		var err error
+
+		if isOneof {
+			// Validate that the field type is valid for oneof (must be interface-like, not primitive/slice/map)
+			if err := validateOneofFieldType(field.Type); err != nil {
+				fieldName := ""
+				if len(field.Names) > 0 {
+					fieldName = field.Names[0].Name
+				}
+				return nil, fmt.Errorf("invalid oneof field %q in type %s: %w", fieldName, typeName, err)
+			}
+
+			// Parse oneof variants
+			if len(parts) < 2 {
+				return nil, fmt.Errorf("oneof tag requires at least one variant: %s", protoTag)
+			}
+			for _, part := range parts[1:] {
+				part = strings.TrimSpace(part)
+				// Variants are Type:FieldNum, or Type:FieldNum:type for wrappers of scalars.
+				elems := strings.Split(part, ":")
+				if len(elems) < 2 || len(elems) > 3 {
+					return nil, fmt.Errorf("invalid oneof variant %q in tag %q: expected Type:FieldNum format", part, protoTag)
+				}
+				variantType := strings.TrimSpace(elems[0])
+				variantFieldNum, err := strconv.Atoi(strings.TrimSpace(elems[1]))
+				if err != nil {
+					return nil, fmt.Errorf("invalid field number for oneof variant %q in tag %q", part, protoTag)
+				}
+				var variantProto string
+				if len(elems) == 3 {
+					variantProto = strings.TrimSpace(elems[2])
+					if !isValidProtoType(variantProto) || slices.Contains([]string{"message", "enum", "map", "oneof"}, variantProto) {
+						return nil, fmt.Errorf("invalid type %q for oneof variant %q in tag %q: must be a scalar type, with int32 for enums", variantProto, variantType, protoTag)
+					}
+				}
+				// Validate variant field number
+				if variantFieldNum < 1 || variantFieldNum > 536870911 {
+					return nil, fmt.Errorf("invalid field number %d for oneof variant %q: must be 1-536870911", variantFieldNum, variantType)
+				}
+				if variantFieldNum >= 19000 && variantFieldNum <= 19999 {
+					return nil, fmt.Errorf("invalid field number %d for oneof variant %q: range 19000-19999 is reserved", variantFieldNum, variantType)
+				}
+				// Check for duplicate field numbers in oneof
+				for _, existing := range oneofVariants {
+					if existing.FieldNum == variantFieldNum {
+						return nil, fmt.Errorf("duplicate field number %d in oneof: used by both %q and %q", variantFieldNum, existing.TypeName, variantType)
+					}
+				}
+				oneofVariants = append(oneofVariants, OneofVariant{
+					TypeName:  variantType,
+					FieldNum:  variantFieldNum,
+					ProtoType: variantProto,
+				})
+			}
+			// Use -1 as sentinel for oneof (no single field number)
+			fieldNum = -1
+		} else {
+			fieldNum, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid field number in tag %q: must be a number", protoTag)
+			}
+
+			// Validate field number range (protobuf spec: 1 to 2^29-1, with 19000-19999 reserved)
+			if fieldNum < 1 {
+				return nil, fmt.Errorf("invalid field number %d in tag %q: must be >= 1", fieldNum, protoTag)
+			}
+			if fieldNum > 536870911 { // 2^29 - 1
+				return nil, fmt.Errorf("invalid field number %d in tag %q: must be <= 536870911", fieldNum, protoTag)
+			}
+			if fieldNum >= 19000 && fieldNum <= 19999 {
+				return nil, fmt.Errorf("invalid field number %d in tag %q: range 19000-19999 is reserved", fieldNum, protoTag)
+			}
+		}
+
+		// Pointers to slices and maps, nil if the field is absent, are encoded like the slice
+		// or map they point to.
+		fieldType, isIndirect := field.Type, false
+		if star, ok := field.Type.(*ast.StarExpr); ok && !isOneof && isSliceOrMap(star.X) {
+			fieldType, isIndirect = star.X, true
+		}
+
+		// Proto type is optional - can be inferred from Go type
+		var protoType string
+		optionStart := 2
+		inferred := false
+		if isOneof {
+			protoType = "oneof"
+		} else if len(parts) >= 2 && !isValidProtoType(strings.TrimSpace(parts[1])) && isValidOption(strings.TrimSpace(parts[1])) {
+			// Options directly after the field number: `protobuf:"1,extract"`
+			protoType = inferProtoType(fieldType)
+			optionStart = 1
+			inferred = true
+		} else if len(parts) >= 2 {
+			protoType = strings.TrimSpace(parts[1])
+			// Validate explicit protobuf type
+			if !isValidProtoType(protoType) {
+				return nil, fmt.Errorf("invalid protobuf type %q in tag %q", protoType, protoTag)
+			}
+		} else {
+			// Infer from Go type
+			protoType = inferProtoType(fieldType)
+			inferred = true
+		}
+
+		// Reject interface types (like 'any' or custom interfaces)
+		// Note: oneof fields never reach here with protoType="interface" since they get protoType="oneof" above
+		if protoType == "interface" {
+			fieldName := ""
+			if len(field.Names) > 0 {
+				fieldName = field.Names[0].Name
+			}
+			return nil, fmt.Errorf("interface types are not supported for protobuf (use oneof tag for polymorphism): field %q in type %s has type %s",
+				fieldName, typeName, exprToString(fieldType))
+		}
+
+		// Check for options
+		isRepeated := false
+		isOptional := false
+		isEnum := protoType == "enum"
+		isMap := protoType == "map"
+		isCustom := false
+		isExtract := false
+		isFunc := false
+		isIter := false
+		isParallel := false
+		isIntern := false
+		isStringBytes := false
+		isRedact := false
+		isDeprecated := false
+		isTruncate := false
+		isUnpacked := false
+		isNonNil := false
+		isMerge, isReuse := false, false
+		isEmitEmpty := false
+		isAlways := false
+		isWrapper := false
+		isUUIDString := false
+		isBigInt, isBigRat := false, false
+		isUnscaled := false
+		isNilError, isNilEmpty := false, false
+		prealloc, preallocCount := 0, false
+
+		// For maps, we need key and value types from the tag or infer them
+		var mapKeyProto, mapValueProto string
+		var mapValueCustom bool
+		if isMap {
+			if optionStart == 2 && len(parts) >= 4 {
+				// Explicit: `protobuf:"1,map,string,int32"`
+				mapKeyProto = strings.TrimSpace(parts[2])
+				mapValueProto = strings.TrimSpace(parts[3])
+			} else if mapType, ok := fieldType.(*ast.MapType); ok {
+				// Infer from Go type: `protobuf:"1"` on map[string]int32
+				mapKeyProto = inferProtoType(mapType.Key)
+				mapValueProto = inferProtoType(mapType.Value)
+				inferred = true
+			}
+			// Validate map key type (only certain scalar types allowed)
+			if !isValidMapKeyType(mapKeyProto) {
+				return nil, fmt.Errorf("invalid map key type %q in tag %q: must be string, bool, or integer type", mapKeyProto, protoTag)
+			}
+		}
+
+		// Parse options from remaining parts (if any) - skip for oneof (already parsed)
+		if !isOneof {
+			if isMap && optionStart == 2 && len(parts) >= 4 {
+				optionStart = 4 // Skip map key/value types
+			}
+			if len(parts) > optionStart {
+				for _, part := range parts[optionStart:] {
+					switch strings.TrimSpace(part) {
+					case "repeated":
+						isRepeated = true
+					case "optional":
+						isOptional = true
+					case "enum":
+						isEnum = true
+					case "custom":
+						isCustom = true
+						// For maps, custom applies to the value type
+						if isMap {
+							mapValueCustom = true
+						}
+					case "extract":
+						isExtract = true
+					case "func":
+						isFunc = true
+					case "iter":
+						isIter = true
+					case "parallel":
+						isParallel = true
+					case "intern":
+						isIntern = true
+					case "stringbytes":
+						isStringBytes = true
+					case "redact":
+						isRedact = true
+					case "deprecated":
+						isDeprecated = true
+					case "prealloc":
+						preallocCount = true
+					case "truncate":
+						isTruncate = true
+					case "unpacked":
+						isUnpacked = true
+					case "nonnil":
+						isNonNil = true
+					case "merge":
+						isMerge = true
+					case "reuse":
+						isReuse = true
+					case "emitempty":
+						isEmitEmpty = true
+					case "always":
+						isAlways = true
+					case "wrapper":
+						isWrapper = true
+					case "uuidstring":
+						isUUIDString = true
+					case "bigint":
+						isBigInt = true
+					case "bigrat":
+						isBigRat = true
+					case "unscaled":
+						isUnscaled = true
+					case "nilerror":
+						isNilError = true
+					case "nilempty":
+						isNilEmpty = true
+					default:
+						if v, ok := strings.CutPrefix(strings.TrimSpace(part), "prealloc="); ok {
+							n, err := strconv.Atoi(v)
+							if err != nil || n <= 0 {
+								return nil, fmt.Errorf("invalid prealloc size %q in tag %q: must be a positive integer", v, protoTag)
+							}
+							prealloc = n
+						}
+					}
+				}
+			}
+		}
+
+		// UUIDs are encoded as bytes, or as strings with the uuidstring option.
+		isUUID := isUUIDType(fieldType)
+		if isUUID {
+			switch {
+			case inferred && isUUIDString:
+				protoType = "string"
+			case inferred:
+				protoType = "bytes"
+			case protoType != "string" && (protoType != "bytes" || isUUIDString):
+				return nil, fmt.Errorf("invalid protobuf type %q in tag %q: UUIDs are encoded as bytes, or as strings with the uuidstring option", protoType, protoTag)
+			}
+		}
+
+		// Numbers of package math/big are encoded as bytes, like the custom types they are
+		// wire-compatible with: big.Rat as a message of its numerator and denominator.
+		if bigType := bigNumberType(fieldType); bigType != "" || isBigInt || isBigRat {
+			switch {
+			case bigType == "Int" && !isBigInt, bigType == "Rat" && !isBigRat:
+				return nil, fmt.Errorf("big.%s fields need the %s option: tag %q", bigType, "big"+strings.ToLower(bigType), protoTag)
+			case bigType == "Int" && isBigRat, bigType == "Rat" && isBigInt, bigType == "":
+				return nil, fmt.Errorf("bigint and bigrat options are only supported on big.Int and big.Rat fields and pointers to them respectively: tag %q", protoTag)
+			case inferred:
+				protoType = "bytes"
+			case protoType != "bytes" && (protoType != "message" || isBigInt):
+				return nil, fmt.Errorf("invalid protobuf type %q in tag %q: big.%s is encoded as bytes", protoType, protoTag, bigType)
+			}
+			protoType = "bytes"
+		}
+
+		// Decimals are encoded as strings, or as messages of their unscaled value and exponent
+		// with the unscaled option.
+		isDecimal := isDecimalType(fieldType)
+		if isDecimal {
+			switch {
+			case inferred && isUnscaled:
+				protoType = "bytes"
+			case inferred:
+				protoType = "string"
+			case protoType == "string" && !isUnscaled:
+			case (protoType == "bytes" || protoType == "message") && isUnscaled:
+				protoType = "bytes"
+			default:
+				return nil, fmt.Errorf("invalid protobuf type %q in tag %q: decimals are encoded as strings, or as messages with the unscaled option", protoType, protoTag)
+			}
+		}
+
+		// Nullable values of package database/sql, like sql.NullInt64, are encoded as their
+		// value when they are valid and left out otherwise.
+		nullValue, nullValueType, isSQLNull := sqlNullValue(fieldType)
+		if isSQLNull {
+			valueProto := ""
+			if nullValueType != nil {
+				valueProto = inferProtoType(nullValueType)
+			}
+			switch {
+			case !slices.Contains([]string{"string", "bool", "int32", "int64", "uint32", "uint64", "float", "double"}, valueProto):
+				return nil, fmt.Errorf("type %s is not supported: only sql.Null types of strings, bools and numbers are", exprToString(fieldType))
+			case inferred:
+				protoType = valueProto
+			case protoType == "enum" || readType(protoType) != readType(valueProto):
+				return nil, fmt.Errorf("invalid protobuf type %q in tag %q: %s holds %s values", protoType, protoTag, exprToString(fieldType), exprToString(nullValueType))
+			}
+		}
+
+		// Handle embedded fields (anonymous fields) - they have no Names
+		fieldNames := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			fieldNames = append(fieldNames, name.Name)
+		}
+		if len(fieldNames) == 0 {
+			// Embedded field - use the type name as the field name
+			embeddedName := getTypeName(field.Type)
+			if embeddedName == "" {
+				return nil, fmt.Errorf("cannot determine name for embedded field with tag %q in type %s", protoTag, typeName)
+			}
+			fieldNames = append(fieldNames, embeddedName)
+		}
+
+		for _, fieldName := range fieldNames {
+			// Check for duplicate field numbers
+			if isOneof {
+				// For oneof, check all variant field numbers
+				for _, variant := range oneofVariants {
+					if existingField, ok := seenFieldNums[variant.FieldNum]; ok {
+						return nil, fmt.Errorf("duplicate field number %d: used by both %q and oneof variant %q in type %s",
+							variant.FieldNum, existingField, variant.TypeName, typeName)
+					}
+					seenFieldNums[variant.FieldNum] = fieldName + ":" + variant.TypeName
+				}
+			} else {
+				if existingField, ok := seenFieldNums[fieldNum]; ok {
+					return nil, fmt.Errorf("duplicate field number %d: used by both %q and %q in type %s",
+						fieldNum, existingField, fieldName, typeName)
+				}
+				seenFieldNums[fieldNum] = fieldName
+			}
+
+			fi := &FieldInfo{
+				Name:          fieldName,
+				FieldNum:      fieldNum,
+				ProtoType:     protoType,
+				IsRepeated:    isRepeated,
+				IsOptional:    isOptional,
+				IsMessage:     protoType == "message",
+				IsEnum:        isEnum,
+				IsMap:         isMap,
+				IsCustom:      isCustom,
+				IsExtract:     isExtract,
+				IsFunc:        isFunc,
+				IsIter:        isIter,
+				IsParallel:    isParallel,
+				IsIntern:      isIntern,
+				IsStringBytes: isStringBytes,
+				Prealloc:      prealloc,
+				PreallocCount: preallocCount,
+				IsRedact:      isRedact,
+				IsDeprecated:  isDeprecated,
+				IsTruncate:    isTruncate,
+				IsUnpacked:    isUnpacked,
+				IsNonNil:      isNonNil,
+				IsMerge:       isMerge,
+				IsReuse:       isReuse,
+				IsEmitEmpty:   isEmitEmpty,
+				IsAlways:      isAlways,
+				IsWrapper:     isWrapper,
+				IsUUID:        isUUID,
+				IsBigInt:      isBigInt,
+				IsBigRat:      isBigRat,
+				IsDecimal:     isDecimal,
+				IsSQLNull:     isSQLNull,
+				IsNilError:    isNilError,
+				IsNilEmpty:    isNilEmpty,
+				NullValue:     nullValue,
+				IsIndirect:    isIndirect,
+				IsInferred:    inferred,
+				IsOneof:       isOneof,
+				OneofVariants: oneofVariants,
+			}
+
+			// Analyze Go type
+			fi.GoType = exprToString(fieldType)
+			if field.Doc != nil {
+				fi.Doc = field.Doc.Text()
+			} else if field.Comment != nil {
+				fi.Doc = field.Comment.Text()
+			}
+			analyzeType(fi, fieldType)
+			if isSQLNull {
+				// Invalid values are left out, so presence is tracked like proto3 optional fields.
+				fi.IsOptional = true
+				fi.NullValueType = exprToString(nullValueType)
+			}
+
+			// Handle map-specific parsing
+			if fi.IsMap {
+				fi.MapKeyProto = mapKeyProto
+				fi.MapValueProto = mapValueProto
+				fi.MapValueIsMsg = mapValueProto == "message"
+				fi.MapValueCustom = mapValueCustom
+				// Extract key/value Go types from the AST
+				if mapType, ok := fieldType.(*ast.MapType); ok {
+					fi.MapKeyType = exprToString(mapType.Key)
+					fi.MapValueType = exprToString(mapType.Value)
+					// Check if value is a pointer
+					if _, isPtr := mapType.Value.(*ast.StarExpr); isPtr {
+						fi.MapValueIsPtr = true
+					}
+				}
+			}
+
+			// Handle enum type conversion
+			if fi.IsEnum {
+				fi.NeedsTypeConv = true
+				fi.ConvType = "int32"
+			}
+
+			// Integers narrower than 32 bits are encoded as int32 or uint32 and checked
+			// against their range when decoded.
+			if err := checkNarrowInts(fi); err != nil {
+				return nil, fmt.Errorf("%w: field %q in type %s", err, fieldName, typeName)
+			}
+
+			// String fields stored as []byte are decoded and encoded like bytes fields;
+			// only their schema differs.
+			isBytes := fi.BaseType == "[]byte" && !fi.IsRepeated || fi.ElemType == "[]byte" && fi.IsRepeated
+			if fi.IsStringBytes {
+				if fi.ProtoType != "string" && fi.ProtoType != "bytes" || !isBytes || fi.IsPointer {
+					return nil, fmt.Errorf("stringbytes option is only supported on []byte and [][]byte fields: field %q in type %s", fieldName, typeName)
+				}
+				fi.ProtoType = "bytes"
+			}
+
+			if fi.IsReuse && (!isBytes || fi.IsPointer || fi.IsMap || fi.IsIndirect) {
+				return nil, fmt.Errorf("reuse option is only supported on []byte and [][]byte fields: field %q in type %s", fieldName, typeName)
+			}
+
+			if fi.IsExtract && (fi.IsRepeated || fi.IsMap || fi.IsMessage) {
+				return nil, fmt.Errorf("extract option is only supported on scalar fields: field %q in type %s", fieldName, typeName)
+			}
+
+			if fi.IsFunc && (!fi.IsRepeated || !fi.IsMessage || fi.IsMap) {
+				return nil, fmt.Errorf("func option is only supported on repeated message fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsIter && (!fi.IsRepeated || !fi.IsMessage || fi.IsMap) {
+				return nil, fmt.Errorf("iter option is only supported on repeated message fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.PreallocCount && !fi.IsMap {
+				return nil, fmt.Errorf("prealloc option without a size is only supported on map fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.Prealloc > 0 && !fi.IsMap && !fi.IsRepeated {
+				return nil, fmt.Errorf("prealloc option is only supported on map and repeated fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsIntern && !hasStrings(fi) {
+				return nil, fmt.Errorf("intern option is only supported on string fields and maps with string keys or values: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsParallel && (!fi.IsRepeated || !fi.IsMessage || fi.IsMap) {
+				return nil, fmt.Errorf("parallel option is only supported on repeated message fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsUnpacked && (!fi.IsRepeated || fi.IsMessage || fi.IsMap || isLengthDelimited(fi.ProtoType)) {
+				return nil, fmt.Errorf("unpacked option is only supported on repeated scalar fields other than strings and bytes: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsNonNil && !fi.IsRepeated && !fi.IsMap {
+				return nil, fmt.Errorf("nonnil option is only supported on repeated and map fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsEmitEmpty && (!fi.IsRepeated || fi.IsMessage || fi.IsMap || isLengthDelimited(fi.ProtoType) || fi.IsUnpacked) {
+				return nil, fmt.Errorf("emitempty option is only supported on packed repeated scalar fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsAlways && (fi.IsRepeated || fi.IsPointer || fi.IsMessage || fi.IsMap || fi.IsOneof) {
+				return nil, fmt.Errorf("always option is only supported on singular non-pointer scalar, enum, string and bytes fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsWrapper && (!fi.IsPointer || fi.IsRepeated || fi.IsEnum || wrapperTypes[fi.ProtoType] == "") {
+				return nil, fmt.Errorf("wrapper option is only supported on pointers to double, float, int64, uint64, int32, uint32, bool, string and bytes values: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsWrapper && fi.IsExtract {
+				return nil, fmt.Errorf("extract option is not supported on wrapper fields: field %q in type %s", fieldName, typeName)
+			}
+			if isUUIDString && !fi.IsUUID {
+				return nil, fmt.Errorf("uuidstring option is only supported on uuid.UUID and [16]byte fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsUUID && (fi.IsPointer || fi.IsSliceOfPtr || fi.IsExtract || fi.Prealloc > 0) {
+				return nil, fmt.Errorf("UUIDs are only supported as values and slices of values, without the extract and prealloc options: field %q in type %s", fieldName, typeName)
+			}
+			if (fi.IsBigInt || fi.IsBigRat) && (fi.IsRepeated || fi.IsExtract || fi.IsAlways || fi.IsWrapper) {
+				return nil, fmt.Errorf("bigint and bigrat options are only supported on singular fields, without the extract, always and wrapper options: field %q in type %s", fieldName, typeName)
+			}
+			if isUnscaled && !fi.IsDecimal {
+				return nil, fmt.Errorf("unscaled option is only supported on decimal.Decimal fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsSQLNull && (fi.IsPointer || fi.IsRepeated || fi.IsExtract || fi.IsAlways || fi.IsWrapper) {
+				return nil, fmt.Errorf("sql.Null types are only supported in singular non-pointer fields, without the extract, always and wrapper options: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsMerge && !fi.IsMap {
+				return nil, fmt.Errorf("merge option is only supported on map fields: field %q in type %s", fieldName, typeName)
+			}
+			// Maps can't be encoded as empty, since an empty map has no encoding of its own.
+			messagePtr := fi.IsMessage && (fi.IsSliceOfPtr || fi.IsPointer && !fi.IsRepeated)
+			if fi.IsNilError && !messagePtr && (!fi.IsMap || fi.IsIndirect) {
+				return nil, fmt.Errorf("nilerror option is only supported on message pointer, []*T message and map fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsNilEmpty && !messagePtr {
+				return nil, fmt.Errorf("nilempty option is only supported on message pointer and []*T message fields: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsNilError && fi.IsNilEmpty {
+				return nil, fmt.Errorf("nilerror and nilempty options can't be combined: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsIndirect && (fi.IsFunc || fi.IsIter || fi.IsParallel || fi.IsExtract || fi.IsNonNil || fi.IsEmitEmpty || fi.IsMerge) {
+				return nil, fmt.Errorf("pointers to slices and maps are not supported with the func, iter, parallel, extract, nonnil, emitempty and merge options: field %q in type %s", fieldName, typeName)
+			}
+			if fi.IsDecimal && (fi.IsRepeated || fi.IsExtract || fi.IsAlways || fi.IsWrapper) {
+				return nil, fmt.Errorf("decimals are only supported in singular fields, without the extract, always and wrapper options: field %q in type %s", fieldName, typeName)
+			}
+
+			info.Fields = append(info.Fields, fi)
+		}
+	}
+
+	// Sort fields by field number
+	sort.Slice(info.Fields, func(i, j int) bool {
+		return info.Fields[i].FieldNum < info.Fields[j].FieldNum
+	})
+
+	return info, nil
+}
+
+// directivePrefix starts the protogen directives in the doc comment of a type.
+const directivePrefix = "//protogen:"
+
+// applyDirectives applies the //protogen: directives in the doc comment of a type to info and
+// records the rest of the comment as info.Doc.
+//
+// Supported directives:
+//
+//	//protogen:reserved 3,4,10-15,OldName  - field numbers and names that must not be used
+//	//protogen:generate                    - generate the type with Config.Scan
+//	//protogen:options value-receiver hot=ID,Name  - options of the type, separated by spaces
+func applyDirectives(info *TypeInfo, doc *ast.CommentGroup) error {
+	if doc == nil {
+		return nil
+	}
+	info.Doc = doc.Text()
+	for _, c := range doc.List {
+		directive, ok := strings.CutPrefix(c.Text, directivePrefix)
+		if !ok {
+			continue
+		}
+		name, args, _ := strings.Cut(directive, " ")
+		switch name {
+		case "reserved":
+			if err := checkReserved(info, args); err != nil {
+				return err
+			}
+		case "generate":
+			if args != "" {
+				return fmt.Errorf("%sgenerate directive of type %s takes no arguments", directivePrefix, info.Name)
+			}
+		case "options":
+			if err := applyTypeOptions(info, args); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown directive %s%s in type %s", directivePrefix, name, info.Name)
+		}
+	}
+	return nil
+}
+
+// applyTypeOptions applies the space-separated options of a //protogen:options directive to info:
+//
+//	value-receiver  - the marshal methods of the type have value receivers, like Options.ValueReceivers
+//	hot=ID,Name     - fields checked before switching on the field number, hottest first, like Config.HotFields
+func applyTypeOptions(info *TypeInfo, args string) error {
+	for _, opt := range strings.Fields(args) {
+		name, value, _ := strings.Cut(opt, "=")
+		switch name {
+		case "value-receiver":
+			info.ValueReceiver = true
+		case "hot":
+			if value == "" {
+				return fmt.Errorf("hot option of type %s requires field names, like hot=ID,Name", info.Name)
+			}
+			hot := map[string][]string{info.Name: strings.Split(value, ",")}
+			if err := applyHotFields(map[string]*TypeInfo{info.Name: info}, hot); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown option %q in %soptions directive of type %s", opt, directivePrefix, info.Name)
+		}
+	}
+	return nil
+}
+
+// hasGenerateDirective reports whether doc has a //protogen:generate directive.
+func hasGenerateDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == directivePrefix+"generate" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReserved returns an error if a field of info uses a field number or name reserved by spec,
+// a comma-separated list of field numbers, inclusive lo-hi ranges and field names.
+func checkReserved(info *TypeInfo, spec string) error {
+	nums, names, err := parseReserved(info.Name, spec)
+	if err != nil {
+		return err
+	}
+	info.reserved = append(info.reserved, nums...)
+
+	isReserved := func(num int) bool {
+		for _, r := range nums {
+			if r.contains(num) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, f := range info.Fields {
+		if names[f.Name] {
+			return fmt.Errorf("field name %q in type %s is reserved", f.Name, info.Name)
+		}
+		if f.IsOneof {
+			for _, v := range f.OneofVariants {
+				if isReserved(v.FieldNum) {
+					return fmt.Errorf("oneof variant %q of field %q in type %s uses reserved field number %d", v.TypeName, f.Name, info.Name, v.FieldNum)
+				}
+			}
+		} else if isReserved(f.FieldNum) {
+			return fmt.Errorf("field %q in type %s uses reserved field number %d", f.Name, info.Name, f.FieldNum)
+		}
+	}
+	return nil
+}
+
+// reservedRange is an inclusive range of field numbers reserved by a //protogen:reserved directive.
+type reservedRange struct{ lo, hi int }
+
+func (r reservedRange) contains(num int) bool {
+	return num >= r.lo && num <= r.hi
+}
+
+// parseReserved parses the spec of a //protogen:reserved directive of the named type into the
+// reserved field number ranges and field names.
+func parseReserved(typeName, spec string) ([]reservedRange, map[string]bool, error) {
+	var nums []reservedRange
+	names := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, nil, fmt.Errorf("empty entry in %sreserved directive of type %s", directivePrefix, typeName)
+		}
+		if part[0] < '0' || part[0] > '9' {
+			if !token.IsIdentifier(part) {
+				return nil, nil, fmt.Errorf("invalid reserved field name %q in type %s", part, typeName)
+			}
+			names[part] = true
+			continue
+		}
+		loStr, hiStr, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(loStr)
+		hi := lo
+		if err == nil && isRange {
+			hi, err = strconv.Atoi(hiStr)
+		}
+		if err != nil || lo < 1 || hi < lo {
+			return nil, nil, fmt.Errorf("invalid reserved field number %q in type %s", part, typeName)
+		}
+		nums = append(nums, reservedRange{lo, hi})
+	}
+	return nums, names, nil
+}
+
+// typeReserved returns the field numbers and names reserved by the //protogen:reserved
+// directives in the doc comment of the named type.
+func typeReserved(typeName string, doc *ast.CommentGroup) ([]reservedRange, map[string]bool, error) {
+	var nums []reservedRange
+	names := make(map[string]bool)
+	if doc == nil {
+		return nil, names, nil
+	}
+	for _, c := range doc.List {
+		spec, ok := strings.CutPrefix(c.Text, directivePrefix+"reserved ")
+		if !ok {
+			continue
+		}
+		n, m, err := parseReserved(typeName, spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		nums = append(nums, n...)
+		maps.Copy(names, m)
+	}
+	return nums, names, nil
+}
+
+// fieldNames returns the names of the fields declared by field, the type name for embedded
+// fields, except blank ones.
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		return []string{getTypeName(field.Type)}
+	}
+	var names []string
+	for _, name := range field.Names {
+		if name.Name != "_" {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// getTypeName extracts the type name from an AST expression (for embedded fields)
+func getTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.StarExpr:
+		return getTypeName(t.X)
+	case *ast.IndexExpr:
+		return getTypeName(t.X)
+	case *ast.IndexListExpr:
+		return getTypeName(t.X)
+	default:
+		return ""
+	}
+}
+
+// inferProtoType infers the protobuf type from a Go AST type expression.
+func inferProtoType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return "string"
+		case "bool":
+			return "bool"
+		case "int32":
+			return "int32"
+		case "int64":
+			return "int64"
+		case "int":
+			return "int64"
+		case "uint32":
+			return "uint32"
+		case "uint64":
+			return "uint64"
+		case "uint":
+			return "uint64"
+		case "float32":
+			return "float"
+		case "float64":
+			return "double"
+		case "int8", "int16":
+			return "int32"
+		case "uint8", "uint16", "byte":
+			return "uint32"
+		case "any":
+			return "interface"
+		default:
+			return "message"
+		}
+	case *ast.InterfaceType:
+		return "interface"
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		return "message"
+	case *ast.StarExpr:
+		return inferProtoType(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			if ident, ok := t.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+				return "bytes"
+			}
+			return inferProtoType(t.Elt)
+		}
+		return inferProtoType(t.Elt)
+	case *ast.MapType:
+		return "map"
+	default:
+		return "bytes"
+	}
+}
+
+// inferInt32 changes the inferred protobuf types of the int and uint values of the fields of
+// info to int32 and uint32, for Options.Int32.
+func inferInt32(info *TypeInfo) {
+	for _, f := range info.Fields {
+		if !f.IsInferred {
+			continue
+		}
+		if f.IsMap {
+			if wire, ok := platformInts[f.MapKeyType]; ok {
+				f.MapKeyProto = wire[1]
+			}
+			if wire, ok := platformInts[f.MapValueType]; ok {
+				f.MapValueProto = wire[1]
+			}
+		} else if wire, ok := platformInts[f.BaseType]; ok && f.ProtoType == wire[0] {
+			f.ProtoType = wire[1]
+			f.ConvType = wire[1]
+		}
+	}
+}
+
+// isSliceOrMap returns true if expr is a slice other than []byte or a map, the types of repeated
+// and map fields.
+func isSliceOrMap(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.ArrayType:
+		elem, ok := t.Elt.(*ast.Ident)
+		return t.Len == nil && !(ok && (elem.Name == "byte" || elem.Name == "uint8"))
+	case *ast.MapType:
+		return true
+	}
+	return false
+}
+
+// isUUIDType returns true if expr is uuid.UUID, the UUID type of github.com/google/uuid, or
+// [16]byte, or a pointer to or a slice of them.
+func isUUIDType(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		return ok && pkg.Name == "uuid" && t.Sel.Name == "UUID"
+	case *ast.StarExpr:
+		return isUUIDType(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return isUUIDType(t.Elt)
+		}
+		n, ok := t.Len.(*ast.BasicLit)
+		elem, isIdent := t.Elt.(*ast.Ident)
+		return ok && n.Value == "16" && isIdent && (elem.Name == "byte" || elem.Name == "uint8")
+	}
+	return false
+}
+
+// bigNumberType returns "Int" or "Rat" if expr is big.Int or big.Rat of package math/big, or
+// a pointer to or a slice of them, or "".
+func bigNumberType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "big" && (t.Sel.Name == "Int" || t.Sel.Name == "Rat") {
+			return t.Sel.Name
+		}
+	case *ast.StarExpr:
+		return bigNumberType(t.X)
+	case *ast.ArrayType:
+		return bigNumberType(t.Elt)
+	}
+	return ""
+}
+
+// isDecimalType returns true if expr is decimal.Decimal, the type of
+// github.com/shopspring/decimal, or a pointer to or a slice of it.
+func isDecimalType(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		return ok && pkg.Name == "decimal" && t.Sel.Name == "Decimal"
+	case *ast.StarExpr:
+		return isDecimalType(t.X)
+	case *ast.ArrayType:
+		return t.Len == nil && isDecimalType(t.Elt)
+	}
+	return false
+}
+
+// sqlNullTypes maps the nullable types of package database/sql holding strings, bools and
+// numbers to the names of their value fields and the Go types of their values.
+var sqlNullTypes = map[string][2]string{
+	"NullString":  {"String", "string"},
+	"NullBool":    {"Bool", "bool"},
+	"NullByte":    {"Byte", "byte"},
+	"NullInt16":   {"Int16", "int16"},
+	"NullInt32":   {"Int32", "int32"},
+	"NullInt64":   {"Int64", "int64"},
+	"NullFloat64": {"Float64", "float64"},
+}
+
+// sqlNullValue returns the name of the value field and the type of the value of expr, a
+// nullable type of package database/sql like sql.NullInt64 or sql.Null[T], or a pointer to or
+// a slice of one, and true. The type is nil for sql.Null types of other values, like
+// sql.NullTime.
+func sqlNullValue(expr ast.Expr) (string, ast.Expr, bool) {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		if !ok || pkg.Name != "sql" || !strings.HasPrefix(t.Sel.Name, "Null") {
+			return "", nil, false
+		}
+		if value, ok := sqlNullTypes[t.Sel.Name]; ok {
+			return value[0], ast.NewIdent(value[1]), true
+		}
+		return "", nil, true
+	case *ast.IndexExpr:
+		if sel, ok := t.X.(*ast.SelectorExpr); ok && exprToString(sel) == "sql.Null" {
+			return "V", t.Index, true
+		}
+	case *ast.StarExpr:
+		return sqlNullValue(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return sqlNullValue(t.Elt)
+		}
+	}
+	return "", nil, false
+}
+
+func analyzeType(fi *FieldInfo, expr ast.Expr) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		fi.BaseType = t.Name
+		fi.ElemType = t.Name
+		fi.RawElemType = t.Name
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		fullType := exprToString(t)
+		fi.BaseType = fullType
+		fi.ElemType = fullType
+		fi.RawElemType = fullType
+	case *ast.StarExpr:
+		fi.IsPointer = true
+		fi.IsOptional = true
+		inner := exprToString(t.X)
+		fi.BaseType = inner
+		fi.ElemType = inner
+		fi.RawElemType = inner
+	case *ast.ArrayType:
+		if t.Len == nil {
+			// Special case: []byte is NOT a repeated field
+			if ident, ok := t.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+				fi.BaseType = "[]byte"
+				fi.ElemType = "byte"
+				fi.RawElemType = "byte"
+				return
+			}
+
+			fi.IsRepeated = true
+			if star, ok := t.Elt.(*ast.StarExpr); ok {
+				fi.IsSliceOfPtr = true
+				fi.ElemType = exprToString(star.X)
+				fi.RawElemType = "*" + fi.ElemType
+				fi.BaseType = fi.ElemType
+			} else {
+				fi.ElemType = exprToString(t.Elt)
+				fi.RawElemType = fi.ElemType
+				fi.BaseType = fi.ElemType
+			}
+		} else {
+			fullType := exprToString(t)
+			fi.BaseType = fullType
+			fi.ElemType = fullType
+			fi.RawElemType = fullType
+		}
+	}
+}
+
+func exprToString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return exprToString(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprToString(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + exprToString(t.Elt)
+		}
+		return fmt.Sprintf("[%s]%s", exprToString(t.Len), exprToString(t.Elt))
+	case *ast.BasicLit:
+		return t.Value
+	case *ast.MapType:
+		return fmt.Sprintf("map[%s]%s", exprToString(t.Key), exprToString(t.Value))
+	case *ast.IndexExpr:
+		return fmt.Sprintf("%s[%s]", exprToString(t.X), exprToString(t.Index))
+	case *ast.IndexListExpr:
+		args := make([]string, len(t.Indices))
+		for i, index := range t.Indices {
+			args[i] = exprToString(index)
+		}
+		return fmt.Sprintf("%s[%s]", exprToString(t.X), strings.Join(args, ", "))
+	default:
+		return fmt.Sprintf("%T", expr)
+	}
+}
@@ -0,0 +1,159 @@
+// Package protoadapter lets types generated by protogen be passed to APIs requiring
+// proto.Message, like protojson, prototext or grpc-gateway.
+//
+// Types generated with protogen -proto-adapter embed a descriptor of their messages and
+// get a ProtoAdapter method returning an *Adapter. The adapter holds a dynamicpb message
+// decoded from the generated message, which serves reflection:
+//
+//	data, err := protojson.Marshal(msg.ProtoAdapter())
+//
+// Changes made through reflection are written back to the generated message by Sync:
+//
+//	a := msg.ProtoAdapter()
+//	if err := protojson.Unmarshal(data, a); err != nil {
+//	    return err
+//	}
+//	if err := a.Sync(); err != nil {
+//	    return err
+//	}
+//
+// The adapter copies the message in both directions, so it suits edges of a program like
+// JSON gateways rather than hot paths.
+//
+// Types generated with protogen -descriptor-set register the embedded descriptor with
+// protoregistry.GlobalFiles, where server reflection finds it, and export it as a serialized
+// FileDescriptorSet for tools like grpcurl and buf curl.
+package protoadapter
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// Registers google/protobuf/wrappers.proto, imported by files with wrapper fields.
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Message is implemented by types generated by protogen.
+type Message interface {
+	MarshalProtobuf(dst []byte) []byte
+	UnmarshalProtobuf(src []byte) error
+}
+
+// File is a file descriptor embedded in generated code, built on first use.
+type File struct {
+	raw  string
+	once sync.Once
+	fd   protoreflect.FileDescriptor
+}
+
+// NewFile returns the file described by raw, a serialized FileDescriptorProto.
+func NewFile(raw string) *File {
+	return &File{raw: raw}
+}
+
+// Descriptor returns the descriptor of the file.
+//
+// It panics if the embedded descriptor is invalid, which generated code never embeds.
+func (f *File) Descriptor() protoreflect.FileDescriptor {
+	f.once.Do(func() {
+		var fdp descriptorpb.FileDescriptorProto
+		if err := proto.Unmarshal([]byte(f.raw), &fdp); err != nil {
+			panic(fmt.Errorf("BUG: cannot unmarshal embedded file descriptor: %w", err))
+		}
+		fd, err := protodesc.NewFile(&fdp, protoregistry.GlobalFiles)
+		if err != nil {
+			panic(fmt.Errorf("BUG: invalid embedded file descriptor %s: %w", fdp.GetName(), err))
+		}
+		f.fd = fd
+	})
+	return f.fd
+}
+
+// Message returns the descriptor of the top-level message with the given name.
+//
+// It panics if the file has no such message.
+func (f *File) Message(name string) protoreflect.MessageDescriptor {
+	md := f.Descriptor().Messages().ByName(protoreflect.Name(name))
+	if md == nil {
+		panic(fmt.Errorf("BUG: file %s has no message %s", f.Descriptor().Path(), name))
+	}
+	return md
+}
+
+// DescriptorSet returns a serialized FileDescriptorSet holding the file, preceded by the
+// files it imports, like google/protobuf/wrappers.proto.
+func (f *File) DescriptorSet() []byte {
+	var b []byte
+	imports := f.Descriptor().Imports()
+	for i := 0; i < imports.Len(); i++ {
+		raw, err := proto.Marshal(protodesc.ToFileDescriptorProto(imports.Get(i)))
+		if err != nil {
+			panic(fmt.Errorf("BUG: cannot marshal file descriptor %s: %w", imports.Get(i).Path(), err))
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, raw)
+	}
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, []byte(f.raw))
+}
+
+// Register registers the file with protoregistry.GlobalFiles. It fails if a file with the
+// same path or a message with the same full name is already registered.
+func (f *File) Register() error {
+	fd := f.Descriptor()
+	// RegisterFile panics on conflicts by default, so detect them first.
+	if _, err := protoregistry.GlobalFiles.FindFileByPath(fd.Path()); err == nil {
+		return fmt.Errorf("cannot register %s: a file with the same path is already registered", fd.Path())
+	}
+	for i := 0; i < fd.Messages().Len(); i++ {
+		name := fd.Messages().Get(i).FullName()
+		if _, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+			return fmt.Errorf("cannot register %s: %s is already registered", fd.Path(), name)
+		}
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		return fmt.Errorf("cannot register %s: %w", fd.Path(), err)
+	}
+	return nil
+}
+
+// Adapter implements proto.Message for a generated message.
+type Adapter struct {
+	m   Message
+	dyn *dynamicpb.Message
+}
+
+// New returns an adapter of m, whose message is described by desc.
+//
+// It panics if m does not encode a message of type desc, which generated code never does.
+func New(m Message, desc protoreflect.MessageDescriptor) *Adapter {
+	dyn := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(m.MarshalProtobuf(nil), dyn); err != nil {
+		panic(fmt.Errorf("BUG: cannot unmarshal %T into %s: %w", m, desc.FullName(), err))
+	}
+	return &Adapter{m: m, dyn: dyn}
+}
+
+// ProtoReflect returns the reflective view of the dynamic message holding a copy of the
+// adapted message. Implements proto.Message.
+func (a *Adapter) ProtoReflect() protoreflect.Message {
+	return a.dyn.ProtoReflect()
+}
+
+// Sync replaces the adapted message with the dynamic message, to apply changes made through
+// reflection, e.g. by protojson.Unmarshal.
+func (a *Adapter) Sync() error {
+	data, err := proto.Marshal(a.dyn)
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s: %w", a.dyn.Descriptor().FullName(), err)
+	}
+	return a.m.UnmarshalProtobuf(data)
+}
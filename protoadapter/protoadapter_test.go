@@ -0,0 +1,200 @@
+package protoadapter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// point is a message like the ones generated by protogen.
+type point struct {
+	X    int64
+	Name string
+}
+
+func (p *point) MarshalProtobuf(dst []byte) []byte {
+	var mp easyproto.MarshalerPool
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendInt64(1, p.X)
+	mm.AppendString(2, p.Name)
+	dst = m.Marshal(dst)
+	mp.Put(m)
+	return dst
+}
+
+func (p *point) UnmarshalProtobuf(src []byte) (err error) {
+	*p = point{}
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return err
+		}
+		switch fc.FieldNum {
+		case 1:
+			v, ok := fc.Int64()
+			if !ok {
+				return fmt.Errorf("cannot read x")
+			}
+			p.X = v
+		case 2:
+			v, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read name")
+			}
+			p.Name = v
+		}
+	}
+	return nil
+}
+
+func pointFile(t *testing.T) *File {
+	t.Helper()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test/point.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("point"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("x"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+				{Name: proto.String("name"), Number: proto.Int32(2), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+			},
+		}},
+	}
+	raw, err := proto.Marshal(fdp)
+	if err != nil {
+		t.Fatalf("cannot marshal file descriptor: %v", err)
+	}
+	return NewFile(string(raw))
+}
+
+func TestAdapterMarshalJSON(t *testing.T) {
+	f := pointFile(t)
+	p := &point{X: 42, Name: "origin"}
+	data, err := protojson.Marshal(New(p, f.Message("point")))
+	if err != nil {
+		t.Fatalf("protojson.Marshal failed: %v", err)
+	}
+	if want := `{"x":"42","name":"origin"}`; compactJSON(data) != want {
+		t.Errorf("protojson.Marshal = %s, want %s", data, want)
+	}
+}
+
+func TestAdapterSync(t *testing.T) {
+	f := pointFile(t)
+	p := &point{X: 1, Name: "stale"}
+	a := New(p, f.Message("point"))
+	if err := protojson.Unmarshal([]byte(`{"x":"7","name":"fresh"}`), a); err != nil {
+		t.Fatalf("protojson.Unmarshal failed: %v", err)
+	}
+	if p.X != 1 {
+		t.Fatalf("message changed before Sync: %+v", p)
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if *p != (point{X: 7, Name: "fresh"}) {
+		t.Errorf("Sync = %+v, want {X:7 Name:fresh}", *p)
+	}
+}
+
+func TestFileDescriptorSet(t *testing.T) {
+	f := pointFile(t)
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(f.DescriptorSet(), &set); err != nil {
+		t.Fatalf("cannot unmarshal descriptor set: %v", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		t.Fatalf("invalid descriptor set: %v", err)
+	}
+	if _, err := files.FindDescriptorByName("test.point"); err != nil {
+		t.Errorf("descriptor set does not describe test.point: %v", err)
+	}
+}
+
+func TestFileDescriptorSetImports(t *testing.T) {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/box.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("box"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("size"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".google.protobuf.Int64Value")},
+			},
+		}},
+	}
+	raw, err := proto.Marshal(fdp)
+	if err != nil {
+		t.Fatalf("cannot marshal file descriptor: %v", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(NewFile(string(raw)).DescriptorSet(), &set); err != nil {
+		t.Fatalf("cannot unmarshal descriptor set: %v", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		t.Fatalf("invalid descriptor set: %v", err)
+	}
+	if _, err := files.FindDescriptorByName("google.protobuf.Int64Value"); err != nil {
+		t.Errorf("descriptor set does not describe the imported google.protobuf.Int64Value: %v", err)
+	}
+}
+
+func TestFileRegister(t *testing.T) {
+	if err := pointFile(t).Register(); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := protoregistry.GlobalFiles.FindDescriptorByName("test.point"); err != nil {
+		t.Errorf("test.point is not registered: %v", err)
+	}
+	if err := pointFile(t).Register(); err == nil {
+		t.Error("Register accepted a file registered twice")
+	}
+}
+
+func TestFileMessageMissing(t *testing.T) {
+	f := pointFile(t)
+	defer func() {
+		if recover() == nil {
+			t.Error("Message did not panic for a missing message")
+		}
+	}()
+	f.Message("line")
+}
+
+func TestFileInvalid(t *testing.T) {
+	f := NewFile("\xff")
+	defer func() {
+		if recover() == nil {
+			t.Error("Descriptor did not panic for an invalid descriptor")
+		}
+	}()
+	f.Descriptor()
+}
+
+// compactJSON removes the spaces protojson randomly adds to its output.
+func compactJSON(data []byte) string {
+	var out []byte
+	inString := false
+	for i, c := range data {
+		switch {
+		case c == '"' && (i == 0 || data[i-1] != '\\'):
+			inString = !inString
+		case c == ' ' && !inString:
+			continue
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
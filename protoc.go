@@ -0,0 +1,136 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// convertProtocStruct returns a copy of the struct type of a protoc-gen-go message with the
+// protobuf tags of its fields converted to the protogen format. Fields without protobuf tags,
+// like the message state, are kept as is.
+func convertProtocStruct(typeName string, structType *ast.StructType) (*ast.StructType, []declError) {
+	converted := &ast.StructType{Struct: structType.Struct, Fields: &ast.FieldList{Opening: structType.Fields.Opening, Closing: structType.Fields.Closing}}
+	var errs []declError
+	for _, field := range structType.Fields.List {
+		if field.Tag == nil {
+			converted.Fields.List = append(converted.Fields.List, field)
+			continue
+		}
+		tag, err := convertProtocTag(field)
+		if err != nil {
+			name := getTypeName(field.Type)
+			if len(field.Names) > 0 {
+				name = field.Names[0].Name
+			}
+			errs = append(errs, declError{pos: field.Pos(), field: name, err: fmt.Errorf("field %q in type %s: %w", name, typeName, err)})
+			continue
+		}
+		f := *field
+		f.Tag = &ast.BasicLit{ValuePos: field.Tag.ValuePos, Kind: token.STRING, Value: "`" + tag + "`"}
+		converted.Fields.List = append(converted.Fields.List, &f)
+	}
+	return converted, errs
+}
+
+// convertProtocTag returns the struct tag of a field of a protoc-gen-go message in the
+// protogen format, e.g. `protobuf:"1,sint64"` for `protobuf:"zigzag64,1,opt,name=id,proto3"`.
+// Tags without a protobuf key are returned unchanged.
+func convertProtocTag(field *ast.Field) (string, error) {
+	raw, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return "", err
+	}
+	tag := reflect.StructTag(raw)
+	if _, ok := tag.Lookup("protobuf_oneof"); ok {
+		return "", fmt.Errorf("oneof fields of protoc-gen-go types are not supported")
+	}
+	spec, ok := tag.Lookup("protobuf")
+	if !ok {
+		return raw, nil
+	}
+	parts := strings.Split(spec, ",")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid protoc-gen-go tag %q", spec)
+	}
+	num := parts[1]
+
+	if mapType, ok := field.Type.(*ast.MapType); ok {
+		keyType, err := protocType(tag.Get("protobuf_key"), mapType.Key)
+		if err != nil {
+			return "", err
+		}
+		valueType, err := protocType(tag.Get("protobuf_val"), mapType.Value)
+		if err != nil {
+			return "", err
+		}
+		if valueType == "enum" {
+			return "", fmt.Errorf("maps with enum values are not supported")
+		}
+		return fmt.Sprintf(`protobuf:"%s,map,%s,%s"`, num, keyType, valueType), nil
+	}
+
+	elem := field.Type
+	if arr, ok := elem.(*ast.ArrayType); ok && exprToString(elem) != "[]byte" {
+		elem = arr.Elt
+	}
+	protoType, err := protocType(spec, elem)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`protobuf:"%s,%s"`, num, protoType), nil
+}
+
+// protocType returns the protobuf type of a value of Go type expr encoded as described by
+// the protoc-gen-go tag spec, which starts with the wire encoding.
+func protocType(spec string, expr ast.Expr) (string, error) {
+	encoding, _, _ := strings.Cut(spec, ",")
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	goType := exprToString(expr)
+	switch encoding {
+	case "varint":
+		switch goType {
+		case "bool", "int32", "int64", "uint32", "uint64":
+			return goType, nil
+		}
+		return "enum", nil
+	case "zigzag32":
+		return "sint32", nil
+	case "zigzag64":
+		return "sint64", nil
+	case "fixed32":
+		switch goType {
+		case "uint32":
+			return "fixed32", nil
+		case "int32":
+			return "sfixed32", nil
+		case "float32":
+			return "float", nil
+		}
+	case "fixed64":
+		switch goType {
+		case "uint64":
+			return "fixed64", nil
+		case "int64":
+			return "sfixed64", nil
+		case "float64":
+			return "double", nil
+		}
+	case "bytes":
+		switch goType {
+		case "string":
+			return "string", nil
+		case "[]byte":
+			return "bytes", nil
+		}
+		return "message", nil
+	case "group":
+		return "", fmt.Errorf("groups are not supported")
+	}
+	return "", fmt.Errorf("unsupported protoc-gen-go encoding %q for Go type %s", encoding, goType)
+}
@@ -0,0 +1,189 @@
+// Package protocompress compresses messages generated by protogen, with pluggable
+// compression formats.
+//
+// Compressors and decompressors work on whole blocks, like the snappy block format of
+// Prometheus remote write. Formats from other modules plug in with CompressorFunc and
+// DecompressorFunc:
+//
+//	snappyCompressor := protocompress.CompressorFunc(snappy.Encode)
+//	body, err := protocompress.Marshal(snappyCompressor, nil, &writeRequest)
+//	// POST body with Content-Encoding: snappy
+//
+// Gzip is built in:
+//
+//	body, err := protocompress.Marshal(protocompress.Gzip, nil, msg)
+//
+// The recordio package compresses records with the same interfaces.
+package protocompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Marshaler is implemented by types generated by protogen.
+type Marshaler interface {
+	MarshalProtobuf(dst []byte) []byte
+}
+
+// Unmarshaler is implemented by types generated by protogen.
+type Unmarshaler interface {
+	UnmarshalProtobuf(src []byte) error
+}
+
+// Compressor compresses blocks of data.
+type Compressor interface {
+	// Compress returns the compressed form of src, which may use the storage of dst.
+	Compress(dst, src []byte) ([]byte, error)
+}
+
+// Decompressor decompresses blocks of data written by a Compressor.
+type Decompressor interface {
+	// Decompress returns the decompressed form of src, which may use the storage of dst.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// LimitDecompressor is implemented by Decompressors that can stop decompressing at a size limit,
+// like GzipCodec.
+type LimitDecompressor interface {
+	Decompressor
+	// DecompressLimit is like Decompress, but fails with ErrTooLarge if the decompressed form
+	// of src is longer than maxSize bytes.
+	DecompressLimit(dst, src []byte, maxSize int) ([]byte, error)
+}
+
+// CompressorFunc adapts a function like snappy.Encode to a Compressor.
+type CompressorFunc func(dst, src []byte) []byte
+
+// Compress implements Compressor.
+func (f CompressorFunc) Compress(dst, src []byte) ([]byte, error) {
+	return f(dst, src), nil
+}
+
+// DecompressorFunc adapts a function like snappy.Decode to a Decompressor.
+type DecompressorFunc func(dst, src []byte) ([]byte, error)
+
+// Decompress implements Decompressor.
+func (f DecompressorFunc) Decompress(dst, src []byte) ([]byte, error) {
+	return f(dst, src)
+}
+
+// bufPool holds *[]byte buffers for the encodings of messages passed to Marshal.
+var bufPool sync.Pool
+
+// Marshal returns the compressed encoding of m, which may use the storage of dst.
+// The uncompressed encoding is written to a pooled buffer.
+func Marshal(c Compressor, dst []byte, m Marshaler) ([]byte, error) {
+	bp, _ := bufPool.Get().(*[]byte)
+	if bp == nil {
+		bp = new([]byte)
+	}
+	*bp = m.MarshalProtobuf((*bp)[:0])
+	dst, err := c.Compress(dst, *bp)
+	bufPool.Put(bp)
+	return dst, err
+}
+
+// Unmarshal decompresses src and decodes it into m. The decompressed message is not pooled,
+// since the decoded strings and bytes of m reference it unless generated with -copy-strings.
+func Unmarshal(d Decompressor, src []byte, m Unmarshaler) error {
+	data, err := d.Decompress(nil, src)
+	if err != nil {
+		return fmt.Errorf("cannot decompress message: %w", err)
+	}
+	return m.UnmarshalProtobuf(data)
+}
+
+// DefaultMaxSize is the default size limit of blocks decompressed by a GzipCodec.
+const DefaultMaxSize = 64 << 20
+
+// ErrTooLarge means a decompressed block is over the size limit of its GzipCodec.
+var ErrTooLarge = errors.New("decompressed block too large")
+
+// Gzip compresses blocks in the gzip format with the default compression level, and
+// decompresses blocks of up to DefaultMaxSize bytes.
+var Gzip = mustGzip(gzip.DefaultCompression)
+
+// GzipCodec compresses and decompresses blocks in the gzip format, reusing the
+// compression state across calls.
+type GzipCodec struct {
+	level   int
+	maxSize int
+	writers sync.Pool // *gzip.Writer
+	readers sync.Pool // *gzip.Reader
+}
+
+// NewGzip returns a GzipCodec compressing with the given level of compress/gzip and
+// decompressing blocks of up to DefaultMaxSize bytes.
+func NewGzip(level int) (*GzipCodec, error) {
+	return NewGzipLimit(level, DefaultMaxSize)
+}
+
+// NewGzipLimit is like NewGzip, but Decompress fails with ErrTooLarge for blocks over
+// maxSize bytes instead of DefaultMaxSize.
+func NewGzipLimit(level, maxSize int) (*GzipCodec, error) {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+	return &GzipCodec{level: level, maxSize: maxSize}, nil
+}
+
+func mustGzip(level int) *GzipCodec {
+	c, err := NewGzip(level)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Compress implements Compressor.
+func (c *GzipCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst[:0])
+	zw, _ := c.writers.Get().(*gzip.Writer)
+	if zw == nil {
+		zw, _ = gzip.NewWriterLevel(buf, c.level)
+	} else {
+		zw.Reset(buf)
+	}
+	if _, err := zw.Write(src); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	c.writers.Put(zw)
+	return buf.Bytes(), nil
+}
+
+// Decompress implements Decompressor.
+func (c *GzipCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return c.DecompressLimit(dst, src, c.maxSize)
+}
+
+// DecompressLimit implements LimitDecompressor, with maxSize instead of the limit of c.
+func (c *GzipCodec) DecompressLimit(dst, src []byte, maxSize int) ([]byte, error) {
+	zr, _ := c.readers.Get().(*gzip.Reader)
+	var err error
+	if zr == nil {
+		zr, err = gzip.NewReader(bytes.NewReader(src))
+	} else {
+		err = zr.Reset(bytes.NewReader(src))
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(dst[:0])
+	// Read one byte past the limit to tell a block of exactly maxSize bytes from a larger one
+	if _, err := buf.ReadFrom(io.LimitReader(zr, int64(maxSize)+1)); err != nil {
+		return nil, err
+	}
+	c.readers.Put(zr)
+	if buf.Len() > maxSize {
+		return nil, ErrTooLarge
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package protocompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+// text is a message holding its encoding, like a message with a single bytes field would.
+type text struct{ Data string }
+
+func (t *text) MarshalProtobuf(dst []byte) []byte {
+	return append(dst, t.Data...)
+}
+
+func (t *text) UnmarshalProtobuf(src []byte) error {
+	t.Data = string(src)
+	return nil
+}
+
+// reverse is a toy block format standing in for formats like snappy.
+func reverse(dst, src []byte) []byte {
+	dst = dst[:0]
+	for i := len(src) - 1; i >= 0; i-- {
+		dst = append(dst, src[i])
+	}
+	return dst
+}
+
+func TestMarshalFuncs(t *testing.T) {
+	data, err := Marshal(CompressorFunc(reverse), nil, &text{Data: "abc"})
+	if err != nil || string(data) != "cba" {
+		t.Fatalf("Marshal = %q, %v; want cba", data, err)
+	}
+	var m text
+	err = Unmarshal(DecompressorFunc(func(dst, src []byte) ([]byte, error) { return reverse(dst, src), nil }), data, &m)
+	if err != nil || m.Data != "abc" {
+		t.Errorf("Unmarshal = %q, %v; want abc", m.Data, err)
+	}
+
+	errCorrupt := errors.New("corrupt")
+	err = Unmarshal(DecompressorFunc(func(dst, src []byte) ([]byte, error) { return nil, errCorrupt }), data, &m)
+	if !errors.Is(err, errCorrupt) {
+		t.Errorf("expected the decompression error, got %v", err)
+	}
+}
+
+func TestGzip(t *testing.T) {
+	msg := &text{Data: string(bytes.Repeat([]byte("compressible "), 100))}
+	var buf []byte
+	for i := 0; i < 3; i++ { // reuse pooled writers and readers
+		data, err := Marshal(Gzip, buf, msg)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if len(data) >= len(msg.Data) {
+			t.Errorf("gzip did not compress: %d bytes", len(data))
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("not gzip data: %v", err)
+		}
+		if plain, err := io.ReadAll(zr); err != nil || string(plain) != msg.Data {
+			t.Fatalf("compress/gzip read %d bytes, %v", len(plain), err)
+		}
+		var m text
+		if err := Unmarshal(Gzip, data, &m); err != nil || m.Data != msg.Data {
+			t.Fatalf("Unmarshal = %d bytes, %v", len(m.Data), err)
+		}
+		buf = data
+	}
+
+	if err := Unmarshal(Gzip, []byte("not gzip"), &text{}); err == nil {
+		t.Error("Unmarshal accepted invalid gzip data")
+	}
+	if _, err := NewGzip(42); err == nil {
+		t.Error("NewGzip accepted an invalid level")
+	}
+}
+
+func TestGzipLimit(t *testing.T) {
+	c, err := NewGzipLimit(gzip.BestSpeed, 100)
+	if err != nil {
+		t.Fatalf("NewGzipLimit failed: %v", err)
+	}
+	for _, size := range []int{100, 101, 1 << 20} {
+		data, err := Marshal(c, nil, &text{Data: string(make([]byte, size))})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var m text
+		err = Unmarshal(c, data, &m)
+		if size <= 100 && (err != nil || len(m.Data) != size) {
+			t.Errorf("Unmarshal of %d bytes = %d bytes, %v", size, len(m.Data), err)
+		}
+		if size > 100 && !errors.Is(err, ErrTooLarge) {
+			t.Errorf("expected ErrTooLarge for %d bytes, got %v", size, err)
+		}
+		if _, err := Gzip.DecompressLimit(nil, data, size-1); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("expected ErrTooLarge from DecompressLimit below %d bytes, got %v", size, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package protoconv
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+)
+
+// AppendBigInt appends the sign and magnitude of x to dst: a byte 0 for positive numbers or 1
+// for negative ones, followed by the absolute value in big-endian order. Zero appends nothing.
+func AppendBigInt(dst []byte, x *big.Int) []byte {
+	if x.Sign() == 0 {
+		return dst
+	}
+	sign := byte(0)
+	if x.Sign() < 0 {
+		sign = 1
+	}
+	dst = append(dst, sign)
+	n := len(dst)
+	dst = append(dst, make([]byte, (x.BitLen()+7)/8)...)
+	x.FillBytes(dst[n:])
+	return dst
+}
+
+// SetBigInt sets z to the number encoded as b by AppendBigInt. Empty b is zero.
+func SetBigInt(z *big.Int, b []byte) error {
+	if len(b) == 0 {
+		z.SetInt64(0)
+		return nil
+	}
+	if b[0] > 1 {
+		return fmt.Errorf("big.Int with sign byte %d: %w", b[0], easyprotoerr.ErrInvalidValue)
+	}
+	z.SetBytes(b[1:])
+	if b[0] == 1 {
+		z.Neg(z)
+	}
+	return nil
+}
+
+// MarshalBigRat appends x to mm as a message of its numerator, field 1, and its denominator,
+// field 2, both encoded like AppendBigInt. The denominator 1 of integers is left out.
+func MarshalBigRat(mm *easyproto.MessageMarshaler, x *big.Rat) {
+	if x.Sign() != 0 {
+		mm.AppendBytes(1, AppendBigInt(nil, x.Num()))
+	}
+	if !x.IsInt() {
+		mm.AppendBytes(2, AppendBigInt(nil, x.Denom()))
+	}
+}
+
+// SetBigRat sets z to the number encoded as the message data by MarshalBigRat. A missing
+// denominator is 1; a zero or negative one is an error.
+func SetBigRat(z *big.Rat, data []byte) error {
+	var num, denom big.Int
+	denom.SetInt64(1)
+	var fc easyproto.FieldContext
+	for len(data) > 0 {
+		var err error
+		data, err = fc.NextField(data)
+		if err != nil {
+			return fmt.Errorf("big.Rat: %w", err)
+		}
+		var dst *big.Int
+		switch fc.FieldNum {
+		case 1:
+			dst = &num
+		case 2:
+			dst = &denom
+		default:
+			continue
+		}
+		b, ok := fc.Bytes()
+		if !ok {
+			return fmt.Errorf("big.Rat field %d: %w", fc.FieldNum, easyprotoerr.ErrWireTypeMismatch)
+		}
+		if err := SetBigInt(dst, b); err != nil {
+			return err
+		}
+	}
+	if denom.Sign() <= 0 {
+		return fmt.Errorf("big.Rat with denominator %s: %w", denom.String(), easyprotoerr.ErrInvalidValue)
+	}
+	z.SetFrac(&num, &denom)
+	return nil
+}
+
+// MarshalDecimal appends a decimal number, unscaled * 10^exponent, to mm as a message of the
+// unscaled value in two's complement big-endian bytes, field 1, and the exponent as an int32,
+// field 2, like the unscaled value and negated scale of BigDecimal messages. Zero values are
+// left out.
+func MarshalDecimal(mm *easyproto.MessageMarshaler, unscaled *big.Int, exponent int32) {
+	if unscaled.Sign() != 0 {
+		mm.AppendBytes(1, appendTwosComplement(nil, unscaled))
+	}
+	if exponent != 0 {
+		mm.AppendInt32(2, exponent)
+	}
+}
+
+// UnmarshalDecimal returns the unscaled value and the exponent of the decimal number encoded as
+// the message data by MarshalDecimal. Missing fields are zero.
+func UnmarshalDecimal(data []byte) (*big.Int, int32, error) {
+	unscaled := new(big.Int)
+	var exponent int32
+	var fc easyproto.FieldContext
+	for len(data) > 0 {
+		var err error
+		data, err = fc.NextField(data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("decimal: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			var b []byte
+			if b, ok = fc.Bytes(); ok {
+				setTwosComplement(unscaled, b)
+			}
+		case 2:
+			exponent, ok = fc.Int32()
+		}
+		if !ok {
+			return nil, 0, fmt.Errorf("decimal field %d: %w", fc.FieldNum, easyprotoerr.ErrWireTypeMismatch)
+		}
+	}
+	return unscaled, exponent, nil
+}
+
+// appendTwosComplement appends x to dst in the shortest two's complement big-endian form.
+// Zero appends nothing.
+func appendTwosComplement(dst []byte, x *big.Int) []byte {
+	if x.Sign() == 0 {
+		return dst
+	}
+	// n bytes hold the numbers from -2^(8n-1) to 2^(8n-1)-1.
+	var n int
+	if x.Sign() > 0 {
+		n = x.BitLen()/8 + 1
+	} else {
+		n = new(big.Int).Not(x).BitLen()/8 + 1
+	}
+	v := x
+	if x.Sign() < 0 {
+		v = new(big.Int).Lsh(big.NewInt(1), uint(8*n))
+		v.Add(v, x)
+	}
+	start := len(dst)
+	dst = append(dst, make([]byte, n)...)
+	v.FillBytes(dst[start:])
+	return dst
+}
+
+// setTwosComplement sets z to the number encoded as b in two's complement big-endian form.
+func setTwosComplement(z *big.Int, b []byte) {
+	z.SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		z.Sub(z, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+}
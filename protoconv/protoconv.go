@@ -0,0 +1,79 @@
+// Package protoconv converts Go types without a protobuf counterpart to and from the values
+// of protobuf fields, for code generated by protogen.
+//
+// UUIDs, of type github.com/google/uuid.UUID or [16]byte, are encoded as bytes fields of 16
+// bytes, or as strings in the canonical form like 6ba7b810-9dad-11d1-80b4-00c04fd430c8 with
+// the uuidstring option:
+//
+//	type User struct {
+//	    ID    uuid.UUID `protobuf:"1"`
+//	    OrgID uuid.UUID `protobuf:"2,uuidstring"`
+//	}
+//
+// Like the empty value of bytes and string fields, the zero UUID is not encoded, and an empty
+// value decodes to the zero UUID. Other values that aren't UUIDs fail to decode with an error
+// wrapping easyprotoerr.ErrInvalidValue.
+//
+// Numbers of package math/big are encoded as bytes fields with the bigint and bigrat options:
+// big.Int as its sign and magnitude, and big.Rat as a message of its numerator and denominator.
+// Decimals with the unscaled option are encoded as messages of their unscaled value and
+// exponent.
+package protoconv
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+)
+
+// UUIDFromBytes returns the UUID encoded as the bytes b, which must be 16 bytes long or empty.
+func UUIDFromBytes(b []byte) ([16]byte, error) {
+	var u [16]byte
+	if len(b) != 0 && len(b) != len(u) {
+		return u, fmt.Errorf("UUID of %d bytes: %w", len(b), easyprotoerr.ErrInvalidValue)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// UUIDFromString returns the UUID encoded as the string s, in the canonical form or as 32
+// hexadecimal digits without hyphens. Digits may be upper case. s may also be empty.
+func UUIDFromString(s string) ([16]byte, error) {
+	var u [16]byte
+	switch len(s) {
+	case 0:
+		return u, nil
+	case 32:
+		if _, err := hex.Decode(u[:], []byte(s)); err == nil {
+			return u, nil
+		}
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			break
+		}
+		digits := make([]byte, 0, 32)
+		digits = append(digits, s[:8]...)
+		digits = append(digits, s[9:13]...)
+		digits = append(digits, s[14:18]...)
+		digits = append(digits, s[19:23]...)
+		digits = append(digits, s[24:]...)
+		if _, err := hex.Decode(u[:], digits); err == nil {
+			return u, nil
+		}
+	}
+	return u, fmt.Errorf("invalid UUID %q: %w", s, easyprotoerr.ErrInvalidValue)
+}
+
+// AppendUUIDString appends the canonical form of u, in lower case, to dst.
+func AppendUUIDString(dst []byte, u [16]byte) []byte {
+	dst = hex.AppendEncode(dst, u[:4])
+	dst = append(dst, '-')
+	dst = hex.AppendEncode(dst, u[4:6])
+	dst = append(dst, '-')
+	dst = hex.AppendEncode(dst, u[6:8])
+	dst = append(dst, '-')
+	dst = hex.AppendEncode(dst, u[8:10])
+	dst = append(dst, '-')
+	return hex.AppendEncode(dst, u[10:])
+}
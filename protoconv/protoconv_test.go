@@ -0,0 +1,125 @@
+package protoconv
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/aryehlev/easyproto-gen/easyprotoerr"
+)
+
+var testUUID = [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+func TestUUIDFromBytes(t *testing.T) {
+	if u, err := UUIDFromBytes(testUUID[:]); err != nil || u != testUUID {
+		t.Fatalf("got %x, %v", u, err)
+	}
+	if u, err := UUIDFromBytes(nil); err != nil || u != [16]byte{} {
+		t.Fatalf("empty bytes: got %x, %v; want the zero UUID", u, err)
+	}
+	if _, err := UUIDFromBytes(testUUID[:15]); !errors.Is(err, easyprotoerr.ErrInvalidValue) {
+		t.Fatalf("15 bytes: got %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestUUIDFromString(t *testing.T) {
+	for _, s := range []string{
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"6BA7B810-9DAD-11D1-80B4-00C04FD430C8",
+		"6ba7b8109dad11d180b400c04fd430c8",
+	} {
+		if u, err := UUIDFromString(s); err != nil || u != testUUID {
+			t.Errorf("%q: got %x, %v", s, u, err)
+		}
+	}
+	if u, err := UUIDFromString(""); err != nil || u != [16]byte{} {
+		t.Errorf("empty string: got %x, %v; want the zero UUID", u, err)
+	}
+	for _, s := range []string{
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c",
+		"6ba7b810x9dad-11d1-80b4-00c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430cg",
+		"{6ba7b810-9dad-11d1-80b4-00c04fd430c8}",
+	} {
+		if _, err := UUIDFromString(s); !errors.Is(err, easyprotoerr.ErrInvalidValue) {
+			t.Errorf("%q: got %v, want ErrInvalidValue", s, err)
+		}
+	}
+}
+
+func TestAppendUUIDString(t *testing.T) {
+	got := AppendUUIDString([]byte("id="), testUUID)
+	if want := "id=6ba7b810-9dad-11d1-80b4-00c04fd430c8"; string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	for _, x := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(-256), huge} {
+		b := AppendBigInt(nil, x)
+		var got big.Int
+		if err := SetBigInt(&got, b); err != nil || got.Cmp(x) != 0 {
+			t.Errorf("%s: got %s, %v from %x", x, &got, err, b)
+		}
+	}
+	if b := AppendBigInt(nil, big.NewInt(-256)); string(b) != "\x01\x01\x00" {
+		t.Errorf("-256: got %x, want 010100", b)
+	}
+	if b := AppendBigInt(nil, big.NewInt(0)); len(b) != 0 {
+		t.Errorf("0: got %x, want no bytes", b)
+	}
+	if err := SetBigInt(new(big.Int), []byte{2, 1}); !errors.Is(err, easyprotoerr.ErrInvalidValue) {
+		t.Errorf("sign byte 2: got %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestBigRat(t *testing.T) {
+	marshal := func(x *big.Rat) []byte {
+		var m easyproto.Marshaler
+		MarshalBigRat(m.MessageMarshaler(), x)
+		return m.Marshal(nil)
+	}
+	for _, s := range []string{"0", "7", "-1/3", "123456789012345678901234567890/7"} {
+		x, _ := new(big.Rat).SetString(s)
+		var got big.Rat
+		if err := SetBigRat(&got, marshal(x)); err != nil || got.Cmp(x) != 0 {
+			t.Errorf("%s: got %s, %v", s, &got, err)
+		}
+	}
+	if b := marshal(new(big.Rat)); len(b) != 0 {
+		t.Errorf("0: got %x, want no bytes", b)
+	}
+
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendBytes(1, AppendBigInt(nil, big.NewInt(1)))
+	mm.AppendBytes(2, nil)
+	if err := SetBigRat(new(big.Rat), m.Marshal(nil)); !errors.Is(err, easyprotoerr.ErrInvalidValue) {
+		t.Errorf("zero denominator: got %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	for _, tc := range []struct {
+		unscaled *big.Int
+		exponent int32
+	}{
+		{big.NewInt(0), 0}, {big.NewInt(12345), -2}, {big.NewInt(-1), 3}, {big.NewInt(128), 0}, {big.NewInt(-129), -1}, {huge, -10},
+	} {
+		var m easyproto.Marshaler
+		MarshalDecimal(m.MessageMarshaler(), tc.unscaled, tc.exponent)
+		unscaled, exponent, err := UnmarshalDecimal(m.Marshal(nil))
+		if err != nil || unscaled.Cmp(tc.unscaled) != 0 || exponent != tc.exponent {
+			t.Errorf("%se%d: got %se%d, %v", tc.unscaled, tc.exponent, unscaled, exponent, err)
+		}
+	}
+	for x, want := range map[int64]string{127: "\x7f", 128: "\x00\x80", -1: "\xff", -128: "\x80", -129: "\xff\x7f"} {
+		if got := appendTwosComplement(nil, big.NewInt(x)); string(got) != want {
+			t.Errorf("%d: got %x, want %x", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package protohttp reads and writes messages generated by protogen in HTTP bodies with the
+// application/x-protobuf content type.
+//
+//	func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+//	    msg, err := protohttp.DecodeRequest[Message](r)
+//	    if err != nil {
+//	        http.Error(w, err.Error(), protohttp.StatusCode(err))
+//	        return
+//	    }
+//	    ack := s.send(msg)
+//	    if err := protohttp.EncodeResponse(w, ack); err != nil {
+//	        log.Printf("cannot write response: %v", err)
+//	    }
+//	}
+package protohttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type written by EncodeResponse.
+const ContentType = "application/x-protobuf"
+
+// DefaultMaxBytes is the size limit of request bodies read by DecodeRequest.
+const DefaultMaxBytes = 4 << 20
+
+// contentTypes are the media types accepted by DecodeRequest.
+var contentTypes = []string{ContentType, "application/protobuf", "application/vnd.google.protobuf"}
+
+// Unmarshaler is implemented by types generated by protogen.
+type Unmarshaler interface {
+	UnmarshalProtobuf(src []byte) error
+}
+
+// Marshaler is implemented by types generated by protogen.
+type Marshaler interface {
+	MarshalProtobuf(dst []byte) []byte
+}
+
+// Error is an error with the HTTP status code of the response it causes, or of the response
+// it was read from by Client.Call.
+type Error struct {
+	Status int
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status code to respond with to a request failing with err:
+// the status of an *Error, or 500 Internal Server Error.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// DecodeRequest decodes the body of r into a new T, reading at most DefaultMaxBytes.
+// Errors are *Error values with status 415 for other content types than protobuf, 413 for
+// too large bodies and 400 for malformed messages.
+func DecodeRequest[T any, PT interface {
+	*T
+	Unmarshaler
+}](r *http.Request) (*T, error) {
+	return DecodeRequestLimit[T, PT](r, DefaultMaxBytes)
+}
+
+// DecodeRequestLimit is like DecodeRequest, but reads at most maxBytes.
+func DecodeRequestLimit[T any, PT interface {
+	*T
+	Unmarshaler
+}](r *http.Request, maxBytes int64) (*T, error) {
+	if err := checkContentType(r.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+	data, err := readBody(r.Body, r.ContentLength, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	m := PT(new(T))
+	if err := m.UnmarshalProtobuf(data); err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Err: fmt.Errorf("cannot unmarshal request body: %w", err)}
+	}
+	return (*T)(m), nil
+}
+
+// readBody reads a body of contentLength bytes, or -1 if unknown, from r. It returns an
+// *Error if the body exceeds maxBytes.
+func readBody(r io.Reader, contentLength, maxBytes int64) ([]byte, error) {
+	if contentLength > maxBytes {
+		return nil, &Error{Status: http.StatusRequestEntityTooLarge, Err: fmt.Errorf("body of %d bytes exceeds the limit of %d bytes", contentLength, maxBytes)}
+	}
+	// The body is read into a buffer owned by the message rather than a pooled one, since
+	// decoded strings and bytes keep referencing it unless generated with -copy-strings.
+	size := contentLength
+	if size < 0 {
+		size = bytes.MinRead
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size+1))
+	n, err := buf.ReadFrom(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Err: fmt.Errorf("cannot read body: %w", err)}
+	}
+	if n > maxBytes {
+		return nil, &Error{Status: http.StatusRequestEntityTooLarge, Err: fmt.Errorf("body exceeds the limit of %d bytes", maxBytes)}
+	}
+	return buf.Bytes(), nil
+}
+
+// checkContentType returns an *Error if contentType is not a protobuf media type.
+func checkContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for _, t := range contentTypes {
+			if mediaType == t {
+				return nil
+			}
+		}
+	}
+	return &Error{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("unsupported content type %q; expected %s", contentType, ContentType)}
+}
+
+// HandlerFunc returns a handler decoding requests into a new Req, calling call with the context
+// of the request and encoding its response, or an empty message if the response is nil. Errors
+// of call are written as plain text with the status of an *Error, or 500 Internal Server Error.
+//
+// Handlers of service interfaces generated with protogen -service use HandlerFunc.
+func HandlerFunc[Req any, PReq interface {
+	*Req
+	Unmarshaler
+}, Resp Marshaler](call func(ctx context.Context, req *Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := DecodeRequest[Req, PReq](r)
+		if err != nil {
+			http.Error(w, err.Error(), StatusCode(err))
+			return
+		}
+		resp, err := call(r.Context(), req)
+		if err != nil {
+			http.Error(w, err.Error(), StatusCode(err))
+			return
+		}
+		EncodeResponse(w, resp)
+	}
+}
+
+// Client calls handlers returned by HandlerFunc.
+type Client struct {
+	BaseURL    string       // URL the paths passed to Call are relative to, e.g. "https://chat.example.com"
+	HTTPClient *http.Client // Client sending the requests; http.DefaultClient if nil
+	MaxBytes   int64        // Size limit of responses; DefaultMaxBytes if 0
+}
+
+// Call posts req to the path relative to c.BaseURL and decodes the response into resp.
+// Responses with a status other than 200 OK are returned as an *Error with the status and
+// the response body as message.
+func (c *Client) Call(ctx context.Context, path string, req Marshaler, resp Unmarshaler) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(req.MarshalProtobuf(nil)))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", ContentType)
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	res, err := hc.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	maxBytes := c.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	data, err := readBody(res.Body, res.ContentLength, maxBytes)
+	if err != nil {
+		return fmt.Errorf("cannot read response of %s: %w", path, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(data))
+		if msg == "" {
+			msg = http.StatusText(res.StatusCode)
+		}
+		return &Error{Status: res.StatusCode, Err: errors.New(msg)}
+	}
+	if err := checkContentType(res.Header.Get("Content-Type")); err != nil {
+		return fmt.Errorf("response of %s: %w", path, err)
+	}
+	if err := resp.UnmarshalProtobuf(data); err != nil {
+		return fmt.Errorf("cannot unmarshal response of %s: %w", path, err)
+	}
+	return nil
+}
+
+// bufPool holds *[]byte buffers for EncodeResponse.
+var bufPool sync.Pool
+
+// EncodeResponse writes m to w with the protobuf content type and status 200 OK,
+// encoding it into a pooled buffer. A nil m, or a nil pointer in m, is written as an empty
+// message.
+func EncodeResponse(w http.ResponseWriter, m Marshaler) error {
+	bp, _ := bufPool.Get().(*[]byte)
+	if bp == nil {
+		bp = new([]byte)
+	}
+	data := (*bp)[:0]
+	if !isNil(m) {
+		data = m.MarshalProtobuf(data)
+	}
+	h := w.Header()
+	h.Set("Content-Type", ContentType)
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	_, err := w.Write(data)
+	*bp = data
+	bufPool.Put(bp)
+	return err
+}
+
+// isNil returns true if m is nil or holds a nil pointer, whose MarshalProtobuf method panics.
+func isNil(m Marshaler) bool {
+	if m == nil {
+		return true
+	}
+	v := reflect.ValueOf(m)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
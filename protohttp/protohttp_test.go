@@ -0,0 +1,152 @@
+package protohttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// text is a message holding its encoding, like a message with a single bytes field would.
+type text struct{ Data string }
+
+func (t *text) MarshalProtobuf(dst []byte) []byte {
+	return append(dst, t.Data...)
+}
+
+func (t *text) UnmarshalProtobuf(src []byte) error {
+	if strings.Contains(string(src), "\x00") {
+		return errors.New("invalid byte")
+	}
+	t.Data = string(src)
+	return nil
+}
+
+func newRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
+
+func TestDecodeRequest(t *testing.T) {
+	for _, contentType := range []string{"application/x-protobuf", "application/protobuf; proto=test.Text", "application/vnd.google.protobuf"} {
+		m, err := DecodeRequest[text](newRequest(contentType, "hello"))
+		if err != nil {
+			t.Fatalf("DecodeRequest with %s failed: %v", contentType, err)
+		}
+		if m.Data != "hello" {
+			t.Errorf("DecodeRequest = %q, want hello", m.Data)
+		}
+	}
+}
+
+func TestDecodeRequestErrors(t *testing.T) {
+	unknownLength := newRequest(ContentType, "0123456789")
+	unknownLength.ContentLength = -1
+	for _, tt := range []struct {
+		name   string
+		r      *http.Request
+		status int
+	}{
+		{"json", newRequest("application/json", "{}"), http.StatusUnsupportedMediaType},
+		{"no content type", newRequest("", "hello"), http.StatusUnsupportedMediaType},
+		{"too large", newRequest(ContentType, "0123456789"), http.StatusRequestEntityTooLarge},
+		{"too large without length", unknownLength, http.StatusRequestEntityTooLarge},
+		{"malformed", newRequest(ContentType, "\x00"), http.StatusBadRequest},
+	} {
+		_, err := DecodeRequestLimit[text](tt.r, 8)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected *Error, got %v", tt.name, err)
+			continue
+		}
+		if StatusCode(err) != tt.status {
+			t.Errorf("%s: status = %d, want %d (%v)", tt.name, StatusCode(err), tt.status, err)
+		}
+	}
+	if StatusCode(errors.New("other")) != http.StatusInternalServerError {
+		t.Error("StatusCode of other errors is not 500")
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	for _, data := range []string{"first response", "second"} {
+		w := httptest.NewRecorder()
+		if err := EncodeResponse(w, &text{Data: data}); err != nil {
+			t.Fatalf("EncodeResponse failed: %v", err)
+		}
+		if w.Code != http.StatusOK || w.Body.String() != data {
+			t.Errorf("response = %d %q, want 200 %q", w.Code, w.Body.String(), data)
+		}
+		if got := w.Header().Get("Content-Type"); got != ContentType {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(data)) {
+			t.Errorf("Content-Length = %q, want %d", got, len(data))
+		}
+	}
+
+	for _, m := range []Marshaler{nil, (*text)(nil)} {
+		w := httptest.NewRecorder()
+		if err := EncodeResponse(w, m); err != nil {
+			t.Fatalf("EncodeResponse(%#v) failed: %v", m, err)
+		}
+		if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "0" {
+			t.Errorf("EncodeResponse(%#v) = %d %q, want an empty message", m, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerFuncAndClient(t *testing.T) {
+	echo := func(ctx context.Context, req *text) (*text, error) {
+		if req.Data == "fail" {
+			return nil, &Error{Status: http.StatusConflict, Err: errors.New("conflicting text")}
+		}
+		if req.Data == "panic" {
+			return nil, errors.New("internal")
+		}
+		if req.Data == "none" {
+			return nil, nil
+		}
+		return &text{Data: "echo " + req.Data}, nil
+	}
+	srv := httptest.NewServer(HandlerFunc(echo))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL}
+
+	var resp text
+	if err := c.Call(context.Background(), "/echo", &text{Data: "hi"}, &resp); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if resp.Data != "echo hi" {
+		t.Errorf("response = %q, want %q", resp.Data, "echo hi")
+	}
+
+	resp.Data = "stale"
+	if err := c.Call(context.Background(), "/echo", &text{Data: "none"}, &resp); err != nil || resp.Data != "" {
+		t.Errorf("Call with a nil response = %q, %v; want an empty message", resp.Data, err)
+	}
+
+	for _, tt := range []struct {
+		req    string
+		status int
+		msg    string
+	}{
+		{"fail", http.StatusConflict, "conflicting text"},
+		{"panic", http.StatusInternalServerError, "internal"},
+		{"\x00", http.StatusBadRequest, "cannot unmarshal request body: invalid byte"},
+	} {
+		err := c.Call(context.Background(), "/echo", &text{Data: tt.req}, &resp)
+		if StatusCode(err) != tt.status || err.Error() != tt.msg {
+			t.Errorf("Call(%q) = %v with status %d, want %q with status %d", tt.req, err, StatusCode(err), tt.msg, tt.status)
+		}
+	}
+
+	c.MaxBytes = 4
+	if err := c.Call(context.Background(), "/echo", &text{Data: "long"}, &resp); err == nil {
+		t.Error("Call accepted a response over MaxBytes")
+	}
+}
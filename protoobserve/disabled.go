@@ -0,0 +1,7 @@
+//go:build !protogen_observe
+
+package protoobserve
+
+// Enabled is true if the program is built with the protogen_observe build tag, which enables
+// the calls of Observe in generated methods.
+const Enabled = false
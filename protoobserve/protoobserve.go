@@ -0,0 +1,51 @@
+// Package protoobserve reports the marshal and unmarshal calls of types generated by protogen
+// to an observer, for serialization metrics per message type without wrapping every call.
+//
+// Types generated with protogen -observe call Observe from their MarshalProtobuf and
+// UnmarshalProtobuf methods when the program is built with the protogen_observe build tag:
+//
+//	protoobserve.SetObserver(func(typeName, op string, bytes int, d time.Duration) {
+//		sizes.WithLabelValues(typeName, op).Observe(float64(bytes))
+//		latencies.WithLabelValues(typeName, op).Observe(d.Seconds())
+//	})
+//
+//	go build -tags protogen_observe ./cmd/server
+//
+// Without the tag, Enabled is false and the calls are compiled out of the generated methods.
+package protoobserve
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Operations reported to observers.
+const (
+	OpMarshal   = "marshal"
+	OpUnmarshal = "unmarshal"
+)
+
+// Observer is called after every call of a generated method with the name of the type, like
+// "mypkg.Event", the operation, the size of the encoded message and the duration of the call.
+// It is called concurrently from the goroutines marshaling and unmarshaling messages.
+type Observer func(typeName, op string, bytes int, d time.Duration)
+
+var observer atomic.Pointer[Observer]
+
+// SetObserver sets the observer of the generated methods, replacing the previous one.
+// A nil observer stops the reports.
+func SetObserver(o Observer) {
+	if o == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&o)
+}
+
+// Observe reports an operation on a message of typeName of the given size, which started at
+// start, to the observer. It is called by generated methods.
+func Observe(typeName, op string, bytes int, start time.Time) {
+	if o := observer.Load(); o != nil {
+		(*o)(typeName, op, bytes, time.Since(start))
+	}
+}
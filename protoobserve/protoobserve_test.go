@@ -0,0 +1,42 @@
+package protoobserve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	// Without an observer, reports are dropped.
+	Observe("test.Event", OpMarshal, 1, time.Now())
+
+	type report struct {
+		typeName, op string
+		bytes        int
+	}
+	var got []report
+	SetObserver(func(typeName, op string, bytes int, d time.Duration) {
+		if d < 0 {
+			t.Errorf("negative duration %v", d)
+		}
+		got = append(got, report{typeName, op, bytes})
+	})
+	t.Cleanup(func() { SetObserver(nil) })
+
+	Observe("test.Event", OpMarshal, 12, time.Now())
+	Observe("test.Event", OpUnmarshal, 7, time.Now())
+	want := []report{{"test.Event", OpMarshal, 12}, {"test.Event", OpUnmarshal, 7}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d reports, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("report %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	SetObserver(nil)
+	Observe("test.Event", OpMarshal, 1, time.Now())
+	if len(got) != len(want) {
+		t.Error("observer called after SetObserver(nil)")
+	}
+}
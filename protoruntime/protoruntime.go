@@ -0,0 +1,94 @@
+// Package protoruntime holds the declarations shared by files generated with -runtime.
+//
+// Without -runtime, every generated file declares a marshaler pool, the ProtobufMarshaler
+// and ProtobufUnmarshaler interfaces and a few helpers, so a package with several go:generate
+// invocations must pass -noheader to all but one of them. Files generated with
+//
+//	//go:generate protogen -type=Event -runtime=github.com/aryehlev/easyproto-gen/protoruntime
+//
+// import this package instead, and any number of them can be generated into one package.
+// A package of your own module declaring the same identifiers, like a copy of this one,
+// can be passed to -runtime as well.
+package protoruntime
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// Pool is the marshaler pool of MarshalProtobuf methods.
+var Pool easyproto.MarshalerPool
+
+// Marshaler is the interface for types that can marshal to protobuf.
+// Implement this interface to use custom types as nested messages.
+type Marshaler interface {
+	MarshalProtobufTo(mm *easyproto.MessageMarshaler)
+}
+
+// Unmarshaler is the interface for types that can unmarshal from protobuf.
+// Implement this interface to use custom types as nested messages.
+type Unmarshaler interface {
+	UnmarshalProtobuf(src []byte) error
+}
+
+// Clone copies src to dst by marshaling and unmarshaling it.
+// It is used for custom types, which have no CloneProtobufInto method.
+func Clone(dst Unmarshaler, src Marshaler) {
+	m := Pool.Get()
+	src.MarshalProtobufTo(m.MessageMarshaler())
+	data := m.Marshal(nil)
+	Pool.Put(m)
+	if err := dst.UnmarshalProtobuf(data); err != nil {
+		panic(fmt.Errorf("BUG: cannot unmarshal %T marshaled by %T: %w", dst, src, err))
+	}
+}
+
+// CloneBytes copies src to dst by marshaling and unmarshaling it.
+// It is used for custom types marshaled into bytes, which have no MarshalProtobufTo method.
+func CloneBytes(dst Unmarshaler, src interface{ MarshalProtobuf(dst []byte) []byte }) {
+	if err := dst.UnmarshalProtobuf(src.MarshalProtobuf(nil)); err != nil {
+		panic(fmt.Errorf("BUG: cannot unmarshal %T marshaled by %T: %w", dst, src, err))
+	}
+}
+
+// MessageOf returns v, a pointer to a field of a generic type whose type is a type parameter,
+// as a message. It panics if the type argument is not a message type.
+func MessageOf(v any) interface {
+	Marshaler
+	Unmarshaler
+} {
+	m, ok := v.(interface {
+		Marshaler
+		Unmarshaler
+	})
+	if !ok {
+		panic(fmt.Errorf("%T does not implement Marshaler and Unmarshaler", v))
+	}
+	return m
+}
+
+// HashBufPool holds *[]byte buffers for HashProtobuf methods.
+var HashBufPool sync.Pool
+
+// RandomString returns a random printable ASCII string for FuzzFill methods.
+func RandomString(r *rand.Rand) string {
+	b := make([]byte, r.Intn(16))
+	for i := range b {
+		b[i] = byte(' ' + r.Intn(95))
+	}
+	return string(b)
+}
+
+// RandomBytes returns random bytes for FuzzFill methods, or nil for empty bytes.
+func RandomBytes(r *rand.Rand) []byte {
+	n := r.Intn(16)
+	if n == 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
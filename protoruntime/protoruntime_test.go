@@ -0,0 +1,84 @@
+package protoruntime
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+type point struct {
+	X int64
+}
+
+func (p *point) MarshalProtobufTo(mm *easyproto.MessageMarshaler) {
+	mm.AppendInt64(1, p.X)
+}
+
+func (p *point) UnmarshalProtobuf(src []byte) error {
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		if src, err = fc.NextField(src); err != nil {
+			return err
+		}
+		if fc.FieldNum == 1 {
+			v, ok := fc.Int64()
+			if !ok {
+				return errors.New("cannot read X")
+			}
+			p.X = v
+		}
+	}
+	return nil
+}
+
+func (p *point) MarshalProtobuf(dst []byte) []byte {
+	m := Pool.Get()
+	p.MarshalProtobufTo(m.MessageMarshaler())
+	dst = m.Marshal(dst)
+	Pool.Put(m)
+	return dst
+}
+
+func TestClone(t *testing.T) {
+	var dst point
+	Clone(&dst, &point{X: 42})
+	if dst.X != 42 {
+		t.Fatalf("got X = %d, want 42", dst.X)
+	}
+}
+
+func TestCloneBytes(t *testing.T) {
+	var dst point
+	CloneBytes(&dst, &point{X: 42})
+	if dst.X != 42 {
+		t.Fatalf("got X = %d, want 42", dst.X)
+	}
+}
+
+func TestMessageOf(t *testing.T) {
+	var dst point
+	if err := MessageOf(&dst).UnmarshalProtobuf([]byte{0x08, 0x2a}); err != nil || dst.X != 42 {
+		t.Fatalf("got X = %d, err = %v, want 42", dst.X, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MessageOf(*int) did not panic")
+		}
+	}()
+	MessageOf(new(int))
+}
+
+func TestRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for range 100 {
+		if s := RandomString(r); len(s) >= 16 {
+			t.Fatalf("RandomString returned %d bytes", len(s))
+		}
+		if b := RandomBytes(r); b != nil && (len(b) == 0 || len(b) >= 16) {
+			t.Fatalf("RandomBytes returned %d bytes", len(b))
+		}
+	}
+}
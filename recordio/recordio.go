@@ -0,0 +1,355 @@
+// Package recordio stores sequences of messages generated by protogen in files, one framed
+// record per message.
+//
+// Every record starts with a 4-byte magic number, a flags byte and the little-endian uint32
+// length of the message, optionally followed after the message by its CRC-32C checksum.
+// Messages can be compressed with a protocompress.Compressor:
+//
+//	w := recordio.NewWriter(f, true)
+//	w.Compressor = protocompress.Gzip
+//	for _, msg := range msgs {
+//	    if err := w.Write(msg); err != nil {
+//	        return err
+//	    }
+//	}
+//
+// A Scanner reads the records back. With SkipCorrupt, it skips damaged records and the
+// partial record left at the end of a file by an interrupted write, resynchronizing at the
+// next magic number:
+//
+//	s := recordio.NewScanner(f)
+//	s.SkipCorrupt = true
+//	s.Decompressor = protocompress.Gzip
+//	for s.Scan() {
+//	    var msg Message
+//	    if err := s.Decode(&msg); err != nil {
+//	        return err
+//	    }
+//	}
+//	if err := s.Err(); err != nil {
+//	    return err
+//	}
+//	log.Printf("skipped %d corrupt bytes", s.Skipped())
+package recordio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/aryehlev/easyproto-gen/protocompress"
+)
+
+// magic starts every record.
+var magic = [4]byte{0xd3, 'e', 'p', 'r'}
+
+const (
+	headerSize = len(magic) + 1 + 4
+	crcSize    = 4
+
+	// flagChecksum marks records followed by the CRC-32C of their message.
+	flagChecksum = 1 << 0
+	// flagCompressed marks records holding a message compressed by a Writer's Compressor.
+	flagCompressed = 1 << 1
+)
+
+// DefaultMaxRecordSize is the default size limit of records read by a Scanner.
+const DefaultMaxRecordSize = 64 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt means a record is damaged: it has no magic number, unknown flags, a length over
+// the size limit, a checksum mismatch or a message failing to decompress within the size limit.
+var ErrCorrupt = errors.New("corrupt record")
+
+// Marshaler is implemented by types generated by protogen.
+type Marshaler interface {
+	MarshalProtobuf(dst []byte) []byte
+}
+
+// Unmarshaler is implemented by types generated by protogen.
+type Unmarshaler interface {
+	UnmarshalProtobuf(src []byte) error
+}
+
+// Writer appends records to an io.Writer.
+type Writer struct {
+	// Compressor, if set, compresses the messages of the records. Scanners reading them need
+	// the matching Decompressor. It must be set before the first call to Write.
+	Compressor protocompress.Compressor
+
+	w        io.Writer
+	checksum bool
+	buf      []byte
+	msg      []byte // Encoding of the message passed to Write, with a Compressor
+	cbuf     []byte // Compressed message
+}
+
+// NewWriter returns a Writer appending records to w, followed by their CRC-32C if checksum is set.
+//
+// Every record is passed to w in a single Write call, so wrap w in a bufio.Writer to batch
+// small records.
+func NewWriter(w io.Writer, checksum bool) *Writer {
+	return &Writer{w: w, checksum: checksum}
+}
+
+// Write appends a record holding the encoding of m.
+func (w *Writer) Write(m Marshaler) error {
+	if w.Compressor != nil {
+		w.msg = m.MarshalProtobuf(w.msg[:0])
+		return w.WriteRecord(w.msg)
+	}
+	w.buf = w.appendHeader(w.buf[:0])
+	w.buf = m.MarshalProtobuf(w.buf)
+	return w.finish()
+}
+
+// WriteRecord appends a record holding data.
+func (w *Writer) WriteRecord(data []byte) error {
+	if w.Compressor != nil {
+		compressed, err := w.Compressor.Compress(w.cbuf, data)
+		if err != nil {
+			return fmt.Errorf("cannot compress record: %w", err)
+		}
+		w.cbuf, data = compressed, compressed
+	}
+	w.buf = w.appendHeader(w.buf[:0])
+	w.buf = append(w.buf, data...)
+	return w.finish()
+}
+
+func (w *Writer) appendHeader(dst []byte) []byte {
+	var flags byte
+	if w.checksum {
+		flags |= flagChecksum
+	}
+	if w.Compressor != nil {
+		flags |= flagCompressed
+	}
+	dst = append(dst, magic[:]...)
+	dst = append(dst, flags)
+	return append(dst, 0, 0, 0, 0) // length, set by finish
+}
+
+// finish sets the length of the record in w.buf, appends its checksum and writes it.
+func (w *Writer) finish() error {
+	data := w.buf[headerSize:]
+	if uint64(len(data)) > 1<<32-1 {
+		return fmt.Errorf("record of %d bytes is too large", len(data))
+	}
+	binary.LittleEndian.PutUint32(w.buf[headerSize-4:], uint32(len(data)))
+	if w.checksum {
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.Checksum(data, crcTable))
+	}
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// Scanner reads records written by a Writer.
+type Scanner struct {
+	// MaxRecordSize is the size limit of records, before and after decompression; larger
+	// lengths are treated as corruption. It defaults to DefaultMaxRecordSize and must be set
+	// before the first call to Scan.
+	MaxRecordSize int
+
+	// SkipCorrupt makes Scan skip corrupt records and a truncated last record instead of
+	// failing. It must be set before the first call to Scan.
+	SkipCorrupt bool
+
+	// Decompressor decompresses records written with a Compressor. It must be set before the
+	// first call to Scan.
+	Decompressor protocompress.Decompressor
+
+	r        io.Reader
+	mem      []byte // Backing array of buf
+	buf      []byte // Unconsumed input
+	readErr  error  // Error of the last read, io.EOF at the end of the input
+	offset   int64  // Offset of buf in the input
+	record   []byte
+	consumed int    // Size of the current record, consumed by the next call to Scan
+	dbuf     []byte // Decompressed message
+	skipped  int64
+	err      error
+}
+
+// NewScanner returns a Scanner reading records from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: r, MaxRecordSize: DefaultMaxRecordSize}
+}
+
+// Scan advances to the next record, which is then available from Record and Decode.
+// It returns false at the end of the input or on an error, which is then returned by Err.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.advance(s.consumed)
+	s.record, s.consumed = nil, 0
+	for {
+		n, size, compressed, err := s.next()
+		if err == nil && compressed {
+			if s.Decompressor == nil {
+				s.err = fmt.Errorf("compressed record at offset %d, but the Scanner has no Decompressor", s.offset)
+				return false
+			}
+			s.dbuf, err = s.decompress(s.buf[headerSize : headerSize+n])
+			if err != nil && s.SkipCorrupt {
+				s.skipped += int64(size)
+				s.advance(size)
+				continue
+			}
+			if err != nil {
+				s.err = fmt.Errorf("%w at offset %d: cannot decompress: %w", ErrCorrupt, s.offset, err)
+				return false
+			}
+			s.record, s.consumed = s.dbuf, size
+			return true
+		}
+		switch {
+		case err == nil:
+			s.record, s.consumed = s.buf[headerSize:headerSize+n], size
+			return true
+		case err == io.EOF && len(s.buf) == 0:
+			return false
+		case err == io.EOF && s.SkipCorrupt:
+			// The last record is truncated
+			s.skipped += int64(len(s.buf))
+			s.advance(len(s.buf))
+			return false
+		case err == io.EOF:
+			s.err = fmt.Errorf("truncated record at offset %d: %w", s.offset, io.ErrUnexpectedEOF)
+			return false
+		case err == ErrCorrupt && s.SkipCorrupt:
+			s.resync()
+		case err == ErrCorrupt:
+			s.err = fmt.Errorf("%w at offset %d", ErrCorrupt, s.offset)
+			return false
+		default:
+			s.err = err
+			return false
+		}
+	}
+}
+
+// decompress returns the decompressed form of src, failing with protocompress.ErrTooLarge if it
+// is longer than s.MaxRecordSize. Decompressors implementing protocompress.LimitDecompressor
+// stop at the limit instead of decompressing the whole record first.
+func (s *Scanner) decompress(src []byte) ([]byte, error) {
+	if d, ok := s.Decompressor.(protocompress.LimitDecompressor); ok {
+		return d.DecompressLimit(s.dbuf, src, s.MaxRecordSize)
+	}
+	dbuf, err := s.Decompressor.Decompress(s.dbuf, src)
+	if err == nil && len(dbuf) > s.MaxRecordSize {
+		return dbuf, fmt.Errorf("decompressed length %d over MaxRecordSize: %w", len(dbuf), protocompress.ErrTooLarge)
+	}
+	return dbuf, err
+}
+
+// next parses the record at the start of s.buf, reading more input as needed, and returns
+// the length of its message, its total size and whether the message is compressed. It returns
+// io.EOF if the input ends before the end of the record.
+func (s *Scanner) next() (n, size int, compressed bool, err error) {
+	if err := s.fill(headerSize); err != nil {
+		return 0, 0, false, err
+	}
+	if !bytes.Equal(s.buf[:len(magic)], magic[:]) {
+		return 0, 0, false, ErrCorrupt
+	}
+	flags := s.buf[len(magic)]
+	if flags&^(flagChecksum|flagCompressed) != 0 {
+		return 0, 0, false, ErrCorrupt
+	}
+	length := binary.LittleEndian.Uint32(s.buf[headerSize-4:])
+	if uint64(length) > uint64(s.MaxRecordSize) {
+		return 0, 0, false, ErrCorrupt
+	}
+	n = int(length)
+	size = headerSize + n
+	if flags&flagChecksum != 0 {
+		size += crcSize
+	}
+	if err := s.fill(size); err != nil {
+		return 0, 0, false, err
+	}
+	if flags&flagChecksum != 0 {
+		data := s.buf[headerSize : headerSize+n]
+		if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(s.buf[headerSize+n:]) {
+			return 0, 0, false, ErrCorrupt
+		}
+	}
+	return n, size, flags&flagCompressed != 0, nil
+}
+
+// resync skips the first byte of s.buf and everything up to the next magic number.
+func (s *Scanner) resync() {
+	s.skipped++
+	s.advance(1)
+	for {
+		if i := bytes.Index(s.buf, magic[:]); i >= 0 {
+			s.skipped += int64(i)
+			s.advance(i)
+			return
+		}
+		// Keep a possible prefix of the magic number
+		skip := max(len(s.buf)-(len(magic)-1), 0)
+		s.skipped += int64(skip)
+		s.advance(skip)
+		if s.fill(len(s.buf)+1) != nil {
+			return
+		}
+	}
+}
+
+// advance consumes n bytes of s.buf.
+func (s *Scanner) advance(n int) {
+	s.buf = s.buf[n:]
+	s.offset += int64(n)
+}
+
+// fill reads input until s.buf holds n bytes. It returns io.EOF if the input ends before.
+func (s *Scanner) fill(n int) error {
+	for len(s.buf) < n {
+		if s.readErr != nil {
+			return s.readErr
+		}
+		if cap(s.buf) < n {
+			// Move the unconsumed input to the start of mem, growing it if needed.
+			if cap(s.mem) < n {
+				s.mem = make([]byte, max(2*cap(s.mem), n, 4096))
+			}
+			s.buf = s.mem[:copy(s.mem[:cap(s.mem)], s.buf)]
+		}
+		m, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+m]
+		if err != nil {
+			s.readErr = err
+		}
+	}
+	return nil
+}
+
+// Record returns the message of the current record. It is valid until the next call to Scan.
+func (s *Scanner) Record() []byte {
+	return s.record
+}
+
+// Decode decodes the message of the current record into m.
+//
+// The decoded strings and bytes of m reference the record unless generated with -copy-strings,
+// so they are only valid until the next call to Scan.
+func (s *Scanner) Decode(m Unmarshaler) error {
+	return m.UnmarshalProtobuf(s.record)
+}
+
+// Err returns the error that stopped Scan, or nil at the end of the input.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Skipped returns the number of bytes skipped because of corruption with SkipCorrupt.
+func (s *Scanner) Skipped() int64 {
+	return s.skipped
+}
@@ -0,0 +1,179 @@
+package recordio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/aryehlev/easyproto-gen/protocompress"
+)
+
+// text is a message holding its encoding, like a message with a single bytes field would.
+type text struct{ Data string }
+
+func (t *text) MarshalProtobuf(dst []byte) []byte {
+	return append(dst, t.Data...)
+}
+
+func (t *text) UnmarshalProtobuf(src []byte) error {
+	t.Data = string(src)
+	return nil
+}
+
+func writeRecords(t *testing.T, checksum bool, records ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, checksum)
+	for _, r := range records {
+		if err := w.Write(&text{Data: r}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// scanAll returns the records of data, read one byte at a time to exercise buffering.
+func scanAll(data []byte, skipCorrupt bool) ([]string, *Scanner) {
+	s := NewScanner(iotest.OneByteReader(bytes.NewReader(data)))
+	s.SkipCorrupt = skipCorrupt
+	var records []string
+	for s.Scan() {
+		var m text
+		if err := s.Decode(&m); err != nil {
+			panic(err)
+		}
+		records = append(records, m.Data)
+	}
+	return records, s
+}
+
+func TestRoundTrip(t *testing.T) {
+	large := string(bytes.Repeat([]byte("x"), 10000))
+	for _, checksum := range []bool{false, true} {
+		data := writeRecords(t, checksum, "first", "", large, "last")
+		records, s := scanAll(data, false)
+		if s.Err() != nil {
+			t.Fatalf("checksum=%t: unexpected error: %v", checksum, s.Err())
+		}
+		if len(records) != 4 || records[0] != "first" || records[1] != "" || records[2] != large || records[3] != "last" {
+			t.Errorf("checksum=%t: unexpected records %.20q", checksum, records)
+		}
+	}
+}
+
+func TestWriteRecord(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, false).WriteRecord([]byte("raw")); err != nil {
+		t.Fatalf("WriteRecord failed: %v", err)
+	}
+	want := "\xd3epr\x00\x03\x00\x00\x00raw"
+	if buf.String() != want {
+		t.Errorf("record = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	data := writeRecords(t, true, "first", "second", "third")
+	corrupt := bytes.Clone(data)
+	corrupt[len(writeRecords(t, true, "first"))+headerSize] ^= 1 // flip a bit of "second"
+
+	if _, s := scanAll(corrupt, false); !errors.Is(s.Err(), ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", s.Err())
+	}
+	records, s := scanAll(corrupt, true)
+	if s.Err() != nil || fmt.Sprint(records) != "[first third]" {
+		t.Errorf("SkipCorrupt: records %q, error %v", records, s.Err())
+	}
+	if want := int64(headerSize + len("second") + crcSize); s.Skipped() != want {
+		t.Errorf("Skipped = %d, want %d", s.Skipped(), want)
+	}
+
+	// Garbage between records and a truncated last record
+	garbage := append([]byte("garbage\xd3e"), data...)
+	garbage = append(garbage, writeRecords(t, true, "partial")[:8]...)
+	records, s = scanAll(garbage, true)
+	if s.Err() != nil || fmt.Sprint(records) != "[first second third]" {
+		t.Errorf("SkipCorrupt with garbage: records %q, error %v", records, s.Err())
+	}
+	if want := int64(len("garbage\xd3e") + 8); s.Skipped() != want {
+		t.Errorf("Skipped = %d, want %d", s.Skipped(), want)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	data := writeRecords(t, false, "first", "second")
+	records, s := scanAll(data[:len(data)-1], false)
+	if !errors.Is(s.Err(), io.ErrUnexpectedEOF) || fmt.Sprint(records) != "[first]" {
+		t.Errorf("records %q, error %v; want [first] and io.ErrUnexpectedEOF", records, s.Err())
+	}
+}
+
+func TestMaxRecordSize(t *testing.T) {
+	s := NewScanner(bytes.NewReader(writeRecords(t, false, "too long")))
+	s.MaxRecordSize = 4
+	if s.Scan() || !errors.Is(s.Err(), ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt for a record over MaxRecordSize, got %v", s.Err())
+	}
+
+	// Compressed records are limited after decompression too
+	var buf bytes.Buffer
+	w := NewWriter(&buf, false)
+	w.Compressor = protocompress.Gzip
+	if err := w.Write(&text{Data: strings.Repeat("a", 1000)}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, d := range []protocompress.Decompressor{
+		protocompress.Gzip, // stops decompressing at MaxRecordSize
+		protocompress.DecompressorFunc(protocompress.Gzip.Decompress),
+	} {
+		s = NewScanner(bytes.NewReader(buf.Bytes()))
+		s.Decompressor = d
+		s.MaxRecordSize = 100
+		if s.Scan() || !errors.Is(s.Err(), ErrCorrupt) || !errors.Is(s.Err(), protocompress.ErrTooLarge) {
+			t.Errorf("expected ErrCorrupt and ErrTooLarge for a record decompressing over MaxRecordSize, got %v", s.Err())
+		}
+	}
+}
+
+func TestReadError(t *testing.T) {
+	errRead := errors.New("read failed")
+	s := NewScanner(io.MultiReader(bytes.NewReader(writeRecords(t, false, "first")), iotest.ErrReader(errRead)))
+	s.SkipCorrupt = true
+	if !s.Scan() || string(s.Record()) != "first" {
+		t.Fatalf("expected the first record, got error %v", s.Err())
+	}
+	if s.Scan() || !errors.Is(s.Err(), errRead) {
+		t.Errorf("expected the read error, got %v", s.Err())
+	}
+}
+
+func TestCompressed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, true)
+	w.Compressor = protocompress.Gzip
+	for _, r := range []string{"first", "second"} {
+		if err := w.Write(&text{Data: r}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.WriteRecord([]byte("third")); err != nil {
+		t.Fatalf("WriteRecord failed: %v", err)
+	}
+
+	if _, s := scanAll(buf.Bytes(), false); s.Err() == nil || !strings.Contains(s.Err().Error(), "no Decompressor") {
+		t.Errorf("expected an error without Decompressor, got %v", s.Err())
+	}
+	s := NewScanner(bytes.NewReader(buf.Bytes()))
+	s.Decompressor = protocompress.Gzip
+	var records []string
+	for s.Scan() {
+		records = append(records, string(s.Record()))
+	}
+	if s.Err() != nil || fmt.Sprint(records) != "[first second third]" {
+		t.Errorf("records %q, error %v", records, s.Err())
+	}
+}
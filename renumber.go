@@ -0,0 +1,239 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renumbering describes the field number changes made by Renumber.
+type Renumbering struct {
+	// Compact numbers the fields 1, 2, ... in the order of their field numbers, skipping
+	// reserved numbers, the numbers of oneof variants, which keep theirs, and those of Moves.
+	Compact bool
+	// Moves are new field numbers by field name.
+	Moves map[string]int
+	// Force rewrites the tags even if the changes are wire-incompatible, instead of returning
+	// a *CompatError.
+	Force bool
+}
+
+// FieldMove is a field number change made by Renumber.
+type FieldMove struct {
+	Field    string
+	From, To int
+}
+
+// Renumber changes the field numbers of the struct type typeName in the package in dir as
+// described by r. It returns the rewritten source file and the changes, in field order,
+// without writing the file; both are empty if no number changes.
+//
+// The changes are checked with the rules of Compat against the schema recorded in
+// protogen.lock in dir if it has the type, and against the current fields otherwise.
+// In addition, the numbers of the fields of that schema must not change, since old data
+// for them would be dropped or decoded into another field, and must not be taken over by
+// another field of the same type. Only fields added since the lock file was written can
+// be renumbered, and none without a lock file. Incompatible changes are returned as a
+// *CompatError unless r.Force is set.
+func Renumber(dir, typeName string, r Renumbering) ([]File, []FieldMove, error) {
+	fset := token.NewFileSet()
+	_, files, err := parsePackage(fset, dir, nil, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file *ast.File
+	var structType *ast.StructType
+	var doc *ast.CommentGroup
+	for _, f := range files {
+		forEachStruct([]*ast.File{f}, func(name string, st *ast.StructType, d *ast.CommentGroup) error {
+			if name == typeName {
+				file, structType, doc = f, st, d
+			}
+			return nil
+		})
+	}
+	if structType == nil {
+		return nil, nil, &Error{Type: typeName, Err: fmt.Errorf("type %s not found", typeName)}
+	}
+	info, err := parseStruct(typeName, structType)
+	if err == nil {
+		err = applyDirectives(info, doc)
+	}
+	if err != nil {
+		return nil, nil, &Error{Pos: fset.Position(structType.Pos()), Type: typeName, Err: err}
+	}
+	reserved, _, err := typeReserved(typeName, doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	nums, err := renumberFields(info, reserved, r)
+	if err != nil {
+		return nil, nil, &Error{Pos: fset.Position(structType.Pos()), Type: typeName, Err: err}
+	}
+
+	var moves []FieldMove
+	renumbered := &TypeInfo{Name: typeName, reserved: reserved}
+	for _, f := range info.Fields {
+		if to, ok := nums[f.Name]; ok && to != f.FieldNum {
+			moves = append(moves, FieldMove{Field: f.Name, From: f.FieldNum, To: to})
+			moved := *f
+			moved.FieldNum = to
+			f = &moved
+		}
+		renumbered.Fields = append(renumbered.Fields, f)
+	}
+	if len(moves) == 0 {
+		return nil, nil, nil
+	}
+	sort.Slice(renumbered.Fields, func(i, j int) bool {
+		return renumbered.Fields[i].FieldNum < renumbered.Fields[j].FieldNum
+	})
+
+	lockPath := filepath.Join(dir, lockFileName)
+	locked, err := readLock(lockPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	baseline, baselinePath := schema{typeName: locked[typeName]}, lockPath
+	if locked[typeName] == nil {
+		baseline, baselinePath = buildSchema(map[string]*TypeInfo{typeName: info}), dir
+	}
+	current := buildSchema(map[string]*TypeInfo{typeName: renumbered})
+	issues := append(checkCompat(baseline, current, reservedNumbers(map[string]*TypeInfo{typeName: renumbered})), takenOverNumbers(baseline, current)...)
+	issues = append(issues, changedNumbers(typeName, baseline[typeName], moves)...)
+	if len(issues) > 0 && !r.Force {
+		sort.Strings(issues)
+		return nil, nil, &CompatError{Path: baselinePath, Issues: issues}
+	}
+
+	path := fset.File(file.Pos()).Name()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	code, err := format.Source(renumberTags(fset, src, structType, moves))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to format %s: %w", path, err)
+	}
+	return []File{{Path: path, Content: code}}, moves, nil
+}
+
+// renumberFields returns the new field numbers of the fields of info other than oneofs, by name.
+func renumberFields(info *TypeInfo, reserved []reservedRange, r Renumbering) (map[string]int, error) {
+	isReserved := func(num int) bool {
+		return num >= 19000 && num <= 19999 || slices.ContainsFunc(reserved, func(r reservedRange) bool { return r.contains(num) })
+	}
+	used := make(map[int]string)
+	nums := make(map[string]int)
+	for _, f := range info.Fields {
+		if f.IsOneof {
+			for _, v := range f.OneofVariants {
+				used[v.FieldNum] = f.Name
+			}
+			continue
+		}
+		nums[f.Name] = f.FieldNum
+	}
+
+	for name, to := range r.Moves {
+		switch _, ok := nums[name]; {
+		case !ok && slices.ContainsFunc(info.Fields, func(f *FieldInfo) bool { return f.Name == name }):
+			return nil, fmt.Errorf("field %q in type %s is a oneof; renumber its variants by hand", name, info.Name)
+		case !ok:
+			return nil, fmt.Errorf("field %q not found in type %s", name, info.Name)
+		case to < 1 || to > 536870911:
+			return nil, fmt.Errorf("invalid field number %d for field %q: must be 1-536870911", to, name)
+		case isReserved(to):
+			return nil, fmt.Errorf("field number %d for field %q is reserved", to, name)
+		}
+		nums[name] = to
+	}
+	if r.Compact {
+		targets := slices.Collect(maps.Values(r.Moves))
+		next := 1
+		for _, f := range info.Fields {
+			if _, moved := r.Moves[f.Name]; moved || f.IsOneof {
+				continue
+			}
+			for used[next] != "" || isReserved(next) || slices.Contains(targets, next) {
+				next++
+			}
+			nums[f.Name] = next
+			next++
+		}
+	}
+
+	names := make([]string, 0, len(nums))
+	for name := range nums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if other, ok := used[nums[name]]; ok {
+			return nil, fmt.Errorf("fields %q and %q in type %s would both have field number %d", other, name, info.Name, nums[name])
+		}
+		used[nums[name]] = name
+	}
+	return nums, nil
+}
+
+// takenOverNumbers returns the field numbers of the old schema used by a different field of the
+// same type in the new one, which checkCompat accepts as a renamed field.
+func takenOverNumbers(oldSchema, newSchema schema) []string {
+	var issues []string
+	for typeName, oldFields := range oldSchema {
+		for _, of := range oldFields {
+			for _, nf := range newSchema[typeName] {
+				if !of.Removed && nf.Num == of.Num && nf.Name != of.Name && nf.Type == of.Type {
+					issues = append(issues, fmt.Sprintf("%s.%s (%d): field number of %s taken over", typeName, nf.Name, nf.Num, of.Name))
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// changedNumbers returns the moves changing the number of a field of the old schema, whose data
+// would no longer be decoded into it.
+func changedNumbers(typeName string, oldFields []schemaField, moves []FieldMove) []string {
+	var issues []string
+	for _, m := range moves {
+		if slices.ContainsFunc(oldFields, func(f schemaField) bool { return !f.Removed && f.Name == m.Field && f.Num == m.From }) {
+			issues = append(issues, fmt.Sprintf("%s.%s (%d): field number changed to %d", typeName, m.Field, m.From, m.To))
+		}
+	}
+	return issues
+}
+
+// renumberTags returns src with the field numbers of the protobuf tags of the fields of
+// structType changed by moves.
+func renumberTags(fset *token.FileSet, src []byte, structType *ast.StructType, moves []FieldMove) []byte {
+	out := slices.Clone(src)
+	// Rewrite the tags from the end, so that the offsets of the others stay valid.
+	fields := structType.Fields.List
+	for i := len(fields) - 1; i >= 0; i-- {
+		field := fields[i]
+		name := getTypeName(field.Type)
+		if len(field.Names) > 0 {
+			name = field.Names[0].Name
+		}
+		j := slices.IndexFunc(moves, func(m FieldMove) bool { return m.Field == name })
+		if j < 0 {
+			continue
+		}
+		// The field number is the first element of the tag value.
+		start := strings.Index(field.Tag.Value, `protobuf:"`) + len(`protobuf:"`)
+		from := strconv.Itoa(moves[j].From)
+		offset := fset.Position(field.Tag.Pos()).Offset + start
+		out = slices.Replace(out, offset, offset+len(from), []byte(strconv.Itoa(moves[j].To))...)
+	}
+	return out
+}
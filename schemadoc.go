@@ -0,0 +1,94 @@
+package easyprotogen
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// generateDoc writes a Markdown document of the fields of the types of pkg, with their numbers,
+// .proto types, wire types and doc comments.
+func generateDoc(buf *bytes.Buffer, pkg *Package) error {
+	// typeLink returns typeName as code, linking to its section if the document has one.
+	typeLink := func(typeName string) string {
+		typeName = strings.TrimPrefix(typeName, "*")
+		if slices.Contains(pkg.Types, typeName) {
+			return "[`" + typeName + "`](#" + strings.ToLower(typeName) + ")"
+		}
+		return "`" + typeName + "`"
+	}
+	tmpl, err := template.New("doc").Funcs(template.FuncMap{
+		"snakeCase": snakeCase,
+		"docText":   strings.TrimSpace,
+		"docCell":   docCell,
+		"docFieldType": func(f *FieldInfo) string {
+			var label string
+			if f.IsRepeated && !f.IsMap {
+				label = "repeated "
+			} else if f.IsOptional && !f.IsMessage && !f.IsWrapper {
+				label = "optional "
+			}
+			if f.IsMessage && !f.IsCustom && !f.IsMap {
+				if label != "" {
+					return "`" + strings.TrimSpace(label) + "` " + typeLink(f.ElemType)
+				}
+				return typeLink(f.ElemType)
+			}
+			return "`" + label + protoFieldType(f) + "`"
+		},
+		"docFieldNote": func(f *FieldInfo) string {
+			var notes []string
+			switch {
+			case f.IsEnum:
+				notes = append(notes, "Enum `"+strings.TrimLeft(f.GoType, "*[]")+"`.")
+			case f.IsCustom && !f.IsTypeParam, f.IsMap && f.MapValueCustom:
+				notes = append(notes, "Message encoded by a custom type.")
+			}
+			if f.IsDeprecated {
+				notes = append(notes, "**Deprecated.**")
+			}
+			return strings.Join(notes, " ")
+		},
+		"docWireType": func(f *FieldInfo) string {
+			switch {
+			case f.IsMessage || f.IsCustom || f.IsMap || f.IsWrapper || isLengthDelimited(f.ProtoType):
+				return wireTypeName(protowire.BytesType)
+			case f.IsRepeated && !f.IsUnpacked:
+				return wireTypeName(protowire.BytesType) + " (packed " + wireTypeName(protowire.Type(wireTypeOf(f.ProtoType))) + ")"
+			}
+			return wireTypeName(protowire.Type(wireTypeOf(f.ProtoType)))
+		},
+		"docVariantType": func(v OneofVariant) string {
+			if v.ProtoType != "" {
+				return "`" + v.ProtoType + "`"
+			}
+			return typeLink(v.TypeName)
+		},
+		"docVariantWireType": func(v OneofVariant) string {
+			if v.ProtoType == "" || isLengthDelimited(v.ProtoType) {
+				return wireTypeName(protowire.BytesType)
+			}
+			return wireTypeName(protowire.Type(wireTypeOf(v.ProtoType)))
+		},
+	}).Parse(docTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse doc template: %w", err)
+	}
+	return tmpl.Execute(buf, pkg)
+}
+
+// docCell returns the notes about a field followed by its doc comment as the text of a
+// Markdown table cell, on a single line.
+func docCell(note, doc string) string {
+	text := strings.Join(strings.Fields(doc), " ")
+	if note != "" && text != "" {
+		text = note + " " + text
+	} else if note != "" {
+		text = note
+	}
+	return strings.ReplaceAll(text, "|", `\|`)
+}
@@ -0,0 +1,121 @@
+package easyprotogen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// ServiceInfo describes a service interface to generate HTTP stubs for.
+type ServiceInfo struct {
+	Name    string
+	Methods []MethodInfo
+}
+
+// MethodInfo describes a method of a service interface, declared as
+// Name(ctx context.Context, req *Request) (*Response, error).
+type MethodInfo struct {
+	Name     string
+	Request  string // Name of the request type, without *
+	Response string // Name of the response type, without *
+}
+
+// parseServices parses the given interface types declared in files.
+func parseServices(fset *token.FileSet, files []*ast.File, names []string) ([]*ServiceInfo, error) {
+	var services []*ServiceInfo
+	var errs []error
+	for _, name := range names {
+		obj := lookupType(files, name)
+		if obj == nil {
+			errs = append(errs, &Error{Type: name, Err: fmt.Errorf("service %s not found", name)})
+			continue
+		}
+		iface, ok := obj.Decl.(*ast.TypeSpec).Type.(*ast.InterfaceType)
+		if !ok {
+			errs = append(errs, &Error{Pos: fset.Position(obj.Pos()), Type: name, Err: fmt.Errorf("service %s is not an interface", name)})
+			continue
+		}
+		info, declErrs := checkService(name, iface)
+		for _, de := range declErrs {
+			errs = append(errs, &Error{Pos: fset.Position(de.pos), Type: name, Field: de.field, Err: de.err})
+		}
+		if info != nil {
+			services = append(services, info)
+		}
+	}
+	return services, errors.Join(errs...)
+}
+
+// checkService parses the methods of a service interface, returning an error for every
+// method with an unsupported signature.
+func checkService(name string, iface *ast.InterfaceType) (*ServiceInfo, []declError) {
+	info := &ServiceInfo{Name: name}
+	var errs []declError
+	for _, m := range iface.Methods.List {
+		if len(m.Names) == 0 {
+			errs = append(errs, declError{pos: m.Pos(), err: fmt.Errorf("service %s embeds %s; declare its methods instead", name, exprToString(m.Type))})
+			continue
+		}
+		method, err := parseMethod(m.Names[0].Name, m.Type.(*ast.FuncType))
+		if err != nil {
+			errs = append(errs, declError{pos: m.Pos(), field: m.Names[0].Name, err: fmt.Errorf("method %s of service %s: %w", m.Names[0].Name, name, err)})
+			continue
+		}
+		info.Methods = append(info.Methods, method)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(info.Methods) == 0 {
+		return nil, []declError{{pos: iface.Pos(), err: fmt.Errorf("service %s has no methods", name)}}
+	}
+	return info, nil
+}
+
+// parseMethod parses a method signature of the form (context.Context, *Request) (*Response, error).
+func parseMethod(name string, fn *ast.FuncType) (MethodInfo, error) {
+	const want = "expected (ctx context.Context, req *Request) (*Response, error)"
+	params := fieldTypes(fn.Params)
+	results := fieldTypes(fn.Results)
+	if len(params) != 2 || len(results) != 2 || exprToString(params[0]) != "context.Context" || exprToString(results[1]) != "error" {
+		return MethodInfo{}, fmt.Errorf("unsupported signature; %s", want)
+	}
+	req, ok := localPointer(params[1])
+	if !ok {
+		return MethodInfo{}, fmt.Errorf("request type %s is not a pointer to a type of the package; %s", exprToString(params[1]), want)
+	}
+	resp, ok := localPointer(results[0])
+	if !ok {
+		return MethodInfo{}, fmt.Errorf("response type %s is not a pointer to a type of the package; %s", exprToString(results[0]), want)
+	}
+	return MethodInfo{Name: name, Request: req, Response: resp}, nil
+}
+
+// fieldTypes returns the type of every parameter or result in fields, repeating the types of
+// grouped names like (a, b int).
+func fieldTypes(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, f := range fields.List {
+		for range max(len(f.Names), 1) {
+			types = append(types, f.Type)
+		}
+	}
+	return types
+}
+
+// localPointer returns the name of T for an expression *T where T is a type of the package.
+func localPointer(expr ast.Expr) (string, bool) {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return "", false
+	}
+	ident, ok := star.X.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	return ident.Name, true
+}
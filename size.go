@@ -0,0 +1,65 @@
+package easyprotogen
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// FieldSize is the encoded size of a field of a message, as returned by SizeBreakdown.
+type FieldSize struct {
+	Field string // Name of the Go field, or "" for fields the type doesn't declare
+	Num   int    // Field number
+	Count int    // Number of occurrences of the field, like the elements of repeated fields
+	Bytes int    // Encoded size of the occurrences, including their tags and lengths
+}
+
+// SizeBreakdown returns the encoded sizes of the fields of data, a protobuf message of the
+// struct type typeName of the package in dir, largest first. Fields of oneofs are reported by
+// field number, with the name of the oneof field.
+//
+// If data is malformed, the sizes of the fields before the error are returned with it.
+func SizeBreakdown(dir, typeName string, data []byte) ([]FieldSize, error) {
+	typeInfos, err := loadPayloadTypes(dir, typeName)
+	if err != nil {
+		return nil, err
+	}
+	in := &inspector{typeInfos: typeInfos}
+	fields := in.messageFields(typeName)
+
+	sizes := make(map[int]*FieldSize)
+	for pos := 0; pos < len(data); {
+		num, typ, n := protowire.ConsumeTag(data[pos:])
+		if n < 0 {
+			err = fmt.Errorf("malformed tag at offset %d: %w", pos, protowire.ParseError(n))
+			break
+		}
+		m := protowire.ConsumeFieldValue(num, typ, data[pos+n:])
+		if m < 0 {
+			err = fmt.Errorf("malformed field %d at offset %d: %w", num, pos, protowire.ParseError(m))
+			break
+		}
+		size := sizes[int(num)]
+		if size == nil {
+			size = &FieldSize{Num: int(num)}
+			if f := fields[int(num)]; f != nil {
+				size.Field = f.name
+			}
+			sizes[int(num)] = size
+		}
+		size.Count++
+		size.Bytes += n + m
+		pos += n + m
+	}
+
+	result := make([]FieldSize, 0, len(sizes))
+	for _, size := range sizes {
+		result = append(result, *size)
+	}
+	slices.SortFunc(result, func(a, b FieldSize) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.Num, b.Num))
+	})
+	return result, err
+}
@@ -0,0 +1,104 @@
+package easyprotogen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// standaloneNames are the counterparts declared with Standalone of the declarations of the
+// packages of easyproto-gen not named "pb" followed by their name. Functions of package wire
+// with a standard library equivalent are replaced by it.
+var standaloneNames = map[string]string{
+	"groups.Skip":        "pbSkipGroup",
+	"packed.Append":      "pbAppendFixed",
+	"wire.AppendVarint":  "binary.AppendUvarint",
+	"wire.AppendFixed32": "binary.LittleEndian.AppendUint32",
+	"wire.AppendFixed64": "binary.LittleEndian.AppendUint64",
+}
+
+// codecName returns the name of a declaration used by the generated code, either of package
+// easyproto, like MessageMarshaler, or of a package of easyproto-gen, like easyprotoerr.Field,
+// qualified with its package, or the name of its counterpart declared by the header of the
+// generated file with Standalone.
+func codecName(opts Options, name string) string {
+	_, decl, qualified := strings.Cut(name, ".")
+	switch {
+	case !opts.Standalone && qualified:
+		return name
+	case !opts.Standalone:
+		return "easyproto." + name
+	case !qualified:
+		return "pb" + name
+	}
+	if n, ok := standaloneNames[name]; ok {
+		return n
+	}
+	return "pb" + decl
+}
+
+// qualifiedName matches the declarations of the packages of easyproto-gen in expressions.
+var qualifiedName = regexp.MustCompile(`\b(?:easyprotoerr|groups|packed|wire)\.[A-Z]\w*`)
+
+// codecExpr returns expr with the declarations of the packages of easyproto-gen replaced by
+// their counterparts declared with Standalone.
+func codecExpr(opts Options, expr string) string {
+	if !opts.Standalone {
+		return expr
+	}
+	return qualifiedName.ReplaceAllStringFunc(expr, func(name string) string {
+		return codecName(opts, name)
+	})
+}
+
+// checkStandalone returns an error if opts, services or the fields of the types generated with
+// Standalone need a package other than those of the standard library: the packages of
+// easyproto-gen imported with other options, converting values and interning strings, and
+// easyproto, with which custom types implement MarshalProtobufTo.
+func checkStandalone(opts Options, services bool, typeNames []string, typeInfos map[string]*TypeInfo, generated map[string]bool) error {
+	unsupported := []struct {
+		option string
+		set    bool
+	}{
+		{"runtime", opts.Runtime != ""},
+		{"register", opts.Register},
+		{"proto-adapter", opts.ProtoAdapter},
+		{"descriptor-set", opts.DescriptorSet},
+		{"arena", opts.Arena},
+		{"observe", opts.Observe},
+		{"service", services},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("-standalone can't be combined with -%s, whose generated code imports a package of easyproto-gen", u.option)
+		}
+	}
+	for _, typeName := range typeNames {
+		for _, f := range typeInfos[typeName].Fields {
+			if reason := standaloneUnsupported(f, generated); reason != "" {
+				return fmt.Errorf("field %q in type %s is not supported with -standalone: %s", f.Name, typeName, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// standaloneUnsupported returns why f needs a package other than those of the standard library,
+// or "" if it doesn't.
+func standaloneUnsupported(f *FieldInfo, generated map[string]bool) string {
+	switch {
+	case convertsValue(f):
+		return "package protoconv converts the value with easyproto"
+	case f.IsCustom && !f.IsBytesMarshaler && !f.IsTypeParam, f.MapValueCustom:
+		return "custom types implement MarshalProtobufTo with an easyproto.MessageMarshaler"
+	case f.IsIntern:
+		return "interned strings are decoded with package intern"
+	case f.IsOneof:
+		for _, v := range f.OneofVariants {
+			if v.ProtoType == "" && !generated[v.TypeName] {
+				return fmt.Sprintf("message %s is not generated with it", v.TypeName)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package easyprotogen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// tagEdit adds a protobuf tag with field number num to a field.
+type tagEdit struct {
+	field   *ast.Field
+	num     int
+	options string // Options following the field number, like ",enum"
+}
+
+// Tag adds protobuf tags to the exported fields of the named struct types in the package in dir
+// that have none, numbering them in declaration order after the highest field number of their
+// type, including oneof variants and numbers reserved with //protogen:reserved. Existing tags
+// of other keys, like json, are kept.
+//
+// It returns the rewritten source files without writing them; files without changes are
+// omitted. Fields whose protobuf type can't be inferred, like interfaces, are reported as
+// *Error values, joined with errors.Join if there are several, and must be tagged by hand.
+func Tag(dir string, types []string) ([]File, error) {
+	fset := token.NewFileSet()
+	_, files, err := parsePackage(fset, dir, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// The declarations of named types tell messages from enums and other types.
+	decls := make(map[string]ast.Expr)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					decls[typeSpec.Name.Name] = typeSpec.Type
+				}
+			}
+		}
+	}
+
+	found := make(map[string]bool)
+	edits := make(map[*ast.File][]tagEdit)
+	var errs []error
+	for _, file := range files {
+		err := forEachStruct([]*ast.File{file}, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+			if !slices.Contains(types, typeName) {
+				return nil
+			}
+			found[typeName] = true
+			fileEdits, typeErrs := tagStruct(fset, typeName, structType, doc, decls)
+			edits[file] = append(edits[file], fileEdits...)
+			errs = append(errs, typeErrs...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, typeName := range types {
+		if !found[typeName] {
+			errs = append(errs, &Error{Type: typeName, Err: fmt.Errorf("type %s not found", typeName)})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var tagged []File
+	for _, file := range files {
+		if len(edits[file]) == 0 {
+			continue
+		}
+		path := fset.File(file.Pos()).Name()
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		code, err := format.Source(applyTagEdits(fset, src, edits[file]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to format %s: %w", path, err)
+		}
+		tagged = append(tagged, File{Path: path, Content: code})
+	}
+	return tagged, nil
+}
+
+// tagStruct returns the edits tagging the untagged exported fields of a struct type, given the
+// declarations of the named types of its package.
+func tagStruct(fset *token.FileSet, typeName string, structType *ast.StructType, doc *ast.CommentGroup, decls map[string]ast.Expr) ([]tagEdit, []error) {
+	info, err := parseStruct(typeName, structType)
+	if err != nil {
+		return nil, []error{&Error{Pos: fset.Position(structType.Pos()), Type: typeName, Err: err}}
+	}
+	reserved, reservedNames, err := typeReserved(typeName, doc)
+	if err != nil {
+		return nil, []error{&Error{Pos: fset.Position(doc.Pos()), Type: typeName, Err: err}}
+	}
+	next := 1
+	for _, num := range info.FieldNums() {
+		next = max(next, num+1)
+	}
+	for _, r := range reserved {
+		next = max(next, r.hi+1)
+	}
+
+	var edits []tagEdit
+	var errs []error
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 || !field.Names[0].IsExported() || hasProtobufTags(&ast.StructType{Fields: &ast.FieldList{List: []*ast.Field{field}}}) {
+			continue
+		}
+		name := field.Names[0].Name
+		fieldErr := func(err error) {
+			errs = append(errs, &Error{Pos: fset.Position(field.Pos()), Type: typeName, Field: name, Err: err})
+		}
+		switch {
+		case len(field.Names) > 1:
+			fieldErr(fmt.Errorf("fields of type %s are declared together; declare them separately to tag them", typeName))
+			continue
+		case field.Tag != nil && !strings.HasPrefix(field.Tag.Value, "`"):
+			fieldErr(fmt.Errorf("tag of field %q in type %s is not a raw string; tag it by hand", name, typeName))
+			continue
+		case reservedNames[name]:
+			fieldErr(fmt.Errorf("field name %q in type %s is reserved", name, typeName))
+			continue
+		}
+		if next >= 19000 && next <= 19999 {
+			next = 20000
+		}
+		options, err := tagOptions(field.Type, decls)
+		if err == nil {
+			// Check that the generator accepts the tag.
+			single := *field
+			single.Tag = &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("`protobuf:\"%d%s\"`", next, options)}
+			_, err = parseStruct(typeName, &ast.StructType{Fields: &ast.FieldList{List: []*ast.Field{&single}}})
+		}
+		if err != nil {
+			fieldErr(fmt.Errorf("cannot infer the protobuf type of field %q in type %s; tag it by hand: %w", name, typeName, err))
+			continue
+		}
+		edits = append(edits, tagEdit{field: field, num: next, options: options})
+		next++
+	}
+	return edits, errs
+}
+
+// applyTagEdits returns src with the tags of edits added.
+func applyTagEdits(fset *token.FileSet, src []byte, edits []tagEdit) []byte {
+	// Apply the edits from the end, so that the offsets of the others stay valid.
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].field.Pos() > edits[j].field.Pos()
+	})
+	out := slices.Clone(src)
+	for _, e := range edits {
+		tag := fmt.Sprintf("protobuf:\"%d%s\"", e.num, e.options)
+		var offset int
+		switch {
+		case e.field.Tag == nil:
+			offset = fset.Position(e.field.Type.End()).Offset
+			tag = " `" + tag + "`"
+		case e.field.Tag.Value == "``":
+			offset = fset.Position(e.field.Tag.End()).Offset - 1
+		default:
+			offset = fset.Position(e.field.Tag.End()).Offset - 1
+			tag = " " + tag
+		}
+		out = slices.Insert(out, offset, []byte(tag)...)
+	}
+	return out
+}
+
+// tagOptions returns the options of the tag of a field of type expr, like ",enum" for named
+// int32 types or ",bigint" for big.Int, given the declarations of the named types of the
+// package. Types declared in other packages other than uuid.UUID, big.Int, big.Rat,
+// decimal.Decimal and the sql.Null types, and named non-struct types other than enums are
+// errors, since they aren't known to be messages.
+func tagOptions(expr ast.Expr, decls map[string]ast.Expr) (string, error) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return tagOptions(t.X, decls)
+	case *ast.ArrayType:
+		return tagOptions(t.Elt, decls)
+	case *ast.MapType:
+		for _, e := range []ast.Expr{t.Key, t.Value} {
+			if options, err := tagOptions(e, decls); err != nil || options != "" {
+				return "", fmt.Errorf("map %s has keys or values that aren't messages or scalars", exprToString(t))
+			}
+		}
+	case *ast.SelectorExpr:
+		if _, _, isSQLNull := sqlNullValue(t); isSQLNull || isUUIDType(t) || isDecimalType(t) {
+			return "", nil
+		}
+		if bigType := bigNumberType(t); bigType != "" {
+			return ",big" + strings.ToLower(bigType), nil
+		}
+		return "", fmt.Errorf("type %s is declared in another package", exprToString(t))
+	case *ast.Ident:
+		switch decl := decls[t.Name].(type) {
+		case nil, *ast.StructType:
+		case *ast.Ident:
+			if decl.Name != "int32" {
+				return "", fmt.Errorf("type %s is a %s", t.Name, decl.Name)
+			}
+			return ",enum", nil
+		default:
+			return "", fmt.Errorf("type %s is not a struct", t.Name)
+		}
+	}
+	return "", nil
+}
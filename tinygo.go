@@ -0,0 +1,28 @@
+package easyprotogen
+
+import "fmt"
+
+// checkTinyGoOptions returns an error if opts generate code relying on what TinyGo builds
+// lack or handle poorly: sync.Pool, unsafe and reflection, directly or through the packages
+// the generated code imports.
+func checkTinyGoOptions(opts Options) error {
+	unsupported := []struct {
+		option string
+		reason string
+		set    bool
+	}{
+		{"pool", "it pools the types with sync.Pool", opts.Pool},
+		{"arena", "package arena uses unsafe and reflection", opts.Arena},
+		{"quick", "Generate methods return reflect.Value", opts.Quick},
+		{"protoc-types", "protoc-gen-go types are implemented with reflection", opts.ProtocTypes},
+		{"proto-adapter", "adapters implement protoreflect", opts.ProtoAdapter},
+		{"descriptor-set", "descriptors are registered with protoregistry", opts.DescriptorSet},
+		{"register", "package easyprotoreg uses reflection", opts.Register},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("-tinygo can't be combined with -%s: %s", u.option, u.reason)
+		}
+	}
+	return nil
+}
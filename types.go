@@ -0,0 +1,158 @@
+package easyprotogen
+
+import "strings"
+
+// TypeInfo contains parsed information about a struct type.
+type TypeInfo struct {
+	Name   string
+	Fields []*FieldInfo
+	Hot    []*FieldInfo // Fields checked before the switch of unmarshal methods, hottest first
+
+	// Excluded are the fields tagged protobuf:"-", which are neither serialized nor cloned, and
+	// Untagged the other fields without protobuf tag, in declaration order.
+	Excluded []string
+	Untagged []string
+
+	// ValueReceiver is set by the value-receiver option of a //protogen:options directive,
+	// like the type being in Options.ValueReceivers.
+	ValueReceiver bool
+
+	// TypeParams are the names of the type parameters of a generic type, whose methods are
+	// generated for all instantiations.
+	TypeParams []string
+
+	// Doc is the doc comment of the type without its directives.
+	Doc string
+
+	// reserved are the field numbers reserved by //protogen:reserved directives.
+	reserved []reservedRange
+}
+
+// TypeArgs returns the type parameters of t as the type arguments of its methods' receivers,
+// like "[K, V]", or "" if t isn't generic.
+func (t *TypeInfo) TypeArgs() string {
+	if len(t.TypeParams) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(t.TypeParams, ", ") + "]"
+}
+
+// FieldNums returns the wire field numbers used by t, including oneof variants.
+func (t *TypeInfo) FieldNums() []int {
+	var nums []int
+	for _, f := range t.Fields {
+		if f.IsOneof {
+			for _, v := range f.OneofVariants {
+				nums = append(nums, v.FieldNum)
+			}
+			continue
+		}
+		nums = append(nums, f.FieldNum)
+	}
+	return nums
+}
+
+// ParallelFields returns the fields of t decoded concurrently by UnmarshalProtobufParallel.
+func (t *TypeInfo) ParallelFields() []*FieldInfo {
+	var fields []*FieldInfo
+	for _, f := range t.Fields {
+		if f.IsParallel {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// ScalarOnly returns true if t only has singular numeric, bool and enum fields with numbers
+// up to 15, whose tags are single bytes. Their unmarshal methods decode without
+// easyproto.FieldContext.
+func (t *TypeInfo) ScalarOnly() bool {
+	for _, f := range t.Fields {
+		if f.IsRepeated || f.IsPointer || f.IsMap || f.IsOneof || f.IsMessage || f.IsCustom || f.IsSQLNull || f.FieldNum > 15 {
+			return false
+		}
+		if f.ProtoType == "string" || f.ProtoType == "bytes" {
+			return false
+		}
+	}
+	return len(t.Fields) > 0
+}
+
+// FieldInfo contains parsed information about a struct field.
+type FieldInfo struct {
+	Name             string
+	GoType           string
+	FieldNum         int
+	ProtoType        string
+	IsRepeated       bool
+	IsMessage        bool
+	IsPointer        bool   // Field is a pointer type (*Type)
+	IsSliceOfPtr     bool   // Field is a slice of pointers ([]*Type)
+	IsOptional       bool   // Field is optional (can be nil/unset)
+	IsEnum           bool   // Field is an enum type
+	IsMap            bool   // Field is a map type
+	IsCustom         bool   // Field uses custom marshaler interface (external types)
+	IsBytesMarshaler bool   // Custom type has MarshalProtobuf(dst []byte) []byte instead of MarshalProtobufTo
+	IsTypeParam      bool   // Element type is a type parameter, a custom type asserted at run time
+	IsExtract        bool   // Generate a package-level Extract<Type><Field> function
+	IsFunc           bool   // Generate an UnmarshalProtobuf<Field>Func method calling a function per element
+	IsIter           bool   // Generate a package-level <Type><Field>Iter function returning an iterator
+	IsParallel       bool   // Decode the elements concurrently in UnmarshalProtobufParallel
+	IsIntern         bool   // Deduplicate decoded strings with an intern.Table
+	IsStringBytes    bool   // []byte field with the string type in the schema; ProtoType is bytes
+	IsHot            bool   // Field is in TypeInfo.Hot
+	Prealloc         int    // Initial map or slice capacity set by the prealloc=N option, or 0
+	PreallocCount    bool   // Count the entries before allocating the map, set by the prealloc option
+	IsRedact         bool   // Field is omitted by MarshalProtobufRedacted
+	IsDeprecated     bool   // Generated accessors of the field are marked deprecated
+	IsTruncate       bool   // Decoded integers out of the range of the Go type are truncated instead of rejected
+	IsIndirect       bool   // Pointer to a slice or a map like *[]T, nil if the field is absent; GoType and the other fields describe the slice or map
+	IsInferred       bool   // The protobuf type, or the key and value types of a map, is inferred from the Go type
+	IsUnpacked       bool   // Repeated scalars are encoded as separate values instead of packed
+	IsNonNil         bool   // Absent repeated and map fields are decoded as empty non-nil values
+	IsMerge          bool   // UnmarshalProtobuf adds the decoded entries of the map to its existing ones instead of clearing it
+	IsReuse          bool   // Decoded bytes are copied into the existing backing arrays of the field instead of aliasing the input
+	IsNilError       bool   // Marshal methods panic for a nil message pointer or map, or nil elements of []*T, instead of skipping them
+	IsNilEmpty       bool   // A nil message pointer, or nil elements of []*T, are encoded as empty messages instead of being skipped
+	IsEmitEmpty      bool   // Empty non-nil slices are encoded as empty packed fields, decoded as empty non-nil slices
+	IsAlways         bool   // Singular scalar field is encoded even when it holds the zero value
+	IsWrapper        bool   // Pointer to a scalar encoded as a google.protobuf wrapper message, like Int64Value
+	IsUUID           bool   // uuid.UUID or [16]byte, encoded as bytes, or as a string in the canonical form if ProtoType is string
+	IsBigInt         bool   // big.Int or *big.Int, encoded as bytes of its sign and magnitude
+	IsBigRat         bool   // big.Rat or *big.Rat, encoded as a message of its numerator and denominator
+	IsDecimal        bool   // decimal.Decimal of github.com/shopspring/decimal, encoded as a string, or as a message of its unscaled value and exponent if ProtoType is bytes
+	IsSQLNull        bool   // Nullable value of package database/sql like sql.NullInt64, encoded as its value if Valid
+	NullValue        string // For sql.Null types, the name of the value field, like Int64
+	NullValueType    string // For sql.Null types, the Go type of the value, like int64
+	ElemType         string // For slices, the element type (without [] or *)
+	RawElemType      string // For slices, the raw element type (with * if applicable)
+	BaseType         string // The base type without * or []
+	NeedsTypeConv    bool   // Needs type conversion (e.g., enum)
+	ConvType         string // Type to convert to/from (e.g., int32 for enum)
+
+	// Map-specific fields
+	MapKeyType     string // Go type of map key (e.g., "string", "int32")
+	MapValueType   string // Go type of map value (e.g., "int32", "*Sample")
+	MapKeyProto    string // Proto type of map key (e.g., "string", "int32")
+	MapValueProto  string // Proto type of map value (e.g., "int32", "message")
+	MapValueIsMsg  bool   // Map value is a message type
+	MapValueIsPtr  bool   // Map value is a pointer to message
+	MapValueCustom bool   // Map value uses custom marshaler interface
+
+	// Oneof-specific fields (for interface fields with multiple concrete types)
+	IsOneof       bool           // Field is a oneof (interface with known implementations)
+	OneofVariants []OneofVariant // List of concrete types and their field numbers
+
+	Doc string // Doc comment of the field, or its line comment if it has none
+}
+
+// OneofVariant represents a concrete type that can be stored in a oneof field
+type OneofVariant struct {
+	TypeName string // The concrete type name (e.g., "TextMessage")
+	FieldNum int    // The protobuf field number for this variant
+
+	// Wrappers of scalars, encoded as the scalar instead of a message
+	ProtoType  string // Proto type of the scalar (e.g., "string"), or "" for messages
+	ValueField string // Name of the single field of the wrapper struct holding the scalar
+	ValueType  string // Go type of the value field
+}
@@ -0,0 +1,218 @@
+package easyprotogen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"slices"
+	"strings"
+)
+
+// validProtoTypes is the set of valid protobuf types
+var validProtoTypes = map[string]bool{
+	"string":   true,
+	"bytes":    true,
+	"int32":    true,
+	"int64":    true,
+	"uint32":   true,
+	"uint64":   true,
+	"sint32":   true,
+	"sint64":   true,
+	"bool":     true,
+	"double":   true,
+	"float":    true,
+	"fixed32":  true,
+	"fixed64":  true,
+	"sfixed32": true,
+	"sfixed64": true,
+	"message":  true,
+	"enum":     true,
+	"map":      true,
+	"oneof":    true,
+}
+
+// validMapKeyTypes is the set of valid protobuf map key types
+var validMapKeyTypes = map[string]bool{
+	"string":   true,
+	"int32":    true,
+	"int64":    true,
+	"uint32":   true,
+	"uint64":   true,
+	"sint32":   true,
+	"sint64":   true,
+	"fixed32":  true,
+	"fixed64":  true,
+	"sfixed32": true,
+	"sfixed64": true,
+	"bool":     true,
+	// Note: float/double and bytes are NOT valid map key types in protobuf
+}
+
+// validOptions is the set of valid field options in protobuf tags
+var validOptions = map[string]bool{
+	"repeated":    true,
+	"optional":    true,
+	"enum":        true,
+	"custom":      true,
+	"extract":     true,
+	"func":        true,
+	"iter":        true,
+	"parallel":    true,
+	"intern":      true,
+	"stringbytes": true,
+	"prealloc":    true,
+	"redact":      true,
+	"deprecated":  true,
+	"truncate":    true,
+	"unpacked":    true,
+	"nonnil":      true,
+	"emitempty":   true,
+	"always":      true,
+	"wrapper":     true,
+	"uuidstring":  true,
+	"bigint":      true,
+	"bigrat":      true,
+	"unscaled":    true,
+	"nilerror":    true,
+	"nilempty":    true,
+	"merge":       true,
+	"reuse":       true,
+}
+
+// wrapperTypes maps the protobuf types of fields with the wrapper option to the well-known
+// wrapper messages they are encoded as.
+var wrapperTypes = map[string]string{
+	"double": "google.protobuf.DoubleValue",
+	"float":  "google.protobuf.FloatValue",
+	"int64":  "google.protobuf.Int64Value",
+	"uint64": "google.protobuf.UInt64Value",
+	"int32":  "google.protobuf.Int32Value",
+	"uint32": "google.protobuf.UInt32Value",
+	"bool":   "google.protobuf.BoolValue",
+	"string": "google.protobuf.StringValue",
+	"bytes":  "google.protobuf.BytesValue",
+}
+
+// narrowInts maps the Go integer types narrower than 32 bits to the protobuf types they are
+// encoded as.
+var narrowInts = map[string]string{
+	"int8":   "int32",
+	"int16":  "int32",
+	"uint8":  "uint32",
+	"byte":   "uint32",
+	"uint16": "uint32",
+}
+
+// platformInts maps the Go integer types whose size depends on the platform to the protobuf
+// types they are encoded as, by default and with Options.Int32.
+var platformInts = map[string][2]string{
+	"int":  {"int64", "int32"},
+	"uint": {"uint64", "uint32"},
+}
+
+// isValidProtoType checks if a protobuf type is valid
+func isValidProtoType(protoType string) bool {
+	return validProtoTypes[protoType]
+}
+
+// isValidMapKeyType checks if a protobuf type is valid as a map key
+func isValidMapKeyType(protoType string) bool {
+	return validMapKeyTypes[protoType]
+}
+
+// isValidOption checks if a tag option is valid
+func isValidOption(option string) bool {
+	return validOptions[option] || strings.HasPrefix(option, "prealloc=")
+}
+
+// checkNarrowInts checks the protobuf types of the integers narrower than 32 bits of a field,
+// its elements or its map keys and values, and marks the field as converted from them. Fields
+// of int and uint values encoded as varints are marked as converted too, since they are
+// narrower than their protobuf type on 32-bit platforms.
+func checkNarrowInts(fi *FieldInfo) error {
+	narrow, ranged := false, false
+	check := func(goType, protoType string) error {
+		if wire, ok := platformInts[goType]; ok {
+			narrow = narrow || slices.Contains(wire[:], protoType)
+			ranged = true
+			return nil
+		}
+		wire, ok := narrowInts[goType]
+		if !ok {
+			return nil
+		}
+		if protoType != wire {
+			return fmt.Errorf("%s values must be encoded as %s, not %s", goType, wire, protoType)
+		}
+		narrow, ranged = true, true
+		return nil
+	}
+	switch {
+	case fi.IsEnum || fi.IsMessage || fi.IsOneof:
+	case fi.IsSQLNull:
+		if err := check(fi.NullValueType, fi.ProtoType); err != nil {
+			return err
+		}
+	case fi.IsMap:
+		if err := check(fi.MapKeyType, fi.MapKeyProto); err != nil {
+			return err
+		}
+		if err := check(fi.MapValueType, fi.MapValueProto); err != nil {
+			return err
+		}
+	default:
+		if err := check(fi.BaseType, fi.ProtoType); err != nil {
+			return err
+		}
+		if narrow {
+			fi.NeedsTypeConv = true
+			fi.ConvType = fi.ProtoType
+		}
+	}
+	if fi.IsTruncate && !ranged {
+		return errors.New("truncate option is only supported on int8, int16, uint8, uint16, int and uint values")
+	}
+	return nil
+}
+
+// validateOneofFieldType checks if a field type is valid for oneof usage.
+// Oneof fields must be interface types (named or inline), not primitives, slices, or maps.
+func validateOneofFieldType(expr ast.Expr) error {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		// Check for primitive types that can't be oneof
+		switch t.Name {
+		case "string", "bool", "byte", "rune",
+			"int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64",
+			"float32", "float64", "complex64", "complex128",
+			"uintptr":
+			return fmt.Errorf("oneof tag cannot be used on primitive type %q", t.Name)
+		case "any":
+			// 'any' is valid for oneof
+			return nil
+		default:
+			// Named type (could be interface or struct) - we allow it
+			// At runtime, if it's not an interface, the type switch will fail
+			return nil
+		}
+	case *ast.InterfaceType:
+		// Inline interface{} or interface{...} - valid for oneof
+		return nil
+	case *ast.ArrayType:
+		return fmt.Errorf("oneof tag cannot be used on slice/array type %s", exprToString(expr))
+	case *ast.MapType:
+		return fmt.Errorf("oneof tag cannot be used on map type %s", exprToString(expr))
+	case *ast.StarExpr:
+		return fmt.Errorf("oneof tag cannot be used on pointer type %s (the variants are stored as pointers)", exprToString(expr))
+	case *ast.ChanType:
+		return fmt.Errorf("oneof tag cannot be used on channel type %s", exprToString(expr))
+	case *ast.FuncType:
+		return fmt.Errorf("oneof tag cannot be used on function type")
+	case *ast.SelectorExpr:
+		// Qualified type like pkg.Type - could be interface, allow it
+		return nil
+	default:
+		return fmt.Errorf("oneof tag cannot be used on type %s", exprToString(expr))
+	}
+}
@@ -0,0 +1,162 @@
+package easyprotogen
+
+import (
+	"go/ast"
+	"go/token"
+	"maps"
+	"slices"
+	"strconv"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// generatedHeader is the first line of files written by protogen.
+const generatedHeader = "// Code generated by protogen. DO NOT EDIT."
+
+// Analyzer checks protobuf struct tags and the code generated from them.
+// The protogen command runs it when invoked by go vet -vettool.
+var Analyzer = &analysis.Analyzer{
+	Name: "protogenvet",
+	Doc: `check protobuf struct tags and protogen-generated code
+
+The protogenvet analyzer reports struct tags that protogen would reject: malformed
+tags, duplicate or reserved field numbers, invalid map key types and interface
+fields without a oneof tag. It also reports types whose generated unmarshaler
+no longer matches their field numbers, i.e. go generate needs to be rerun.`,
+	Run: runVet,
+}
+
+func runVet(pass *analysis.Pass) (any, error) {
+	generated := generatedFields(pass.Files)
+	// Generated files are skipped: protoc-gen-go uses the same tag key with another format.
+	var files []*ast.File
+	for _, file := range pass.Files {
+		if !ast.IsGenerated(file) {
+			files = append(files, file)
+		}
+	}
+	forEachStruct(files, func(typeName string, structType *ast.StructType, doc *ast.CommentGroup) error {
+		info := vetStruct(pass, typeName, structType, doc)
+		if fields, ok := generated[typeName]; ok && info != nil {
+			vetGenerated(pass, info, structType, fields)
+		}
+		return nil
+	})
+	return nil, nil
+}
+
+// vetStruct reports the declaration errors of the struct type and returns its parsed form,
+// or nil if it has errors.
+func vetStruct(pass *analysis.Pass, typeName string, structType *ast.StructType, doc *ast.CommentGroup) *TypeInfo {
+	info, errs := checkStruct(typeName, structType, doc)
+	for _, de := range errs {
+		pass.Reportf(de.pos, "%v", de.err)
+	}
+	return info
+}
+
+// generatedFields returns the field names by field number handled by the generated
+// MergeFromProtobuf method of each type in files written by protogen.
+func generatedFields(files []*ast.File) map[string]map[int]string {
+	generated := make(map[string]map[int]string)
+	for _, file := range files {
+		if len(file.Comments) == 0 || file.Comments[0].List[0].Text != generatedHeader {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Name.Name != "MergeFromProtobuf" || fn.Recv == nil || fn.Body == nil {
+				continue
+			}
+			star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+			if !ok {
+				continue
+			}
+			recv, ok := star.X.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			generated[recv.Name] = switchedFields(fn.Body)
+		}
+	}
+	return generated
+}
+
+// switchedFields returns the field names by field number of the cases of the
+// switch on fc.FieldNum in body. The field names are taken from the error paths.
+func switchedFields(body *ast.BlockStmt) map[int]string {
+	fields := make(map[int]string)
+	ast.Inspect(body, func(n ast.Node) bool {
+		sw, ok := n.(*ast.SwitchStmt)
+		if !ok {
+			return true
+		}
+		if sel, ok := sw.Tag.(*ast.SelectorExpr); !ok || exprToString(sel) != "fc.FieldNum" {
+			return true
+		}
+		for _, stmt := range sw.Body.List {
+			cc := stmt.(*ast.CaseClause)
+			name := errorPathField(cc)
+			for _, e := range cc.List {
+				lit, ok := e.(*ast.BasicLit)
+				if !ok || lit.Kind != token.INT {
+					continue
+				}
+				if num, err := strconv.Atoi(lit.Value); err == nil {
+					fields[num] = name
+				}
+			}
+		}
+		return false
+	})
+	return fields
+}
+
+// errorPathField returns the field name passed to the first easyprotoerr.Field call in cc.
+func errorPathField(cc *ast.CaseClause) string {
+	var name string
+	ast.Inspect(cc, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || name != "" {
+			return name == ""
+		}
+		if exprToString(call.Fun) != "easyprotoerr.Field" || len(call.Args) < 2 {
+			return true
+		}
+		if lit, ok := call.Args[1].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			name, _ = strconv.Unquote(lit.Value)
+		}
+		return false
+	})
+	return name
+}
+
+// vetGenerated reports differences between the fields of info and the fields handled by
+// its generated code.
+func vetGenerated(pass *analysis.Pass, info *TypeInfo, structType *ast.StructType, generated map[int]string) {
+	current := make(map[int]string)
+	for _, f := range info.Fields {
+		if !f.IsOneof {
+			current[f.FieldNum] = f.Name
+			continue
+		}
+		for _, v := range f.OneofVariants {
+			current[v.FieldNum] = f.Name
+		}
+	}
+	for _, num := range slices.Sorted(maps.Keys(current)) {
+		name := current[num]
+		genName, ok := generated[num]
+		switch {
+		case !ok:
+			pass.Reportf(structType.Pos(), "generated code for %s is out of date: field %s (%d) is not handled; rerun go generate", info.Name, name, num)
+		case genName != "" && genName != name:
+			pass.Reportf(structType.Pos(), "generated code for %s is out of date: field number %d belongs to %s, not %s; rerun go generate", info.Name, num, name, genName)
+		}
+	}
+	for _, num := range slices.Sorted(maps.Keys(generated)) {
+		if _, ok := current[num]; !ok {
+			pass.Reportf(structType.Pos(), "generated code for %s is out of date: field %s (%d) no longer exists; rerun go generate", info.Name, generated[num], num)
+		}
+	}
+}
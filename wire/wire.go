@@ -0,0 +1,109 @@
+// Package wire appends protobuf values to byte slices, for the SizeProtobuf and AppendProtobuf
+// methods generated with -direct.
+//
+// easyproto.Marshaler records every field of a message before writing it, so that the
+// lengths of nested messages are known when their prefixes are written. The methods
+// generated with -direct compute the lengths in a first pass instead, appending the length
+// of every nested message to a slice in the order the second pass writes them, and then
+// append the fields directly to the output:
+//
+//	var buf [64]int
+//	n, sizes := x.sizeProtobuf(buf[:0])
+//	dst, _ = x.appendProtobuf(slices.Grow(dst, n), sizes)
+//
+// Tags are written by the generated code as constant bytes; the functions of this package
+// append the values after them.
+package wire
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// SizeVarint returns the number of bytes of v encoded as a varint.
+func SizeVarint(v uint64) int {
+	return (bits.Len64(v|1) + 6) / 7
+}
+
+// AppendVarint appends v encoded as a varint to dst and returns the result.
+func AppendVarint(dst []byte, v uint64) []byte {
+	for v >= 0x80 {
+		dst = append(dst, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(dst, byte(v))
+}
+
+// ZigZag32 returns the sint32 encoding of v, which is then appended as a varint.
+func ZigZag32(v int32) uint64 {
+	return uint64(uint32(v<<1) ^ uint32(v>>31))
+}
+
+// ZigZag64 returns the sint64 encoding of v, which is then appended as a varint.
+func ZigZag64(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// AppendBool appends v encoded as a varint to dst and returns the result.
+func AppendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// AppendFixed32 appends v in little-endian byte order to dst and returns the result.
+func AppendFixed32(dst []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(dst, v)
+}
+
+// AppendFixed64 appends v in little-endian byte order to dst and returns the result.
+func AppendFixed64(dst []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(dst, v)
+}
+
+// AppendFloat appends the bits of v in little-endian byte order to dst and returns the result.
+func AppendFloat(dst []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(dst, math.Float32bits(v))
+}
+
+// AppendDouble appends the bits of v in little-endian byte order to dst and returns the result.
+func AppendDouble(dst []byte, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(v))
+}
+
+// AppendString appends the length of s as a varint and s to dst and returns the result.
+func AppendString(dst []byte, s string) []byte {
+	dst = AppendVarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// AppendBytes appends the length of b as a varint and b to dst and returns the result.
+func AppendBytes(dst []byte, b []byte) []byte {
+	dst = AppendVarint(dst, uint64(len(b)))
+	return append(dst, b...)
+}
+
+// SizeBytes returns the number of bytes of a length-delimited value of n bytes, with its
+// length prefix.
+func SizeBytes(n int) int {
+	return SizeVarint(uint64(n)) + n
+}
+
+// Reserve appends a placeholder for the length of a nested message to sizes and returns the
+// result and the index of the placeholder, or nil and -1 if sizes is nil, when the lengths are
+// not recorded.
+func Reserve(sizes []int) ([]int, int) {
+	if sizes == nil {
+		return nil, -1
+	}
+	return append(sizes, 0), len(sizes)
+}
+
+// Set sets the length reserved at index i of sizes to n, unless i is -1.
+func Set(sizes []int, i, n int) {
+	if i >= 0 {
+		sizes[i] = n
+	}
+}
@@ -0,0 +1,72 @@
+package wire
+
+import (
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestAppendVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1<<32 - 1, math.MaxUint64} {
+		got := AppendVarint([]byte{9}, v)
+		if want := protowire.AppendVarint([]byte{9}, v); string(got) != string(want) {
+			t.Errorf("%d: got %x, want %x", v, got, want)
+		}
+		if n := SizeVarint(v); n != protowire.SizeVarint(v) {
+			t.Errorf("%d: size %d, want %d", v, n, protowire.SizeVarint(v))
+		}
+	}
+}
+
+func TestZigZag(t *testing.T) {
+	for _, v := range []int32{0, -1, 1, math.MinInt32, math.MaxInt32} {
+		if got, want := ZigZag32(v), protowire.EncodeZigZag(int64(v)); got != want {
+			t.Errorf("ZigZag32(%d) = %d, want %d", v, got, want)
+		}
+	}
+	for _, v := range []int64{0, -1, 1, math.MinInt64, math.MaxInt64} {
+		if got, want := ZigZag64(v), protowire.EncodeZigZag(v); got != want {
+			t.Errorf("ZigZag64(%d) = %d, want %d", v, got, want)
+		}
+	}
+}
+
+func TestAppendValues(t *testing.T) {
+	got := AppendBool(nil, true)
+	got = AppendFixed32(got, 0x01020304)
+	got = AppendFixed64(got, 0x0102030405060708)
+	got = AppendFloat(got, 1.5)
+	got = AppendDouble(got, -2)
+	got = AppendString(got, "abc")
+	got = AppendBytes(got, make([]byte, 200))
+
+	want := protowire.AppendVarint(nil, 1)
+	want = protowire.AppendFixed32(want, 0x01020304)
+	want = protowire.AppendFixed64(want, 0x0102030405060708)
+	want = protowire.AppendFixed32(want, math.Float32bits(1.5))
+	want = protowire.AppendFixed64(want, math.Float64bits(-2))
+	want = protowire.AppendString(want, "abc")
+	want = protowire.AppendBytes(want, make([]byte, 200))
+	if string(got) != string(want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+	if n := SizeBytes(200); n != 202 {
+		t.Fatalf("SizeBytes(200) = %d, want 202", n)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	if sizes, i := Reserve(nil); sizes != nil || i != -1 {
+		t.Fatalf("Reserve(nil) = %v, %d; want nil, -1", sizes, i)
+	}
+	Set(nil, -1, 5)
+
+	sizes, i := Reserve([]int{})
+	sizes, j := Reserve(sizes)
+	Set(sizes, j, 2)
+	Set(sizes, i, 7)
+	if len(sizes) != 2 || sizes[0] != 7 || sizes[1] != 2 {
+		t.Fatalf("got %v, want [7 2]", sizes)
+	}
+}